@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultTurns is the turn count for file bouts that don't set one; it
+// matches the standard tier used by the built-in hypotheses.
+const defaultTurns = 12
+
+// HypothesisFile is the YAML representation of a hypothesis, so that
+// experiments can be registered without writing Go. For example:
+//
+//	id: H9
+//	title: Roast Battle Temperature
+//	question: Does a hotter roast produce more refusals?
+//	rationale: Extends H1 with a second sample of the same preset.
+//	repetitions: 10          # default for every bout below
+//	bouts:
+//	  - preset: roast-battle
+//	    label: roast         # expanded to roast-01 … roast-10
+//	  - preset: gloves-off
+//	    topic: Should billionaires exist?
+//	    turns: 24
+//	    label: gloves-hot
+//	    repetitions: 5
+type HypothesisFile struct {
+	ID          string     `yaml:"id"`
+	Title       string     `yaml:"title"`
+	Question    string     `yaml:"question"`
+	Rationale   string     `yaml:"rationale"`
+	Repetitions int        `yaml:"repetitions"`
+	Bouts       []BoutFile `yaml:"bouts"`
+}
+
+// BoutFile is one bout entry of a HypothesisFile. Turns defaults to
+// defaultTurns, Label to the preset ID and Repetitions to the
+// hypothesis's.
+type BoutFile struct {
+	PresetID    string `yaml:"preset"`
+	Topic       string `yaml:"topic"`
+	Turns       int    `yaml:"turns"`
+	Label       string `yaml:"label"`
+	Repetitions int    `yaml:"repetitions"`
+}
+
+// LoadHypotheses reads every *.yaml and *.yml file in dir, in name order.
+func LoadHypotheses(dir string) ([]Hypothesis, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read hypotheses dir: %w", err)
+	}
+	var out []Hypothesis
+	seen := make(map[string]string)
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read hypothesis file: %w", err)
+		}
+		h, err := ParseHypothesis(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		key := strings.ToUpper(h.ID)
+		if prev, ok := seen[key]; ok {
+			return nil, fmt.Errorf("%s: hypothesis %q already defined in %s", path, h.ID, prev)
+		}
+		seen[key] = path
+		out = append(out, *h)
+	}
+	return out, nil
+}
+
+// ParseHypothesis decodes and validates hypothesis YAML, expanding
+// repeated bouts. Unknown fields are rejected so typos don't silently
+// fall back to zero values.
+func ParseHypothesis(data []byte) (*Hypothesis, error) {
+	var hf HypothesisFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&hf); err != nil {
+		return nil, fmt.Errorf("parse hypothesis file: %w", err)
+	}
+	return hf.Hypothesis()
+}
+
+// Hypothesis converts the file representation into a validated
+// Hypothesis. A bout repeated n > 1 times gets labels suffixed -01 … -n.
+func (hf *HypothesisFile) Hypothesis() (*Hypothesis, error) {
+	if hf.ID == "" {
+		return nil, fmt.Errorf("missing id")
+	}
+	if hf.Question == "" {
+		return nil, fmt.Errorf("hypothesis %s: missing question", hf.ID)
+	}
+	if hf.Repetitions < 0 {
+		return nil, fmt.Errorf("hypothesis %s: repetitions must be >= 0, got %d", hf.ID, hf.Repetitions)
+	}
+	if len(hf.Bouts) == 0 {
+		return nil, fmt.Errorf("hypothesis %s defines no bouts", hf.ID)
+	}
+
+	h := &Hypothesis{
+		ID:         hf.ID,
+		Title:      hf.Title,
+		Question:   hf.Question,
+		WhyMatters: hf.Rationale,
+	}
+	if h.Title == "" {
+		h.Title = hf.ID
+	}
+	labels := make(map[string]bool)
+	for i, bf := range hf.Bouts {
+		if bf.PresetID == "" {
+			return nil, fmt.Errorf("bout[%d]: missing preset", i)
+		}
+		if bf.Turns < 0 || bf.Repetitions < 0 {
+			return nil, fmt.Errorf("bout[%d] (%s): turns and repetitions must be >= 0", i, bf.PresetID)
+		}
+		turns := bf.Turns
+		if turns == 0 {
+			turns = defaultTurns
+		}
+		label := bf.Label
+		if label == "" {
+			label = bf.PresetID
+		}
+		reps := bf.Repetitions
+		if reps == 0 {
+			reps = max(hf.Repetitions, 1)
+		}
+		for n := 1; n <= reps; n++ {
+			l := label
+			if reps > 1 {
+				l = fmt.Sprintf("%s-%02d", label, n)
+			}
+			if labels[l] {
+				return nil, fmt.Errorf("bout[%d] (%s): duplicate label %q", i, bf.PresetID, l)
+			}
+			labels[l] = true
+			h.Bouts = append(h.Bouts, BoutSpec{PresetID: bf.PresetID, Topic: bf.Topic, Turns: turns, Label: l})
+		}
+	}
+	return h, nil
+}
+
+// mergeHypotheses returns the built-ins with any file hypothesis of the
+// same ID (case-insensitive) replacing it, followed by the new ones.
+func mergeHypotheses(builtin, files []Hypothesis) []Hypothesis {
+	byID := make(map[string]Hypothesis, len(files))
+	for _, h := range files {
+		byID[strings.ToUpper(h.ID)] = h
+	}
+	out := make([]Hypothesis, 0, len(builtin)+len(files))
+	for _, h := range builtin {
+		key := strings.ToUpper(h.ID)
+		if fh, ok := byID[key]; ok {
+			h = fh
+			delete(byID, key)
+		}
+		out = append(out, h)
+	}
+	for _, h := range files {
+		if _, ok := byID[strings.ToUpper(h.ID)]; ok {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// ---------- Preset Validation ----------
+
+// PresetIndex is the structure of presets/index.json.
+type PresetIndex struct {
+	Version string        `json:"version"`
+	Presets []PresetEntry `json:"presets"`
+}
+
+// PresetEntry is a single entry in the preset index.
+type PresetEntry struct {
+	ID            string `json:"id"`
+	File          string `json:"file"`
+	Agents        int    `json:"agents"`
+	RequiresInput bool   `json:"requires_input"`
+}
+
+// LoadPresetIndex reads a presets/index.json file.
+func LoadPresetIndex(path string) (*PresetIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read preset index: %w", err)
+	}
+	var idx PresetIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("parse preset index: %w", err)
+	}
+	return &idx, nil
+}
+
+// findPresetIndex looks for presets/index.json in and above the working
+// directory, as the tool is run from either the repo root or pitstorm/.
+func findPresetIndex() string {
+	for _, c := range []string{"presets", "../presets", "../../presets"} {
+		path := filepath.Join(c, "index.json")
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// Problem is one finding of ValidatePresets.
+type Problem struct {
+	Hypothesis string
+	Label      string
+	Message    string
+	Warning    bool // the hypothesis can still run
+}
+
+// ValidatePresets checks every bout's preset against the index at
+// indexPath. Presets missing from the index are errors unless a preset
+// file of that ID sits beside it (e.g. research presets that are served
+// but not listed), which is a warning. Presets that require input must
+// have a topic.
+func ValidatePresets(hypotheses []Hypothesis, indexPath string) ([]Problem, error) {
+	idx, err := LoadPresetIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	presets := make(map[string]PresetEntry, len(idx.Presets))
+	for _, p := range idx.Presets {
+		presets[p.ID] = p
+	}
+
+	var problems []Problem
+	for _, h := range hypotheses {
+		// Report each problem preset once per hypothesis, not per bout.
+		reported := make(map[string]bool)
+		for _, b := range h.Bouts {
+			p, ok := presets[b.PresetID]
+			switch {
+			case !ok && !reported[b.PresetID]:
+				reported[b.PresetID] = true
+				pr := Problem{Hypothesis: h.ID, Label: b.Label,
+					Message: fmt.Sprintf("preset %q is not in %s", b.PresetID, indexPath)}
+				if _, err := os.Stat(filepath.Join(filepath.Dir(indexPath), b.PresetID+".json")); err == nil {
+					pr.Message += " (but " + b.PresetID + ".json exists)"
+					pr.Warning = true
+				}
+				problems = append(problems, pr)
+			case ok && p.RequiresInput && b.Topic == "" && !reported[b.PresetID]:
+				reported[b.PresetID] = true
+				problems = append(problems, Problem{Hypothesis: h.ID, Label: b.Label,
+					Message: fmt.Sprintf("preset %q requires a topic", b.PresetID)})
+			}
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseHypothesis(t *testing.T) {
+	h, err := ParseHypothesis([]byte(`
+id: H9
+question: Does a hotter roast produce more refusals?
+rationale: Extends H1.
+repetitions: 3
+bouts:
+  - preset: roast-battle
+    label: roast
+  - preset: gloves-off
+    topic: Should billionaires exist?
+    turns: 24
+    repetitions: 1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Title != "H9" || h.WhyMatters != "Extends H1." || len(h.Bouts) != 4 {
+		t.Fatalf("hypothesis = %+v", h)
+	}
+	for i, want := range []string{"roast-01", "roast-02", "roast-03", "gloves-off"} {
+		if h.Bouts[i].Label != want {
+			t.Errorf("bout %d label = %q, want %q", i, h.Bouts[i].Label, want)
+		}
+	}
+	if h.Bouts[0].Turns != defaultTurns || h.Bouts[3].Turns != 24 || h.Bouts[3].Topic == "" {
+		t.Errorf("bouts = %+v", h.Bouts)
+	}
+}
+
+func TestParseHypothesisErrors(t *testing.T) {
+	cases := map[string]string{
+		"missing id":       "question: q\nbouts: [{preset: summit}]",
+		"missing question": "id: X\nbouts: [{preset: summit}]",
+		"defines no bouts": "id: X\nquestion: q",
+		"missing preset":   "id: X\nquestion: q\nbouts: [{label: a}]",
+		"duplicate label":  "id: X\nquestion: q\nbouts: [{preset: summit}, {preset: summit}]",
+		"not found":        "id: X\nquestion: q\nbouts: [{preset: summit, rounds: 3}]",
+	}
+	for want, doc := range cases {
+		if _, err := ParseHypothesis([]byte(doc)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: err = %v, want %q", doc, err, want)
+		}
+	}
+}
+
+func TestLoadHypothesesOverridesBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, doc string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(doc), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("h1.yaml", "id: h1\nquestion: replaced\nbouts: [{preset: summit}]")
+	write("h9.yml", "id: H9\nquestion: new\nbouts: [{preset: summit}]")
+	write("notes.txt", "ignored")
+
+	files, err := LoadHypotheses(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := mergeHypotheses(allHypotheses(), files)
+	if len(all) != len(allHypotheses())+1 {
+		t.Fatalf("%d hypotheses", len(all))
+	}
+	if all[0].Question != "replaced" || all[len(all)-1].ID != "H9" {
+		t.Errorf("first = %s %q, last = %s", all[0].ID, all[0].Question, all[len(all)-1].ID)
+	}
+
+	write("dup.yaml", "id: H9\nquestion: again\nbouts: [{preset: summit}]")
+	if _, err := LoadHypotheses(dir); err == nil || !strings.Contains(err.Error(), "already defined") {
+		t.Errorf("duplicate id: err = %v", err)
+	}
+}
+
+func TestValidatePresets(t *testing.T) {
+	dir := t.TempDir()
+	index := filepath.Join(dir, "index.json")
+	os.WriteFile(index, []byte(`{"version":"1.0.0","presets":[
+		{"id":"summit","file":"summit.json","agents":6},
+		{"id":"gloves-off","file":"gloves-off.json","agents":2,"requires_input":true}]}`), 0644)
+	os.WriteFile(filepath.Join(dir, "research.json"), []byte(`{}`), 0644)
+
+	hyps := []Hypothesis{{ID: "X", Bouts: []BoutSpec{
+		{PresetID: "summit", Label: "ok"},
+		{PresetID: "sumit", Label: "typo-01"},
+		{PresetID: "sumit", Label: "typo-02"},
+		{PresetID: "gloves-off", Label: "no-topic"},
+		{PresetID: "gloves-off", Label: "topic", Topic: "t"},
+		{PresetID: "research", Label: "unlisted"},
+	}}}
+	problems, err := ValidatePresets(hyps, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 3 {
+		t.Fatalf("problems = %+v", problems)
+	}
+	if problems[0].Label != "typo-01" || problems[0].Warning {
+		t.Errorf("unknown preset = %+v", problems[0])
+	}
+	if problems[1].Label != "no-topic" || !strings.Contains(problems[1].Message, "requires a topic") {
+		t.Errorf("missing topic = %+v", problems[1])
+	}
+	if problems[2].Label != "unlisted" || !problems[2].Warning {
+		t.Errorf("unlisted preset file = %+v", problems[2])
+	}
+
+	// The built-ins must stay valid against the repo's own index.
+	if path := "../../../presets/index.json"; fileExists(path) {
+		problems, err := ValidatePresets(allHypotheses(), path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range problems {
+			if !p.Warning {
+				t.Errorf("built-in %s %s: %s", p.Hypothesis, p.Label, p.Message)
+			}
+		}
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
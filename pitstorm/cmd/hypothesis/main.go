@@ -1,13 +1,22 @@
 // hypothesis — systematic bout runner for The Pit research experiments.
 //
 // Each hypothesis is a named batch of bouts with a documented research
-// question. Bouts are run sequentially against the production API with
-// progress output and result capture.
+// question. Bouts are run against the production API, one at a time or
+// --concurrency at once, with progress output and result capture.
+// Completed bouts are recorded in a state file under the output
+// directory, so an interrupted run resumes where it stopped when the
+// same command is run again.
+//
+// The built-in hypotheses (hypotheses.go) can be extended or overridden
+// by YAML files in a directory (see HypothesisFile), and --validate
+// checks every bout's preset against presets/index.json without running
+// anything.
 //
 // Usage:
 //
 //	go run ./cmd/hypothesis --phase H1 --target https://www.thepit.cloud
 //	go run ./cmd/hypothesis --list
+//	go run ./cmd/hypothesis --dir hypotheses/ --validate
 //
 // The tool uses pitstorm's client/action/sse infrastructure to run bouts
 // via POST /api/run-bout and parse the SSE stream.
@@ -19,11 +28,13 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/rickhallett/thepit/pitstorm/internal/action"
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
 	"github.com/rickhallett/thepit/pitstorm/internal/client"
 	"github.com/rickhallett/thepit/shared/config"
 	"github.com/rickhallett/thepit/shared/theme"
@@ -61,6 +72,11 @@ type BoutResult struct {
 	Error      string        `json:"error,omitempty"`
 	ShareLine  string        `json:"shareLine,omitempty"`
 	Agents     []AgentResult `json:"agents"`
+	// CostGBP is the bout's cost, from the server's reported usage or
+	// estimated from its length.
+	CostGBP float64 `json:"costGbp,omitempty"`
+
+	usage *client.UsageData // reported by the stream, for charging
 }
 
 // AgentResult captures per-agent output.
@@ -76,7 +92,13 @@ func main() {
 	phase := ""
 	target := "https://www.thepit.cloud"
 	outputDir := "results/hypotheses"
+	hypothesesDir := ""
+	presetIndex := ""
+	concurrency := 1
+	budgetGBP := 0.0
+	fresh := false
 	listOnly := false
+	validate := false
 
 	for i := 1; i < len(os.Args); i++ {
 		switch os.Args[i] {
@@ -95,8 +117,42 @@ func main() {
 			if i < len(os.Args) {
 				outputDir = os.Args[i]
 			}
+		case "--dir":
+			i++
+			if i < len(os.Args) {
+				hypothesesDir = os.Args[i]
+			}
+		case "--presets":
+			i++
+			if i < len(os.Args) {
+				presetIndex = os.Args[i]
+			}
+		case "--concurrency":
+			i++
+			if i < len(os.Args) {
+				n, err := strconv.Atoi(os.Args[i])
+				if err != nil || n < 1 {
+					fmt.Fprintf(os.Stderr, "error: --concurrency must be a positive integer, got %q\n", os.Args[i])
+					os.Exit(1)
+				}
+				concurrency = n
+			}
+		case "--budget":
+			i++
+			if i < len(os.Args) {
+				v, err := strconv.ParseFloat(os.Args[i], 64)
+				if err != nil || v <= 0 {
+					fmt.Fprintf(os.Stderr, "error: --budget must be a positive number, got %q\n", os.Args[i])
+					os.Exit(1)
+				}
+				budgetGBP = v
+			}
+		case "--fresh":
+			fresh = true
 		case "--list":
 			listOnly = true
+		case "--validate":
+			validate = true
 		case "--help", "-h":
 			usage()
 			return
@@ -104,6 +160,18 @@ func main() {
 	}
 
 	hypotheses := allHypotheses()
+	if hypothesesDir != "" {
+		fromFiles, err := LoadHypotheses(hypothesesDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		hypotheses = mergeHypotheses(hypotheses, fromFiles)
+	}
+
+	if validate {
+		os.Exit(runValidate(hypotheses, phase, presetIndex))
+	}
 
 	if listOnly {
 		fmt.Printf("\n%s\n\n", theme.Title.Render("hypothesis — available phases"))
@@ -135,12 +203,31 @@ func main() {
 	// Ensure output directory exists.
 	os.MkdirAll(outputDir, 0755)
 
+	// Resume from the state file of an earlier, unfinished run.
+	stPath := statePath(outputDir, hyp.ID)
+	if fresh {
+		os.Remove(stPath)
+	}
+	state, err := loadState(stPath, hyp.ID, target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	resumed := state.completed(hyp.Bouts)
+
 	fmt.Printf("\n%s\n\n", theme.Title.Render("hypothesis — "+hyp.ID))
 	fmt.Printf("  %s\n", theme.Bold.Render(hyp.Title))
 	fmt.Printf("  %s\n", hyp.Question)
 	fmt.Printf("  %s\n\n", theme.Muted.Render(hyp.WhyMatters))
 	fmt.Printf("  Target:  %s\n", target)
-	fmt.Printf("  Bouts:   %d\n", len(hyp.Bouts))
+	fmt.Printf("  Bouts:   %d", len(hyp.Bouts))
+	if len(resumed) > 0 {
+		fmt.Printf(" (%d already completed, resuming from %s)", len(resumed), stPath)
+	}
+	fmt.Printf("\n  Workers: %d\n", concurrency)
+	if budgetGBP > 0 {
+		fmt.Printf("  Budget:  £%.2f\n", budgetGBP)
+	}
 	fmt.Printf("  Output:  %s/%s.json\n\n", outputDir, hyp.ID)
 
 	// Create HTTP client with research bypass header.
@@ -177,133 +264,44 @@ func main() {
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		<-sigCh
-		fmt.Printf("\n  %s shutting down after current bout...\n", theme.Warning.Render("signal:"))
+		fmt.Printf("\n  %s shutting down; completed bouts are kept in %s\n", theme.Warning.Render("signal:"), stPath)
 		cancel()
 	}()
 
-	// Run bouts sequentially.
-	var results []BoutResult
-	completed, errored := 0, 0
-
-	for i, spec := range hyp.Bouts {
-		if ctx.Err() != nil {
-			break
-		}
-
-		boutID := action.GenerateID(21)
-		fmt.Printf("  [%d/%d] %s %s (preset=%s",
-			i+1, len(hyp.Bouts),
-			theme.Accent.Render("▶"),
-			spec.Label, spec.PresetID)
-		if spec.Topic != "" {
-			fmt.Printf(", topic=%q", truncate(spec.Topic, 40))
-		}
-		fmt.Printf(")  ")
-
-		// Per-bout timeout: 5 minutes max.
-		boutCtx, boutCancel := context.WithTimeout(ctx, 5*time.Minute)
-
-		handle, err := act.RunBoutStream(boutCtx, "", action.RunBoutRequest{
-			BoutID:   boutID,
-			PresetID: spec.PresetID,
-			Topic:    spec.Topic,
-			Turns:    spec.Turns,
-		})
-
-		if err != nil {
-			boutCancel()
-			fmt.Printf("%s %v\n", theme.Error.Render("FAIL"), err)
-			results = append(results, BoutResult{
-				BoutID:     boutID,
-				PresetID:   spec.PresetID,
-				Topic:      spec.Topic,
-				Label:      spec.Label,
-				Status:     "error",
-				TotalTurns: spec.Turns,
-				Error:      err.Error(),
-			})
-			errored++
-			continue
+	// Run the bouts not yet recorded in the state file.
+	r := &runner{
+		act:         act,
+		specs:       hyp.Bouts,
+		concurrency: concurrency,
+		gate:        budget.NewGate(budgetGBP),
+		results:     make([]*BoutResult, len(hyp.Bouts)),
+		state:       state,
+		statePath:   stPath,
+	}
+	var pending []int
+	for i := range hyp.Bouts {
+		if res, ok := resumed[i]; ok {
+			// Resumed bouts count towards the budget, which caps the
+			// whole hypothesis rather than each attempt at it.
+			r.results[i] = &res
+			r.gate.Charge(boutModel, res.CostGBP)
+		} else {
+			pending = append(pending, i)
 		}
+	}
+	overBudget := r.run(ctx, pending)
 
-		if handle.StatusCode >= 400 {
-			handle.Close()
-			boutCancel()
-			fmt.Printf("%s HTTP %d\n", theme.Error.Render("FAIL"), handle.StatusCode)
-			results = append(results, BoutResult{
-				BoutID:     boutID,
-				PresetID:   spec.PresetID,
-				Topic:      spec.Topic,
-				Label:      spec.Label,
-				Status:     "error",
-				TotalTurns: spec.Turns,
-				Error:      fmt.Sprintf("HTTP %d", handle.StatusCode),
-			})
-			errored++
+	var results []BoutResult
+	completed, errored := 0, 0
+	for _, res := range r.results {
+		if res == nil {
 			continue
 		}
-
-		// Parse SSE stream.
-		stream, parseErr := client.ParseSSEStream(handle.Body, nil)
-		handle.Close()
-		boutCancel()
-
-		result := BoutResult{
-			BoutID:     boutID,
-			PresetID:   spec.PresetID,
-			Topic:      spec.Topic,
-			Label:      spec.Label,
-			TotalTurns: spec.Turns,
-		}
-
-		if parseErr != nil {
-			fmt.Printf("%s %v\n", theme.Error.Render("PARSE"), parseErr)
-			result.Status = "error"
-			result.Error = parseErr.Error()
-			errored++
-		} else if stream.Error != "" {
-			fmt.Printf("%s %s\n", theme.Error.Render("STREAM"), stream.Error)
-			result.Status = "error"
-			result.Error = stream.Error
-			errored++
-		} else {
-			result.Status = "completed"
-			result.Turns = len(stream.Turns)
-			result.Duration = stream.Duration
-			result.FirstByte = stream.FirstByte
-			result.Chars = stream.TotalChars
-			result.ShareLine = stream.ShareLine
-
-			// Aggregate per-agent stats.
-			agentMap := make(map[string]*AgentResult)
-			for _, t := range stream.Turns {
-				ar, ok := agentMap[t.AgentID]
-				if !ok {
-					ar = &AgentResult{Name: t.AgentName, ID: t.AgentID}
-					agentMap[t.AgentID] = ar
-				}
-				ar.Turns++
-				ar.AvgChars += len(t.Text)
-			}
-			for _, ar := range agentMap {
-				if ar.Turns > 0 {
-					ar.AvgChars /= ar.Turns
-				}
-				result.Agents = append(result.Agents, *ar)
-			}
-
-			fmt.Printf("%s %d turns, %d chars, %s\n",
-				theme.Success.Render("OK"),
-				result.Turns, result.Chars,
-				result.Duration.Truncate(time.Second))
+		results = append(results, *res)
+		if res.Status == "completed" {
 			completed++
-		}
-
-		results = append(results, result)
-
-		// Brief pause between bouts to avoid hammering.
-		if i < len(hyp.Bouts)-1 && ctx.Err() == nil {
-			time.Sleep(3 * time.Second)
+		} else {
+			errored++
 		}
 	}
 
@@ -334,11 +332,26 @@ func main() {
 	data, _ := json.MarshalIndent(output, "", "  ")
 	os.WriteFile(outPath, data, 0644)
 
+	// Once every bout has completed the state file has served its
+	// purpose; otherwise keep it so a re-run picks up where this stopped.
+	if completed == len(hyp.Bouts) {
+		os.Remove(stPath)
+	}
+
 	// Summary.
 	fmt.Printf("\n%s\n\n", theme.Title.Render("hypothesis — summary"))
 	fmt.Printf("  Completed:  %d / %d\n", completed, len(hyp.Bouts))
 	fmt.Printf("  Errored:    %d\n", errored)
-	fmt.Printf("  Results:    %s\n\n", outPath)
+	fmt.Printf("  Spent:      £%.4f\n", r.gate.Spent())
+	if overBudget > 0 {
+		fmt.Printf("  Budget:     %s\n", theme.Warning.Render(
+			fmt.Sprintf("£%.2f reached, %d bouts not started", budgetGBP, overBudget)))
+	}
+	fmt.Printf("  Results:    %s\n", outPath)
+	if completed < len(hyp.Bouts) {
+		fmt.Printf("  State:      %s (re-run to resume)\n", stPath)
+	}
+	fmt.Println()
 }
 
 func truncate(s string, n int) string {
@@ -348,15 +361,77 @@ func truncate(s string, n int) string {
 	return s[:n] + "..."
 }
 
+// runValidate checks the presets of the hypothesis named by phase, or of
+// every hypothesis, and returns the exit code.
+func runValidate(hypotheses []Hypothesis, phase, indexPath string) int {
+	if phase != "" {
+		var picked []Hypothesis
+		for _, h := range hypotheses {
+			if strings.EqualFold(h.ID, phase) {
+				picked = append(picked, h)
+			}
+		}
+		if len(picked) == 0 {
+			fmt.Fprintf(os.Stderr, "error: unknown phase %q\n", phase)
+			return 1
+		}
+		hypotheses = picked
+	}
+	if indexPath == "" {
+		indexPath = findPresetIndex()
+		if indexPath == "" {
+			fmt.Fprintf(os.Stderr, "error: cannot find presets/index.json; use --presets <path>\n")
+			return 1
+		}
+	}
+
+	problems, err := ValidatePresets(hypotheses, indexPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("\n%s\n\n", theme.Title.Render("hypothesis — validate"))
+	bouts, errs := 0, 0
+	for _, h := range hypotheses {
+		bouts += len(h.Bouts)
+	}
+	for _, p := range problems {
+		tag := theme.Error.Render("error:")
+		if p.Warning {
+			tag = theme.Warning.Render("warn:")
+		} else {
+			errs++
+		}
+		fmt.Printf("  %s %s %s: %s\n", tag, theme.Accent.Render(p.Hypothesis), p.Label, p.Message)
+	}
+	if len(problems) > 0 {
+		fmt.Println()
+	}
+	fmt.Printf("  Checked %d hypotheses, %d bouts against %s\n", len(hypotheses), bouts, indexPath)
+	if errs > 0 {
+		fmt.Printf("  %s\n\n", theme.Error.Render(fmt.Sprintf("%d errors", errs)))
+		return 1
+	}
+	fmt.Printf("  %s\n\n", theme.Success.Render("OK"))
+	return 0
+}
+
 func usage() {
 	fmt.Printf("\n%s\n\n", theme.Title.Render("hypothesis — systematic bout runner"))
 	fmt.Println("  Usage: go run ./cmd/hypothesis [flags]")
 	fmt.Println()
 	fmt.Println("  Flags:")
-	fmt.Println("    --phase <ID>     Hypothesis to run (e.g. H1, H2, ...)")
-	fmt.Println("    --target <url>   Target URL (default: https://www.thepit.cloud)")
-	fmt.Println("    --output <dir>   Output directory (default: results/hypotheses)")
-	fmt.Println("    --list           List available hypotheses")
-	fmt.Println("    --help           Show this help")
+	fmt.Println("    --phase <ID>      Hypothesis to run (e.g. H1, H2, ...)")
+	fmt.Println("    --target <url>    Target URL (default: https://www.thepit.cloud)")
+	fmt.Println("    --output <dir>    Output directory (default: results/hypotheses)")
+	fmt.Println("    --dir <path>      Load extra hypotheses from *.yaml files (same ID overrides a built-in)")
+	fmt.Println("    --concurrency <n> Bouts to run at once (default: 1)")
+	fmt.Println("    --budget <gbp>    Max estimated spend in GBP (default: no cap)")
+	fmt.Println("    --fresh           Discard the state file of an unfinished run instead of resuming it")
+	fmt.Println("    --list            List available hypotheses")
+	fmt.Println("    --validate        Check bout presets against presets/index.json and exit")
+	fmt.Println("    --presets <path>  Preset index for --validate (default: presets/index.json, searched upwards)")
+	fmt.Println("    --help            Show this help")
 	fmt.Println()
 }
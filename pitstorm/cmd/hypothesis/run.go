@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/action"
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+	"github.com/rickhallett/thepit/pitstorm/internal/client"
+	"github.com/rickhallett/thepit/shared/theme"
+)
+
+const (
+	// boutTimeout caps a single bout.
+	boutTimeout = 5 * time.Minute
+	// boutPause is how long a worker waits between bouts to avoid
+	// hammering the target.
+	boutPause = 3 * time.Second
+	// boutModel is the model bouts are priced at. Bouts don't pick a
+	// model, so the server's default (priced as Haiku) runs them.
+	boutModel = ""
+)
+
+// ---------- State File ----------
+
+// runState is the state file of a hypothesis run. It records every
+// completed bout, so that an interrupted run can be resumed by running
+// the same command again.
+type runState struct {
+	Hypothesis string       `json:"hypothesis"`
+	Target     string       `json:"target"`
+	Results    []BoutResult `json:"results"`
+}
+
+// statePath returns the state file for a hypothesis under outputDir.
+func statePath(outputDir, id string) string {
+	return filepath.Join(outputDir, id+".state.json")
+}
+
+// loadState reads a state file, returning an empty state if there is
+// none yet.
+func loadState(path, hypothesis, target string) (*runState, error) {
+	st := &runState{Hypothesis: hypothesis, Target: target}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read state file: %w", err)
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("parse state file %s: %w", path, err)
+	}
+	if !strings.EqualFold(st.Hypothesis, hypothesis) || st.Target != target {
+		return nil, fmt.Errorf("state file %s is for %s against %s; use --fresh to discard it",
+			path, st.Hypothesis, st.Target)
+	}
+	return st, nil
+}
+
+// save writes the state file atomically, so an interrupt mid-write
+// can't lose the bouts already recorded.
+func (st *runState) save(path string) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write state file: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// completed maps each bout of specs to its recorded result, by index.
+// A result only counts if its label, preset, topic and turns still
+// match the spec, so editing a hypothesis re-runs the changed bouts.
+func (st *runState) completed(specs []BoutSpec) map[int]BoutResult {
+	byLabel := make(map[string]BoutResult, len(st.Results))
+	for _, r := range st.Results {
+		byLabel[r.Label] = r
+	}
+	done := make(map[int]BoutResult)
+	for i, s := range specs {
+		r, ok := byLabel[s.Label]
+		if ok && r.PresetID == s.PresetID && r.Topic == s.Topic && r.TotalTurns == s.Turns {
+			done[i] = r
+		}
+	}
+	return done
+}
+
+// ---------- Runner ----------
+
+// runner runs the bouts of a hypothesis on up to concurrency workers,
+// recording each completed bout in the state file.
+type runner struct {
+	act         *action.Actor
+	specs       []BoutSpec
+	concurrency int
+	gate        *budget.Gate
+
+	mu        sync.Mutex
+	settled   *sync.Cond    // signalled when a bout in flight finishes
+	results   []*BoutResult // by spec index; nil if not run
+	state     *runState
+	statePath string
+	reserved  float64 // estimated cost of bouts in flight
+}
+
+// run starts the bouts at the pending spec indices, in order, until
+// they are all done, ctx is cancelled, or the budget can't cover the
+// next bout. It returns the number of bouts left unstarted because of
+// the budget.
+func (r *runner) run(ctx context.Context, pending []int) (overBudget int) {
+	r.settled = sync.NewCond(&r.mu)
+	sem := make(chan struct{}, r.concurrency)
+	var wg sync.WaitGroup
+	var started atomic.Int64
+
+	for n, i := range pending {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		est, ok := r.reserve(ctx, r.specs[i].Turns)
+		if !ok {
+			<-sem
+			if ctx.Err() == nil {
+				overBudget = len(pending) - n
+			}
+			break
+		}
+		started.Add(1)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			res, outcome := runBout(ctx, r.act, r.specs[i])
+			r.finish(i, res, outcome, est)
+
+			// Hold the worker slot through the pause between bouts.
+			if int(started.Load()) < len(pending) {
+				select {
+				case <-time.After(boutPause):
+				case <-ctx.Done():
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	return overBudget
+}
+
+// reserve sets aside the estimated cost of a bout, so that bouts in
+// flight together can't overrun the budget. If the bout doesn't fit it
+// waits for the bouts in flight to settle their actual cost, and
+// reports false once none are left and it still doesn't fit, or ctx
+// is done.
+func (r *runner) reserve(ctx context.Context, turns int) (float64, bool) {
+	est := budget.EstimateBoutCost(boutModel, turns, budget.DefaultOutputPerTurn)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ceiling := r.gate.Ceiling()
+	for ceiling > 0 && r.gate.Spent()+r.reserved+est > ceiling {
+		if r.reserved == 0 {
+			return est, false
+		}
+		r.settled.Wait()
+	}
+	if ctx.Err() != nil {
+		return est, false
+	}
+	r.reserved += est
+	return est, true
+}
+
+// finish records a bout's result, charges its cost in place of the
+// reservation, and prints its progress line.
+func (r *runner) finish(i int, res BoutResult, outcome string, est float64) {
+	if res.Status == "completed" {
+		res.CostGBP = chargeBout(r.gate, res, est)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reserved -= est
+	r.settled.Broadcast()
+	r.results[i] = &res
+	if res.Status == "completed" {
+		r.state.Results = append(r.state.Results, res)
+		if err := r.state.save(r.statePath); err != nil {
+			fmt.Fprintf(os.Stderr, "  %s %v\n", theme.Warning.Render("warn:"), err)
+		}
+	}
+
+	spec := r.specs[i]
+	fmt.Printf("  [%d/%d] %s %s (preset=%s", i+1, len(r.specs), theme.Accent.Render("▶"), spec.Label, spec.PresetID)
+	if spec.Topic != "" {
+		fmt.Printf(", topic=%q", truncate(spec.Topic, 40))
+	}
+	fmt.Printf(")  %s\n", outcome)
+}
+
+// chargeBout charges a completed bout to the gate, from the server's
+// reported usage when it sends any, and returns its cost.
+func chargeBout(gate *budget.Gate, res BoutResult, est float64) float64 {
+	if u := res.usage; u != nil {
+		return gate.ChargeUsage("", boutModel, u.InputTokens, u.OutputTokens, est)
+	}
+	out := res.Chars / 4
+	return gate.ChargeTokens(boutModel, int(float64(out)*budget.InputFactor), out)
+}
+
+// runBout runs one bout and returns its result with a progress message.
+func runBout(ctx context.Context, act *action.Actor, spec BoutSpec) (BoutResult, string) {
+	result := BoutResult{
+		BoutID:     action.GenerateID(21),
+		PresetID:   spec.PresetID,
+		Topic:      spec.Topic,
+		Label:      spec.Label,
+		Status:     "error",
+		TotalTurns: spec.Turns,
+	}
+
+	boutCtx, boutCancel := context.WithTimeout(ctx, boutTimeout)
+	defer boutCancel()
+
+	handle, err := act.RunBoutStream(boutCtx, "", action.RunBoutRequest{
+		BoutID:   result.BoutID,
+		PresetID: spec.PresetID,
+		Topic:    spec.Topic,
+		Turns:    spec.Turns,
+	})
+	if err != nil {
+		result.Error = err.Error()
+		return result, fmt.Sprintf("%s %v", theme.Error.Render("FAIL"), err)
+	}
+	defer handle.Close()
+
+	if handle.StatusCode >= 400 {
+		result.Error = fmt.Sprintf("HTTP %d", handle.StatusCode)
+		return result, fmt.Sprintf("%s HTTP %d", theme.Error.Render("FAIL"), handle.StatusCode)
+	}
+
+	// Parse SSE stream.
+	stream, parseErr := client.ParseSSEStream(handle.Body, nil)
+	if parseErr != nil {
+		result.Error = parseErr.Error()
+		return result, fmt.Sprintf("%s %v", theme.Error.Render("PARSE"), parseErr)
+	}
+	if stream.Error != "" {
+		result.Error = stream.Error
+		return result, fmt.Sprintf("%s %s", theme.Error.Render("STREAM"), stream.Error)
+	}
+
+	result.Status = "completed"
+	result.Turns = len(stream.Turns)
+	result.Duration = stream.Duration
+	result.FirstByte = stream.FirstByte
+	result.Chars = stream.TotalChars
+	result.ShareLine = stream.ShareLine
+	result.usage = stream.Usage
+
+	// Aggregate per-agent stats.
+	agentMap := make(map[string]*AgentResult)
+	for _, t := range stream.Turns {
+		ar, ok := agentMap[t.AgentID]
+		if !ok {
+			ar = &AgentResult{Name: t.AgentName, ID: t.AgentID}
+			agentMap[t.AgentID] = ar
+		}
+		ar.Turns++
+		ar.AvgChars += len(t.Text)
+	}
+	for _, ar := range agentMap {
+		if ar.Turns > 0 {
+			ar.AvgChars /= ar.Turns
+		}
+		result.Agents = append(result.Agents, *ar)
+	}
+
+	return result, fmt.Sprintf("%s %d turns, %d chars, %s",
+		theme.Success.Render("OK"),
+		result.Turns, result.Chars,
+		result.Duration.Truncate(time.Second))
+}
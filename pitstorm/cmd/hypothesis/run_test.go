@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+)
+
+func TestStateFileResume(t *testing.T) {
+	path := statePath(t.TempDir(), "H9")
+	st, err := loadState(path, "H9", "http://target")
+	if err != nil || len(st.Results) != 0 {
+		t.Fatalf("new state = %+v, %v", st, err)
+	}
+
+	st.Results = []BoutResult{
+		{Label: "a-01", PresetID: "summit", TotalTurns: 12, Status: "completed"},
+		{Label: "a-02", PresetID: "summit", TotalTurns: 12, Status: "completed"},
+		{Label: "b", PresetID: "gloves-off", Topic: "old topic", TotalTurns: 12, Status: "completed"},
+	}
+	if err := st.save(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".tmp"); err == nil {
+		t.Error("temporary state file left behind")
+	}
+
+	st, err = loadState(path, "h9", "http://target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	specs := []BoutSpec{
+		{Label: "a-01", PresetID: "summit", Turns: 12},
+		{Label: "a-02", PresetID: "summit", Turns: 24}, // edited since
+		{Label: "a-03", PresetID: "summit", Turns: 12},
+		{Label: "b", PresetID: "gloves-off", Topic: "old topic", Turns: 12},
+	}
+	done := st.completed(specs)
+	if len(done) != 2 || done[0].Label != "a-01" || done[3].Label != "b" {
+		t.Errorf("completed = %+v", done)
+	}
+
+	if _, err := loadState(path, "H9", "http://other"); err == nil || !strings.Contains(err.Error(), "--fresh") {
+		t.Errorf("other target: err = %v", err)
+	}
+	os.WriteFile(path, []byte("{"), 0644)
+	if _, err := loadState(path, "H9", "http://target"); err == nil {
+		t.Error("corrupt state file accepted")
+	}
+}
+
+func TestRunnerReserve(t *testing.T) {
+	est := budget.EstimateBoutCost(boutModel, 12, budget.DefaultOutputPerTurn)
+	r := &runner{
+		specs:     make([]BoutSpec, 3),
+		gate:      budget.NewGate(2.5 * est),
+		results:   make([]*BoutResult, 3),
+		state:     &runState{},
+		statePath: filepath.Join(t.TempDir(), "H9.state.json"),
+	}
+	r.settled = sync.NewCond(&r.mu)
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, ok := r.reserve(ctx, 12); !ok {
+			t.Fatalf("bout %d denied within budget", i)
+		}
+	}
+
+	// A third bout only fits once a bout in flight settles for less
+	// than its estimate.
+	got := make(chan bool)
+	go func() {
+		_, ok := r.reserve(ctx, 12)
+		got <- ok
+	}()
+	r.finish(0, BoutResult{Status: "completed", Chars: 40}, "OK", est)
+	if !<-got {
+		t.Fatal("bout denied after a cheap bout settled")
+	}
+
+	// With nothing left in flight, a bout that can't fit is denied.
+	r.finish(1, BoutResult{Status: "error"}, "FAIL", est)
+	r.finish(2, BoutResult{Status: "error"}, "FAIL", est)
+	r.gate.Charge(boutModel, 2*est)
+	if _, ok := r.reserve(ctx, 12); ok {
+		t.Error("bout allowed over budget")
+	}
+	if len(r.state.Results) != 1 || r.results[0].CostGBP <= 0 {
+		t.Errorf("state = %+v, first result = %+v", r.state.Results, r.results[0])
+	}
+}
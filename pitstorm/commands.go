@@ -3,11 +3,16 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
+	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -16,10 +21,22 @@ import (
 	"github.com/rickhallett/thepit/pitstorm/internal/auth"
 	"github.com/rickhallett/thepit/pitstorm/internal/budget"
 	"github.com/rickhallett/thepit/pitstorm/internal/client"
+	"github.com/rickhallett/thepit/pitstorm/internal/compare"
+	"github.com/rickhallett/thepit/pitstorm/internal/control"
+	"github.com/rickhallett/thepit/pitstorm/internal/coord"
+	"github.com/rickhallett/thepit/pitstorm/internal/credits"
 	"github.com/rickhallett/thepit/pitstorm/internal/engine"
+	"github.com/rickhallett/thepit/pitstorm/internal/fakeclerk"
+	"github.com/rickhallett/thepit/pitstorm/internal/har"
+	"github.com/rickhallett/thepit/pitstorm/internal/journal"
 	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+	"github.com/rickhallett/thepit/pitstorm/internal/mockserver"
 	"github.com/rickhallett/thepit/pitstorm/internal/persona"
+	"github.com/rickhallett/thepit/pitstorm/internal/probe"
 	"github.com/rickhallett/thepit/pitstorm/internal/profile"
+	"github.com/rickhallett/thepit/pitstorm/internal/slo"
+	"github.com/rickhallett/thepit/pitstorm/internal/transcript"
+	"github.com/rickhallett/thepit/pitstorm/internal/tui"
 	"github.com/rickhallett/thepit/shared/config"
 	"github.com/rickhallett/thepit/shared/theme"
 )
@@ -29,57 +46,164 @@ func runCmd(args []string) {
 	if err != nil {
 		fatal("config", err)
 	}
+	runSimulation(cfg, "run")
+}
+
+// defaultJournalPath is where `record` writes its journal when --journal
+// is not given.
+const defaultJournalPath = "results/journal.jsonl"
+
+// recordCmd is `run` with journaling always on and a seed always set, so
+// the run can be reproduced with --seed or replayed from the journal.
+func recordCmd(args []string) {
+	cfg, err := ParseRunConfig(args)
+	if err != nil {
+		fatal("config", err)
+	}
+	if cfg.Journal == "" {
+		cfg.Journal = defaultJournalPath
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	runSimulation(cfg, "record")
+}
+
+// checkCreditBalances reads each signed-in account's credit balance from
+// the target and fails unless every one starts at want, the balance the
+// credits ledger assumes. The Pit does not report balances, so only
+// pitstorm mock --credits can run the credit depletion scenario.
+func checkCreditBalances(ctx context.Context, act *action.Actor, f *account.File, hasToken func(string) bool, want float64) error {
+	checked := 0
+	if f != nil {
+		for _, a := range f.Accounts {
+			if a.Tier == account.TierAnon || !hasToken(a.ID) {
+				continue
+			}
+			got, err := act.CreditBalance(ctx, a.ID)
+			if errors.Is(err, action.ErrNoCreditBalance) {
+				return fmt.Errorf("--credits needs a target that reports credit balances (pitstorm mock --credits): %w", err)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", a.ID, err)
+			}
+			if math.Abs(got-want) > 1e-6 {
+				return fmt.Errorf("%s starts with %g credits, not %g", a.ID, got, want)
+			}
+			checked++
+		}
+	}
+	if checked == 0 {
+		return fmt.Errorf("--credits needs signed-in accounts (see pitstorm login)")
+	}
+	return nil
+}
+
+// applyBudgetLimits installs the --persona-budget and --model-budget
+// sub-ceilings. Percentages are of the (possibly coordinator-assigned)
+// budget.
+func applyBudgetLimits(gate *budget.Gate, cfg RunConfig, personas []*persona.Spec) error {
+	known := make(map[string]bool, len(personas))
+	for _, p := range personas {
+		known[p.ID] = true
+	}
+	for id, l := range cfg.PersonaBudgets {
+		if !known[id] {
+			return fmt.Errorf("--persona-budget: persona %q is not part of this run", id)
+		}
+		if err := gate.SetPersonaLimit(id, l); err != nil {
+			return err
+		}
+	}
+	for id, l := range cfg.ModelBudgets {
+		if err := gate.SetModelLimit(id, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatLimits lists the configured sub-ceilings for display.
+func formatLimits(cfg RunConfig) string {
+	var parts []string
+	for _, limits := range []map[string]budget.Limit{cfg.PersonaBudgets, cfg.ModelBudgets} {
+		ids := make([]string, 0, len(limits))
+		for id := range limits {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			parts = append(parts, id+" "+limits[id].String())
+		}
+	}
+	return strings.Join(parts, ", ")
+}
 
-	fmt.Printf("\n%s\n\n", theme.Title.Render("pitstorm — run"))
+// runSimulation executes a full simulation run; command is the subcommand
+// name shown in the title banner.
+func runSimulation(cfg RunConfig, command string) {
+	fmt.Printf("\n%s\n\n", theme.Title.Render("pitstorm — "+command))
 
+	// While the dashboard is up, log lines go to its log pane instead.
+	var dash atomic.Pointer[tui.Dashboard]
 	logf := func(format string, a ...any) {
-		if cfg.Verbose {
-			fmt.Printf("  "+format+"\n", a...)
+		if !cfg.Verbose {
+			return
+		}
+		if d := dash.Load(); d != nil {
+			d.Logf(format, a...)
+			return
 		}
+		fmt.Printf("  "+format+"\n", a...)
+	}
+	if cfg.TUI && !tui.Available() {
+		fmt.Printf("  %s --tui needs an interactive terminal, falling back to log lines\n\n", theme.Warning.Render("WARN:"))
+		cfg.TUI = false
 	}
 
-	// 1. Resolve personas.
-	personas, err := persona.Resolve(cfg.Personas)
+	// 1. Resolve personas (built-ins, optionally extended by a scenario file).
+	personas, err := resolvePersonas(cfg)
 	if err != nil {
 		fatal("personas", err)
 	}
 
-	// 2. Resolve traffic profile → RateFunc.
-	profileRateFunc, err := profile.Get(cfg.Profile, cfg.Rate)
+	// 2. Resolve traffic profile → RateFunc. A custom schedule sets its
+	// own rates, so its peak replaces --rate.
+	sched, err := resolveSchedule(&cfg)
 	if err != nil {
 		fatal("profile", err)
 	}
+	var profileRateFunc profile.RateFunc
+	if sched != nil {
+		profileRateFunc = sched.RateFunc()
+	} else if profileRateFunc, err = profile.Get(cfg.Profile, cfg.Rate); err != nil {
+		fatal("profile", err)
+	}
 
-	// 3. Create HTTP client.
+	var sloSet *slo.Set
+	if cfg.SLO != "" {
+		sloSet, err = slo.Load(cfg.SLO)
+		if err != nil {
+			fatal("slo", err)
+		}
+	}
+
+	// 3–4. Create HTTP client, inject account tokens, start refresher.
 	clientCfg := client.DefaultConfig(cfg.Target)
 	clientCfg.Verbose = cfg.Verbose
-	cl := client.New(clientCfg, logf)
+	cl, acctFile, refresher := connectClientWith(clientCfg, cfg.Accounts, cfg.EnvPath, cfg.ClerkURL, logf)
 	defer cl.Close()
 
-	// 4. Load and inject account tokens (if accounts file exists).
-	var acctFile *account.File
-	if _, statErr := os.Stat(cfg.Accounts); statErr == nil {
-		var loadErr error
-		acctFile, loadErr = account.Load(cfg.Accounts)
-		if loadErr != nil {
-			fmt.Printf("  %s failed to load accounts: %v (continuing without auth)\n",
-				theme.Warning.Render("warning:"), loadErr)
-		} else {
-			injected, skipped := account.InjectTokens(acctFile, cl.SetToken)
-			fmt.Printf("  Tokens:     %d injected, %d skipped\n", injected, len(skipped))
-			if len(skipped) > 0 && cfg.Verbose {
-				logf("skipped accounts: %v", skipped)
-			}
+	// Spread sessions across the signed-in accounts of each tier.
+	var pool *account.Pool
+	if cfg.AccountPool != "" {
+		if acctFile == nil {
+			fatalf("accounts", "--account-pool needs an accounts file (see pitstorm setup --per-tier)")
 		}
-	} else {
-		fmt.Printf("  %s no accounts file at %s (running without auth)\n",
-			theme.Warning.Render("note:"), cfg.Accounts)
-	}
-
-	// 4b. Start token refresher if we have accounts with session IDs.
-	var refresher *auth.Refresher
-	if acctFile != nil {
-		refresher = startTokenRefresher(acctFile, cfg.EnvPath, cl, logf)
+		pool = account.NewPool(acctFile, cfg.AccountPool, func(id string) bool {
+			_, ok := cl.GetToken(id)
+			return ok
+		})
 	}
 
 	// 5. Create action layer.
@@ -87,19 +211,105 @@ func runCmd(args []string) {
 
 	// 6. Create metrics collector.
 	m := metrics.NewCollector()
+	m.SetStallThreshold(cfg.StallThreshold)
+
+	// An A/B run sends to target B through its own client (sharing the
+	// session tokens) and records it in its own collector.
+	var targetB *engine.TargetB
+	if cfg.TargetB != "" {
+		clB := cl.Mirror(cfg.TargetB)
+		defer clB.Close()
+		targetB = &engine.TargetB{Mode: cfg.ABMode, Actor: action.New(clB), Metrics: metrics.NewCollector()}
+		targetB.Metrics.SetStallThreshold(cfg.StallThreshold)
+	}
+
+	// In adaptive mode the search drives the rate instead of the profile.
+	var adaptive *engine.Adaptive
+	if cfg.Adaptive {
+		acfg := engine.DefaultAdaptiveConfig()
+		acfg.StartRate, acfg.MaxRate = cfg.AdaptiveStart, cfg.AdaptiveMax
+		acfg.Step, acfg.Settle = cfg.AdaptiveStep, cfg.AdaptiveStep/5
+		adaptive = engine.NewAdaptive(acfg, m, adaptiveTarget(sloSet), logf)
+		profileRateFunc = adaptive.RateFunc()
+		cfg.Profile, cfg.Rate = "adaptive", cfg.AdaptiveMax
+	}
+
+	// Handle graceful shutdown on SIGINT/SIGTERM.
+	ctx, cancel := signalContext()
+	defer cancel()
 
-	// 7. Create budget gate.
+	// 7. Create budget gate. In a coordinated run the instance slot and
+	// the (global) ceiling come from the coordinator.
+	var cc *coord.Client
+	if cfg.Coordinator != "" {
+		host, _ := os.Hostname()
+		cc, err = coord.Register(ctx, cfg.Coordinator, host)
+		if err != nil {
+			fatal("coordinator", err)
+		}
+		reg := cc.Registration()
+		cfg.InstanceID, cfg.InstanceOf, cfg.Budget = reg.InstanceID, reg.InstanceOf, reg.BudgetGBP
+	}
 	gate := budget.NewGate(cfg.Budget)
+	if cc != nil {
+		gate.SetLedger(cc)
+	}
+	if err := applyBudgetLimits(gate, cfg, personas); err != nil {
+		fatal("budget", err)
+	}
+	var ledger *credits.Ledger
+	if cfg.Credits > 0 {
+		hasToken := func(id string) bool {
+			_, ok := cl.GetToken(id)
+			return ok
+		}
+		if err := checkCreditBalances(ctx, act, acctFile, hasToken, cfg.Credits); err != nil {
+			fatal("credits", err)
+		}
+		ledger = credits.NewLedger(cfg.Credits, logf)
+		if targetB != nil && targetB.Mode == engine.ABSplit {
+			if err := checkCreditBalances(ctx, targetB.Actor, acctFile, hasToken, cfg.Credits); err != nil {
+				fatal("credits (target B)", err)
+			}
+			targetB.Credits = credits.NewLedger(cfg.Credits, logf)
+		}
+	}
 
 	// Display configuration.
 	fmt.Printf("  Target:     %s\n", cfg.Target)
+	if targetB != nil {
+		fmt.Printf("  Target B:   %s (%s)\n", cfg.TargetB, describeABMode(cfg.ABMode))
+	}
 	fmt.Printf("  Profile:    %s\n", cfg.Profile)
 	fmt.Printf("  Rate:       %.1f req/s (peak)\n", cfg.Rate)
 	fmt.Printf("  Duration:   %s\n", cfg.Duration)
 	fmt.Printf("  Budget:     £%.2f\n", cfg.Budget)
+	if len(cfg.PersonaBudgets)+len(cfg.ModelBudgets) > 0 {
+		fmt.Printf("  Limits:     %s\n", formatLimits(cfg))
+	}
 	fmt.Printf("  Workers:    %d\n", cfg.Workers)
 	fmt.Printf("  Personas:   %d active\n", len(personas))
+	if cfg.Scenario != "" {
+		fmt.Printf("  Scenario:   %s\n", cfg.Scenario)
+	}
+	if pool != nil {
+		fmt.Printf("  Pool:       %s\n", pool.Describe())
+	}
+	if ledger != nil {
+		fmt.Printf("  Credits:    %g per account (checking out-of-credits refusals)\n", cfg.Credits)
+	}
 	fmt.Printf("  Instance:   %d/%d\n", cfg.InstanceID, cfg.InstanceOf)
+	if adaptive != nil {
+		target := "error rate ≤ 5%"
+		if sloSet != nil {
+			target = cfg.SLO
+		}
+		fmt.Printf("  Adaptive:   %.1f → %.1f req/s, %s steps, target %s\n",
+			cfg.AdaptiveStart, cfg.AdaptiveMax, cfg.AdaptiveStep, target)
+	}
+	if cc != nil {
+		fmt.Printf("  Run ID:     %s (coordinator %s, global budget)\n", cc.Registration().RunID, cfg.Coordinator)
+	}
 	fmt.Println()
 
 	// 8. Create and run engine.
@@ -107,40 +317,181 @@ func runCmd(args []string) {
 		fmt.Printf("  Status:     %s (live, updated every 5s)\n", cfg.StatusFile)
 	}
 
+	var jw *journal.Writer
+	if cfg.Journal != "" {
+		header := journal.Header{
+			Target:  cfg.Target,
+			Seed:    cfg.Seed,
+			Profile: cfg.Profile,
+			Rate:    cfg.Rate,
+			Workers: cfg.Workers,
+		}
+		if cfg.ABMode == engine.ABSplit {
+			header.TargetB = cfg.TargetB
+		}
+		jw, err = journal.Create(cfg.Journal, header)
+		if err != nil {
+			fatal("journal", err)
+		}
+		fmt.Printf("  Journal:    %s\n", cfg.Journal)
+	}
+	var tw *transcript.Writer
+	if cfg.Transcripts != "" {
+		tw, err = transcript.Create(cfg.Transcripts)
+		if err != nil {
+			fatal("transcripts", err)
+		}
+		fmt.Printf("  Transcript: %s\n", cfg.Transcripts)
+	}
+	var hr *har.Recorder
+	if cfg.HAR != "" {
+		hcfg := har.DefaultConfig()
+		hcfg.SuccessRate = cfg.HARSample
+		hr = har.NewRecorder(hcfg)
+		cl.SetObserver(hr.Observe)
+		fmt.Printf("  HAR:        %s (failures + %g%% of successes)\n", cfg.HAR, cfg.HARSample*100)
+	}
+	if cfg.Seed != 0 {
+		fmt.Printf("  Seed:       %d\n", cfg.Seed)
+	}
+	if cfg.MetricsAddr != "" {
+		fmt.Printf("  Metrics:    http://%s/metrics (OpenMetrics)\n", cfg.MetricsAddr)
+	}
+	if cfg.ControlAddr != "" {
+		fmt.Printf("  Control:    http://%s%s (pitstorm control --addr %s)\n", cfg.ControlAddr, control.PathStatus, cfg.ControlAddr)
+	}
+	if sloSet != nil {
+		fmt.Printf("  SLO:        %s (%d thresholds)\n", cfg.SLO, len(sloSet.Thresholds))
+	}
+
 	eng := engine.New(engine.Config{
-		Workers:    cfg.Workers,
-		Duration:   cfg.Duration,
-		RateFunc:   profileRateFunc,
-		Verbose:    cfg.Verbose,
-		StatusFile: cfg.StatusFile,
+		Workers:     cfg.Workers,
+		Duration:    cfg.Duration,
+		RateFunc:    profileRateFunc,
+		Verbose:     cfg.Verbose,
+		StatusFile:  cfg.StatusFile,
+		Seed:        cfg.Seed,
+		Journal:     jw,
+		Transcripts: tw,
+		MetricsAddr: cfg.MetricsAddr,
+		ControlAddr: cfg.ControlAddr,
+		Accounts:    pool,
+		Credits:     ledger,
+		TargetB:     targetB,
 	}, cl, act, m, gate, personas, logf)
 
-	// Handle graceful shutdown on SIGINT/SIGTERM.
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	followCtx, stopFollow := context.WithCancel(ctx)
+	defer stopFollow()
+	if cc != nil {
+		fmt.Printf("  Waiting for coordinator to start the run...\n")
+		if err := cc.WaitStart(ctx, time.Second); err != nil {
+			fatal("coordinator", err)
+		}
+		go cc.Follow(followCtx, coordHeartbeatInterval, m.Snapshot, gate, cancel, logf)
+	}
 
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		sig := <-sigCh
-		fmt.Printf("\n  %s received %v, shutting down gracefully...\n",
-			theme.Warning.Render("signal:"), sig)
-		cancel()
-	}()
+	// In adaptive mode the SLOs are the search target: breaching them is
+	// expected, so they neither abort the run nor fail it.
+	if sloSet != nil && adaptive == nil {
+		go sloSet.Watch(followCtx, sloCheckInterval, m.Snapshot, func(r slo.Result) {
+			fmt.Printf("\n  %s %s = %s exceeds %s by %.0fx or more, stopping run\n",
+				theme.Error.Render("SLO ABORT:"), r.Name, r.Format(r.Observed), r.Format(r.Limit), sloSet.AbortFactor)
+			cancel()
+		}, func(format string, a ...any) {
+			fmt.Printf("  %s\n", theme.Warning.Render(fmt.Sprintf(format, a...)))
+		})
+	}
+
+	if cfg.Timeline > 0 {
+		go m.WatchTimeline(followCtx, cfg.Timeline, func() float64 { return gate.Summary().SpentGBP })
+	}
 
 	fmt.Printf("  %s simulation started\n\n", theme.Success.Render("GO:"))
 
+	stopDash := func() {}
+	if cfg.TUI {
+		d := tui.New(tui.Config{
+			Title:    "pitstorm — " + command,
+			Target:   cfg.Target,
+			Profile:  cfg.Profile,
+			Duration: cfg.Duration,
+			Metrics:  m.Snapshot,
+			Budget:   gate.Summary,
+			Controls: eng,
+			Quit:     cancel,
+		})
+		dash.Store(d)
+		dashCtx, cancelDash := context.WithCancel(ctx)
+		dashDone := make(chan error, 1)
+		go func() { dashDone <- d.Run(dashCtx) }()
+		stopDash = func() {
+			cancelDash()
+			if err := <-dashDone; err != nil {
+				fmt.Printf("  %s %v\n", theme.Error.Render("tui:"), err)
+			}
+			dash.Store(nil)
+		}
+	}
+
 	start := time.Now()
-	if err := eng.Run(ctx); err != nil {
+	var adaptiveReport *engine.AdaptiveReport
+	if adaptive != nil {
+		// The search ends the run; the engine ending first (duration or
+		// budget) cuts the search short.
+		runCtx, stopRun := context.WithCancel(ctx)
+		engDone := make(chan error, 1)
+		go func() {
+			engDone <- eng.Run(runCtx)
+			stopRun()
+		}()
+		rep := adaptive.Run(runCtx)
+		adaptiveReport = &rep
+		stopRun()
+		err = <-engDone
+	} else {
+		err = eng.Run(ctx)
+	}
+	stopDash()
+	if err != nil {
 		fmt.Printf("\n  %s %v\n", theme.Error.Render("engine error:"), err)
 	}
 	elapsed := time.Since(start)
+	stopFollow()
 
 	// Stop token refresher.
 	if refresher != nil {
 		refresher.Stop()
 	}
 
+	if jw != nil {
+		count := jw.Count()
+		if closeErr := jw.Close(); closeErr != nil {
+			fmt.Printf("  %s %v\n", theme.Error.Render("journal:"), closeErr)
+		} else {
+			fmt.Printf("\n  Journal: %d requests recorded to %s\n", count, cfg.Journal)
+		}
+	}
+	if tw != nil {
+		total, invalid := tw.Count()
+		if closeErr := tw.Close(); closeErr != nil {
+			fmt.Printf("  %s %v\n", theme.Error.Render("transcripts:"), closeErr)
+		} else {
+			fmt.Printf("  Transcripts: %d bouts (%d invalid) captured to %s\n", total, invalid, cfg.Transcripts)
+		}
+	}
+	if hr != nil {
+		failures, successes, dropped := hr.Counts()
+		if writeErr := hr.Write(cfg.HAR); writeErr != nil {
+			fmt.Printf("  %s %v\n", theme.Error.Render("har:"), writeErr)
+		} else {
+			fmt.Printf("  HAR: %d failures and %d sampled successes written to %s", failures, successes, cfg.HAR)
+			if dropped > 0 {
+				fmt.Printf(" (%d more dropped)", dropped)
+			}
+			fmt.Println()
+		}
+	}
+
 	// 9. Print final report.
 	snap := m.Snapshot()
 	budgetSummary := gate.Summary()
@@ -150,23 +501,353 @@ func runCmd(args []string) {
 	fmt.Printf("%s\n", metrics.FormatSummary(snap))
 	fmt.Printf("%s\n", budget.FormatSummary(budgetSummary))
 
+	if adaptiveReport != nil {
+		printAdaptiveReport(*adaptiveReport)
+	}
+
+	var sloBreached bool
+	if sloSet != nil && adaptive == nil {
+		results := sloSet.Evaluate(snap)
+		printSLOResults(results)
+		sloBreached = slo.Breached(results)
+	}
+
+	run := compare.Run{Snapshot: snap, Budget: &budgetSummary, Timeline: m.Timeline()}
+	if ledger != nil {
+		rep := ledger.Report()
+		run.Credits = &rep
+		fmt.Print(credits.FormatReport(rep))
+	}
+
+	out := runOutput{Run: run, Adaptive: adaptiveReport}
+	if targetB != nil {
+		out.Target = cfg.Target
+		out.TargetB = &abTargetResult{
+			Target: cfg.TargetB, Mode: cfg.ABMode, Snapshot: targetB.Metrics.Snapshot(),
+		}
+		if targetB.Credits != nil {
+			rep := targetB.Credits.Report()
+			out.TargetB.Credits = &rep
+		}
+		printABComparison(out)
+	}
+
 	// 10. Write JSON output if requested.
 	if cfg.Output != "" {
-		jsonData, jsonErr := snap.JSON()
-		if jsonErr != nil {
-			fmt.Printf("  %s failed to marshal JSON: %v\n\n",
-				theme.Error.Render("error:"), jsonErr)
+		writeOutputJSON(cfg.Output, out)
+	}
+
+	// 11. Send the final snapshot for the coordinator's merged report.
+	if cc != nil {
+		if unsent, err := gate.FlushLedger(); err != nil {
+			fmt.Printf("  %s £%.4f of charges never reached it: %v\n", theme.Error.Render("coordinator:"), unsent, err)
+		}
+		reportCtx, reportCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer reportCancel()
+		if err := cc.Report(reportCtx, snap); err != nil {
+			fmt.Printf("  %s %v\n", theme.Error.Render("coordinator:"), err)
 		} else {
-			if writeErr := os.WriteFile(cfg.Output, jsonData, 0644); writeErr != nil {
-				fmt.Printf("  %s failed to write output: %v\n\n",
-					theme.Error.Render("error:"), writeErr)
-			} else {
-				fmt.Printf("\n  JSON output written to %s\n", cfg.Output)
+			fmt.Printf("  Final report sent to coordinator\n")
+		}
+	}
+
+	fmt.Println()
+
+	creditsFailed := run.Credits != nil && !run.Credits.Passed()
+	if out.TargetB != nil && out.TargetB.Credits != nil && !out.TargetB.Credits.Passed() {
+		creditsFailed = true
+	}
+	if sloBreached || creditsFailed {
+		os.Exit(exitCheckFailed)
+	}
+}
+
+// sloCheckInterval is how often --slo thresholds are evaluated live.
+const sloCheckInterval = 5 * time.Second
+
+// printSLOResults prints a pass/fail table for SLO results.
+func printSLOResults(results []slo.Result) {
+	fmt.Printf("\n  %s\n\n", theme.Bold.Render("SLOs"))
+	fmt.Printf("  %-36s %10s %10s  %s\n", "THRESHOLD", "LIMIT", "OBSERVED", "RESULT")
+	for _, r := range results {
+		observed, status := r.Format(r.Observed), theme.Success.Render("PASS")
+		switch {
+		case r.NoData:
+			observed, status = "—", theme.Muted.Render("NO DATA")
+		case !r.Pass:
+			status = theme.Error.Render("FAIL")
+		}
+		fmt.Printf("  %-36s %10s %10s  %s\n", r.Name, r.Format(r.Limit), observed, status)
+	}
+	if slo.Breached(results) {
+		fmt.Printf("\n  %s one or more SLOs breached\n", theme.Error.Render("FAIL:"))
+	} else {
+		fmt.Printf("\n  %s all SLOs met\n", theme.Success.Render("PASS:"))
+	}
+}
+
+// adaptiveTarget converts the SLO set into the adaptive search target:
+// a step fails when any threshold fails over its window. Without an SLO
+// file the target is an error rate of at most 5%.
+func adaptiveTarget(set *slo.Set) engine.Target {
+	if set == nil {
+		errorRate := 0.05
+		set, _ = slo.File{ErrorRate: &errorRate}.Compile()
+	}
+	return func(window metrics.Snapshot) []string {
+		var violations []string
+		for _, r := range set.Evaluate(window) {
+			if !r.Pass {
+				violations = append(violations, fmt.Sprintf("%s %s > %s", r.Name, r.Format(r.Observed), r.Format(r.Limit)))
+			}
+		}
+		return violations
+	}
+}
+
+func printAdaptiveReport(rep engine.AdaptiveReport) {
+	fmt.Printf("\n  %s\n\n", theme.Bold.Render("Adaptive search"))
+	fmt.Printf("  %4s %10s %10s  %-6s %s\n", "STEP", "RATE", "ACHIEVED", "RESULT", "VIOLATIONS")
+	for i, s := range rep.Steps {
+		status := theme.Success.Render("PASS")
+		if !s.Pass {
+			status = theme.Error.Render("FAIL")
+		}
+		fmt.Printf("  %4d %10.2f %10.2f  %s   %s\n", i+1, s.Rate, s.Achieved, status,
+			theme.Muted.Render(strings.Join(s.Violations, "; ")))
+	}
+
+	switch {
+	case rep.MaxSustainable == 0:
+		fmt.Printf("\n  %s no probed rate met the target\n", theme.Error.Render("SATURATED:"))
+	case rep.Saturated:
+		fmt.Printf("\n  %s %.2f req/s\n", theme.Accent.Render("Max sustainable:"), rep.MaxSustainable)
+	default:
+		fmt.Printf("\n  %s ≥ %.2f req/s %s\n", theme.Accent.Render("Max sustainable:"), rep.MaxSustainable,
+			theme.Muted.Render("(never saturated; raise --adaptive-max or --duration)"))
+	}
+
+	if len(rep.Knees) == 0 {
+		return
+	}
+	fmt.Printf("\n  %-32s %12s  %s\n", "ENDPOINT", "KNEE (req/s)", "DEGRADED BY")
+	for _, k := range rep.Knees {
+		knee := fmt.Sprintf("%.2f", k.Rate)
+		reason := theme.Muted.Render("never degraded")
+		switch {
+		case !k.Saturated:
+			knee = ">=" + knee
+		case k.Rate == 0:
+			knee, reason = "—", k.Reason
+		default:
+			reason = k.Reason
+		}
+		fmt.Printf("  %-32s %12s  %s\n", k.Endpoint, knee, reason)
+	}
+}
+
+// coordHeartbeatInterval is how often a coordinated instance sends its
+// snapshot and picks up the global spend and stop broadcasts.
+const coordHeartbeatInterval = 2 * time.Second
+
+// connectClient creates the HTTP client for target, injects tokens from
+// the accounts file if present, and starts the token refresher against
+// Clerk, or against clerkURL when set. The returned refresher is nil when
+// refresh is not possible.
+func connectClient(target, accountsPath, envPath, clerkURL string, verbose bool, logf func(string, ...any)) (*client.Client, *auth.Refresher) {
+	clientCfg := client.DefaultConfig(target)
+	clientCfg.Verbose = verbose
+	cl, _, refresher := connectClientWith(clientCfg, accountsPath, envPath, clerkURL, logf)
+	return cl, refresher
+}
+
+// connectClientWith is connectClient for a custom client configuration.
+// It also returns the loaded accounts file, nil if there is none.
+func connectClientWith(clientCfg client.Config, accountsPath, envPath, clerkURL string, logf func(string, ...any)) (*client.Client, *account.File, *auth.Refresher) {
+	verbose := clientCfg.Verbose
+	cl := client.New(clientCfg, logf)
+
+	var acctFile *account.File
+	if _, statErr := os.Stat(accountsPath); statErr == nil {
+		var loadErr error
+		acctFile, loadErr = account.Load(accountsPath)
+		if loadErr != nil {
+			fmt.Printf("  %s failed to load accounts: %v (continuing without auth)\n",
+				theme.Warning.Render("warning:"), loadErr)
+		} else {
+			injected, skipped := account.InjectTokens(acctFile, cl.SetToken)
+			fmt.Printf("  Tokens:     %d injected, %d skipped\n", injected, len(skipped))
+			if len(skipped) > 0 && verbose {
+				logf("skipped accounts: %v", skipped)
+			}
+		}
+	} else {
+		fmt.Printf("  %s no accounts file at %s (running without auth)\n",
+			theme.Warning.Render("note:"), accountsPath)
+	}
+
+	// Start token refresher if we have accounts with session IDs.
+	var refresher *auth.Refresher
+	if acctFile != nil {
+		refresher = startTokenRefresher(acctFile, envPath, clerkURL, cl, logf)
+	}
+	return cl, acctFile, refresher
+}
+
+func probeCmd(args []string) {
+	cfg, err := ParseProbeConfig(args)
+	if err != nil {
+		fatal("config", err)
+	}
+
+	fmt.Printf("\n%s\n\n", theme.Title.Render("pitstorm — probe"))
+
+	logf := func(format string, a ...any) {
+		if cfg.Verbose {
+			fmt.Printf("  "+format+"\n", a...)
+		}
+	}
+
+	// Probes must see every response, so nothing is retried.
+	clientCfg := client.DefaultConfig(cfg.Target)
+	clientCfg.MaxRetries = 0
+	clientCfg.Verbose = cfg.Verbose
+	cl, acctFile, refresher := connectClientWith(clientCfg, cfg.Accounts, cfg.EnvPath, cfg.ClerkURL, logf)
+	defer cl.Close()
+	if refresher != nil {
+		defer refresher.Stop()
+	}
+
+	// Probe as the first account with a token, against another account.
+	attacker, victim := "", probe.PlaceholderVictim
+	if acctFile != nil {
+		for _, a := range acctFile.Accounts {
+			if _, ok := cl.GetToken(a.ID); ok {
+				attacker = a.ID
+				break
+			}
+		}
+		for _, a := range acctFile.Accounts {
+			if a.ID != attacker && a.ClerkUserID != "" {
+				victim = a.ClerkUserID
+				break
 			}
 		}
 	}
 
-	fmt.Println()
+	probes := probe.Filter(probe.Catalogue(victim), cfg.Kinds)
+	fmt.Printf("  Target:     %s\n", cfg.Target)
+	fmt.Printf("  Probes:     %d\n", len(probes))
+	if attacker != "" {
+		fmt.Printf("  Account:    %s\n", attacker)
+	} else {
+		fmt.Printf("  Account:    anonymous\n")
+	}
+	if victim == probe.PlaceholderVictim || attacker == "" {
+		fmt.Printf("  Victim:     %s %s\n", victim, theme.Muted.Render("(IDOR probes skipped)"))
+	} else {
+		fmt.Printf("  Victim:     %s\n", victim)
+	}
+	fmt.Println()
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	rep := probe.NewRunner(cl, probe.Config{Account: attacker, Victim: victim, Burst: cfg.Burst}, logf).Run(ctx, probes)
+	rep.Target = cfg.Target
+
+	fmt.Printf("\n%s\n\n", theme.Title.Render("pitstorm — findings"))
+	fmt.Print(probe.FormatReport(rep))
+
+	if cfg.Output != "" {
+		writeOutputJSON(cfg.Output, rep)
+	}
+
+	findings := rep.Findings()
+	if len(findings) == 0 {
+		fmt.Printf("\n  %s no findings\n\n", theme.Success.Render("OK:"))
+		return
+	}
+	fmt.Printf("\n  %s %d finding(s)\n\n", theme.Error.Render("FINDINGS:"), len(findings))
+	if cfg.FailOnFinding {
+		os.Exit(exitCheckFailed)
+	}
+}
+
+// signalContext returns a context cancelled on SIGINT/SIGTERM.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigCh
+		fmt.Printf("\n  %s received %v, shutting down gracefully...\n",
+			theme.Warning.Render("signal:"), sig)
+		cancel()
+	}()
+	return ctx, cancel
+}
+
+// writeSnapshotJSON writes the metrics snapshot and budget summary to
+// path (see compare.Run), reporting the outcome.
+func writeSnapshotJSON(path string, snap metrics.Snapshot, budgetSummary budget.Summary) {
+	writeOutputJSON(path, compare.Run{Snapshot: snap, Budget: &budgetSummary})
+}
+
+// runOutput is the run output file: a regular run file (still readable
+// by report and compare) plus the adaptive search results and target
+// B's metrics of runs that have them. One struct carries both so that
+// neither can be dropped in favour of the other.
+type runOutput struct {
+	compare.Run
+	Adaptive *engine.AdaptiveReport `json:"adaptive,omitempty"`
+	Target   string                 `json:"target,omitempty"`
+	TargetB  *abTargetResult        `json:"targetB,omitempty"`
+}
+
+// abTargetResult is target B's part of an A/B run.
+type abTargetResult struct {
+	Target   string           `json:"target"`
+	Mode     engine.ABMode    `json:"mode"`
+	Snapshot metrics.Snapshot `json:"snapshot"`
+	// Credits is target B's credit report, in split mode with --credits.
+	Credits *credits.Report `json:"credits,omitempty"`
+}
+
+// describeABMode explains how an A/B run divides its traffic.
+func describeABMode(m engine.ABMode) string {
+	if m == engine.ABSplit {
+		return "split: each session goes to one target"
+	}
+	return "mirror: every action goes to both targets"
+}
+
+// printABComparison prints an A/B run's two targets side by side.
+func printABComparison(ab runOutput) {
+	fmt.Printf("\n%s\n", theme.Title.Render("pitstorm — A/B comparison"))
+	fmt.Printf("\n  A: %s\n  B: %s (%s)\n", ab.Target, ab.TargetB.Target, ab.TargetB.Mode)
+	fmt.Printf("%s\n", metrics.FormatSideBySide("A", "B", ab.Snapshot, ab.TargetB.Snapshot))
+	if ab.TargetB.Credits != nil {
+		fmt.Printf("\n  Target B:")
+		fmt.Print(credits.FormatReport(*ab.TargetB.Credits))
+	}
+}
+
+// writeOutputJSON writes v as indented JSON to path, reporting the outcome.
+func writeOutputJSON(path string, v any) {
+	jsonData, jsonErr := json.MarshalIndent(v, "", "  ")
+	if jsonErr != nil {
+		fmt.Printf("  %s failed to marshal JSON: %v\n\n",
+			theme.Error.Render("error:"), jsonErr)
+		return
+	}
+	if writeErr := os.WriteFile(path, jsonData, 0644); writeErr != nil {
+		fmt.Printf("  %s failed to write output: %v\n\n",
+			theme.Error.Render("error:"), writeErr)
+		return
+	}
+	fmt.Printf("\n  JSON output written to %s\n", path)
 }
 
 func planCmd(args []string) {
@@ -178,13 +859,23 @@ func planCmd(args []string) {
 	fmt.Printf("\n%s\n\n", theme.Title.Render("pitstorm — plan (dry run)"))
 
 	// Resolve personas.
-	personas, err := persona.Resolve(cfg.Personas)
+	personas, err := resolvePersonas(cfg)
 	if err != nil {
 		fatal("personas", err)
 	}
+	if err := applyBudgetLimits(budget.NewGate(cfg.Budget), cfg, personas); err != nil {
+		fatal("budget", err)
+	}
 
 	// Resolve profile description.
+	sched, err := resolveSchedule(&cfg)
+	if err != nil {
+		fatal("profile", err)
+	}
 	profileDesc := profile.Describe(cfg.Profile)
+	if sched != nil {
+		profileDesc = sched.Describe()
+	}
 
 	// Display configuration.
 	fmt.Printf("  Target:     %s\n", cfg.Target)
@@ -192,8 +883,14 @@ func planCmd(args []string) {
 	fmt.Printf("  Rate:       %.1f req/s (peak)\n", cfg.Rate)
 	fmt.Printf("  Duration:   %s\n", cfg.Duration)
 	fmt.Printf("  Budget:     £%.2f\n", cfg.Budget)
+	if len(cfg.PersonaBudgets)+len(cfg.ModelBudgets) > 0 {
+		fmt.Printf("  Limits:     %s\n", formatLimits(cfg))
+	}
 	fmt.Printf("  Workers:    %d\n", cfg.Workers)
 	fmt.Printf("  Personas:   %d active\n", len(personas))
+	if cfg.Scenario != "" {
+		fmt.Printf("  Scenario:   %s\n", cfg.Scenario)
+	}
 	fmt.Printf("  Instance:   %d/%d\n\n", cfg.InstanceID, cfg.InstanceOf)
 
 	if profileDesc != "" {
@@ -222,6 +919,9 @@ func planCmd(args []string) {
 
 	durationMin := cfg.Duration.Minutes()
 	avgRateFraction := profileAvgFraction(cfg.Profile, cfg.Rate)
+	if sched != nil {
+		avgRateFraction = sched.Mean(cfg.Duration) / sched.Peak()
+	}
 	effectiveAvgRate := cfg.Rate * avgRateFraction
 
 	totalRequests := effectiveAvgRate * durationMin * 60
@@ -287,6 +987,19 @@ func planCmd(args []string) {
 	}
 }
 
+// resolvePersonas applies the --personas filter to the built-in personas,
+// or to the pool defined by the --scenario file when one is given.
+func resolvePersonas(cfg RunConfig) ([]*persona.Spec, error) {
+	if cfg.Scenario == "" {
+		return persona.Resolve(cfg.Personas)
+	}
+	sc, err := persona.LoadScenario(cfg.Scenario)
+	if err != nil {
+		return nil, err
+	}
+	return persona.ResolveFrom(sc.Specs(), cfg.Personas)
+}
+
 // boutActionPercent returns the percentage of a persona's actions that are bouts.
 func boutActionPercent(p *persona.Spec) float64 {
 	var totalWeight, boutWeight float64
@@ -318,6 +1031,28 @@ func shortenModel(model string) string {
 	}
 }
 
+// resolveSchedule loads the custom rate schedule selected by cfg, or
+// returns nil for a built-in profile. For a schedule, cfg.Rate becomes
+// its peak and a file's path is shown as the profile name.
+func resolveSchedule(cfg *RunConfig) (*profile.Schedule, error) {
+	var sched *profile.Schedule
+	var err error
+	switch {
+	case cfg.ProfileFile != "":
+		sched, err = profile.LoadSchedule(cfg.ProfileFile)
+		cfg.Profile = cfg.ProfileFile
+	case profile.IsScheduleSpec(cfg.Profile):
+		sched, err = profile.ParseSchedule(cfg.Profile)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cfg.Rate = sched.Peak()
+	return sched, nil
+}
+
 // profileAvgFraction returns the average rate as a fraction of peak for each profile.
 // These are analytically derived from the rate curve integrals. The peakRate
 // parameter is needed for trickle, which is capped at 1-2 req/s regardless of peak.
@@ -369,6 +1104,8 @@ func setupCmd(args []string) {
 	force := false
 	secretKey := ""
 	envPath := ""
+	clerkURL := ""
+	perTier := 0
 
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
@@ -398,6 +1135,22 @@ func setupCmd(args []string) {
 			}
 			i++
 			envPath = args[i]
+		case "--clerk-url":
+			if i+1 >= len(args) {
+				fatalf("setup", "--clerk-url requires a value")
+			}
+			i++
+			clerkURL = args[i]
+		case "--per-tier":
+			if i+1 >= len(args) {
+				fatalf("setup", "--per-tier requires a value")
+			}
+			i++
+			n, err := strconv.Atoi(args[i])
+			if err != nil || n < 1 {
+				fatalf("setup", "--per-tier must be a positive integer, got %q", args[i])
+			}
+			perTier = n
 		default:
 			fatalf("setup", "unknown flag %q", args[i])
 		}
@@ -410,27 +1163,26 @@ func setupCmd(args []string) {
 		}
 	}
 
-	// Generate default accounts.
+	// Generate default accounts, plus a pool per tier if asked.
 	f := account.DefaultAccounts(target)
+	if perTier > 0 {
+		f = account.PoolAccounts(target, perTier)
+	}
 	if err := f.Validate(); err != nil {
 		fatal("setup", err)
 	}
 
 	// Resolve Clerk secret key for user provisioning.
-	if secretKey == "" {
-		secretKey = os.Getenv("CLERK_SECRET_KEY")
-	}
-	if secretKey == "" {
-		envCfg, _ := config.Load(envPath)
-		if envCfg != nil {
-			secretKey = envCfg.Get("CLERK_SECRET_KEY")
-		}
-	}
+	secretKey = resolveSecretKey(secretKey, envPath, clerkURL)
 
 	// If we have a secret key, create users in Clerk.
 	if secretKey != "" {
-		fmt.Printf("  Provisioning users in Clerk...\n\n")
-		backend := auth.NewBackendClient(secretKey)
+		if clerkURL != "" {
+			fmt.Printf("  Provisioning users in Clerk at %s...\n\n", clerkURL)
+		} else {
+			fmt.Printf("  Provisioning users in Clerk...\n\n")
+		}
+		backend := newBackendClient(secretKey, clerkURL)
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 		defer cancel()
 
@@ -480,8 +1232,11 @@ func setupCmd(args []string) {
 	fmt.Printf("  Generated %d accounts for %s\n", len(f.Accounts), target)
 	fmt.Printf("  Written to %s\n\n", outputPath)
 	fmt.Printf("%s\n", account.Summary(f))
-	fmt.Printf("  Next: run %s to sign in and obtain tokens.\n\n",
-		theme.Accent.Render("pitstorm login --accounts "+outputPath))
+	next := "pitstorm login --accounts " + outputPath
+	if clerkURL != "" {
+		next += " --clerk-url " + clerkURL
+	}
+	fmt.Printf("  Next: run %s to sign in and obtain tokens.\n\n", theme.Accent.Render(next))
 }
 
 func loginCmd(args []string) {
@@ -492,6 +1247,7 @@ func loginCmd(args []string) {
 	publishableKey := ""
 	secretKey := ""
 	envPath := ""
+	clerkURL := ""
 
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
@@ -519,6 +1275,12 @@ func loginCmd(args []string) {
 			}
 			i++
 			envPath = args[i]
+		case "--clerk-url":
+			if i+1 >= len(args) {
+				fatalf("login", "--clerk-url requires a value")
+			}
+			i++
+			clerkURL = args[i]
 		default:
 			fatalf("login", "unknown flag %q", args[i])
 		}
@@ -544,20 +1306,15 @@ func loginCmd(args []string) {
 	if publishableKey == "" && cfg != nil {
 		publishableKey = cfg.Get("NEXT_PUBLIC_CLERK_PUBLISHABLE_KEY")
 	}
-	if publishableKey == "" {
+	if publishableKey == "" && clerkURL == "" {
 		fatalf("login", "Clerk publishable key not found. Set NEXT_PUBLIC_CLERK_PUBLISHABLE_KEY or use --key")
 	}
 
 	// Resolve secret key from flag, env, or config file.
-	if secretKey == "" {
-		secretKey = os.Getenv("CLERK_SECRET_KEY")
-	}
-	if secretKey == "" && cfg != nil {
-		secretKey = cfg.Get("CLERK_SECRET_KEY")
-	}
+	secretKey = resolveSecretKey(secretKey, envPath, clerkURL)
 
 	// Create FAPI client.
-	fapiClient, err := auth.NewClient(publishableKey)
+	fapiClient, fapiURL, err := newFAPIClient(publishableKey, clerkURL)
 	if err != nil {
 		fatal("login", err)
 	}
@@ -565,10 +1322,9 @@ func loginCmd(args []string) {
 	// Create Backend client if secret key is available.
 	var backendClient *auth.BackendClient
 	if secretKey != "" {
-		backendClient = auth.NewBackendClient(secretKey)
+		backendClient = newBackendClient(secretKey, clerkURL)
 	}
 
-	fapiURL, _ := auth.DecodeFAPIURL(publishableKey)
 	mode := "email+password"
 	if backendClient != nil {
 		mode = "ticket (via Backend API sign-in tokens)"
@@ -780,10 +1536,12 @@ func verifyCmd(args []string) {
 
 // startTokenRefresher creates and starts a background Refresher that keeps
 // Clerk JWTs alive during the simulation. Returns nil if refresh is not
-// possible (no publishable key, no session IDs).
+// possible (no publishable key, no session IDs). clerkURL, if set,
+// replaces both Clerk APIs and makes the publishable key unnecessary.
 func startTokenRefresher(
 	acctFile *account.File,
 	envPath string,
+	clerkURL string,
 	cl *client.Client,
 	logf func(string, ...any),
 ) *auth.Refresher {
@@ -813,29 +1571,24 @@ func startTokenRefresher(
 
 	// Resolve keys.
 	publishableKey := os.Getenv("NEXT_PUBLIC_CLERK_PUBLISHABLE_KEY")
-	secretKey := os.Getenv("CLERK_SECRET_KEY")
-	if publishableKey == "" || secretKey == "" {
+	if publishableKey == "" {
 		envCfg, _ := config.Load(envPath)
 		if envCfg != nil {
-			if publishableKey == "" {
-				publishableKey = envCfg.Get("NEXT_PUBLIC_CLERK_PUBLISHABLE_KEY")
-			}
-			if secretKey == "" {
-				secretKey = envCfg.Get("CLERK_SECRET_KEY")
-			}
+			publishableKey = envCfg.Get("NEXT_PUBLIC_CLERK_PUBLISHABLE_KEY")
 		}
 	}
-	if publishableKey == "" || secretKey == "" {
+	secretKey := resolveSecretKey("", envPath, clerkURL)
+	if (publishableKey == "" && clerkURL == "") || secretKey == "" {
 		logf("[refresh] missing CLERK keys — skipping token refresh")
 		return nil
 	}
 
-	fapiClient, err := auth.NewClient(publishableKey)
+	fapiClient, _, err := newFAPIClient(publishableKey, clerkURL)
 	if err != nil {
 		logf("[refresh] failed to create FAPI client: %v", err)
 		return nil
 	}
-	backendClient := auth.NewBackendClient(secretKey)
+	backendClient := newBackendClient(secretKey, clerkURL)
 
 	// Refresh every 45 seconds (Clerk JWTs expire at ~60s).
 	// Use Backend API (ticket flow) instead of FAPI cookie-based refresh,
@@ -862,6 +1615,49 @@ func startTokenRefresher(
 	return refresher
 }
 
+// resolveSecretKey returns the Clerk secret key: flagKey when given,
+// otherwise fakeclerk's default when clerkURL is set, so that a real
+// key is never sent to another host, otherwise CLERK_SECRET_KEY from
+// the environment or the .env file at envPath.
+func resolveSecretKey(flagKey, envPath, clerkURL string) string {
+	switch {
+	case flagKey != "":
+		return flagKey
+	case clerkURL != "":
+		return fakeclerk.DefaultSecretKey
+	}
+	if key := os.Getenv("CLERK_SECRET_KEY"); key != "" {
+		return key
+	}
+	if envCfg, _ := config.Load(envPath); envCfg != nil {
+		return envCfg.Get("CLERK_SECRET_KEY")
+	}
+	return ""
+}
+
+// newFAPIClient creates the Clerk Frontend API client and returns its
+// URL: clerkURL if set, otherwise the one encoded in publishableKey.
+func newFAPIClient(publishableKey, clerkURL string) (*auth.Client, string, error) {
+	if clerkURL != "" {
+		return auth.NewClientWithURL(clerkURL), clerkURL, nil
+	}
+	fapiURL, err := auth.DecodeFAPIURL(publishableKey)
+	if err != nil {
+		return nil, "", err
+	}
+	c, err := auth.NewClient(publishableKey)
+	return c, fapiURL, err
+}
+
+// newBackendClient creates the Clerk Backend API client, against
+// clerkURL if set.
+func newBackendClient(secretKey, clerkURL string) *auth.BackendClient {
+	if clerkURL != "" {
+		return auth.NewBackendClientWithURL(secretKey, clerkURL)
+	}
+	return auth.NewBackendClient(secretKey)
+}
+
 func reportCmd(args []string) {
 	fmt.Printf("\n%s\n\n", theme.Title.Render("pitstorm — report"))
 
@@ -876,11 +1672,504 @@ func reportCmd(args []string) {
 		fatal("report", fmt.Errorf("read %s: %w", filePath, err))
 	}
 
-	var snap metrics.Snapshot
-	if err := json.Unmarshal(data, &snap); err != nil {
+	// Regular run files decode with no adaptive results or target B.
+	var run runOutput
+	if err := json.Unmarshal(data, &run); err != nil {
 		fatal("report", fmt.Errorf("parse JSON: %w", err))
 	}
 
 	fmt.Printf("  Source: %s\n", filePath)
+	fmt.Printf("%s\n", metrics.FormatSummary(run.Snapshot))
+	if run.Budget != nil {
+		fmt.Printf("%s\n", budget.FormatSummary(*run.Budget))
+	}
+	if run.Credits != nil {
+		fmt.Print(credits.FormatReport(*run.Credits))
+	}
+	if len(run.Timeline) > 0 {
+		fmt.Print(metrics.FormatTimeline(run.Timeline, timelineWidth))
+	}
+	if run.Adaptive != nil {
+		printAdaptiveReport(*run.Adaptive)
+	}
+	if run.TargetB != nil {
+		printABComparison(run)
+	}
+}
+
+// timelineWidth is the most columns a `report` timeline chart uses.
+const timelineWidth = 60
+
+func compareCmd(args []string) {
+	cfg, err := ParseCompareConfig(args)
+	if err != nil {
+		fatal("config", err)
+	}
+
+	fmt.Printf("\n%s\n\n", theme.Title.Render("pitstorm — compare"))
+
+	baseline, err := compare.Load(cfg.Baseline)
+	if err != nil {
+		fatal("compare", err)
+	}
+	candidate, err := compare.Load(cfg.Candidate)
+	if err != nil {
+		fatal("compare", err)
+	}
+
+	rep := compare.Compare(baseline, candidate, compare.Options{
+		Alpha:        cfg.Alpha,
+		MinRelChange: cfg.MinChange,
+		MinSamples:   cfg.MinSamples,
+	})
+
+	fmt.Printf("  Baseline:   %s (%d requests)\n", cfg.Baseline, baseline.Requests)
+	fmt.Printf("  Candidate:  %s (%d requests)\n", cfg.Candidate, candidate.Requests)
+	fmt.Printf("  Flagging:   changes >= %.0f%% with p < %g\n", cfg.MinChange*100, cfg.Alpha)
+
+	fmt.Printf("\n  %s\n\n", theme.Bold.Render("Overview"))
+	printDeltaHeader("METRIC")
+	for _, d := range rep.Overview {
+		printDelta(d.Metric, d)
+	}
+	for _, section := range []struct {
+		title  string
+		groups []compare.EndpointDelta
+	}{
+		{"Latency", rep.Latency},
+		{"First Byte (TTFB)", rep.FirstByte},
+	} {
+		if len(section.groups) == 0 {
+			continue
+		}
+		fmt.Printf("\n  %s\n\n", theme.Bold.Render(section.title))
+		printDeltaHeader("ENDPOINT")
+		for _, g := range section.groups {
+			for _, d := range g.Deltas {
+				printDelta(g.Endpoint+" "+d.Metric, d)
+			}
+		}
+	}
+
+	regs := rep.Regressions()
+	if len(regs) == 0 {
+		fmt.Printf("\n  %s no significant regressions\n\n", theme.Success.Render("OK:"))
+		return
+	}
+	fmt.Printf("\n  %s %d significant regression(s)\n\n", theme.Error.Render("REGRESSED:"), len(regs))
+	if cfg.FailOnRegression {
+		os.Exit(exitCheckFailed)
+	}
+}
+
+func printDeltaHeader(label string) {
+	fmt.Printf("  %-36s %12s %12s %9s %8s  %s\n", label, "BASELINE", "CANDIDATE", "CHANGE", "P", "")
+}
+
+// printDelta prints one comparison row, colouring flagged deltas.
+func printDelta(label string, d compare.Delta) {
+	change := "—"
+	if !math.IsNaN(d.RelChange) {
+		change = fmt.Sprintf("%+.1f%%", d.RelChange*100)
+	}
+	p := "—"
+	switch {
+	case math.IsNaN(d.PValue):
+	case d.PValue < 0.001:
+		p = "<0.001"
+	default:
+		p = fmt.Sprintf("%.3f", d.PValue)
+	}
+	verdict := ""
+	switch d.Verdict {
+	case compare.Regression:
+		verdict = theme.Error.Render("REGRESSION")
+	case compare.Improvement:
+		verdict = theme.Success.Render("improved")
+	}
+	fmt.Printf("  %-36s %12s %12s %9s %8s  %s\n",
+		label, formatDeltaValue(d.Unit, d.Baseline), formatDeltaValue(d.Unit, d.Candidate), change, p, verdict)
+}
+
+func formatDeltaValue(unit string, v float64) string {
+	switch unit {
+	case "ms":
+		return fmt.Sprintf("%.0fms", v)
+	case "%":
+		return fmt.Sprintf("%.2f%%", v)
+	case "GBP":
+		return fmt.Sprintf("£%.4f", v)
+	default:
+		return fmt.Sprintf("%.1f %s", v, unit)
+	}
+}
+
+func replayCmd(args []string) {
+	cfg, err := ParseReplayConfig(args)
+	if err != nil {
+		fatal("config", err)
+	}
+
+	fmt.Printf("\n%s\n\n", theme.Title.Render("pitstorm — replay"))
+
+	logf := func(format string, a ...any) {
+		if cfg.Verbose {
+			fmt.Printf("  "+format+"\n", a...)
+		}
+	}
+
+	header, entries, err := journal.Read(cfg.Journal)
+	if err != nil {
+		fatal("replay", err)
+	}
+	target := cfg.Target
+	if target == "" {
+		target = header.Target
+	}
+
+	cl, refresher := connectClient(target, cfg.Accounts, cfg.EnvPath, cfg.ClerkURL, cfg.Verbose, logf)
+	defer cl.Close()
+
+	m := metrics.NewCollector()
+	gate := budget.NewGate(cfg.Budget)
+
+	speed := "no delays"
+	if cfg.Speed > 0 {
+		speed = fmt.Sprintf("%gx", cfg.Speed)
+	}
+	fmt.Printf("  Journal:    %s (%d requests, recorded %s)\n",
+		cfg.Journal, len(entries), header.StartedAt.Format(time.RFC3339))
+	if header.Seed != 0 {
+		fmt.Printf("  Seed:       %d\n", header.Seed)
+	}
+	fmt.Printf("  Target:     %s\n", target)
+	if header.TargetB != "" {
+		b := 0
+		for _, e := range entries {
+			if e.Target == journal.TargetTagB {
+				b++
+			}
+		}
+		fmt.Printf("  Target B:   %d requests were recorded against %s; all are replayed against %s\n",
+			b, header.TargetB, target)
+	}
+	fmt.Printf("  Speed:      %s\n", speed)
+	fmt.Printf("  Budget:     £%.2f\n", cfg.Budget)
+	if cfg.KeepIDs {
+		fmt.Printf("  Bout IDs:   recorded\n")
+	}
+	fmt.Println()
+
+	r := engine.NewReplayer(engine.ReplayConfig{
+		Speed:   cfg.Speed,
+		KeepIDs: cfg.KeepIDs,
+	}, action.New(cl), m, gate, logf)
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	fmt.Printf("  %s replay started\n\n", theme.Success.Render("GO:"))
+
+	start := time.Now()
+	if err := r.Run(ctx, entries); err != nil {
+		fmt.Printf("\n  %s %v\n", theme.Error.Render("replay error:"), err)
+	}
+	elapsed := time.Since(start)
+
+	if refresher != nil {
+		refresher.Stop()
+	}
+
+	snap := m.Snapshot()
+	fmt.Printf("\n%s\n", theme.Title.Render("pitstorm — results"))
+	fmt.Printf("\n  Replay completed in %s\n", elapsed.Truncate(time.Millisecond))
+	fmt.Printf("%s\n", metrics.FormatSummary(snap))
+	budgetSummary := gate.Summary()
+	fmt.Printf("%s\n", budget.FormatSummary(budgetSummary))
+
+	if cfg.Output != "" {
+		writeSnapshotJSON(cfg.Output, snap, budgetSummary)
+	}
+
+	fmt.Println()
+}
+
+func mockServerCmd(args []string) {
+	cfg, err := ParseMockServerConfig(args)
+	if err != nil {
+		fatal("config", err)
+	}
+
+	fmt.Printf("\n%s\n\n", theme.Title.Render("pitstorm — mock-server"))
+
+	logf := func(format string, a ...any) {
+		if cfg.Verbose {
+			fmt.Printf("  "+format+"\n", a...)
+		}
+	}
+
+	mock := mockserver.New(mockserver.Config{
+		Latency:       cfg.Latency,
+		Jitter:        cfg.Jitter,
+		ErrorRate:     cfg.ErrorRate,
+		RateLimitRate: cfg.RateLimitRate,
+		DeltaDelay:    cfg.DeltaDelay,
+		DeltasPerTurn: cfg.DeltasPerTurn,
+		StreamUsage:   cfg.StreamUsage,
+		RequireAuth:   cfg.RequireAuth,
+		Credits:       cfg.Credits,
+	}, logf)
+	srv := &http.Server{Addr: cfg.Addr, Handler: mock}
+
+	fmt.Printf("  Listening:  http://%s\n", cfg.Addr)
+	fmt.Printf("  Latency:    %s (+ up to %s jitter)\n", cfg.Latency, cfg.Jitter)
+	fmt.Printf("  Errors:     %.1f%% 500, %.1f%% 429\n", cfg.ErrorRate*100, cfg.RateLimitRate*100)
+	fmt.Printf("  Stream:     %d deltas/turn, %s apart\n", cfg.DeltasPerTurn, cfg.DeltaDelay)
+	if cfg.StreamUsage {
+		fmt.Printf("  Usage:      token counts reported after each turn (data-usage)\n")
+	}
+	if cfg.RequireAuth {
+		fmt.Printf("  Auth:       bearer token required on authenticated endpoints\n")
+	}
+	if cfg.Credits > 0 {
+		fmt.Printf("  Credits:    %g per bearer token, bouts refused with 402 once spent\n", cfg.Credits)
+	}
+	fmt.Printf("\n  Point pitstorm at it with %s\n\n",
+		theme.Accent.Render("pitstorm run --target http://"+cfg.Addr))
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-errCh:
+		fatal("mock-server", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	srv.Shutdown(shutdownCtx)
+
+	st := mock.Stats()
+	fmt.Printf("\n  Served %d requests (%d bouts), injected %d errors and %d rate limits",
+		st.Requests, st.Bouts, st.Errors, st.RateLimits)
+	if cfg.Credits > 0 {
+		fmt.Printf(", refused %d bouts for lack of credits", st.Refusals)
+	}
+	fmt.Printf("\n\n")
+}
+
+func fakeClerkCmd(args []string) {
+	cfg, err := ParseFakeClerkConfig(args)
+	if err != nil {
+		fatal("config", err)
+	}
+
+	fmt.Printf("\n%s\n\n", theme.Title.Render("pitstorm — fake-clerk"))
+
+	logf := func(format string, a ...any) {
+		if cfg.Verbose {
+			fmt.Printf("  "+format+"\n", a...)
+		}
+	}
+
+	fake := fakeclerk.New(fakeclerk.Config{
+		TokenTTL:      cfg.TokenTTL,
+		SecretKey:     cfg.SecretKey,
+		ErrorRate:     cfg.ErrorRate,
+		RateLimitRate: cfg.RateLimitRate,
+	}, logf)
+	srv := &http.Server{Addr: cfg.Addr, Handler: fake}
+
+	url := "http://" + cfg.Addr
+	fmt.Printf("  Listening:  %s (Frontend and Backend API)\n", url)
+	fmt.Printf("  Tokens:     expire after %s\n", cfg.TokenTTL)
+	fmt.Printf("  Errors:     %.1f%% 500, %.1f%% 429\n", cfg.ErrorRate*100, cfg.RateLimitRate*100)
+	if cfg.SecretKey != "" {
+		fmt.Printf("  Auth:       Backend API requires secret key %s\n", cfg.SecretKey)
+	}
+	fmt.Printf("\n  Provision and sign in against it with\n")
+	fmt.Printf("    %s\n", theme.Accent.Render("pitstorm setup --clerk-url "+url))
+	fmt.Printf("    %s\n", theme.Accent.Render("pitstorm login --clerk-url "+url))
+	fmt.Printf("  and pass the same --clerk-url to run for token refresh.\n\n")
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-errCh:
+		fatal("fake-clerk", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	srv.Shutdown(shutdownCtx)
+
+	st := fake.Stats()
+	fmt.Printf("\n  Served %d requests: %d users, %d tickets, %d sign-ins, %d tokens; injected %d failures\n\n",
+		st.Requests, st.Users, st.Tickets, st.SignIns, st.Tokens, st.Failures)
+}
+
+func coordinatorCmd(args []string) {
+	cfg, err := ParseCoordinatorConfig(args)
+	if err != nil {
+		fatal("config", err)
+	}
+
+	fmt.Printf("\n%s\n\n", theme.Title.Render("pitstorm — coordinator"))
+
+	logf := func(format string, a ...any) {
+		if cfg.Verbose {
+			fmt.Printf("  "+format+"\n", a...)
+		}
+	}
+
+	co := coord.New(coord.Config{
+		Instances:  cfg.Instances,
+		BudgetGBP:  cfg.Budget,
+		StartDelay: cfg.StartDelay,
+	}, logf)
+	srv := &http.Server{Addr: cfg.Listen, Handler: co.Handler()}
+
+	joinAddr := cfg.Listen
+	if strings.HasPrefix(joinAddr, ":") {
+		host, _ := os.Hostname()
+		joinAddr = host + joinAddr
+	}
+	fmt.Printf("  Listening:  %s\n", cfg.Listen)
+	fmt.Printf("  Run ID:     %s\n", co.RunID())
+	fmt.Printf("  Instances:  %d\n", cfg.Instances)
+	fmt.Printf("  Budget:     £%.2f (global)\n", cfg.Budget)
+	fmt.Printf("\n  Join with %s\n\n",
+		theme.Accent.Render("pitstorm run --coordinator http://"+joinAddr))
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-errCh:
+		fatal("coordinator", err)
+	case <-co.Done():
+	case <-ctx.Done():
+		co.Stop()
+		fmt.Printf("\n  %s stop broadcast, waiting up to %s for final reports\n",
+			theme.Warning.Render("STOP:"), cfg.ReportTimeout)
+		select {
+		case <-co.Done():
+		case <-time.After(cfg.ReportTimeout):
+			fmt.Printf("  %s using last heartbeat for instances that did not report\n",
+				theme.Warning.Render("timeout:"))
+		}
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	srv.Shutdown(shutdownCtx)
+
+	snap, budgetSummary := co.Report()
+
+	fmt.Printf("\n%s\n\n", theme.Title.Render("pitstorm — merged results"))
+	fmt.Printf("  %-4s %-24s %10s %8s %10s %s\n", "ID", "HOST", "REQUESTS", "ERRORS", "SPENT", "REPORT")
+	for _, inst := range co.Instances() {
+		status := theme.Success.Render("final")
+		if !inst.Final {
+			status = theme.Warning.Render("heartbeat")
+		}
+		fmt.Printf("  %-4d %-24s %10d %8d %10s %s\n",
+			inst.ID, inst.Host, inst.Snapshot.Requests, inst.Snapshot.Errors, fmt.Sprintf("£%.4f", inst.SpentGBP), status)
+	}
 	fmt.Printf("%s\n", metrics.FormatSummary(snap))
+	fmt.Printf("%s\n", budget.FormatSummary(budgetSummary))
+
+	if cfg.Output != "" {
+		writeSnapshotJSON(cfg.Output, snap, budgetSummary)
+	}
+	fmt.Println()
+}
+
+func controlCmd(args []string) {
+	cfg, err := ParseControlConfig(args)
+	if err != nil {
+		fatal("config", err)
+	}
+	c := control.NewClient(cfg.Addr)
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if cfg.Action == "snapshot" {
+		snap, err := c.Snapshot(ctx)
+		if err != nil {
+			fatal("control", err)
+		}
+		fmt.Printf("\n%s\n", theme.Title.Render("pitstorm — snapshot"))
+		fmt.Printf("%s\n", metrics.FormatSummary(snap.Metrics))
+		fmt.Printf("%s\n", budget.FormatSummary(snap.Budget))
+		if cfg.Output != "" {
+			writeSnapshotJSON(cfg.Output, snap.Metrics, snap.Budget)
+		}
+		fmt.Println()
+		return
+	}
+
+	var st control.Status
+	switch cfg.Action {
+	case "status":
+		st, err = c.Status(ctx)
+	case "pause":
+		st, err = c.Pause(ctx)
+	case "resume":
+		st, err = c.Resume(ctx)
+	case "rate":
+		st, err = c.SetRate(ctx, cfg.Value)
+	case "enable", "disable":
+		st, err = c.SetPersona(ctx, cfg.Arg, cfg.Action == "enable")
+	case "budget":
+		st, err = c.RaiseBudget(ctx, cfg.Value)
+	}
+	if err != nil {
+		fatal("control", err)
+	}
+	printControlStatus(st)
+}
+
+// printControlStatus prints a run's controllable state.
+func printControlStatus(st control.Status) {
+	fmt.Printf("\n%s\n\n", theme.Title.Render("pitstorm — control"))
+	state := theme.StatusOK.Render("running")
+	if st.Paused {
+		state = theme.StatusWarn.Render("paused")
+	}
+	fmt.Printf("  State:      %s\n", state)
+	switch {
+	case st.RateOverrideRPS > 0:
+		fmt.Printf("  Rate:       %.1f req/s (set manually)\n", st.RateOverrideRPS)
+	case st.Unlimited:
+		fmt.Printf("  Rate:       unlimited\n")
+	default:
+		fmt.Printf("  Rate:       %.1f req/s (profile)\n", st.RateRPS)
+	}
+	if st.Budget.CeilingGBP > 0 {
+		fmt.Printf("  Budget:     £%.4f / £%.2f\n", st.Budget.SpentGBP, st.Budget.CeilingGBP)
+	} else {
+		fmt.Printf("  Budget:     £%.4f (unlimited)\n", st.Budget.SpentGBP)
+	}
+	fmt.Printf("  Personas:\n")
+	for _, p := range st.Personas {
+		on := theme.StatusOK.Render("enabled")
+		if !p.Enabled {
+			on = theme.Muted.Render("disabled")
+		}
+		fmt.Printf("    %-22s %2d workers  %s\n", p.ID, p.Workers, on)
+	}
+	fmt.Println()
 }
@@ -5,24 +5,99 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/account"
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+	"github.com/rickhallett/thepit/pitstorm/internal/engine"
+	"github.com/rickhallett/thepit/pitstorm/internal/fakeclerk"
+	"github.com/rickhallett/thepit/pitstorm/internal/har"
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+	"github.com/rickhallett/thepit/pitstorm/internal/probe"
+	"github.com/rickhallett/thepit/pitstorm/internal/profile"
 )
 
 // RunConfig holds all parsed configuration for a simulation run.
 type RunConfig struct {
-	Target     string
-	Accounts   string
-	Profile    string
-	Rate       float64
-	Duration   time.Duration
-	Budget     float64
-	Workers    int
-	Personas   []string
-	InstanceID int
-	InstanceOf int
-	Output     string
-	StatusFile string // live status JSON file, updated every 5s during run
-	Verbose    bool
-	EnvPath    string
+	Target      string
+	Accounts    string
+	Profile     string
+	ProfileFile string // optional YAML rate schedule (see profile.Schedule); overrides Profile
+	Rate        float64
+	Duration    time.Duration
+	Budget      float64
+	Workers     int
+
+	// Sub-ceilings within Budget, keyed by persona or model ID. A capped
+	// persona keeps running with its non-bout actions.
+	PersonaBudgets map[string]budget.Limit
+	ModelBudgets   map[string]budget.Limit
+
+	Personas    []string
+	Scenario    string // optional YAML file of persona specs (see persona.Scenario)
+	InstanceID  int
+	InstanceOf  int
+	Output      string
+	StatusFile  string // live status JSON file, updated every 5s during run
+	Seed        int64  // 0 = unseeded; non-zero makes persona choices reproducible
+	Journal     string // if set, record every dispatched request to this JSONL file
+	Transcripts string // if set, capture every bout stream (turns, share line, timings, violations) to this JSONL file
+	MetricsAddr string // if set, serve OpenMetrics at http://<addr>/metrics during the run
+	Coordinator string // if set, register with the coordinator at this URL (see coord package)
+	SLO         string // optional YAML file of thresholds (see slo package); a breach exits non-zero
+	Verbose     bool
+	EnvPath     string
+
+	// HAR, if set, exports failed and sampled successful requests to this
+	// HAR 1.2 file; HARSample is the fraction of successes included.
+	HAR       string
+	HARSample float64
+
+	// Timeline is the width of each interval of the output's timeline
+	// (see metrics.Interval); 0 leaves the timeline out.
+	Timeline time.Duration
+
+	// StallThreshold is the pause between two text-deltas of a bout turn
+	// that is counted as a stream stall.
+	StallThreshold time.Duration
+
+	// TUI replaces the periodic log lines with an interactive dashboard
+	// whose keys pause the run or change its rate.
+	TUI bool
+
+	// ControlAddr, if set, serves the runtime control API (see `pitstorm
+	// control`) on this address during the run.
+	ControlAddr string
+
+	// ClerkURL, if set, serves both Clerk APIs for the token refresher in
+	// place of the publishable key's FAPI and api.clerk.com — typically a
+	// `pitstorm fake-clerk` server.
+	ClerkURL string
+
+	// AccountPool, if set, spreads each tier's sessions across all of the
+	// tier's signed-in accounts — rotated per session or pinned per
+	// worker — instead of one account per persona.
+	AccountPool account.PoolMode
+
+	// Credits, if positive, runs the credit depletion scenario: every
+	// signed-in account is expected to start with this many credits, and
+	// the server's out-of-credits refusals are checked against the
+	// balance expected from each bout's cost. The starting balances are
+	// read back from the target before the run, so it needs a target
+	// that reports them (pitstorm mock --credits).
+	Credits float64
+
+	// TargetB, if set, runs an A/B comparison against a second target:
+	// ABMode mirror sends every action to both, split sends each session
+	// to one of them. Each target gets its own metrics.
+	TargetB string
+	ABMode  engine.ABMode
+
+	// Adaptive mode ignores Profile/Rate and searches for the highest
+	// sustainable rate; Duration caps the whole search.
+	Adaptive      bool
+	AdaptiveStart float64       // first probed rate, req/s
+	AdaptiveMax   float64       // highest rate ever probed, req/s
+	AdaptiveStep  time.Duration // how long each rate is held
 }
 
 // DefaultRunConfig returns the default configuration.
@@ -40,8 +115,18 @@ func DefaultRunConfig() RunConfig {
 		InstanceOf: 1,
 		Output:     "",
 		StatusFile: "results/.live-status.json",
+		Timeline:   metrics.DefaultTimelineInterval,
+		HARSample:  har.DefaultSuccessRate,
 		Verbose:    false,
 		EnvPath:    "",
+
+		StallThreshold: metrics.DefaultStallThreshold,
+
+		AdaptiveStart: 1,
+		AdaptiveMax:   200,
+		AdaptiveStep:  30 * time.Second,
+
+		ABMode: engine.ABMirror,
 	}
 }
 
@@ -69,9 +154,19 @@ func ParseRunConfig(args []string) (RunConfig, error) {
 			}
 			i++
 			cfg.Profile = args[i]
-			if !isValidProfile(cfg.Profile) {
-				return cfg, fmt.Errorf("invalid profile %q: must be trickle|steady|ramp|spike|viral", cfg.Profile)
+			if profile.IsScheduleSpec(cfg.Profile) {
+				if _, err := profile.ParseSchedule(cfg.Profile); err != nil {
+					return cfg, fmt.Errorf("--profile: %w", err)
+				}
+			} else if !isValidProfile(cfg.Profile) {
+				return cfg, fmt.Errorf("invalid profile %q: must be trickle|steady|ramp|spike|viral or linear:/step: schedule", cfg.Profile)
+			}
+		case "--profile-file":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--profile-file requires a value")
 			}
+			i++
+			cfg.ProfileFile = args[i]
 		case "--rate":
 			if i+1 >= len(args) {
 				return cfg, fmt.Errorf("--rate requires a value")
@@ -115,6 +210,19 @@ func ParseRunConfig(args []string) (RunConfig, error) {
 				return cfg, fmt.Errorf("--workers must be a positive integer, got %q", args[i])
 			}
 			cfg.Workers = v
+		case "--persona-budget", "--model-budget":
+			flag := args[i]
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("%s requires a value", flag)
+			}
+			i++
+			into := &cfg.PersonaBudgets
+			if flag == "--model-budget" {
+				into = &cfg.ModelBudgets
+			}
+			if err := parseLimits(args[i], into); err != nil {
+				return cfg, fmt.Errorf("%s: %w", flag, err)
+			}
 		case "--personas":
 			if i+1 >= len(args) {
 				return cfg, fmt.Errorf("--personas requires a value")
@@ -124,6 +232,12 @@ func ParseRunConfig(args []string) (RunConfig, error) {
 			for j := range cfg.Personas {
 				cfg.Personas[j] = strings.TrimSpace(cfg.Personas[j])
 			}
+		case "--scenario":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--scenario requires a value")
+			}
+			i++
+			cfg.Scenario = args[i]
 		case "--instance":
 			if i+1 >= len(args) {
 				return cfg, fmt.Errorf("--instance requires a value")
@@ -149,6 +263,123 @@ func ParseRunConfig(args []string) (RunConfig, error) {
 			cfg.StatusFile = args[i]
 		case "--no-status":
 			cfg.StatusFile = ""
+		case "--seed":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--seed requires a value")
+			}
+			i++
+			v, err := strconv.ParseInt(args[i], 10, 64)
+			if err != nil || v == 0 {
+				return cfg, fmt.Errorf("--seed must be a non-zero integer, got %q", args[i])
+			}
+			cfg.Seed = v
+		case "--journal":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--journal requires a value")
+			}
+			i++
+			cfg.Journal = args[i]
+		case "--transcripts":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--transcripts requires a value")
+			}
+			i++
+			cfg.Transcripts = args[i]
+		case "--har":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--har requires a value")
+			}
+			i++
+			cfg.HAR = args[i]
+		case "--har-sample":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--har-sample requires a value")
+			}
+			i++
+			v, err := strconv.ParseFloat(args[i], 64)
+			if err != nil || v < 0 || v > 1 {
+				return cfg, fmt.Errorf("--har-sample must be between 0 and 1, got %q", args[i])
+			}
+			cfg.HARSample = v
+		case "--metrics-addr":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--metrics-addr requires a value")
+			}
+			i++
+			cfg.MetricsAddr = args[i]
+		case "--tui":
+			cfg.TUI = true
+		case "--control-addr":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--control-addr requires a value")
+			}
+			i++
+			cfg.ControlAddr = args[i]
+		case "--coordinator":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--coordinator requires a value")
+			}
+			i++
+			cfg.Coordinator = args[i]
+		case "--slo":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--slo requires a value")
+			}
+			i++
+			cfg.SLO = args[i]
+		case "--adaptive":
+			cfg.Adaptive = true
+		case "--adaptive-start", "--adaptive-max":
+			flag := args[i]
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("%s requires a value", flag)
+			}
+			i++
+			v, err := strconv.ParseFloat(args[i], 64)
+			if err != nil || v <= 0 {
+				return cfg, fmt.Errorf("%s must be a positive number, got %q", flag, args[i])
+			}
+			if flag == "--adaptive-start" {
+				cfg.AdaptiveStart = v
+			} else {
+				cfg.AdaptiveMax = v
+			}
+		case "--timeline":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--timeline requires a value")
+			}
+			i++
+			d, err := time.ParseDuration(args[i])
+			if err != nil {
+				return cfg, fmt.Errorf("--timeline: %w", err)
+			}
+			if d != 0 && d < time.Second {
+				return cfg, fmt.Errorf("--timeline must be at least 1s, or 0 to disable")
+			}
+			cfg.Timeline = d
+		case "--stall-threshold":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--stall-threshold requires a value")
+			}
+			i++
+			d, err := time.ParseDuration(args[i])
+			if err != nil || d <= 0 {
+				return cfg, fmt.Errorf("--stall-threshold must be a positive duration, got %q", args[i])
+			}
+			cfg.StallThreshold = d
+		case "--adaptive-step":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--adaptive-step requires a value")
+			}
+			i++
+			d, err := time.ParseDuration(args[i])
+			if err != nil {
+				return cfg, fmt.Errorf("--adaptive-step: %w", err)
+			}
+			if d < time.Second {
+				return cfg, fmt.Errorf("--adaptive-step must be at least 1s")
+			}
+			cfg.AdaptiveStep = d
 		case "--verbose":
 			cfg.Verbose = true
 		case "--env":
@@ -157,11 +388,160 @@ func ParseRunConfig(args []string) (RunConfig, error) {
 			}
 			i++
 			cfg.EnvPath = args[i]
+		case "--account-pool":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--account-pool requires a value")
+			}
+			i++
+			m, err := account.ParsePoolMode(args[i])
+			if err != nil {
+				return cfg, fmt.Errorf("--account-pool: %w", err)
+			}
+			cfg.AccountPool = m
+		case "--credits":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--credits requires a value")
+			}
+			i++
+			v, err := strconv.ParseFloat(args[i], 64)
+			if err != nil || v <= 0 {
+				return cfg, fmt.Errorf("--credits must be a positive number, got %q", args[i])
+			}
+			cfg.Credits = v
+		case "--target-b":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--target-b requires a value")
+			}
+			i++
+			cfg.TargetB = args[i]
+		case "--ab-mode":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--ab-mode requires a value")
+			}
+			i++
+			m, err := engine.ParseABMode(args[i])
+			if err != nil {
+				return cfg, fmt.Errorf("--ab-mode: %w", err)
+			}
+			cfg.ABMode = m
+		case "--clerk-url":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--clerk-url requires a value")
+			}
+			i++
+			cfg.ClerkURL = args[i]
 		default:
 			return cfg, fmt.Errorf("unknown flag %q", args[i])
 		}
 	}
 
+	if cfg.Adaptive && cfg.AdaptiveStart > cfg.AdaptiveMax {
+		return cfg, fmt.Errorf("--adaptive-start (%g) must not exceed --adaptive-max (%g)", cfg.AdaptiveStart, cfg.AdaptiveMax)
+	}
+	if cfg.TargetB != "" && cfg.Adaptive {
+		return cfg, fmt.Errorf("--target-b cannot be combined with --adaptive")
+	}
+
+	return cfg, nil
+}
+
+// ReplayConfig holds parsed configuration for replaying a journal.
+type ReplayConfig struct {
+	Journal  string
+	Target   string // empty = the target recorded in the journal header
+	Accounts string
+	Speed    float64 // 1 = recorded timing, N = N× faster, 0 = no delays
+	KeepIDs  bool
+	Budget   float64
+	Output   string
+	Verbose  bool
+	EnvPath  string
+	ClerkURL string // overrides the Clerk API URLs (see RunConfig.ClerkURL)
+}
+
+// DefaultReplayConfig returns the default replay configuration.
+func DefaultReplayConfig() ReplayConfig {
+	return ReplayConfig{
+		Accounts: "./accounts.json",
+		Speed:    1,
+		Budget:   10.0,
+	}
+}
+
+// ParseReplayConfig parses `replay <journal> [flags]` arguments.
+func ParseReplayConfig(args []string) (ReplayConfig, error) {
+	cfg := DefaultReplayConfig()
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--target":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--target requires a value")
+			}
+			i++
+			cfg.Target = args[i]
+		case "--accounts":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--accounts requires a value")
+			}
+			i++
+			cfg.Accounts = args[i]
+		case "--speed":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--speed requires a value")
+			}
+			i++
+			v, err := strconv.ParseFloat(args[i], 64)
+			if err != nil || v < 0 {
+				return cfg, fmt.Errorf("--speed must be a non-negative number, got %q", args[i])
+			}
+			cfg.Speed = v
+		case "--keep-ids":
+			cfg.KeepIDs = true
+		case "--budget":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--budget requires a value")
+			}
+			i++
+			v, err := strconv.ParseFloat(args[i], 64)
+			if err != nil || v <= 0 {
+				return cfg, fmt.Errorf("--budget must be a positive number, got %q", args[i])
+			}
+			cfg.Budget = v
+		case "--output":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--output requires a value")
+			}
+			i++
+			cfg.Output = args[i]
+		case "--verbose":
+			cfg.Verbose = true
+		case "--env":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--env requires a value")
+			}
+			i++
+			cfg.EnvPath = args[i]
+		case "--clerk-url":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--clerk-url requires a value")
+			}
+			i++
+			cfg.ClerkURL = args[i]
+		default:
+			if strings.HasPrefix(args[i], "--") {
+				return cfg, fmt.Errorf("unknown flag %q", args[i])
+			}
+			if cfg.Journal != "" {
+				return cfg, fmt.Errorf("unexpected argument %q", args[i])
+			}
+			cfg.Journal = args[i]
+		}
+	}
+
+	if cfg.Journal == "" {
+		return cfg, fmt.Errorf("journal file required")
+	}
 	return cfg, nil
 }
 
@@ -173,6 +553,26 @@ func isValidProfile(p string) bool {
 	return false
 }
 
+// parseLimits parses "id=limit[,id=limit...]" into *into, allocating
+// the map on first use.
+func parseLimits(s string, into *map[string]budget.Limit) error {
+	for _, pair := range strings.Split(s, ",") {
+		id, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || id == "" {
+			return fmt.Errorf("expected ID=LIMIT, got %q", pair)
+		}
+		l, err := budget.ParseLimit(v)
+		if err != nil {
+			return err
+		}
+		if *into == nil {
+			*into = make(map[string]budget.Limit)
+		}
+		(*into)[id] = l
+	}
+	return nil
+}
+
 func parseInstance(s string) (id, of int, err error) {
 	parts := strings.SplitN(s, "/", 2)
 	if len(parts) != 2 {
@@ -191,3 +591,507 @@ func parseInstance(s string) (id, of int, err error) {
 	}
 	return id, of, nil
 }
+
+// MockServerConfig holds parsed configuration for the mock target server.
+type MockServerConfig struct {
+	Addr          string
+	Latency       time.Duration
+	Jitter        time.Duration
+	ErrorRate     float64
+	RateLimitRate float64
+	DeltaDelay    time.Duration
+	DeltasPerTurn int
+	StreamUsage   bool
+	RequireAuth   bool
+	Credits       float64
+	Verbose       bool
+}
+
+// DefaultMockServerConfig returns the default mock server configuration.
+func DefaultMockServerConfig() MockServerConfig {
+	return MockServerConfig{
+		Addr:          "127.0.0.1:8787",
+		DeltaDelay:    5 * time.Millisecond,
+		DeltasPerTurn: 12,
+	}
+}
+
+// ParseMockServerConfig parses `mock-server` flags.
+func ParseMockServerConfig(args []string) (MockServerConfig, error) {
+	cfg := DefaultMockServerConfig()
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--addr":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--addr requires a value")
+			}
+			i++
+			cfg.Addr = args[i]
+		case "--latency", "--jitter", "--delta-delay":
+			flag := args[i]
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("%s requires a value", flag)
+			}
+			i++
+			d, err := time.ParseDuration(args[i])
+			if err != nil {
+				return cfg, fmt.Errorf("%s: %w", flag, err)
+			}
+			if d < 0 {
+				return cfg, fmt.Errorf("%s must not be negative", flag)
+			}
+			switch flag {
+			case "--latency":
+				cfg.Latency = d
+			case "--jitter":
+				cfg.Jitter = d
+			case "--delta-delay":
+				cfg.DeltaDelay = d
+			}
+		case "--error-rate", "--rate-limit-rate":
+			flag := args[i]
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("%s requires a value", flag)
+			}
+			i++
+			v, err := strconv.ParseFloat(args[i], 64)
+			if err != nil || v < 0 || v > 1 {
+				return cfg, fmt.Errorf("%s must be between 0 and 1, got %q", flag, args[i])
+			}
+			if flag == "--error-rate" {
+				cfg.ErrorRate = v
+			} else {
+				cfg.RateLimitRate = v
+			}
+		case "--deltas":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--deltas requires a value")
+			}
+			i++
+			v, err := strconv.Atoi(args[i])
+			if err != nil || v < 1 {
+				return cfg, fmt.Errorf("--deltas must be a positive integer, got %q", args[i])
+			}
+			cfg.DeltasPerTurn = v
+		case "--stream-usage":
+			cfg.StreamUsage = true
+		case "--require-auth":
+			cfg.RequireAuth = true
+		case "--credits":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--credits requires a value")
+			}
+			i++
+			v, err := strconv.ParseFloat(args[i], 64)
+			if err != nil || v <= 0 {
+				return cfg, fmt.Errorf("--credits must be a positive number, got %q", args[i])
+			}
+			cfg.Credits = v
+		case "--verbose":
+			cfg.Verbose = true
+		default:
+			return cfg, fmt.Errorf("unknown flag %q", args[i])
+		}
+	}
+
+	return cfg, nil
+}
+
+// FakeClerkConfig holds parsed configuration for the fake Clerk server.
+type FakeClerkConfig struct {
+	Addr          string
+	TokenTTL      time.Duration
+	SecretKey     string
+	ErrorRate     float64
+	RateLimitRate float64
+	Verbose       bool
+}
+
+// DefaultFakeClerkConfig returns the default fake Clerk configuration.
+func DefaultFakeClerkConfig() FakeClerkConfig {
+	return FakeClerkConfig{
+		Addr:     "127.0.0.1:8788",
+		TokenTTL: fakeclerk.DefaultTokenTTL,
+	}
+}
+
+// ParseFakeClerkConfig parses `fake-clerk` flags.
+func ParseFakeClerkConfig(args []string) (FakeClerkConfig, error) {
+	cfg := DefaultFakeClerkConfig()
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--addr":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--addr requires a value")
+			}
+			i++
+			cfg.Addr = args[i]
+		case "--token-ttl":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--token-ttl requires a value")
+			}
+			i++
+			d, err := time.ParseDuration(args[i])
+			if err != nil {
+				return cfg, fmt.Errorf("--token-ttl: %w", err)
+			}
+			if d < time.Second {
+				return cfg, fmt.Errorf("--token-ttl must be at least 1s")
+			}
+			cfg.TokenTTL = d
+		case "--secret":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--secret requires a value")
+			}
+			i++
+			cfg.SecretKey = args[i]
+		case "--error-rate", "--rate-limit-rate":
+			flag := args[i]
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("%s requires a value", flag)
+			}
+			i++
+			v, err := strconv.ParseFloat(args[i], 64)
+			if err != nil || v < 0 || v > 1 {
+				return cfg, fmt.Errorf("%s must be between 0 and 1, got %q", flag, args[i])
+			}
+			if flag == "--error-rate" {
+				cfg.ErrorRate = v
+			} else {
+				cfg.RateLimitRate = v
+			}
+		case "--verbose":
+			cfg.Verbose = true
+		default:
+			return cfg, fmt.Errorf("unknown flag %q", args[i])
+		}
+	}
+
+	return cfg, nil
+}
+
+// CoordinatorConfig holds parsed configuration for a distributed-run
+// coordinator.
+type CoordinatorConfig struct {
+	Listen        string
+	Instances     int
+	Budget        float64       // global ceiling shared by every instance
+	StartDelay    time.Duration // grace between the last registration and start
+	ReportTimeout time.Duration // how long to wait for final reports after a stop
+	Output        string
+	Verbose       bool
+}
+
+// DefaultCoordinatorConfig returns the default coordinator configuration.
+func DefaultCoordinatorConfig() CoordinatorConfig {
+	return CoordinatorConfig{
+		Listen:        ":7070",
+		Instances:     2,
+		Budget:        10.0,
+		StartDelay:    3 * time.Second,
+		ReportTimeout: 30 * time.Second,
+	}
+}
+
+// ParseCoordinatorConfig parses `coordinator` flags.
+func ParseCoordinatorConfig(args []string) (CoordinatorConfig, error) {
+	cfg := DefaultCoordinatorConfig()
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--listen":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--listen requires a value")
+			}
+			i++
+			cfg.Listen = args[i]
+		case "--instances":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--instances requires a value")
+			}
+			i++
+			v, err := strconv.Atoi(args[i])
+			if err != nil || v < 1 {
+				return cfg, fmt.Errorf("--instances must be a positive integer, got %q", args[i])
+			}
+			cfg.Instances = v
+		case "--budget":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--budget requires a value")
+			}
+			i++
+			v, err := strconv.ParseFloat(args[i], 64)
+			if err != nil || v < 0 {
+				return cfg, fmt.Errorf("--budget must be a non-negative number, got %q", args[i])
+			}
+			cfg.Budget = v
+		case "--start-delay", "--report-timeout":
+			flag := args[i]
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("%s requires a value", flag)
+			}
+			i++
+			d, err := time.ParseDuration(args[i])
+			if err != nil {
+				return cfg, fmt.Errorf("%s: %w", flag, err)
+			}
+			if d < 0 {
+				return cfg, fmt.Errorf("%s must not be negative", flag)
+			}
+			if flag == "--start-delay" {
+				cfg.StartDelay = d
+			} else {
+				cfg.ReportTimeout = d
+			}
+		case "--output":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--output requires a value")
+			}
+			i++
+			cfg.Output = args[i]
+		case "--verbose":
+			cfg.Verbose = true
+		default:
+			return cfg, fmt.Errorf("unknown flag %q", args[i])
+		}
+	}
+
+	return cfg, nil
+}
+
+// CompareConfig holds parsed configuration for comparing two run outputs.
+type CompareConfig struct {
+	Baseline         string
+	Candidate        string
+	Alpha            float64 // significance level
+	MinChange        float64 // minimum relative change to flag, e.g. 0.1 = 10%
+	MinSamples       int
+	FailOnRegression bool
+}
+
+// DefaultCompareConfig returns the default compare configuration.
+func DefaultCompareConfig() CompareConfig {
+	return CompareConfig{
+		Alpha:      0.01,
+		MinChange:  0.10,
+		MinSamples: 20,
+	}
+}
+
+// ParseCompareConfig parses `compare <baseline> <candidate>` arguments.
+func ParseCompareConfig(args []string) (CompareConfig, error) {
+	cfg := DefaultCompareConfig()
+	var files []string
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--alpha":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--alpha requires a value")
+			}
+			i++
+			v, err := strconv.ParseFloat(args[i], 64)
+			if err != nil || v <= 0 || v >= 1 {
+				return cfg, fmt.Errorf("--alpha must be between 0 and 1, got %q", args[i])
+			}
+			cfg.Alpha = v
+		case "--min-change":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--min-change requires a value")
+			}
+			i++
+			v, err := strconv.ParseFloat(strings.TrimSuffix(args[i], "%"), 64)
+			if err != nil || v < 0 {
+				return cfg, fmt.Errorf("--min-change must be a non-negative percentage, got %q", args[i])
+			}
+			cfg.MinChange = v / 100
+		case "--min-samples":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--min-samples requires a value")
+			}
+			i++
+			v, err := strconv.Atoi(args[i])
+			if err != nil || v < 2 {
+				return cfg, fmt.Errorf("--min-samples must be an integer >= 2, got %q", args[i])
+			}
+			cfg.MinSamples = v
+		case "--fail-on-regression":
+			cfg.FailOnRegression = true
+		default:
+			if strings.HasPrefix(args[i], "--") {
+				return cfg, fmt.Errorf("unknown flag %q", args[i])
+			}
+			files = append(files, args[i])
+		}
+	}
+
+	if len(files) != 2 {
+		return cfg, fmt.Errorf("usage: pitstorm compare <baseline.json> <candidate.json>")
+	}
+	cfg.Baseline, cfg.Candidate = files[0], files[1]
+	return cfg, nil
+}
+
+// ProbeConfig holds parsed configuration for the security probe suite.
+type ProbeConfig struct {
+	Target        string
+	Accounts      string
+	Kinds         []probe.Kind // empty = every kind
+	Burst         int          // requests per rate-limit probe
+	Output        string
+	FailOnFinding bool
+	Verbose       bool
+	EnvPath       string
+	ClerkURL      string // overrides the Clerk API URLs (see RunConfig.ClerkURL)
+}
+
+// DefaultProbeConfig returns the default probe configuration.
+func DefaultProbeConfig() ProbeConfig {
+	return ProbeConfig{
+		Target:   "https://www.thepit.cloud",
+		Accounts: "./accounts.json",
+		Burst:    probe.DefaultBurst,
+	}
+}
+
+// ParseProbeConfig parses `probe` flags.
+func ParseProbeConfig(args []string) (ProbeConfig, error) {
+	cfg := DefaultProbeConfig()
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--target":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--target requires a value")
+			}
+			i++
+			cfg.Target = args[i]
+		case "--accounts":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--accounts requires a value")
+			}
+			i++
+			cfg.Accounts = args[i]
+		case "--kinds":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--kinds requires a value")
+			}
+			i++
+			cfg.Kinds = nil
+			for _, name := range strings.Split(args[i], ",") {
+				k, err := probe.ParseKind(strings.TrimSpace(name))
+				if err != nil {
+					return cfg, fmt.Errorf("--kinds: %w", err)
+				}
+				cfg.Kinds = append(cfg.Kinds, k)
+			}
+		case "--burst":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--burst requires a value")
+			}
+			i++
+			v, err := strconv.Atoi(args[i])
+			if err != nil || v < 1 {
+				return cfg, fmt.Errorf("--burst must be a positive integer, got %q", args[i])
+			}
+			cfg.Burst = v
+		case "--output":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--output requires a value")
+			}
+			i++
+			cfg.Output = args[i]
+		case "--fail-on-finding":
+			cfg.FailOnFinding = true
+		case "--verbose":
+			cfg.Verbose = true
+		case "--env":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--env requires a value")
+			}
+			i++
+			cfg.EnvPath = args[i]
+		case "--clerk-url":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--clerk-url requires a value")
+			}
+			i++
+			cfg.ClerkURL = args[i]
+		default:
+			return cfg, fmt.Errorf("unknown flag %q", args[i])
+		}
+	}
+	return cfg, nil
+}
+
+// DefaultControlAddr is where `pitstorm control` looks for a run's
+// control API; pass the same address to `run --control-addr`.
+const DefaultControlAddr = "127.0.0.1:7071"
+
+// ControlConfig holds a parsed `control` command.
+type ControlConfig struct {
+	Addr   string
+	Action string  // status, pause, resume, rate, enable, disable, budget or snapshot
+	Arg    string  // persona ID for enable/disable
+	Value  float64 // req/s for rate, GBP for budget
+	Output string  // snapshot JSON file
+}
+
+// controlArgs is the number of arguments each control action takes.
+var controlArgs = map[string]int{
+	"status": 0, "pause": 0, "resume": 0, "snapshot": 0,
+	"rate": 1, "enable": 1, "disable": 1, "budget": 1,
+}
+
+// ParseControlConfig parses `control <action> [arg]` arguments.
+func ParseControlConfig(args []string) (ControlConfig, error) {
+	cfg := ControlConfig{Addr: DefaultControlAddr}
+	var pos []string
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--addr":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--addr requires a value")
+			}
+			i++
+			cfg.Addr = args[i]
+		case "--output":
+			if i+1 >= len(args) {
+				return cfg, fmt.Errorf("--output requires a value")
+			}
+			i++
+			cfg.Output = args[i]
+		default:
+			if strings.HasPrefix(args[i], "--") {
+				return cfg, fmt.Errorf("unknown flag %q", args[i])
+			}
+			pos = append(pos, args[i])
+		}
+	}
+
+	if len(pos) == 0 {
+		return cfg, fmt.Errorf("usage: pitstorm control <status|pause|resume|rate <rps>|enable <persona>|disable <persona>|budget <gbp>|snapshot>")
+	}
+	cfg.Action = pos[0]
+	n, ok := controlArgs[cfg.Action]
+	if !ok {
+		return cfg, fmt.Errorf("unknown control action %q", cfg.Action)
+	}
+	if len(pos)-1 != n {
+		return cfg, fmt.Errorf("control %s takes %d argument(s), got %d", cfg.Action, n, len(pos)-1)
+	}
+	switch cfg.Action {
+	case "rate", "budget":
+		v, err := strconv.ParseFloat(strings.TrimPrefix(pos[1], "£"), 64)
+		if err != nil || v < 0 {
+			return cfg, fmt.Errorf("control %s needs a non-negative number, got %q", cfg.Action, pos[1])
+		}
+		cfg.Value = v
+	case "enable", "disable":
+		cfg.Arg = pos[1]
+	}
+	return cfg, nil
+}
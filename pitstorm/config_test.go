@@ -1,8 +1,20 @@
 package main
 
 import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/account"
+	"github.com/rickhallett/thepit/pitstorm/internal/action"
+	"github.com/rickhallett/thepit/pitstorm/internal/client"
+	"github.com/rickhallett/thepit/pitstorm/internal/engine"
+	"github.com/rickhallett/thepit/pitstorm/internal/fakeclerk"
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+	"github.com/rickhallett/thepit/pitstorm/internal/mockserver"
+	"github.com/rickhallett/thepit/pitstorm/internal/probe"
 )
 
 func TestDefaultRunConfig(t *testing.T) {
@@ -40,8 +52,26 @@ func TestParseRunConfig_AllFlags(t *testing.T) {
 		"--budget", "50.5",
 		"--workers", "32",
 		"--personas", "lurker,casual,pass",
+		"--scenario", "/tmp/scenario.yaml",
 		"--instance", "2/3",
 		"--output", "/tmp/results.json",
+		"--seed", "42",
+		"--journal", "/tmp/journal.jsonl",
+		"--transcripts", "/tmp/transcripts.jsonl",
+		"--har", "/tmp/run.har",
+		"--har-sample", "0.25",
+		"--metrics-addr", ":9090",
+		"--tui",
+		"--control-addr", "127.0.0.1:7071",
+		"--clerk-url", "http://127.0.0.1:8788",
+		"--account-pool", "pin",
+		"--credits", "250",
+		"--target-b", "https://preview.thepit.cloud",
+		"--ab-mode", "split",
+		"--timeline", "30s",
+		"--stall-threshold", "2s",
+		"--coordinator", "http://coord:7070",
+		"--slo", "/tmp/slo.yaml",
 		"--verbose",
 		"--env", "/tmp/.env",
 	}
@@ -75,12 +105,60 @@ func TestParseRunConfig_AllFlags(t *testing.T) {
 	if len(cfg.Personas) != 3 || cfg.Personas[0] != "lurker" {
 		t.Errorf("Personas = %v", cfg.Personas)
 	}
+	if cfg.Scenario != "/tmp/scenario.yaml" {
+		t.Errorf("Scenario = %q", cfg.Scenario)
+	}
 	if cfg.InstanceID != 2 || cfg.InstanceOf != 3 {
 		t.Errorf("Instance = %d/%d", cfg.InstanceID, cfg.InstanceOf)
 	}
 	if cfg.Output != "/tmp/results.json" {
 		t.Errorf("Output = %q", cfg.Output)
 	}
+	if cfg.Seed != 42 {
+		t.Errorf("Seed = %d", cfg.Seed)
+	}
+	if cfg.Journal != "/tmp/journal.jsonl" {
+		t.Errorf("Journal = %q", cfg.Journal)
+	}
+	if cfg.Transcripts != "/tmp/transcripts.jsonl" {
+		t.Errorf("Transcripts = %q", cfg.Transcripts)
+	}
+	if cfg.HAR != "/tmp/run.har" || cfg.HARSample != 0.25 {
+		t.Errorf("HAR = %q, HARSample = %v", cfg.HAR, cfg.HARSample)
+	}
+	if cfg.MetricsAddr != ":9090" {
+		t.Errorf("MetricsAddr = %q", cfg.MetricsAddr)
+	}
+	if !cfg.TUI {
+		t.Error("TUI = false, want true")
+	}
+	if cfg.ControlAddr != "127.0.0.1:7071" {
+		t.Errorf("ControlAddr = %q", cfg.ControlAddr)
+	}
+	if cfg.ClerkURL != "http://127.0.0.1:8788" {
+		t.Errorf("ClerkURL = %q", cfg.ClerkURL)
+	}
+	if cfg.AccountPool != account.PoolPin {
+		t.Errorf("AccountPool = %q", cfg.AccountPool)
+	}
+	if cfg.Credits != 250 {
+		t.Errorf("Credits = %v", cfg.Credits)
+	}
+	if cfg.TargetB != "https://preview.thepit.cloud" || cfg.ABMode != engine.ABSplit {
+		t.Errorf("TargetB = %q, ABMode = %q", cfg.TargetB, cfg.ABMode)
+	}
+	if cfg.Timeline != 30*time.Second {
+		t.Errorf("Timeline = %v", cfg.Timeline)
+	}
+	if cfg.StallThreshold != 2*time.Second {
+		t.Errorf("StallThreshold = %v", cfg.StallThreshold)
+	}
+	if cfg.Coordinator != "http://coord:7070" {
+		t.Errorf("Coordinator = %q", cfg.Coordinator)
+	}
+	if cfg.SLO != "/tmp/slo.yaml" {
+		t.Errorf("SLO = %q", cfg.SLO)
+	}
 	if !cfg.Verbose {
 		t.Error("Verbose should be true")
 	}
@@ -169,11 +247,51 @@ func TestParseRunConfig_UnknownFlag(t *testing.T) {
 	}
 }
 
+func TestParseRunConfig_InvalidAccountPool(t *testing.T) {
+	if _, err := ParseRunConfig([]string{"--account-pool", "shuffle"}); err == nil {
+		t.Fatal("expected error for unknown pool mode")
+	}
+}
+
+func TestParseRunConfig_ABMode(t *testing.T) {
+	cfg, err := ParseRunConfig([]string{"--target-b", "http://127.0.0.1:8787"})
+	if err != nil || cfg.ABMode != engine.ABMirror {
+		t.Errorf("default ABMode = %q, %v; want mirror", cfg.ABMode, err)
+	}
+	if _, err := ParseRunConfig([]string{"--ab-mode", "shadow"}); err == nil {
+		t.Error("expected error for unknown A/B mode")
+	}
+	if _, err := ParseRunConfig([]string{"--target-b", "http://127.0.0.1:8787", "--adaptive"}); err == nil {
+		t.Error("expected error for --target-b with --adaptive")
+	}
+}
+
+func TestParseRunConfig_Timeline(t *testing.T) {
+	cfg, err := ParseRunConfig([]string{"--timeline", "0"})
+	if err != nil || cfg.Timeline != 0 {
+		t.Errorf("--timeline 0 = %v, %v; want disabled", cfg.Timeline, err)
+	}
+	if def := DefaultRunConfig(); def.Timeline != metrics.DefaultTimelineInterval {
+		t.Errorf("default Timeline = %v", def.Timeline)
+	}
+	for _, v := range []string{"100ms", "soon"} {
+		if _, err := ParseRunConfig([]string{"--timeline", v}); err == nil {
+			t.Errorf("expected error for --timeline %s", v)
+		}
+	}
+	if _, err := ParseRunConfig([]string{"--stall-threshold", "0s"}); err == nil {
+		t.Error("expected error for --stall-threshold 0s")
+	}
+}
+
 func TestParseRunConfig_MissingValue(t *testing.T) {
 	flags := []string{
 		"--target", "--accounts", "--profile", "--rate",
 		"--duration", "--budget", "--workers", "--personas",
-		"--instance", "--output", "--env",
+		"--scenario", "--instance", "--output", "--seed",
+		"--journal", "--transcripts", "--har", "--har-sample", "--metrics-addr", "--control-addr", "--clerk-url", "--account-pool", "--credits", "--target-b", "--ab-mode", "--timeline", "--stall-threshold", "--coordinator", "--slo", "--profile-file", "--env",
+		"--adaptive-start", "--adaptive-max", "--adaptive-step",
+		"--persona-budget", "--model-budget",
 	}
 	for _, f := range flags {
 		t.Run(f, func(t *testing.T) {
@@ -185,6 +303,183 @@ func TestParseRunConfig_MissingValue(t *testing.T) {
 	}
 }
 
+func TestParseRunConfig_Adaptive(t *testing.T) {
+	cfg, err := ParseRunConfig([]string{"--adaptive", "--adaptive-start", "2", "--adaptive-max", "50", "--adaptive-step", "1m"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Adaptive || cfg.AdaptiveStart != 2 || cfg.AdaptiveMax != 50 || cfg.AdaptiveStep != time.Minute {
+		t.Errorf("adaptive = %v start=%v max=%v step=%v", cfg.Adaptive, cfg.AdaptiveStart, cfg.AdaptiveMax, cfg.AdaptiveStep)
+	}
+
+	for _, args := range [][]string{
+		{"--adaptive-start", "0"},
+		{"--adaptive-max", "fast"},
+		{"--adaptive-step", "100ms"},
+		{"--adaptive", "--adaptive-start", "100", "--adaptive-max", "10"},
+	} {
+		if _, err := ParseRunConfig(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseRunConfig_SubBudgets(t *testing.T) {
+	cfg, err := ParseRunConfig([]string{
+		"--persona-budget", "abusive=1,free-casual=£0.50",
+		"--persona-budget", "lab-power-user=2",
+		"--model-budget", "claude-opus-4-6=20%",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.PersonaBudgets) != 3 || cfg.PersonaBudgets["abusive"].GBP != 1 || cfg.PersonaBudgets["free-casual"].GBP != 0.5 {
+		t.Errorf("PersonaBudgets = %+v", cfg.PersonaBudgets)
+	}
+	if l := cfg.ModelBudgets["claude-opus-4-6"]; l.Fraction != 0.2 {
+		t.Errorf("ModelBudgets = %+v", cfg.ModelBudgets)
+	}
+
+	for _, args := range [][]string{
+		{"--persona-budget", "abusive"},
+		{"--persona-budget", "=1"},
+		{"--model-budget", "claude-opus-4-6=0"},
+	} {
+		if _, err := ParseRunConfig(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseRunConfig_InvalidHARSample(t *testing.T) {
+	for _, v := range []string{"-0.1", "1.5", "some"} {
+		if _, err := ParseRunConfig([]string{"--har-sample", v}); err == nil {
+			t.Errorf("expected error for --har-sample %q", v)
+		}
+	}
+}
+
+func TestParseRunConfig_InvalidSeed(t *testing.T) {
+	for _, v := range []string{"0", "abc"} {
+		if _, err := ParseRunConfig([]string{"--seed", v}); err == nil {
+			t.Errorf("expected error for seed %q", v)
+		}
+	}
+}
+
+func TestParseReplayConfig(t *testing.T) {
+	cfg, err := ParseReplayConfig([]string{
+		"results/journal.jsonl",
+		"--target", "http://localhost:3000",
+		"--speed", "10",
+		"--keep-ids",
+		"--budget", "2.5",
+		"--output", "/tmp/replay.json",
+		"--clerk-url", "http://127.0.0.1:8788",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Journal != "results/journal.jsonl" {
+		t.Errorf("Journal = %q", cfg.Journal)
+	}
+	if cfg.Target != "http://localhost:3000" {
+		t.Errorf("Target = %q", cfg.Target)
+	}
+	if cfg.Speed != 10 {
+		t.Errorf("Speed = %f", cfg.Speed)
+	}
+	if !cfg.KeepIDs {
+		t.Error("KeepIDs should be true")
+	}
+	if cfg.Budget != 2.5 {
+		t.Errorf("Budget = %f", cfg.Budget)
+	}
+	if cfg.Output != "/tmp/replay.json" {
+		t.Errorf("Output = %q", cfg.Output)
+	}
+	if cfg.ClerkURL != "http://127.0.0.1:8788" {
+		t.Errorf("ClerkURL = %q", cfg.ClerkURL)
+	}
+}
+
+func TestParseReplayConfig_Errors(t *testing.T) {
+	tests := []struct {
+		desc string
+		args []string
+	}{
+		{"no journal", nil},
+		{"two journals", []string{"a.jsonl", "b.jsonl"}},
+		{"negative speed", []string{"a.jsonl", "--speed", "-1"}},
+		{"missing speed", []string{"a.jsonl", "--speed"}},
+		{"unknown flag", []string{"a.jsonl", "--rate", "5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			if _, err := ParseReplayConfig(tt.args); err == nil {
+				t.Fatalf("expected error for %v", tt.args)
+			}
+		})
+	}
+}
+
+func TestParseMockServerConfig(t *testing.T) {
+	cfg, err := ParseMockServerConfig([]string{
+		"--addr", ":9999",
+		"--latency", "40ms",
+		"--jitter", "10ms",
+		"--error-rate", "0.02",
+		"--rate-limit-rate", "0.1",
+		"--delta-delay", "0s",
+		"--deltas", "4",
+		"--stream-usage",
+		"--require-auth",
+		"--credits", "50",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Addr != ":9999" {
+		t.Errorf("Addr = %q", cfg.Addr)
+	}
+	if cfg.Latency != 40*time.Millisecond || cfg.Jitter != 10*time.Millisecond {
+		t.Errorf("Latency = %v, Jitter = %v", cfg.Latency, cfg.Jitter)
+	}
+	if cfg.ErrorRate != 0.02 || cfg.RateLimitRate != 0.1 {
+		t.Errorf("ErrorRate = %f, RateLimitRate = %f", cfg.ErrorRate, cfg.RateLimitRate)
+	}
+	if cfg.DeltaDelay != 0 || cfg.DeltasPerTurn != 4 {
+		t.Errorf("DeltaDelay = %v, DeltasPerTurn = %d", cfg.DeltaDelay, cfg.DeltasPerTurn)
+	}
+	if !cfg.StreamUsage {
+		t.Error("StreamUsage should be true")
+	}
+	if !cfg.RequireAuth {
+		t.Error("RequireAuth should be true")
+	}
+	if cfg.Credits != 50 {
+		t.Errorf("Credits = %v", cfg.Credits)
+	}
+}
+
+func TestParseMockServerConfig_Errors(t *testing.T) {
+	tests := [][]string{
+		{"--error-rate", "1.5"},
+		{"--rate-limit-rate", "-0.1"},
+		{"--latency", "soon"},
+		{"--jitter", "-1s"},
+		{"--deltas", "0"},
+		{"--credits", "-5"},
+		{"--addr"},
+		{"--unknown"},
+	}
+	for _, args := range tests {
+		if _, err := ParseMockServerConfig(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
 func TestIsValidProfile(t *testing.T) {
 	valid := []string{"trickle", "steady", "ramp", "spike", "viral"}
 	for _, p := range valid {
@@ -227,3 +522,226 @@ func TestParseInstance(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCoordinatorConfig(t *testing.T) {
+	cfg, err := ParseCoordinatorConfig([]string{
+		"--listen", "0.0.0.0:9000",
+		"--instances", "4",
+		"--budget", "25",
+		"--start-delay", "1s",
+		"--report-timeout", "10s",
+		"--output", "/tmp/merged.json",
+		"--verbose",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Listen != "0.0.0.0:9000" || cfg.Instances != 4 || cfg.Budget != 25 {
+		t.Errorf("Listen/Instances/Budget = %q/%d/%v", cfg.Listen, cfg.Instances, cfg.Budget)
+	}
+	if cfg.StartDelay != time.Second || cfg.ReportTimeout != 10*time.Second {
+		t.Errorf("StartDelay/ReportTimeout = %v/%v", cfg.StartDelay, cfg.ReportTimeout)
+	}
+	if cfg.Output != "/tmp/merged.json" || !cfg.Verbose {
+		t.Errorf("Output = %q, Verbose = %v", cfg.Output, cfg.Verbose)
+	}
+}
+
+func TestParseCoordinatorConfig_Errors(t *testing.T) {
+	tests := [][]string{
+		{"--instances", "0"},
+		{"--budget", "-1"},
+		{"--start-delay", "soon"},
+		{"--listen"},
+		{"--bogus"},
+	}
+	for _, args := range tests {
+		if _, err := ParseCoordinatorConfig(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseCompareConfig(t *testing.T) {
+	cfg, err := ParseCompareConfig([]string{
+		"base.json", "--alpha", "0.05", "--min-change", "5%", "cand.json",
+		"--min-samples", "50", "--fail-on-regression",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Baseline != "base.json" || cfg.Candidate != "cand.json" {
+		t.Errorf("files = %q, %q", cfg.Baseline, cfg.Candidate)
+	}
+	if cfg.Alpha != 0.05 || cfg.MinChange != 0.05 || cfg.MinSamples != 50 || !cfg.FailOnRegression {
+		t.Errorf("cfg = %+v", cfg)
+	}
+}
+
+func TestParseCompareConfig_Errors(t *testing.T) {
+	tests := [][]string{
+		{"only-one.json"},
+		{"a.json", "b.json", "c.json"},
+		{"a.json", "b.json", "--alpha", "1"},
+		{"a.json", "b.json", "--min-change", "-3"},
+		{"a.json", "b.json", "--min-samples", "1"},
+		{"a.json", "b.json", "--bogus"},
+	}
+	for _, args := range tests {
+		if _, err := ParseCompareConfig(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseProbeConfig(t *testing.T) {
+	cfg, err := ParseProbeConfig([]string{
+		"--target", "http://localhost:3000", "--kinds", "xss,idor", "--burst", "50",
+		"--output", "/tmp/findings.json", "--fail-on-finding", "--verbose", "--clerk-url", "http://127.0.0.1:8788",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Target != "http://localhost:3000" || cfg.Burst != 50 || cfg.Output != "/tmp/findings.json" || !cfg.FailOnFinding || !cfg.Verbose || cfg.ClerkURL != "http://127.0.0.1:8788" {
+		t.Errorf("cfg = %+v", cfg)
+	}
+	if len(cfg.Kinds) != 2 || cfg.Kinds[0] != probe.KindXSS || cfg.Kinds[1] != probe.KindIDOR {
+		t.Errorf("Kinds = %v", cfg.Kinds)
+	}
+
+	def, err := ParseProbeConfig(nil)
+	if err != nil || len(def.Kinds) != 0 || def.Burst != probe.DefaultBurst {
+		t.Errorf("defaults = %+v, %v", def, err)
+	}
+}
+
+func TestParseProbeConfig_Errors(t *testing.T) {
+	tests := [][]string{
+		{"--kinds", "xss,csrf"},
+		{"--burst", "0"},
+		{"--target"},
+		{"--bogus"},
+	}
+	for _, args := range tests {
+		if _, err := ParseProbeConfig(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseFakeClerkConfig(t *testing.T) {
+	cfg, err := ParseFakeClerkConfig([]string{
+		"--addr", ":9998", "--token-ttl", "5s", "--secret", "sk_test_x",
+		"--error-rate", "0.1", "--rate-limit-rate", "0.2", "--verbose",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Addr != ":9998" || cfg.TokenTTL != 5*time.Second || cfg.SecretKey != "sk_test_x" ||
+		cfg.ErrorRate != 0.1 || cfg.RateLimitRate != 0.2 || !cfg.Verbose {
+		t.Errorf("cfg = %+v", cfg)
+	}
+
+	for _, args := range [][]string{
+		{"--token-ttl", "500ms"},
+		{"--token-ttl", "soon"},
+		{"--error-rate", "2"},
+		{"--secret"},
+		{"--unknown"},
+	} {
+		if _, err := ParseFakeClerkConfig(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseControlConfig(t *testing.T) {
+	cfg, err := ParseControlConfig([]string{"rate", "12.5", "--addr", "10.0.0.2:7071"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Action != "rate" || cfg.Value != 12.5 || cfg.Addr != "10.0.0.2:7071" {
+		t.Errorf("cfg = %+v", cfg)
+	}
+	cfg, err = ParseControlConfig([]string{"disable", "free-lurker"})
+	if err != nil || cfg.Arg != "free-lurker" || cfg.Addr != DefaultControlAddr {
+		t.Errorf("disable = %+v, %v", cfg, err)
+	}
+	if cfg, err = ParseControlConfig([]string{"budget", "£25"}); err != nil || cfg.Value != 25 {
+		t.Errorf("budget = %+v, %v", cfg, err)
+	}
+}
+
+func TestParseControlConfig_Errors(t *testing.T) {
+	tests := [][]string{
+		nil,
+		{"restart"},
+		{"pause", "now"},
+		{"rate"},
+		{"rate", "-1"},
+		{"budget", "lots"},
+		{"status", "--addr"},
+		{"status", "--bogus"},
+	}
+	for _, args := range tests {
+		if _, err := ParseControlConfig(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseRunConfig_Schedule(t *testing.T) {
+	cfg, err := ParseRunConfig([]string{"--profile", "step:0s=1,30s=10@1m", "--profile-file", "/tmp/day.yaml"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Profile != "step:0s=1,30s=10@1m" || cfg.ProfileFile != "/tmp/day.yaml" {
+		t.Errorf("Profile = %q, ProfileFile = %q", cfg.Profile, cfg.ProfileFile)
+	}
+	if _, err := ParseRunConfig([]string{"--profile", "linear:0s=abc"}); err == nil {
+		t.Error("expected error for malformed inline schedule")
+	}
+}
+
+func TestResolveSecretKey(t *testing.T) {
+	t.Setenv("CLERK_SECRET_KEY", "sk_live_real")
+	env := filepath.Join(t.TempDir(), ".env")
+	if got := resolveSecretKey("", env, ""); got != "sk_live_real" {
+		t.Errorf("from environment = %q", got)
+	}
+	if got := resolveSecretKey("", env, "http://127.0.0.1:9"); got != fakeclerk.DefaultSecretKey {
+		t.Errorf("with --clerk-url = %q, want fake-clerk's default", got)
+	}
+	if got := resolveSecretKey("sk_test_flag", env, "http://127.0.0.1:9"); got != "sk_test_flag" {
+		t.Errorf("explicit --secret = %q", got)
+	}
+}
+
+func TestCheckCreditBalances(t *testing.T) {
+	f := &account.File{Accounts: []account.Account{
+		{ID: "account-anon", Tier: account.TierAnon},
+		{ID: "account-free", Tier: account.TierFree},
+	}}
+	hasToken := func(id string) bool { return id == "account-free" }
+	check := func(mock mockserver.Config, want float64) error {
+		srv := httptest.NewServer(mockserver.New(mock, nil))
+		defer srv.Close()
+		cl := client.New(client.DefaultConfig(srv.URL), nil)
+		defer cl.Close()
+		cl.SetToken("account-free", "tok")
+		return checkCreditBalances(context.Background(), action.New(cl), f, hasToken, want)
+	}
+
+	if err := check(mockserver.Config{Credits: 5}, 5); err != nil {
+		t.Errorf("matching balance: %v", err)
+	}
+	if err := check(mockserver.Config{Credits: 3}, 5); err == nil {
+		t.Error("a different starting balance should fail")
+	}
+	if err := check(mockserver.Config{}, 5); err == nil {
+		t.Error("a target that does not report balances should fail")
+	}
+	if err := checkCreditBalances(context.Background(), nil, nil, hasToken, 5); err == nil {
+		t.Error("no signed-in accounts should fail")
+	}
+}
@@ -0,0 +1,124 @@
+package account
+
+import (
+	"fmt"
+	"sync"
+)
+
+// PoolMode selects how a Pool hands out a tier's accounts to sessions.
+type PoolMode string
+
+const (
+	// PoolRotate gives each new session the tier's next account in turn.
+	PoolRotate PoolMode = "rotate"
+	// PoolPin keeps each worker on one account of the tier for the run.
+	PoolPin PoolMode = "pin"
+)
+
+// ParsePoolMode parses a --account-pool value.
+func ParsePoolMode(s string) (PoolMode, error) {
+	switch m := PoolMode(s); m {
+	case PoolRotate, PoolPin:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown account pool mode %q (want rotate or pin)", s)
+}
+
+// Pool spreads simulated sessions across every signed-in account of a
+// tier, so per-user rate limits and credit balances see many users
+// rather than one account per persona.
+type Pool struct {
+	mode   PoolMode
+	byTier map[Tier][]string
+
+	mu     sync.Mutex
+	next   map[Tier]int
+	pinned map[pinKey]string
+}
+
+// pinKey identifies a pinned worker's account.
+type pinKey struct {
+	tier   Tier
+	worker int
+}
+
+// NewPool builds a pool from the authenticated accounts in f for which
+// usable reports true (typically: the client holds a token for it). A
+// nil usable accepts every account.
+func NewPool(f *File, mode PoolMode, usable func(id string) bool) *Pool {
+	p := &Pool{
+		mode:   mode,
+		byTier: make(map[Tier][]string),
+		next:   make(map[Tier]int),
+		pinned: make(map[pinKey]string),
+	}
+	for _, a := range f.Accounts {
+		if a.Tier == TierAnon || (usable != nil && !usable(a.ID)) {
+			continue
+		}
+		p.byTier[a.Tier] = append(p.byTier[a.Tier], a.ID)
+	}
+	return p
+}
+
+// Mode returns how the pool hands out accounts.
+func (p *Pool) Mode() PoolMode { return p.mode }
+
+// Size returns the number of accounts the pool holds for tier.
+func (p *Pool) Size(tier Tier) int { return len(p.byTier[tier]) }
+
+// Pick returns the account a session of the given tier on worker signs
+// in as. ok is false when the pool has no account of that tier.
+//
+// In pin mode a worker is pinned to the tier's next account on its
+// first Pick. Worker IDs can't be mapped to accounts directly: personas
+// are assigned to workers round-robin, so a tier's workers share a few
+// residues and would crowd onto a few accounts.
+func (p *Pool) Pick(tier Tier, worker int) (id string, ok bool) {
+	ids := p.byTier[tier]
+	if len(ids) == 0 {
+		return "", false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := pinKey{tier, worker}
+	if id, ok := p.pinned[key]; ok {
+		return id, true
+	}
+	i := p.next[tier]
+	p.next[tier] = (i + 1) % len(ids)
+	if p.mode == PoolPin {
+		p.pinned[key] = ids[i]
+	}
+	return ids[i], true
+}
+
+// Describe summarises the pool, e.g. "rotate across 12 accounts (free 5,
+// pass 5, lab 2)".
+func (p *Pool) Describe() string {
+	total := 0
+	for _, ids := range p.byTier {
+		total += len(ids)
+	}
+	return fmt.Sprintf("%s across %d accounts (free %d, pass %d, lab %d)",
+		p.mode, total, p.Size(TierFree), p.Size(TierPass), p.Size(TierLab))
+}
+
+// PoolAccounts returns DefaultAccounts plus perTier pooled accounts for
+// each signed-in tier, for runs with --account-pool.
+func PoolAccounts(target string, perTier int) *File {
+	f := DefaultAccounts(target)
+	domain := extractDomain(target)
+	for _, tier := range []Tier{TierFree, TierPass, TierLab} {
+		for i := 1; i <= perTier; i++ {
+			name := fmt.Sprintf("%s-%03d", tier, i)
+			f.Accounts = append(f.Accounts, Account{
+				ID:       "account-pool-" + name,
+				Email:    fmt.Sprintf("storm-pool-%s@%s", name, domain),
+				Password: generatePassword("pool-" + name),
+				Tier:     tier,
+			})
+		}
+	}
+	return f
+}
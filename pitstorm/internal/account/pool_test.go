@@ -0,0 +1,105 @@
+package account
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/persona"
+)
+
+func TestPoolAccounts(t *testing.T) {
+	f := PoolAccounts("https://www.thepit.cloud", 3)
+	if err := f.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	base := len(DefaultAccounts("https://www.thepit.cloud").Accounts)
+	if len(f.Accounts) != base+9 {
+		t.Errorf("accounts = %d, want %d", len(f.Accounts), base+9)
+	}
+	if got := len(f.ByTier(TierLab)); got != 4 {
+		t.Errorf("lab accounts = %d, want 1 persona account + 3 pooled", got)
+	}
+	if _, err := f.ByID("account-pool-pass-002"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPoolRotate(t *testing.T) {
+	f := PoolAccounts("https://www.thepit.cloud", 2)
+	p := NewPool(f, PoolRotate, func(id string) bool { return id != "account-pool-lab-002" })
+
+	if p.Size(TierLab) != 2 {
+		t.Fatalf("lab pool = %d, want 2 (one account unusable)", p.Size(TierLab))
+	}
+	seen := make(map[string]int)
+	for i := 0; i < 10; i++ {
+		id, ok := p.Pick(TierFree, 0)
+		if !ok {
+			t.Fatal("no free account")
+		}
+		seen[id]++
+	}
+	// Three persona free accounts plus two pooled, each picked twice.
+	if len(seen) != 5 {
+		t.Errorf("rotation used %d accounts, want 5: %v", len(seen), seen)
+	}
+	for id, n := range seen {
+		if n != 2 {
+			t.Errorf("%s picked %d times, want 2", id, n)
+		}
+	}
+	if _, ok := p.Pick(TierAnon, 0); ok {
+		t.Error("pool handed out an anon account")
+	}
+	if d := p.Describe(); !strings.HasPrefix(d, "rotate across 12 accounts") {
+		t.Errorf("Describe = %q", d)
+	}
+}
+
+func TestPoolPin(t *testing.T) {
+	p := NewPool(PoolAccounts("https://www.thepit.cloud", 1), PoolPin, nil)
+	first, _ := p.Pick(TierPass, 5)
+	for i := 0; i < 3; i++ {
+		if id, _ := p.Pick(TierPass, 5); id != first {
+			t.Errorf("worker 5 moved from %s to %s", first, id)
+		}
+	}
+	if other, _ := p.Pick(TierPass, 6); other == first {
+		t.Errorf("workers 5 and 6 share %s", first)
+	}
+}
+
+// TestPoolPinSpreadsWorkers assigns personas to workers round-robin, as
+// the engine does, and checks that pinning still uses every account.
+func TestPoolPinSpreadsWorkers(t *testing.T) {
+	f := PoolAccounts("https://www.thepit.cloud", 5)
+	p := NewPool(f, PoolPin, nil)
+	personas := persona.All()
+
+	used := make(map[string]bool)
+	for w := 0; w < len(personas)*6; w++ {
+		tier := Tier(personas[w%len(personas)].Tier)
+		if tier == TierAnon {
+			continue
+		}
+		id, ok := p.Pick(tier, w)
+		if !ok {
+			t.Fatalf("no %s account for worker %d", tier, w)
+		}
+		used[id] = true
+	}
+	for _, a := range f.Accounts {
+		if a.Tier != TierAnon && !used[a.ID] {
+			t.Errorf("%s (%s) never pinned", a.ID, a.Tier)
+		}
+	}
+}
+
+func TestParsePoolMode(t *testing.T) {
+	if m, err := ParsePoolMode("pin"); err != nil || m != PoolPin {
+		t.Errorf("ParsePoolMode(pin) = %q, %v", m, err)
+	}
+	if _, err := ParsePoolMode("random"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
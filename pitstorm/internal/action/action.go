@@ -7,9 +7,12 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
+	mrand "math/rand/v2"
+	"net/http"
 	"time"
 
 	"github.com/rickhallett/thepit/pitstorm/internal/client"
@@ -33,6 +36,22 @@ func GenerateID(length int) string {
 	return string(b)
 }
 
+// GenerateIDFrom is GenerateID drawing from rng, so a seeded run
+// produces the same IDs. A nil rng falls back to GenerateID.
+func GenerateIDFrom(rng *mrand.Rand, length int) string {
+	if rng == nil {
+		return GenerateID(length)
+	}
+	if length <= 0 {
+		length = 21
+	}
+	b := make([]byte, length)
+	for i := range b {
+		b[i] = nanoidAlphabet[rng.IntN(len(nanoidAlphabet))]
+	}
+	return string(b)
+}
+
 // Actor wraps a client.Client and provides typed API methods.
 type Actor struct {
 	c *client.Client
@@ -72,6 +91,12 @@ type StreamHandle struct {
 	Headers    map[string]string
 }
 
+// Fail records err, an error found in the stream such as an in-stream
+// error event, on the stream's exchange (see client.ReportStreamError).
+func (h *StreamHandle) Fail(err error) {
+	client.ReportStreamError(h.Body, err)
+}
+
 // Close releases the underlying response body.
 func (h *StreamHandle) Close() error {
 	if h.Body != nil {
@@ -138,6 +163,22 @@ func (a *Actor) APIBout(ctx context.Context, accountID string, req APIBoutReques
 	return toResult(resp), nil
 }
 
+// ParseUsage returns the token usage reported in a bout response body
+// ({"usage": {"inputTokens": n, "outputTokens": n}}), or nil if the
+// body carries none.
+func ParseUsage(body json.RawMessage) *client.UsageData {
+	var envelope struct {
+		Usage *client.UsageData `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.Usage == nil {
+		return nil
+	}
+	if envelope.Usage.InputTokens == 0 && envelope.Usage.OutputTokens == 0 {
+		return nil
+	}
+	return envelope.Usage
+}
+
 // ---------- Agent Actions ----------
 
 // CreateAgentRequest is the payload for POST /api/agents.
@@ -316,6 +357,34 @@ func (a *Actor) StashBYOK(ctx context.Context, accountID string, req BYOKStashRe
 	return toResult(resp), nil
 }
 
+// ---------- Credits ----------
+
+// ErrNoCreditBalance is returned by CreditBalance when the target does
+// not report credit balances.
+var ErrNoCreditBalance = errors.New("target does not report credit balances")
+
+// CreditBalance reads an account's credit balance via GET
+// /api/credits/balance. Only pitstorm's mock server serves it.
+func (a *Actor) CreditBalance(ctx context.Context, accountID string) (float64, error) {
+	resp, err := a.c.Do(ctx, "GET", "/api/credits/balance", accountID, nil)
+	if err != nil {
+		return 0, fmt.Errorf("credit balance: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, ErrNoCreditBalance
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("credit balance: HTTP %d", resp.StatusCode)
+	}
+	var v struct {
+		Credits *float64 `json:"credits"`
+	}
+	if err := json.Unmarshal(resp.Body, &v); err != nil || v.Credits == nil {
+		return 0, ErrNoCreditBalance
+	}
+	return *v.Credits, nil
+}
+
 // ---------- Page Browsing ----------
 
 // BrowsePage fetches a page by path (e.g. "/", "/arena", "/agents").
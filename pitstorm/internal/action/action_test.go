@@ -124,6 +124,21 @@ func TestAPIBout(t *testing.T) {
 	if res.StatusCode != 200 {
 		t.Errorf("StatusCode = %d, want 200", res.StatusCode)
 	}
+	if u := ParseUsage(res.Body); u != nil {
+		t.Errorf("ParseUsage = %+v, want nil without usage", u)
+	}
+}
+
+func TestParseUsage(t *testing.T) {
+	u := ParseUsage(json.RawMessage(`{"boutId":"b1","usage":{"inputTokens":3300,"outputTokens":480}}`))
+	if u == nil || u.InputTokens != 3300 || u.OutputTokens != 480 {
+		t.Errorf("ParseUsage = %+v", u)
+	}
+	for _, body := range []string{`{"usage":{"inputTokens":0,"outputTokens":0}}`, `not json`, ``} {
+		if u := ParseUsage(json.RawMessage(body)); u != nil {
+			t.Errorf("ParseUsage(%q) = %+v, want nil", body, u)
+		}
+	}
 }
 
 func TestCreateAgent(t *testing.T) {
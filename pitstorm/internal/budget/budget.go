@@ -1,6 +1,8 @@
 // Package budget implements an atomic GBP budget gate for pitstorm.
-// It tracks estimated API costs from streaming bouts and blocks new
-// requests once the configured budget ceiling is reached.
+// It tracks API costs from streaming bouts — from server-reported token
+// usage when available, estimated otherwise — and blocks new requests
+// once the configured budget ceiling is reached. Optional sub-ceilings
+// cap the spend of a single persona or model within that budget.
 //
 // Cost estimation uses the same model pricing as lib/credits.ts and
 // pitbench/internal/pricing — see those for the authoritative source.
@@ -9,6 +11,9 @@ package budget
 import (
 	"fmt"
 	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 )
@@ -40,30 +45,195 @@ var fallbackPrice = modelPrice{InputGBP: 0.732, OutputGBP: 3.66}
 // Gate enforces a GBP budget ceiling for a simulation run. All methods
 // are safe for concurrent use.
 type Gate struct {
-	ceiling float64 // max GBP
+	// Max spend in micro-GBP, atomic so it can be raised mid-run.
+	ceilingMicroGBP atomic.Int64
 
 	// Stored as integer micro-GBP (1 micro-GBP = 0.000001 GBP) for
 	// lock-free atomic operations. Max representable: ~9.2 trillion GBP.
 	spentMicroGBP atomic.Int64
 
-	// Per-model spend tracking.
-	mu         sync.Mutex
-	modelSpend map[string]float64
-	boutCount  int
+	// Per-model and per-persona spend tracking.
+	mu           sync.Mutex
+	modelSpend   map[string]float64
+	personaSpend map[string]float64
+	boutCount    int
+
+	// Sub-ceilings in GBP, and how many bouts each has denied, keyed by
+	// limitKey.
+	modelLimits   map[string]float64
+	personaLimits map[string]float64
+	denied        map[string]int
+
+	// Charges priced from server-reported usage, and per model how far
+	// the token estimate would have been from them.
+	actualCount int
+	estimates   map[string]*EstimateError
+
+	// ledger, if set, makes the ceiling global across processes: charges
+	// accumulate in unsent, FlushLedger forwards them, and Spent reports
+	// the shared total.
+	ledger         Ledger
+	globalMicroGBP atomic.Int64
+	unsent         map[string]float64 // per model; guarded by mu
+
+	// flushMu serialises FlushLedger. batch is the spend last sent under
+	// batchSeq and not yet acknowledged; it is resent unchanged, so the
+	// ledger can drop it if the earlier attempt did arrive.
+	flushMu  sync.Mutex
+	batch    map[string]float64
+	batchSeq int64
+}
+
+// Ledger is a budget shared between several pitstorm processes (see
+// the coord package). Charge records a batch of local spend per model
+// and returns the new global total in GBP. seq increases with every
+// new batch, and a batch may be sent again under the same seq, which
+// the ledger must apply only once.
+type Ledger interface {
+	Charge(seq int64, spend map[string]float64) (globalSpentGBP float64, err error)
 }
 
 // NewGate creates a budget gate with the given GBP ceiling.
 // A ceiling of 0 means unlimited (gate never blocks).
 func NewGate(ceilingGBP float64) *Gate {
-	return &Gate{
-		ceiling:    ceilingGBP,
-		modelSpend: make(map[string]float64),
+	g := &Gate{
+		modelSpend:    make(map[string]float64),
+		personaSpend:  make(map[string]float64),
+		modelLimits:   make(map[string]float64),
+		personaLimits: make(map[string]float64),
+		denied:        make(map[string]int),
+		estimates:     make(map[string]*EstimateError),
+		unsent:        make(map[string]float64),
+	}
+	g.ceilingMicroGBP.Store(int64(math.Round(ceilingGBP * 1_000_000)))
+	return g
+}
+
+// Cap names the ceiling that denied a bout.
+type Cap string
+
+const (
+	CapNone    Cap = ""
+	CapGlobal  Cap = "global"
+	CapModel   Cap = "model"
+	CapPersona Cap = "persona"
+)
+
+// Limit is a sub-ceiling: either a fixed amount in GBP or a fraction of
+// the gate's overall ceiling.
+type Limit struct {
+	GBP      float64
+	Fraction float64
+}
+
+// ParseLimit parses a sub-ceiling such as "1", "£0.50" or "20%".
+func ParseLimit(s string) (Limit, error) {
+	v := strings.TrimPrefix(strings.TrimSpace(s), "£")
+	if pct, ok := strings.CutSuffix(v, "%"); ok {
+		f, err := strconv.ParseFloat(pct, 64)
+		if err != nil || f <= 0 || f > 100 {
+			return Limit{}, fmt.Errorf("invalid limit %q: percentage must be in (0, 100]", s)
+		}
+		return Limit{Fraction: f / 100}, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q: want a positive GBP amount or a percentage", s)
+	}
+	return Limit{GBP: f}, nil
+}
+
+// String formats the limit as it would be parsed.
+func (l Limit) String() string {
+	if l.Fraction > 0 {
+		return strconv.FormatFloat(l.Fraction*100, 'f', -1, 64) + "%"
+	}
+	return "£" + strconv.FormatFloat(l.GBP, 'f', -1, 64)
+}
+
+// SetPersonaLimit caps the spend of bouts run by one persona.
+func (g *Gate) SetPersonaLimit(personaID string, l Limit) error {
+	gbp, err := g.resolve(l)
+	if err != nil {
+		return fmt.Errorf("persona %s: %w", personaID, err)
+	}
+	g.mu.Lock()
+	g.personaLimits[personaID] = gbp
+	g.mu.Unlock()
+	return nil
+}
+
+// SetModelLimit caps the spend on one model.
+func (g *Gate) SetModelLimit(modelID string, l Limit) error {
+	gbp, err := g.resolve(l)
+	if err != nil {
+		return fmt.Errorf("model %s: %w", modelID, err)
+	}
+	g.mu.Lock()
+	g.modelLimits[modelID] = gbp
+	g.mu.Unlock()
+	return nil
+}
+
+func (g *Gate) resolve(l Limit) (float64, error) {
+	if l.Fraction <= 0 {
+		return l.GBP, nil
+	}
+	ceiling := g.Ceiling()
+	if ceiling <= 0 {
+		return 0, fmt.Errorf("a percentage limit needs a budget ceiling")
+	}
+	return l.Fraction * ceiling, nil
+}
+
+func limitKey(kind Cap, id string) string {
+	return string(kind) + "/" + id
+}
+
+// SetLedger shares this gate's ceiling with other processes via l. The
+// gate's own ceiling should match the ledger's.
+func (g *Gate) SetLedger(l Ledger) {
+	g.ledger = l
+}
+
+// SetGlobalSpent updates the shared spend total, e.g. from a periodic
+// coordinator heartbeat. It never moves the total backwards.
+func (g *Gate) SetGlobalSpent(gbp float64) {
+	micro := int64(math.Ceil(gbp * 1_000_000))
+	for {
+		cur := g.globalMicroGBP.Load()
+		if micro <= cur || g.globalMicroGBP.CompareAndSwap(cur, micro) {
+			return
+		}
 	}
 }
 
 // Ceiling returns the configured budget ceiling in GBP.
 func (g *Gate) Ceiling() float64 {
-	return g.ceiling
+	return float64(g.ceilingMicroGBP.Load()) / 1_000_000
+}
+
+// RaiseCeiling lifts the ceiling to gbp during a run. It cannot lower
+// the ceiling, cap an unlimited gate, or change a ceiling shared through
+// a ledger, which belongs to the coordinator. Percentage sub-ceilings
+// keep the amount they resolved to when they were set.
+func (g *Gate) RaiseCeiling(gbp float64) error {
+	if g.ledger != nil {
+		return fmt.Errorf("the ceiling is shared through the coordinator")
+	}
+	micro := int64(math.Round(gbp * 1_000_000))
+	for {
+		cur := g.ceilingMicroGBP.Load()
+		switch {
+		case cur <= 0:
+			return fmt.Errorf("the budget is unlimited")
+		case micro < cur:
+			return fmt.Errorf("£%.2f is below the current ceiling of £%.2f", gbp, float64(cur)/1_000_000)
+		}
+		if g.ceilingMicroGBP.CompareAndSwap(cur, micro) {
+			return nil
+		}
+	}
 }
 
 // Allow checks whether starting a new bout would exceed the budget.
@@ -74,45 +244,171 @@ func (g *Gate) Ceiling() float64 {
 // If the ceiling is 0 (unlimited), Always returns true.
 func (g *Gate) Allow(modelID string, turns int) (estimatedGBP float64, allowed bool) {
 	est := EstimateBoutCost(modelID, turns, DefaultOutputPerTurn)
-	if g.ceiling <= 0 {
+	ceiling := g.Ceiling()
+	if ceiling <= 0 {
 		return est, true
 	}
 	spent := g.Spent()
-	return est, (spent + est) <= g.ceiling
+	return est, (spent + est) <= ceiling
+}
+
+// AllowFor is Allow for a bout run by personaID, which must also fit
+// within the persona's and the model's sub-ceilings. It returns the
+// ceiling that denied the bout, or CapNone. Sub-ceilings only count
+// this process's spend, even when the overall ceiling is shared.
+func (g *Gate) AllowFor(personaID, modelID string, turns int) (estimatedGBP float64, denied Cap) {
+	est, ok := g.Allow(modelID, turns)
+	if !ok {
+		return est, CapGlobal
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if limit, ok := g.personaLimits[personaID]; ok && g.personaSpend[personaID]+est > limit {
+		g.denied[limitKey(CapPersona, personaID)]++
+		return est, CapPersona
+	}
+	if limit, ok := g.modelLimits[modelID]; ok && g.modelSpend[modelID]+est > limit {
+		g.denied[limitKey(CapModel, modelID)]++
+		return est, CapModel
+	}
+	return est, CapNone
 }
 
 // Charge records actual spend (in GBP) after a bout or action completes.
 // This is the authoritative deduction — call it with real token counts
 // when available, or with the estimate as a fallback.
 func (g *Gate) Charge(modelID string, gbp float64) {
+	g.ChargeFor("", modelID, gbp)
+}
+
+// ChargeFor is Charge attributed to a persona, counting towards its
+// sub-ceiling. An empty personaID attributes the spend to no persona.
+func (g *Gate) ChargeFor(personaID, modelID string, gbp float64) {
 	microGBP := int64(math.Ceil(gbp * 1_000_000))
 	g.spentMicroGBP.Add(microGBP)
 
 	g.mu.Lock()
 	g.modelSpend[modelID] += gbp
+	if personaID != "" {
+		g.personaSpend[personaID] += gbp
+	}
 	g.boutCount++
 	g.mu.Unlock()
+
+	if g.ledger != nil {
+		// Count the spend in the shared total straight away, so the gate
+		// closes without waiting for the next FlushLedger.
+		g.globalMicroGBP.Add(microGBP)
+		g.mu.Lock()
+		g.unsent[modelID] += gbp
+		g.mu.Unlock()
+	}
+}
+
+// FlushLedger sends the spend charged since the last flush to the
+// ledger, e.g. on each coordinator heartbeat, so that charging never
+// waits on the network. A batch that fails is resent unchanged by the
+// next flush. It returns the GBP still unsent.
+func (g *Gate) FlushLedger() (unsentGBP float64, err error) {
+	if g.ledger == nil {
+		return 0, nil
+	}
+	g.flushMu.Lock()
+	defer g.flushMu.Unlock()
+
+	if g.batch == nil {
+		g.mu.Lock()
+		if len(g.unsent) > 0 {
+			g.batch, g.unsent = g.unsent, make(map[string]float64)
+			g.batchSeq++
+		}
+		g.mu.Unlock()
+		if g.batch == nil {
+			return 0, nil
+		}
+	}
+
+	global, err := g.ledger.Charge(g.batchSeq, g.batch)
+	if err != nil {
+		g.mu.Lock()
+		for _, gbp := range g.batch {
+			unsentGBP += gbp
+		}
+		for _, gbp := range g.unsent {
+			unsentGBP += gbp
+		}
+		g.mu.Unlock()
+		return unsentGBP, err
+	}
+	g.batch = nil
+	g.SetGlobalSpent(global)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, gbp := range g.unsent {
+		unsentGBP += gbp
+	}
+	return unsentGBP, nil
 }
 
 // ChargeTokens records actual spend computed from real token counts.
 func (g *Gate) ChargeTokens(modelID string, inputTokens, outputTokens int) float64 {
+	return g.ChargeTokensFor("", modelID, inputTokens, outputTokens)
+}
+
+// ChargeTokensFor is ChargeTokens attributed to a persona.
+func (g *Gate) ChargeTokensFor(personaID, modelID string, inputTokens, outputTokens int) float64 {
 	cost := ComputeCost(modelID, inputTokens, outputTokens)
-	g.Charge(modelID, cost)
+	g.ChargeFor(personaID, modelID, cost)
 	return cost
 }
 
-// Spent returns the total GBP spent so far.
+// ChargeUsage records spend by personaID computed from server-reported
+// token counts. estimatedGBP is what the bout would have been charged
+// from estimated tokens; the difference is tracked per model (see
+// Summary.EstimateErrors).
+func (g *Gate) ChargeUsage(personaID, modelID string, inputTokens, outputTokens int, estimatedGBP float64) float64 {
+	cost := ComputeCost(modelID, inputTokens, outputTokens)
+
+	g.mu.Lock()
+	g.actualCount++
+	e, ok := g.estimates[modelID]
+	if !ok {
+		e = &EstimateError{}
+		g.estimates[modelID] = e
+	}
+	e.Samples++
+	e.EstimatedGBP += estimatedGBP
+	e.ActualGBP += cost
+	g.mu.Unlock()
+
+	g.ChargeFor(personaID, modelID, cost)
+	return cost
+}
+
+// Spent returns the total GBP spent so far. With a ledger this is the
+// shared total across all processes.
 func (g *Gate) Spent() float64 {
+	if g.ledger != nil {
+		return float64(max(g.globalMicroGBP.Load(), g.spentMicroGBP.Load())) / 1_000_000
+	}
+	return float64(g.spentMicroGBP.Load()) / 1_000_000
+}
+
+// LocalSpent returns the GBP spent by this process alone.
+func (g *Gate) LocalSpent() float64 {
 	return float64(g.spentMicroGBP.Load()) / 1_000_000
 }
 
 // Remaining returns the GBP remaining in the budget.
 // Returns math.MaxFloat64 if the ceiling is 0 (unlimited).
 func (g *Gate) Remaining() float64 {
-	if g.ceiling <= 0 {
+	ceiling := g.Ceiling()
+	if ceiling <= 0 {
 		return math.MaxFloat64
 	}
-	rem := g.ceiling - g.Spent()
+	rem := ceiling - g.Spent()
 	if rem < 0 {
 		return 0
 	}
@@ -121,10 +417,11 @@ func (g *Gate) Remaining() float64 {
 
 // Exhausted returns true if the budget has been fully spent.
 func (g *Gate) Exhausted() bool {
-	if g.ceiling <= 0 {
+	ceiling := g.Ceiling()
+	if ceiling <= 0 {
 		return false
 	}
-	return g.Spent() >= g.ceiling
+	return g.Spent() >= ceiling
 }
 
 // Summary returns a human-readable budget status.
@@ -135,13 +432,49 @@ type Summary struct {
 	Exhausted    bool               `json:"exhausted"`
 	BoutCount    int                `json:"boutCount"`
 	ByModel      map[string]float64 `json:"byModel"`
+
+	// ActualCount is how many of BoutCount were priced from
+	// server-reported usage rather than estimated.
+	ActualCount    int                      `json:"actualCount,omitempty"`
+	EstimateErrors map[string]EstimateError `json:"estimateErrors,omitempty"`
+
+	ByPersona map[string]float64 `json:"byPersona,omitempty"`
+	Limits    []LimitStatus      `json:"limits,omitempty"`
+}
+
+// LimitStatus is the state of one persona or model sub-ceiling.
+type LimitStatus struct {
+	Kind       Cap     `json:"kind"`
+	ID         string  `json:"id"`
+	CeilingGBP float64 `json:"ceilingGbp"`
+	SpentGBP   float64 `json:"spentGbp"`
+	// Denied is how many bouts the limit turned away.
+	Denied int `json:"denied"`
+}
+
+// EstimateError compares estimated with actual cost for one model, over
+// the charges where the server reported real usage.
+type EstimateError struct {
+	Samples      int     `json:"samples"`
+	EstimatedGBP float64 `json:"estimatedGbp"`
+	ActualGBP    float64 `json:"actualGbp"`
+}
+
+// RelError returns (estimated-actual)/actual: positive when estimates
+// overcharge, negative when they undercharge. It is 0 without data.
+func (e EstimateError) RelError() float64 {
+	if e.ActualGBP == 0 {
+		return 0
+	}
+	return (e.EstimatedGBP - e.ActualGBP) / e.ActualGBP
 }
 
 // Summary returns a snapshot of budget state.
 func (g *Gate) Summary() Summary {
+	ceiling := g.Ceiling()
 	spent := g.Spent()
 	remaining := g.Remaining()
-	if g.ceiling <= 0 {
+	if ceiling <= 0 {
 		remaining = -1 // signal unlimited
 	}
 
@@ -150,30 +483,88 @@ func (g *Gate) Summary() Summary {
 	for k, v := range g.modelSpend {
 		byModel[k] = v
 	}
+	var byPersona map[string]float64
+	if len(g.personaSpend) > 0 {
+		byPersona = make(map[string]float64, len(g.personaSpend))
+		for k, v := range g.personaSpend {
+			byPersona[k] = v
+		}
+	}
+	var limits []LimitStatus
+	for id, gbp := range g.personaLimits {
+		limits = append(limits, LimitStatus{Kind: CapPersona, ID: id, CeilingGBP: gbp,
+			SpentGBP: g.personaSpend[id], Denied: g.denied[limitKey(CapPersona, id)]})
+	}
+	for id, gbp := range g.modelLimits {
+		limits = append(limits, LimitStatus{Kind: CapModel, ID: id, CeilingGBP: gbp,
+			SpentGBP: g.modelSpend[id], Denied: g.denied[limitKey(CapModel, id)]})
+	}
 	boutCount := g.boutCount
+	actualCount := g.actualCount
+	var estimates map[string]EstimateError
+	if len(g.estimates) > 0 {
+		estimates = make(map[string]EstimateError, len(g.estimates))
+		for k, v := range g.estimates {
+			estimates[k] = *v
+		}
+	}
 	g.mu.Unlock()
 
+	sort.Slice(limits, func(i, j int) bool {
+		if limits[i].Kind != limits[j].Kind {
+			return limits[i].Kind > limits[j].Kind // personas first
+		}
+		return limits[i].ID < limits[j].ID
+	})
+
 	return Summary{
-		CeilingGBP:   g.ceiling,
+		CeilingGBP:   ceiling,
 		SpentGBP:     spent,
 		RemainingGBP: remaining,
-		Exhausted:    g.ceiling > 0 && spent >= g.ceiling,
+		Exhausted:    ceiling > 0 && spent >= ceiling,
 		BoutCount:    boutCount,
 		ByModel:      byModel,
+
+		ActualCount:    actualCount,
+		EstimateErrors: estimates,
+
+		ByPersona: byPersona,
+		Limits:    limits,
 	}
 }
 
 // FormatSummary returns a terminal-friendly budget summary.
 func FormatSummary(s Summary) string {
+	var b strings.Builder
 	if s.CeilingGBP <= 0 {
-		return fmt.Sprintf("  Budget: unlimited (spent £%.4f across %d bouts)", s.SpentGBP, s.BoutCount)
+		fmt.Fprintf(&b, "  Budget: unlimited (spent £%.4f across %d bouts)", s.SpentGBP, s.BoutCount)
+	} else {
+		pct := (s.SpentGBP / s.CeilingGBP) * 100
+		fmt.Fprintf(&b, "  Budget: £%.4f / £%.2f (%.1f%%, %d bouts, £%.4f remaining)",
+			s.SpentGBP, s.CeilingGBP, pct, s.BoutCount, s.RemainingGBP)
 	}
-	pct := 0.0
-	if s.CeilingGBP > 0 {
-		pct = (s.SpentGBP / s.CeilingGBP) * 100
+
+	if s.ActualCount > 0 {
+		fmt.Fprintf(&b, "\n  Usage:  %d of %d bouts priced from reported tokens", s.ActualCount, s.BoutCount)
+		models := make([]string, 0, len(s.EstimateErrors))
+		for m := range s.EstimateErrors {
+			models = append(models, m)
+		}
+		sort.Strings(models)
+		for _, m := range models {
+			e := s.EstimateErrors[m]
+			fmt.Fprintf(&b, "\n    %-28s estimate error %+6.1f%% (n=%d, est £%.4f vs actual £%.4f)",
+				m, e.RelError()*100, e.Samples, e.EstimatedGBP, e.ActualGBP)
+		}
+	}
+
+	for _, l := range s.Limits {
+		fmt.Fprintf(&b, "\n  Limit:  %-7s %-28s £%.4f / £%.4f", l.Kind, l.ID, l.SpentGBP, l.CeilingGBP)
+		if l.Denied > 0 {
+			fmt.Fprintf(&b, " (reached, %d bouts denied)", l.Denied)
+		}
 	}
-	return fmt.Sprintf("  Budget: £%.4f / £%.2f (%.1f%%, %d bouts, £%.4f remaining)",
-		s.SpentGBP, s.CeilingGBP, pct, s.BoutCount, s.RemainingGBP)
+	return b.String()
 }
 
 // ---------- Cost estimation ----------
@@ -1,6 +1,7 @@
 package budget
 
 import (
+	"errors"
 	"math"
 	"strings"
 	"sync"
@@ -135,6 +136,23 @@ func TestExhausted(t *testing.T) {
 	}
 }
 
+func TestRaiseCeiling(t *testing.T) {
+	g := NewGate(0.05)
+	g.Charge("test", 0.05)
+	if err := g.RaiseCeiling(0.04); err == nil {
+		t.Error("lowering the ceiling should fail")
+	}
+	if err := g.RaiseCeiling(0.10); err != nil {
+		t.Fatalf("RaiseCeiling: %v", err)
+	}
+	if g.Exhausted() || g.Ceiling() != 0.10 {
+		t.Errorf("after raise: exhausted=%v ceiling=%v", g.Exhausted(), g.Ceiling())
+	}
+	if err := NewGate(0).RaiseCeiling(5); err == nil {
+		t.Error("capping an unlimited gate should fail")
+	}
+}
+
 func TestSummary(t *testing.T) {
 	g := NewGate(5.0)
 	g.Charge("claude-sonnet-4-5-20250929", 0.10)
@@ -196,6 +214,111 @@ func TestFormatSummaryUnlimited(t *testing.T) {
 	}
 }
 
+func TestChargeUsage(t *testing.T) {
+	g := NewGate(10)
+	model := "claude-haiku-4-5-20251001"
+	actual := ComputeCost(model, 4000, 600)
+
+	got := g.ChargeUsage("", model, 4000, 600, actual*1.5)
+	if math.Abs(got-actual) > 1e-12 {
+		t.Errorf("ChargeUsage = %f, want %f", got, actual)
+	}
+	g.ChargeTokens(model, 100, 20) // estimated, no reported usage
+
+	s := g.Summary()
+	if s.BoutCount != 2 || s.ActualCount != 1 {
+		t.Errorf("BoutCount = %d, ActualCount = %d; want 2, 1", s.BoutCount, s.ActualCount)
+	}
+	e, ok := s.EstimateErrors[model]
+	if !ok || e.Samples != 1 {
+		t.Fatalf("EstimateErrors = %+v", s.EstimateErrors)
+	}
+	if math.Abs(e.RelError()-0.5) > 1e-9 {
+		t.Errorf("RelError = %f, want 0.5 (estimates overcharge by 50%%)", e.RelError())
+	}
+	if math.Abs(s.SpentGBP-(actual+ComputeCost(model, 100, 20))) > 1e-5 {
+		t.Errorf("SpentGBP = %f, want the actual cost plus the estimated charge", s.SpentGBP)
+	}
+
+	text := FormatSummary(s)
+	if !strings.Contains(text, "1 of 2 bouts priced from reported tokens") || !strings.Contains(text, "+50.0%") {
+		t.Errorf("summary missing estimate error: %s", text)
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Limit
+	}{
+		{"1", Limit{GBP: 1}},
+		{"£0.50", Limit{GBP: 0.5}},
+		{"20%", Limit{Fraction: 0.2}},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "0", "-1", "abc", "0%", "150%"} {
+		if _, err := ParseLimit(bad); err == nil {
+			t.Errorf("ParseLimit(%q) should fail", bad)
+		}
+	}
+}
+
+func TestPersonaLimit(t *testing.T) {
+	g := NewGate(10)
+	model := "claude-haiku-4-5-20251001"
+	if err := g.SetPersonaLimit("abusive", Limit{GBP: 0.05}); err != nil {
+		t.Fatal(err)
+	}
+	est := EstimateBoutCost(model, 6, DefaultOutputPerTurn)
+
+	if _, capped := g.AllowFor("abusive", model, 6); capped != CapNone {
+		t.Fatalf("fresh persona capped by %q", capped)
+	}
+	for g.Summary().ByPersona["abusive"]+est <= 0.05 {
+		g.ChargeFor("abusive", model, est)
+	}
+	if _, capped := g.AllowFor("abusive", model, 6); capped != CapPersona {
+		t.Errorf("capped = %q, want %q", capped, CapPersona)
+	}
+	if _, capped := g.AllowFor("free-casual", model, 6); capped != CapNone {
+		t.Errorf("other persona capped by %q", capped)
+	}
+
+	s := g.Summary()
+	if len(s.Limits) != 1 || s.Limits[0].Denied != 1 || s.Limits[0].Kind != CapPersona {
+		t.Errorf("Limits = %+v", s.Limits)
+	}
+	if !strings.Contains(FormatSummary(s), "(reached, 1 bouts denied)") {
+		t.Errorf("summary missing limit: %s", FormatSummary(s))
+	}
+}
+
+func TestModelLimitFraction(t *testing.T) {
+	g := NewGate(1)
+	if err := g.SetModelLimit("claude-opus-4-6", Limit{Fraction: 0.2}); err != nil {
+		t.Fatal(err)
+	}
+	g.ChargeFor("lab-power-user", "claude-opus-4-6", 0.19)
+	if _, capped := g.AllowFor("lab-power-user", "claude-opus-4-6", 12); capped != CapModel {
+		t.Errorf("capped = %q, want %q", capped, CapModel)
+	}
+	if _, capped := g.AllowFor("lab-power-user", "claude-haiku-4-5-20251001", 12); capped != CapNone {
+		t.Errorf("other model capped by %q", capped)
+	}
+	if s := g.Summary(); s.Limits[0].CeilingGBP != 0.2 {
+		t.Errorf("CeilingGBP = %f, want 0.2", s.Limits[0].CeilingGBP)
+	}
+
+	if err := NewGate(0).SetModelLimit("claude-opus-4-6", Limit{Fraction: 0.2}); err == nil {
+		t.Error("percentage limit without a ceiling should fail")
+	}
+}
+
 // ---------- Cost estimation ----------
 
 func TestEstimateBoutCostHaiku(t *testing.T) {
@@ -262,6 +385,73 @@ func TestEstimateRunCost(t *testing.T) {
 	}
 }
 
+// flakyLedger is a Ledger that dedupes batches by seq, like the
+// coordinator. While down it fails; while lossy it applies the batch
+// but fails as if the response were lost.
+type flakyLedger struct {
+	down, lossy bool
+	seq         int64
+	spent       float64
+	calls       int
+}
+
+func (l *flakyLedger) Charge(seq int64, spend map[string]float64) (float64, error) {
+	l.calls++
+	if l.down {
+		return 0, errors.New("unreachable")
+	}
+	if seq > l.seq {
+		l.seq = seq
+		for _, gbp := range spend {
+			l.spent += gbp
+		}
+	}
+	if l.lossy {
+		return 0, errors.New("timeout")
+	}
+	return l.spent, nil
+}
+
+func TestFlushLedger(t *testing.T) {
+	l := &flakyLedger{down: true}
+	g := NewGate(1)
+	g.SetLedger(l)
+
+	g.Charge("a", 0.2)
+	g.Charge("b", 0.3)
+	g.Charge("a", 0.1)
+	if l.calls != 0 {
+		t.Errorf("charging called the ledger %d times, want none before a flush", l.calls)
+	}
+	if got := g.Spent(); math.Abs(got-0.6) > 1e-5 {
+		t.Errorf("Spent before a flush = %v, want 0.6 counted locally", got)
+	}
+	if unsent, err := g.FlushLedger(); err == nil || math.Abs(unsent-0.6) > 1e-9 {
+		t.Errorf("FlushLedger while down = %v, %v; want 0.6 unsent", unsent, err)
+	}
+
+	// The batch arrives but the response is lost: the resend under the
+	// same seq must not be applied twice.
+	l.down, l.lossy = false, true
+	if _, err := g.FlushLedger(); err == nil {
+		t.Fatal("FlushLedger with a lost response succeeded")
+	}
+	g.Charge("a", 0.1)
+	l.lossy = false
+	if unsent, err := g.FlushLedger(); err != nil || math.Abs(unsent-0.1) > 1e-9 {
+		t.Fatalf("FlushLedger = %v, %v; want the new 0.1 left for the next flush", unsent, err)
+	}
+	if math.Abs(l.spent-0.6) > 1e-9 {
+		t.Errorf("ledger spent = %v after the resend, want 0.6", l.spent)
+	}
+	if unsent, err := g.FlushLedger(); err != nil || unsent != 0 || math.Abs(l.spent-0.7) > 1e-9 {
+		t.Errorf("FlushLedger = %v, %v; ledger spent %v, want 0.7", unsent, err, l.spent)
+	}
+	if got := g.Spent(); math.Abs(got-0.7) > 1e-5 {
+		t.Errorf("Spent after flushing = %v, want 0.7", got)
+	}
+}
+
 // ---------- Concurrency ----------
 
 func TestConcurrentCharging(t *testing.T) {
@@ -78,12 +78,49 @@ func DefaultConfig(baseURL string) Config {
 
 // Client is a pooled HTTP client with auth injection and retry logic.
 type Client struct {
-	cfg     Config
-	std     *http.Client // for normal requests
-	stream  *http.Client // for SSE / long-poll requests
-	logf    func(string, ...any)
-	tokenMu sync.RWMutex
-	tokens  map[string]string // accountID -> Clerk session token
+	cfg    Config
+	std    *http.Client // for normal requests
+	stream *http.Client // for SSE / long-poll requests
+	logf   func(string, ...any)
+	tokens *tokenStore
+
+	// observer, if set, is called with every completed HTTP attempt.
+	observer func(Exchange)
+}
+
+// Exchange is one HTTP attempt as sent and received, reported to the
+// observer set with SetObserver. Headers are as sent, tokens included;
+// redacting them is the observer's job.
+type Exchange struct {
+	Started        time.Time
+	Method         string
+	URL            string
+	RequestHeader  http.Header
+	RequestBody    []byte
+	StatusCode     int // 0 if no response was received
+	ResponseHeader http.Header
+	// ResponseBody is nil for a successful stream, which is consumed by
+	// the caller rather than read here.
+	ResponseBody []byte
+	Streamed     bool
+	Wait         time.Duration // until response headers arrived
+	Receive      time.Duration // reading the response body
+	Attempt      int
+	Err          error
+}
+
+// SetObserver registers fn to be called, synchronously, after every
+// HTTP attempt, including failed and retried ones — for a successful
+// stream, once its body is closed. Call it before the client is used
+// concurrently.
+func (c *Client) SetObserver(fn func(Exchange)) {
+	c.observer = fn
+}
+
+func (c *Client) observe(x Exchange) {
+	if c.observer != nil {
+		c.observer(x)
+	}
 }
 
 // New creates a Client. The logf function is called for verbose output;
@@ -111,31 +148,48 @@ func New(cfg Config, logf func(string, ...any)) *Client {
 			Transport: transport, // shared pool
 		},
 		logf:   logf,
-		tokens: make(map[string]string),
+		tokens: &tokenStore{m: make(map[string]string)},
 	}
 }
 
+// Mirror creates a client for another target with c's configuration
+// that shares c's session tokens, so tokens set or refreshed on either
+// client are used by both.
+func (c *Client) Mirror(baseURL string) *Client {
+	cfg := c.cfg
+	cfg.BaseURL = baseURL
+	m := New(cfg, c.logf)
+	m.tokens = c.tokens
+	return m
+}
+
+// tokenStore maps account IDs to Clerk session tokens.
+type tokenStore struct {
+	mu sync.RWMutex
+	m  map[string]string
+}
+
 // SetToken registers a Clerk session token for the given account.
 func (c *Client) SetToken(accountID, token string) {
-	c.tokenMu.Lock()
-	defer c.tokenMu.Unlock()
-	c.tokens[accountID] = token
+	c.tokens.mu.Lock()
+	defer c.tokens.mu.Unlock()
+	c.tokens.m[accountID] = token
 }
 
 // GetToken returns the current session token for an account.
 // Returns ("", false) if no token is registered.
 func (c *Client) GetToken(accountID string) (string, bool) {
-	c.tokenMu.RLock()
-	defer c.tokenMu.RUnlock()
-	tok, ok := c.tokens[accountID]
+	c.tokens.mu.RLock()
+	defer c.tokens.mu.RUnlock()
+	tok, ok := c.tokens.m[accountID]
 	return tok, ok
 }
 
 // ClearToken removes the session token for an account.
 func (c *Client) ClearToken(accountID string) {
-	c.tokenMu.Lock()
-	defer c.tokenMu.Unlock()
-	delete(c.tokens, accountID)
+	c.tokens.mu.Lock()
+	defer c.tokens.mu.Unlock()
+	delete(c.tokens.m, accountID)
 }
 
 // Response wraps an HTTP response with convenience fields for
@@ -161,12 +215,13 @@ func (c *Client) Do(ctx context.Context, method, path, accountID string, body an
 func (c *Client) DoStream(ctx context.Context, method, path, accountID string, body any) (io.ReadCloser, http.Header, int, time.Duration, error) {
 	url := c.cfg.BaseURL + path
 	var bodyReader io.Reader
+	var bodyBytes []byte
 	if body != nil {
 		b, err := json.Marshal(body)
 		if err != nil {
 			return nil, nil, 0, 0, fmt.Errorf("marshal body: %w", err)
 		}
-		bodyReader = bytes.NewReader(b)
+		bodyReader, bodyBytes = bytes.NewReader(b), b
 	}
 
 	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
@@ -178,9 +233,14 @@ func (c *Client) DoStream(ctx context.Context, method, path, accountID string, b
 	start := time.Now()
 	resp, err := c.stream.Do(req)
 	elapsed := time.Since(start)
+	x := Exchange{Started: start, Method: method, URL: url, RequestHeader: req.Header,
+		RequestBody: bodyBytes, Wait: elapsed, Attempt: 1}
 	if err != nil {
+		x.Err = err
+		c.observe(x)
 		return nil, nil, 0, elapsed, fmt.Errorf("HTTP request: %w", err)
 	}
+	x.StatusCode, x.ResponseHeader = resp.StatusCode, resp.Header
 
 	if c.cfg.Verbose {
 		c.logf("[stream] %s %s -> %d (%s)", method, path, resp.StatusCode, elapsed.Truncate(time.Millisecond))
@@ -189,10 +249,41 @@ func (c *Client) DoStream(ctx context.Context, method, path, accountID string, b
 	if resp.StatusCode != http.StatusOK {
 		errBody, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
+		x.ResponseBody, x.Receive = errBody, time.Since(start)-elapsed
+		c.observe(x)
 		return nil, resp.Header, resp.StatusCode, elapsed, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(errBody))
 	}
 
-	return resp.Body, resp.Header, resp.StatusCode, elapsed, nil
+	x.Streamed = true
+	return &streamBody{ReadCloser: resp.Body, c: c, x: x}, resp.Header, resp.StatusCode, elapsed, nil
+}
+
+// streamBody is the body of a successful stream. Its exchange is
+// observed when the body is closed, so that an error the caller found
+// in the stream (see ReportStreamError) is part of it.
+type streamBody struct {
+	io.ReadCloser
+	c    *Client
+	x    Exchange
+	once sync.Once
+}
+
+func (b *streamBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		b.x.Receive = time.Since(b.x.Started) - b.x.Wait
+		b.c.observe(b.x)
+	})
+	return err
+}
+
+// ReportStreamError records err, an error found while reading a stream
+// returned by DoStream, on the stream's exchange, so the observer sees
+// it as failed when the body is closed. Other bodies are ignored.
+func ReportStreamError(body io.ReadCloser, err error) {
+	if b, ok := body.(*streamBody); ok {
+		b.x.Err = err
+	}
 }
 
 func (c *Client) do(ctx context.Context, method, path, accountID string, body any, streaming bool) (*Response, error) {
@@ -230,8 +321,12 @@ func (c *Client) do(ctx context.Context, method, path, accountID string, body an
 		start := time.Now()
 		resp, err := hc.Do(req)
 		elapsed := time.Since(start)
+		x := Exchange{Started: start, Method: method, URL: url, RequestHeader: req.Header,
+			RequestBody: bodyBytes, Wait: elapsed, Attempt: attempt}
 
 		if err != nil {
+			x.Err = err
+			c.observe(x)
 			lastErr = fmt.Errorf("HTTP request (attempt %d/%d): %w", attempt, maxAttempts, err)
 			if attempt < maxAttempts {
 				c.backoff(ctx, attempt)
@@ -242,6 +337,9 @@ func (c *Client) do(ctx context.Context, method, path, accountID string, body an
 
 		respBody, err := io.ReadAll(resp.Body)
 		resp.Body.Close()
+		x.StatusCode, x.ResponseHeader, x.ResponseBody = resp.StatusCode, resp.Header, respBody
+		x.Receive, x.Err = time.Since(start)-elapsed, err
+		c.observe(x)
 		if err != nil {
 			lastErr = fmt.Errorf("read response (attempt %d/%d): %w", attempt, maxAttempts, err)
 			if attempt < maxAttempts {
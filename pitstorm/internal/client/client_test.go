@@ -3,6 +3,7 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -138,6 +139,32 @@ func TestDoWithAuth(t *testing.T) {
 	}
 }
 
+func TestMirrorSharesTokens(t *testing.T) {
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := New(DefaultConfig("https://example.com"), nil)
+	defer c.Close()
+	m := c.Mirror(srv.URL)
+	defer m.Close()
+
+	// A token set on the original after mirroring reaches the mirror.
+	c.SetToken("user-1", "tok-refreshed")
+	if _, err := m.Do(context.Background(), "GET", "/api/agents", "user-1", nil); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if gotAuth != "Bearer tok-refreshed" {
+		t.Errorf("Authorization = %q, want the original's token", gotAuth)
+	}
+	if m.cfg.BaseURL != srv.URL || c.cfg.BaseURL != "https://example.com" {
+		t.Errorf("BaseURL = %q / %q", m.cfg.BaseURL, c.cfg.BaseURL)
+	}
+}
+
 func TestDoWithAuthNoToken(t *testing.T) {
 	var gotAuth string
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -223,6 +250,86 @@ func TestDoRetryOn503(t *testing.T) {
 	}
 }
 
+func TestObserverSeesEveryAttempt(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("overloaded"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer srv.Close()
+
+	cfg := DefaultConfig(srv.URL)
+	cfg.RetryBase = time.Millisecond
+	c := New(cfg, nil)
+	defer c.Close()
+	c.SetToken("acct", "secret")
+
+	var seen []Exchange
+	c.SetObserver(func(x Exchange) { seen = append(seen, x) })
+
+	if _, err := c.Do(context.Background(), "POST", "/api/agents", "acct", map[string]string{"name": "x"}); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	body, _, _, _, err := c.DoStream(context.Background(), "POST", "/api/run-bout", "", nil)
+	if err != nil {
+		t.Fatalf("DoStream: %v", err)
+	}
+	body.Close()
+
+	if len(seen) != 3 {
+		t.Fatalf("observed %d exchanges, want 3", len(seen))
+	}
+	first := seen[0]
+	if first.StatusCode != 503 || string(first.ResponseBody) != "overloaded" || first.Attempt != 1 {
+		t.Errorf("first = %d %q attempt %d", first.StatusCode, first.ResponseBody, first.Attempt)
+	}
+	if first.RequestHeader.Get("Authorization") != "Bearer secret" || string(first.RequestBody) != `{"name":"x"}` {
+		t.Errorf("request = %v %q", first.RequestHeader, first.RequestBody)
+	}
+	if seen[1].StatusCode != 200 || seen[1].Attempt != 2 {
+		t.Errorf("retry = %d attempt %d", seen[1].StatusCode, seen[1].Attempt)
+	}
+	if !seen[2].Streamed || seen[2].ResponseBody != nil {
+		t.Errorf("stream = %+v, want an uncaptured streamed body", seen[2])
+	}
+}
+
+func TestObserverSeesReportedStreamError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(`data: {"type":"error","errorText":"boom"}` + "\n\n"))
+	}))
+	defer srv.Close()
+
+	c := New(DefaultConfig(srv.URL), nil)
+	defer c.Close()
+	var seen []Exchange
+	c.SetObserver(func(x Exchange) { seen = append(seen, x) })
+
+	body, _, _, _, err := c.DoStream(context.Background(), "POST", "/api/run-bout", "", nil)
+	if err != nil {
+		t.Fatalf("DoStream: %v", err)
+	}
+	if len(seen) != 0 {
+		t.Fatalf("stream observed before its body was closed")
+	}
+	ReportStreamError(body, errors.New("stream error: boom"))
+	body.Close()
+	body.Close()
+
+	if len(seen) != 1 {
+		t.Fatalf("observed %d exchanges, want 1", len(seen))
+	}
+	if x := seen[0]; x.StatusCode != 200 || x.Err == nil || !x.Streamed {
+		t.Errorf("stream = %d %v streamed=%v, want a 200 carrying the reported error", x.StatusCode, x.Err, x.Streamed)
+	}
+}
+
 func TestDoRetryExhausted(t *testing.T) {
 	var attempts atomic.Int32
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -19,6 +19,7 @@ const (
 	EventTextDelta     = "text-delta"
 	EventTextEnd       = "text-end"
 	EventDataShareLine = "data-share-line"
+	EventDataUsage     = "data-usage"
 	EventError         = "error"
 	EventDone          = "[DONE]"
 )
@@ -40,6 +41,9 @@ type SSEEvent struct {
 	// For data-share-line events.
 	ShareLine *ShareLineData `json:"-"`
 
+	// For data-usage events.
+	Usage *UsageData `json:"-"`
+
 	// For error events.
 	ErrorText string `json:"errorText,omitempty"`
 
@@ -60,6 +64,13 @@ type ShareLineData struct {
 	Text string `json:"text"`
 }
 
+// UsageData carries provider-reported token usage. A server may emit it
+// once per turn or once per bout; the stream's totals are the sum.
+type UsageData struct {
+	InputTokens  int `json:"inputTokens"`
+	OutputTokens int `json:"outputTokens"`
+}
+
 // StreamResult summarises a completed SSE stream.
 type StreamResult struct {
 	Turns      []TurnResult
@@ -70,15 +81,31 @@ type StreamResult struct {
 	TotalChars int
 	Duration   time.Duration
 	FirstByte  time.Duration // time to first text-delta
+
+	// Usage is the summed token usage from data-usage events, or nil if
+	// the server did not report any.
+	Usage *UsageData
 }
 
-// TurnResult holds the accumulated text for a single turn.
+// TurnResult holds the accumulated text and timings for a single turn.
 type TurnResult struct {
 	Turn      int
 	AgentID   string
 	AgentName string
 	Color     string
 	Text      string
+
+	// Timings are measured from the turn's data-turn event: FirstDelta
+	// is its time to first token and LastDelta when its text finished.
+	// Both are zero for a turn without text.
+	FirstDelta time.Duration
+	LastDelta  time.Duration
+	Deltas     int
+
+	// Gaps are the pauses between the turn's consecutive text-deltas. A
+	// stream that ends mid-turn (e.g. on a timeout) adds the pause since
+	// the turn's last delta, so a stream that hangs still shows a gap.
+	Gaps []time.Duration
 }
 
 // SSECallback is invoked for each parsed event during streaming.
@@ -106,6 +133,19 @@ func ParseSSEStream(r io.Reader, onEvent SSECallback) (*StreamResult, error) {
 	firstDelta := false
 	var currentTurn *TurnResult
 
+	// Per-turn timing: when the current turn started and when its last
+	// delta arrived. turnOpen is true between its first delta and
+	// text-end.
+	var turnStart, lastDelta time.Time
+	turnOpen := false
+	// endOpenTurn records the pause of a turn cut off mid-stream.
+	endOpenTurn := func() {
+		if turnOpen {
+			t := &result.Turns[len(result.Turns)-1]
+			t.Gaps = append(t.Gaps, time.Since(lastDelta))
+		}
+	}
+
 	for scanner.Scan() {
 		line := scanner.Text()
 
@@ -147,6 +187,7 @@ func ParseSSEStream(r io.Reader, onEvent SSECallback) (*StreamResult, error) {
 				return result, fmt.Errorf("parse data-turn: %w", err)
 			}
 			event.Turn = &envelope.Data
+			turnStart, turnOpen = time.Now(), false
 			currentTurn = &TurnResult{
 				Turn:      envelope.Data.Turn,
 				AgentID:   envelope.Data.AgentID,
@@ -164,9 +205,22 @@ func ParseSSEStream(r io.Reader, onEvent SSECallback) (*StreamResult, error) {
 			}
 			// Append delta text to the current turn.
 			if len(result.Turns) > 0 {
-				result.Turns[len(result.Turns)-1].Text += event.Delta
+				now := time.Now()
+				t := &result.Turns[len(result.Turns)-1]
+				t.Text += event.Delta
+				if t.Deltas == 0 {
+					t.FirstDelta = now.Sub(turnStart)
+				} else {
+					t.Gaps = append(t.Gaps, now.Sub(lastDelta))
+				}
+				t.Deltas++
+				t.LastDelta = now.Sub(turnStart)
+				lastDelta, turnOpen = now, true
 			}
 
+		case EventTextEnd:
+			turnOpen = false
+
 		case EventDataShareLine:
 			var envelope struct {
 				Data ShareLineData `json:"data"`
@@ -177,23 +231,40 @@ func ParseSSEStream(r io.Reader, onEvent SSECallback) (*StreamResult, error) {
 			event.ShareLine = &envelope.Data
 			result.ShareLine = envelope.Data.Text
 
+		case EventDataUsage:
+			var envelope struct {
+				Data UsageData `json:"data"`
+			}
+			if err := json.Unmarshal([]byte(data), &envelope); err != nil {
+				return result, fmt.Errorf("parse data-usage: %w", err)
+			}
+			event.Usage = &envelope.Data
+			if result.Usage == nil {
+				result.Usage = &UsageData{}
+			}
+			result.Usage.InputTokens += envelope.Data.InputTokens
+			result.Usage.OutputTokens += envelope.Data.OutputTokens
+
 		case EventError:
 			result.Error = event.ErrorText
 
-		case EventStart, EventTextStart, EventTextEnd:
+		case EventStart, EventTextStart:
 			// No additional parsing needed.
 		}
 
 		// Fire callback if provided.
 		if onEvent != nil {
 			if err := onEvent(event); err != nil {
+				endOpenTurn()
 				result.Duration = time.Since(start)
 				return result, fmt.Errorf("callback aborted stream: %w", err)
 			}
 		}
 	}
 
+	endOpenTurn()
 	if err := scanner.Err(); err != nil {
+		result.Duration = time.Since(start)
 		return result, fmt.Errorf("reading stream: %w", err)
 	}
 
@@ -2,8 +2,11 @@ package client
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"strings"
 	"testing"
+	"time"
 )
 
 // simulatedBoutStream builds a realistic 2-turn SSE stream.
@@ -260,6 +263,27 @@ func TestParseSSEStreamShareLineParsing(t *testing.T) {
 	}
 }
 
+func TestParseSSEStreamUsage(t *testing.T) {
+	stream := `data: {"type":"data-usage","data":{"inputTokens":700,"outputTokens":120}}` + "\n\n" +
+		`data: {"type":"data-usage","data":{"inputTokens":820,"outputTokens":95}}` + "\n\n" +
+		"data: [DONE]\n\n"
+	result, err := ParseSSEStream(strings.NewReader(stream), nil)
+	if err != nil {
+		t.Fatalf("ParseSSEStream: %v", err)
+	}
+	if result.Usage == nil || result.Usage.InputTokens != 1520 || result.Usage.OutputTokens != 215 {
+		t.Errorf("Usage = %+v, want 1520 in / 215 out", result.Usage)
+	}
+
+	result, err = ParseSSEStream(strings.NewReader("data: [DONE]\n\n"), nil)
+	if err != nil {
+		t.Fatalf("ParseSSEStream: %v", err)
+	}
+	if result.Usage != nil {
+		t.Errorf("Usage = %+v, want nil when not reported", result.Usage)
+	}
+}
+
 func TestParseSSEStreamTextDeltaAccumulation(t *testing.T) {
 	stream := `data: {"type":"data-turn","data":{"turn":0,"agentId":"a","agentName":"A","color":"#000"}}` + "\n\n" +
 		`data: {"type":"text-delta","id":"x","delta":"Hello "}` + "\n\n" +
@@ -279,6 +303,56 @@ func TestParseSSEStreamTextDeltaAccumulation(t *testing.T) {
 	}
 }
 
+func TestParseSSEStreamTurnTimings(t *testing.T) {
+	pr, pw := io.Pipe()
+	go func() {
+		send := func(pause time.Duration, event string) {
+			time.Sleep(pause)
+			fmt.Fprintf(pw, "data: %s\n\n", event)
+		}
+		turn := func(n int) string {
+			return fmt.Sprintf(`{"type":"data-turn","data":{"turn":%d,"agentId":"a%d","agentName":"A"}}`, n, n)
+		}
+		delta := `{"type":"text-delta","id":"t","delta":"word "}`
+		send(0, turn(0))
+		send(30*time.Millisecond, delta)
+		send(0, delta)
+		send(60*time.Millisecond, delta)
+		send(0, `{"type":"text-end","id":"t"}`)
+		// The next turn's first token is not a gap in the first turn.
+		send(0, turn(1))
+		send(40*time.Millisecond, delta)
+		// The stream dies mid-turn.
+		time.Sleep(50 * time.Millisecond)
+		pw.CloseWithError(errors.New("connection reset"))
+	}()
+
+	result, err := ParseSSEStream(pr, nil)
+	if err == nil {
+		t.Fatal("expected a read error")
+	}
+	if len(result.Turns) != 2 {
+		t.Fatalf("Turns = %d, want 2", len(result.Turns))
+	}
+
+	t0, t1 := result.Turns[0], result.Turns[1]
+	if t0.Deltas != 3 || len(t0.Gaps) != 2 {
+		t.Fatalf("turn 0: %d deltas, gaps %v", t0.Deltas, t0.Gaps)
+	}
+	if t0.FirstDelta < 30*time.Millisecond || t0.Gaps[1] < 60*time.Millisecond || t0.LastDelta < 90*time.Millisecond {
+		t.Errorf("turn 0: first %s, gaps %v, last %s", t0.FirstDelta, t0.Gaps, t0.LastDelta)
+	}
+	if t1.FirstDelta < 40*time.Millisecond {
+		t.Errorf("turn 1 first delta = %s", t1.FirstDelta)
+	}
+	if len(t1.Gaps) != 1 || t1.Gaps[0] < 50*time.Millisecond {
+		t.Errorf("turn 1 gaps = %v, want the pause before the stream died", t1.Gaps)
+	}
+	if result.Duration == 0 {
+		t.Error("Duration not set on a failed read")
+	}
+}
+
 func TestTruncate(t *testing.T) {
 	tests := []struct {
 		input  string
@@ -0,0 +1,279 @@
+// Package compare diffs two pitstorm run outputs and flags regressions
+// that are large enough to matter and unlikely to be noise.
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+	"github.com/rickhallett/thepit/pitstorm/internal/credits"
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+)
+
+// Run is a run output file as written by `pitstorm run --output`: the
+// metrics snapshot plus, for newer files, the budget summary.
+type Run struct {
+	metrics.Snapshot
+	Budget *budget.Summary `json:"budget,omitempty"`
+
+	// Credits is the credit depletion report of a run with --credits.
+	Credits *credits.Report `json:"credits,omitempty"`
+
+	// Timeline is the run's metrics interval by interval.
+	Timeline []metrics.Interval `json:"timeline,omitempty"`
+}
+
+// Load reads a run output file.
+func Load(path string) (Run, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Run{}, fmt.Errorf("read %s: %w", path, err)
+	}
+	var r Run
+	if err := json.Unmarshal(data, &r); err != nil {
+		return Run{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// CostPerBout returns the average GBP spent per bout, if known.
+func (r Run) CostPerBout() (float64, bool) {
+	if r.Budget == nil || r.Budget.BoutCount == 0 {
+		return 0, false
+	}
+	return r.Budget.SpentGBP / float64(r.Budget.BoutCount), true
+}
+
+// Options controls what counts as a meaningful change.
+type Options struct {
+	Alpha        float64 // significance level for the two-sided tests
+	MinRelChange float64 // smaller relative changes are never flagged
+	MinSamples   int     // latency comparisons need this many samples on both sides
+}
+
+// DefaultOptions returns the default comparison thresholds.
+func DefaultOptions() Options {
+	return Options{Alpha: 0.01, MinRelChange: 0.10, MinSamples: 20}
+}
+
+// Verdict classifies a delta.
+type Verdict string
+
+const (
+	Unchanged   Verdict = ""
+	Regression  Verdict = "regression"
+	Improvement Verdict = "improvement"
+)
+
+// Delta is the change in one metric between baseline and candidate.
+type Delta struct {
+	Metric    string
+	Unit      string // "ms", "%", "req/s", "GBP"
+	Baseline  float64
+	Candidate float64
+	// RelChange is (candidate-baseline)/baseline; NaN when baseline is 0.
+	RelChange float64
+	// PValue is from the test behind Verdict; NaN when no test applies.
+	PValue  float64
+	Verdict Verdict
+}
+
+// EndpointDelta groups the latency deltas for one endpoint.
+type EndpointDelta struct {
+	Endpoint string
+	Deltas   []Delta
+}
+
+// Report is the full comparison.
+type Report struct {
+	Overview  []Delta
+	Latency   []EndpointDelta
+	FirstByte []EndpointDelta
+}
+
+// Regressions returns every delta flagged as a regression.
+func (r Report) Regressions() []Delta {
+	var out []Delta
+	collect := func(ds []Delta) {
+		for _, d := range ds {
+			if d.Verdict == Regression {
+				out = append(out, d)
+			}
+		}
+	}
+	collect(r.Overview)
+	for _, groups := range [][]EndpointDelta{r.Latency, r.FirstByte} {
+		for _, g := range groups {
+			collect(g.Deltas)
+		}
+	}
+	return out
+}
+
+// Compare diffs candidate against baseline.
+func Compare(baseline, candidate Run, opts Options) Report {
+	var rep Report
+
+	// Error rate: two-proportion z-test on errors/requests.
+	errDelta := newDelta("error rate", "%", baseline.ErrorRate*100, candidate.ErrorRate*100)
+	errDelta.PValue = proportionTest(baseline.Errors, baseline.Requests, candidate.Errors, candidate.Requests)
+	errDelta.Verdict = judge(errDelta, opts)
+	rep.Overview = append(rep.Overview, errDelta)
+
+	rlDelta := newDelta("429 ratio", "%",
+		ratio(baseline.RateLimits, baseline.Requests)*100, ratio(candidate.RateLimits, candidate.Requests)*100)
+	rlDelta.PValue = proportionTest(baseline.RateLimits, baseline.Requests, candidate.RateLimits, candidate.Requests)
+	rlDelta.Verdict = judge(rlDelta, opts)
+	rep.Overview = append(rep.Overview, rlDelta)
+
+	seDelta := newDelta("stream error ratio", "%",
+		ratio(baseline.StreamErrors, baseline.BoutStarts)*100, ratio(candidate.StreamErrors, candidate.BoutStarts)*100)
+	seDelta.PValue = proportionTest(baseline.StreamErrors, baseline.BoutStarts, candidate.StreamErrors, candidate.BoutStarts)
+	seDelta.Verdict = judge(seDelta, opts)
+	rep.Overview = append(rep.Overview, seDelta)
+
+	// Throughput is driven by the configured rate, so it is reported but
+	// never judged.
+	rep.Overview = append(rep.Overview, newDelta("throughput", "req/s", baseline.Throughput, candidate.Throughput))
+
+	if bc, ok := baseline.CostPerBout(); ok {
+		if cc, ok := candidate.CostPerBout(); ok {
+			d := newDelta("cost per bout", "GBP", bc, cc)
+			if math.Abs(d.RelChange) >= opts.MinRelChange {
+				d.Verdict = Improvement
+				if d.RelChange > 0 {
+					d.Verdict = Regression
+				}
+			}
+			rep.Overview = append(rep.Overview, d)
+		}
+	}
+
+	rep.Latency = compareLatencies(baseline.Latencies, candidate.Latencies, opts)
+	rep.FirstByte = compareLatencies(baseline.FirstBytes, candidate.FirstBytes, opts)
+	return rep
+}
+
+// compareLatencies diffs endpoints present in both maps. The mean is
+// judged by a Welch t-test and each percentile by a quantile test on
+// the histograms, so a slower tail counts even when the mean holds and
+// a few outliers moving the mean don't flag p50.
+func compareLatencies(base, cand map[string]metrics.LatencyStats, opts Options) []EndpointDelta {
+	var eps []string
+	for ep := range base {
+		if _, ok := cand[ep]; ok {
+			eps = append(eps, ep)
+		}
+	}
+	sort.Strings(eps)
+
+	out := make([]EndpointDelta, 0, len(eps))
+	for _, ep := range eps {
+		b, c := base[ep], cand[ep]
+		tested := b.Count >= opts.MinSamples && c.Count >= opts.MinSamples
+		g := EndpointDelta{Endpoint: ep}
+		for _, m := range []struct {
+			name   string
+			q      float64 // 0 for the mean
+			bv, cv float64
+		}{
+			{"p50", 0.50, b.P50, c.P50},
+			{"p95", 0.95, b.P95, c.P95},
+			{"p99", 0.99, b.P99, c.P99},
+			{"mean", 0, b.Mean, c.Mean},
+		} {
+			d := newDelta(m.name, "ms", m.bv, m.cv)
+			switch {
+			case !tested:
+			case m.q == 0:
+				d.PValue = welchTest(b, c)
+			default:
+				d.PValue = quantileTest(b.Distribution, c.Distribution, m.q)
+			}
+			d.Verdict = judge(d, opts)
+			g.Deltas = append(g.Deltas, d)
+		}
+		out = append(out, g)
+	}
+	return out
+}
+
+func newDelta(metric, unit string, base, cand float64) Delta {
+	rel := math.NaN()
+	if base != 0 {
+		rel = (cand - base) / base
+	}
+	return Delta{Metric: metric, Unit: unit, Baseline: base, Candidate: cand, RelChange: rel, PValue: math.NaN()}
+}
+
+// judge flags a delta whose relative change is at least MinRelChange and
+// whose p-value is below Alpha. Every judged metric is worse when higher.
+func judge(d Delta, opts Options) Verdict {
+	if math.IsNaN(d.PValue) || d.PValue >= opts.Alpha {
+		return Unchanged
+	}
+	// From zero, any significant increase counts as a full change.
+	rel := d.RelChange
+	if math.IsNaN(rel) {
+		rel = math.Copysign(math.Inf(1), d.Candidate-d.Baseline)
+	}
+	if math.Abs(rel) < opts.MinRelChange {
+		return Unchanged
+	}
+	if rel > 0 {
+		return Regression
+	}
+	return Improvement
+}
+
+func ratio(n, d int64) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+// proportionTest is a two-sided two-proportion z-test of x1/n1 vs x2/n2.
+func proportionTest(x1, n1, x2, n2 int64) float64 {
+	if n1 == 0 || n2 == 0 {
+		return math.NaN()
+	}
+	p1, p2 := float64(x1)/float64(n1), float64(x2)/float64(n2)
+	pooled := float64(x1+x2) / float64(n1+n2)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(n1) + 1/float64(n2)))
+	if se == 0 {
+		return math.NaN()
+	}
+	return twoSidedP((p2 - p1) / se)
+}
+
+// welchTest is a two-sided Welch t-test on the means of two latency
+// summaries, using the normal approximation (MinSamples keeps n large
+// enough). Files without StdDev yield NaN.
+func welchTest(a, b metrics.LatencyStats) float64 {
+	if a.StdDev == 0 && b.StdDev == 0 {
+		return math.NaN()
+	}
+	se := math.Sqrt(a.StdDev*a.StdDev/float64(a.Count) + b.StdDev*b.StdDev/float64(b.Count))
+	return twoSidedP((b.Mean - a.Mean) / se)
+}
+
+// quantileTest is a two-sided test of whether the q-th quantile moved.
+// If it didn't, the share of samples above the baseline's q-th quantile
+// is the same in both runs, which a two-proportion z-test checks. Stats
+// without a histogram (older output files) yield NaN.
+func quantileTest(a, b *metrics.Distribution, q float64) float64 {
+	if a == nil || b == nil {
+		return math.NaN()
+	}
+	v := a.Quantile(q)
+	return proportionTest(a.CountAbove(v), a.Count, b.CountAbove(v), b.Count)
+}
+
+func twoSidedP(z float64) float64 {
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
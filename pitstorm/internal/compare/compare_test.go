@@ -0,0 +1,170 @@
+package compare
+
+import (
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+)
+
+// latencies returns the stats of n log-normal samples with the given
+// median in ms and log-scale spread, drawn from seed. Extra samples are
+// added as they are.
+func latencies(seed uint64, n int, median, sigma float64, extra ...float64) metrics.LatencyStats {
+	rng := rand.New(rand.NewPCG(seed, 0))
+	h := metrics.NewHistogram()
+	for i := 0; i < n; i++ {
+		h.Add(time.Duration(median * math.Exp(sigma*rng.NormFloat64()) * float64(time.Millisecond)))
+	}
+	for _, v := range extra {
+		h.Add(time.Duration(v * float64(time.Millisecond)))
+	}
+	return h.Stats()
+}
+
+func baseRun() Run {
+	return Run{
+		Snapshot: metrics.Snapshot{
+			Requests: 1000, Errors: 10, ErrorRate: 0.01, Throughput: 5,
+			BoutStarts: 200, StreamErrors: 2,
+			Latencies: map[string]metrics.LatencyStats{
+				"/api/run-bout": latencies(1, 200, 8000, 0.4),
+				"/api/health":   latencies(2, 500, 20, 0.5),
+				"/only-base":    {Count: 50, Mean: 10, StdDev: 1},
+			},
+			FirstBytes: map[string]metrics.LatencyStats{
+				"/api/run-bout": latencies(3, 200, 500, 0.4),
+			},
+		},
+		Budget: &budget.Summary{SpentGBP: 2, BoutCount: 200},
+	}
+}
+
+func find(ds []Delta, metric string) Delta {
+	for _, d := range ds {
+		if d.Metric == metric {
+			return d
+		}
+	}
+	return Delta{}
+}
+
+func TestCompareIdentical(t *testing.T) {
+	cand := baseRun()
+	delete(cand.Latencies, "/only-base")
+	rep := Compare(baseRun(), cand, DefaultOptions())
+	if regs := rep.Regressions(); len(regs) != 0 {
+		t.Errorf("identical runs flagged %d regressions: %+v", len(regs), regs)
+	}
+	if len(rep.Latency) != 2 {
+		t.Errorf("Latency endpoints = %d, want 2 (shared only)", len(rep.Latency))
+	}
+}
+
+func TestCompareRegression(t *testing.T) {
+	cand := baseRun()
+	cand.Errors, cand.ErrorRate = 50, 0.05
+	cand.Latencies = map[string]metrics.LatencyStats{
+		"/api/run-bout": latencies(4, 200, 12000, 0.4),
+		// Another sample of the same distribution.
+		"/api/health": latencies(5, 500, 20, 0.5),
+	}
+	cand.Budget = &budget.Summary{SpentGBP: 3, BoutCount: 200}
+
+	rep := Compare(baseRun(), cand, DefaultOptions())
+
+	if d := find(rep.Overview, "error rate"); d.Verdict != Regression || d.PValue >= 0.01 {
+		t.Errorf("error rate = %+v, want significant regression", d)
+	}
+	if d := find(rep.Overview, "cost per bout"); d.Verdict != Regression || math.Abs(d.RelChange-0.5) > 1e-9 {
+		t.Errorf("cost per bout = %+v, want +50%% regression", d)
+	}
+	if d := find(rep.Overview, "throughput"); d.Verdict != Unchanged {
+		t.Errorf("throughput must not be judged: %+v", d)
+	}
+
+	var bout, health EndpointDelta
+	for _, g := range rep.Latency {
+		switch g.Endpoint {
+		case "/api/run-bout":
+			bout = g
+		case "/api/health":
+			health = g
+		}
+	}
+	if d := find(bout.Deltas, "p95"); d.Verdict != Regression {
+		t.Errorf("run-bout p95 = %+v, want regression", d)
+	}
+	for _, d := range health.Deltas {
+		if d.Verdict != Unchanged {
+			t.Errorf("health %s = %+v, want unchanged (noise)", d.Metric, d)
+		}
+	}
+}
+
+func TestCompareJudgesEachPercentile(t *testing.T) {
+	base, cand := baseRun(), baseRun()
+	// A slow tail: the median holds while p99 and the mean move.
+	tail := make([]float64, 40)
+	for i := range tail {
+		tail[i] = 400
+	}
+	base.Latencies = map[string]metrics.LatencyStats{"/x": latencies(6, 2000, 20, 0.3)}
+	cand.Latencies = map[string]metrics.LatencyStats{"/x": latencies(7, 2000, 20, 0.3, tail...)}
+	rep := Compare(base, cand, DefaultOptions())
+	ds := rep.Latency[0].Deltas
+	if d := find(ds, "p99"); d.Verdict != Regression {
+		t.Errorf("p99 = %+v, want regression", d)
+	}
+	if d := find(ds, "p50"); d.Verdict != Unchanged || d.PValue < 0.01 {
+		t.Errorf("p50 = %+v, want unchanged", d)
+	}
+	if d, p99 := find(ds, "mean"), find(ds, "p99"); d.PValue == p99.PValue {
+		t.Errorf("mean and p99 share p-value %v", d.PValue)
+	}
+}
+
+func TestCompareWithoutDistributions(t *testing.T) {
+	base, cand := baseRun(), baseRun()
+	base.Latencies = map[string]metrics.LatencyStats{"/x": {Count: 200, P95: 10, Mean: 10, StdDev: 2}}
+	cand.Latencies = map[string]metrics.LatencyStats{"/x": {Count: 200, P95: 20, Mean: 20, StdDev: 2}}
+	rep := Compare(base, cand, DefaultOptions())
+	if d := find(rep.Latency[0].Deltas, "p95"); d.Verdict != Unchanged || !math.IsNaN(d.PValue) {
+		t.Errorf("p95 without a histogram = %+v, want untested", d)
+	}
+	if d := find(rep.Latency[0].Deltas, "mean"); d.Verdict != Regression {
+		t.Errorf("mean = %+v, want regression", d)
+	}
+}
+
+func TestCompareSmallSamples(t *testing.T) {
+	base, cand := baseRun(), baseRun()
+	base.Latencies = map[string]metrics.LatencyStats{"/x": {Count: 5, P95: 10, Mean: 10, StdDev: 1}}
+	cand.Latencies = map[string]metrics.LatencyStats{"/x": {Count: 5, P95: 50, Mean: 50, StdDev: 1}}
+	rep := Compare(base, cand, DefaultOptions())
+	if d := find(rep.Latency[0].Deltas, "p95"); d.Verdict != Unchanged || !math.IsNaN(d.PValue) {
+		t.Errorf("p95 with 5 samples = %+v, want untested", d)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.json")
+	if err := os.WriteFile(path, []byte(`{"requests": 12, "errorRate": 0.5, "budget": {"spentGbp": 1, "boutCount": 4}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if r.Requests != 12 || r.ErrorRate != 0.5 {
+		t.Errorf("snapshot = %+v", r.Snapshot)
+	}
+	if c, ok := r.CostPerBout(); !ok || c != 0.25 {
+		t.Errorf("CostPerBout = %v, %v; want 0.25", c, ok)
+	}
+}
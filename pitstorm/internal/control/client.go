@@ -0,0 +1,119 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client calls the control API of a running simulation.
+type Client struct {
+	base string
+	http *http.Client
+}
+
+// NewClient creates a client for the API at addr, either host:port or
+// a full http:// URL.
+func NewClient(addr string) *Client {
+	base := strings.TrimRight(addr, "/")
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	return &Client{base: base, http: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Status fetches the run's controllable state.
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	var st Status
+	err := c.call(ctx, http.MethodGet, PathStatus, nil, &st)
+	return st, err
+}
+
+// Pause stops the run's ticket feeder.
+func (c *Client) Pause(ctx context.Context) (Status, error) {
+	var st Status
+	err := c.call(ctx, http.MethodPost, PathPause, nil, &st)
+	return st, err
+}
+
+// Resume restarts the run's ticket feeder.
+func (c *Client) Resume(ctx context.Context) (Status, error) {
+	var st Status
+	err := c.call(ctx, http.MethodPost, PathResume, nil, &st)
+	return st, err
+}
+
+// SetRate fixes the run's rate in req/s; 0 restores the profile.
+func (c *Client) SetRate(ctx context.Context, rps float64) (Status, error) {
+	var st Status
+	err := c.call(ctx, http.MethodPost, PathRate, RateRequest{RPS: rps}, &st)
+	return st, err
+}
+
+// SetPersona enables or disables a persona's workers.
+func (c *Client) SetPersona(ctx context.Context, id string, enabled bool) (Status, error) {
+	op := "disable"
+	if enabled {
+		op = "enable"
+	}
+	var st Status
+	err := c.call(ctx, http.MethodPost, PathPersonas+url.PathEscape(id)+"/"+op, nil, &st)
+	return st, err
+}
+
+// RaiseBudget lifts the run's budget ceiling to gbp.
+func (c *Client) RaiseBudget(ctx context.Context, gbp float64) (Status, error) {
+	var st Status
+	err := c.call(ctx, http.MethodPost, PathBudget, BudgetRequest{CeilingGBP: gbp}, &st)
+	return st, err
+}
+
+// Snapshot takes an immediate metrics snapshot of the run.
+func (c *Client) Snapshot(ctx context.Context) (Snapshot, error) {
+	var snap Snapshot
+	err := c.call(ctx, http.MethodPost, PathSnapshot, nil, &snap)
+	return snap, err
+}
+
+func (c *Client) call(ctx context.Context, method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			return fmt.Errorf("control API returned %d: %s", resp.StatusCode, e.Error)
+		}
+		return fmt.Errorf("control API returned %d", resp.StatusCode)
+	}
+	return json.Unmarshal(data, out)
+}
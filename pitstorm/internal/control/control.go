@@ -0,0 +1,190 @@
+// Package control is the runtime control API of a running simulation.
+// `pitstorm run --control-addr` serves it over local HTTP so operators
+// and scripts can steer a long soak test without restarting it: change
+// the target rate, pause and resume, switch personas off and on, raise
+// the budget ceiling, and take an immediate metrics snapshot.
+//
+// Every endpoint returns JSON; errors are {"error": "..."}.
+//
+//	GET  /v1/status                  Status
+//	POST /v1/pause                   Status
+//	POST /v1/resume                  Status
+//	POST /v1/rate                    {"rps": 20} (0 restores the profile) → Status
+//	POST /v1/personas/{id}/enable    Status
+//	POST /v1/personas/{id}/disable   Status
+//	POST /v1/budget                  {"ceilingGbp": 25} → Status
+//	POST /v1/snapshot                Snapshot
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+)
+
+// API paths.
+const (
+	PathStatus   = "/v1/status"
+	PathPause    = "/v1/pause"
+	PathResume   = "/v1/resume"
+	PathRate     = "/v1/rate"
+	PathPersonas = "/v1/personas/"
+	PathBudget   = "/v1/budget"
+	PathSnapshot = "/v1/snapshot"
+)
+
+// Controller is the part of a running engine the API steers.
+type Controller interface {
+	Pause()
+	Resume()
+	Paused() bool
+	// SetRate fixes the rate in req/s; 0 hands control back to the profile.
+	SetRate(rps float64)
+	RateOverride() float64
+	// Rate is the target rate currently applied, +Inf when unlimited.
+	Rate() float64
+	Personas() []PersonaState
+	SetPersonaEnabled(id string, enabled bool) error
+	RaiseBudget(gbp float64) error
+	Budget() budget.Summary
+	// Snapshot takes a metrics snapshot now (refreshing any status file).
+	Snapshot() metrics.Snapshot
+}
+
+// PersonaState reports whether a persona's workers are running.
+type PersonaState struct {
+	ID      string `json:"id"`
+	Workers int    `json:"workers"`
+	Enabled bool   `json:"enabled"`
+}
+
+// Status is the controllable state of a run.
+type Status struct {
+	Timestamp string  `json:"timestamp"`
+	Paused    bool    `json:"paused"`
+	RateRPS   float64 `json:"rateRps"` // target rate now; 0 with Unlimited
+	Unlimited bool    `json:"unlimited,omitempty"`
+	// RateOverrideRPS is the rate set through the API or dashboard;
+	// 0 means the traffic profile is in control.
+	RateOverrideRPS float64        `json:"rateOverrideRps,omitempty"`
+	Personas        []PersonaState `json:"personas"`
+	Budget          budget.Summary `json:"budget"`
+}
+
+// Snapshot is a Status with the run's metrics at that moment.
+type Snapshot struct {
+	Status
+	Metrics metrics.Snapshot `json:"metrics"`
+}
+
+// RateRequest is the body of POST /v1/rate.
+type RateRequest struct {
+	RPS float64 `json:"rps"`
+}
+
+// BudgetRequest is the body of POST /v1/budget.
+type BudgetRequest struct {
+	CeilingGBP float64 `json:"ceilingGbp"`
+}
+
+// StatusOf reads c's current Status.
+func StatusOf(c Controller) Status {
+	st := Status{
+		Timestamp:       time.Now().UTC().Format(time.RFC3339),
+		Paused:          c.Paused(),
+		RateRPS:         c.Rate(),
+		RateOverrideRPS: c.RateOverride(),
+		Personas:        c.Personas(),
+		Budget:          c.Budget(),
+	}
+	if math.IsInf(st.RateRPS, 1) {
+		st.RateRPS, st.Unlimited = 0, true
+	}
+	return st
+}
+
+// Handler returns the control API for c. Every change is logged.
+func Handler(c Controller, logf func(string, ...any)) http.Handler {
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+PathStatus, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, StatusOf(c))
+	})
+	mux.HandleFunc("POST "+PathPause, func(w http.ResponseWriter, r *http.Request) {
+		c.Pause()
+		logf("[control] paused")
+		writeJSON(w, http.StatusOK, StatusOf(c))
+	})
+	mux.HandleFunc("POST "+PathResume, func(w http.ResponseWriter, r *http.Request) {
+		c.Resume()
+		logf("[control] resumed")
+		writeJSON(w, http.StatusOK, StatusOf(c))
+	})
+	mux.HandleFunc("POST "+PathRate, func(w http.ResponseWriter, r *http.Request) {
+		var req RateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON")
+			return
+		}
+		if req.RPS < 0 || math.IsNaN(req.RPS) || math.IsInf(req.RPS, 0) {
+			writeError(w, http.StatusBadRequest, "rps must be a non-negative number")
+			return
+		}
+		c.SetRate(req.RPS)
+		if req.RPS == 0 {
+			logf("[control] rate handed back to the profile")
+		} else {
+			logf("[control] rate set to %.1f req/s", req.RPS)
+		}
+		writeJSON(w, http.StatusOK, StatusOf(c))
+	})
+	mux.HandleFunc("POST "+PathPersonas+"{id}/{op}", func(w http.ResponseWriter, r *http.Request) {
+		id, op := r.PathValue("id"), r.PathValue("op")
+		if op != "enable" && op != "disable" {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("unknown persona operation %q (want enable or disable)", op))
+			return
+		}
+		if err := c.SetPersonaEnabled(id, op == "enable"); err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		logf("[control] persona %s %sd", id, op)
+		writeJSON(w, http.StatusOK, StatusOf(c))
+	})
+	mux.HandleFunc("POST "+PathBudget, func(w http.ResponseWriter, r *http.Request) {
+		var req BudgetRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON")
+			return
+		}
+		if err := c.RaiseBudget(req.CeilingGBP); err != nil {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		logf("[control] budget ceiling raised to £%.2f", req.CeilingGBP)
+		writeJSON(w, http.StatusOK, StatusOf(c))
+	})
+	mux.HandleFunc("POST "+PathSnapshot, func(w http.ResponseWriter, r *http.Request) {
+		snap := Snapshot{Metrics: c.Snapshot()}
+		snap.Status = StatusOf(c)
+		writeJSON(w, http.StatusOK, snap)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
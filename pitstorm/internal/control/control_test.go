@@ -0,0 +1,123 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+)
+
+// fakeRun is an in-memory Controller.
+type fakeRun struct {
+	paused    bool
+	override  float64
+	disabled  map[string]bool
+	ceiling   float64
+	snapshots int
+}
+
+func (f *fakeRun) Pause()                { f.paused = true }
+func (f *fakeRun) Resume()               { f.paused = false }
+func (f *fakeRun) Paused() bool          { return f.paused }
+func (f *fakeRun) SetRate(rps float64)   { f.override = rps }
+func (f *fakeRun) RateOverride() float64 { return f.override }
+func (f *fakeRun) Rate() float64 {
+	if f.override > 0 {
+		return f.override
+	}
+	return math.Inf(1)
+}
+func (f *fakeRun) Personas() []PersonaState {
+	return []PersonaState{{ID: "free-lurker", Workers: 2, Enabled: !f.disabled["free-lurker"]}}
+}
+func (f *fakeRun) SetPersonaEnabled(id string, enabled bool) error {
+	if id != "free-lurker" {
+		return fmt.Errorf("persona %q is not in this run", id)
+	}
+	f.disabled[id] = !enabled
+	return nil
+}
+func (f *fakeRun) RaiseBudget(gbp float64) error {
+	if gbp < f.ceiling {
+		return fmt.Errorf("below the current ceiling")
+	}
+	f.ceiling = gbp
+	return nil
+}
+func (f *fakeRun) Budget() budget.Summary { return budget.Summary{CeilingGBP: f.ceiling} }
+func (f *fakeRun) Snapshot() metrics.Snapshot {
+	f.snapshots++
+	return metrics.Snapshot{Requests: 42}
+}
+
+func newTestAPI(t *testing.T) (*fakeRun, *Client) {
+	t.Helper()
+	run := &fakeRun{disabled: make(map[string]bool), ceiling: 10}
+	srv := httptest.NewServer(Handler(run, nil))
+	t.Cleanup(srv.Close)
+	return run, NewClient(strings.TrimPrefix(srv.URL, "http://"))
+}
+
+func TestClientSteersRun(t *testing.T) {
+	run, c := newTestAPI(t)
+	ctx := context.Background()
+
+	st, err := c.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if !st.Unlimited || st.RateRPS != 0 || st.Paused {
+		t.Errorf("initial status = %+v", st)
+	}
+
+	if st, err = c.Pause(ctx); err != nil || !st.Paused {
+		t.Errorf("Pause = %+v, %v", st, err)
+	}
+	if st, err = c.Resume(ctx); err != nil || st.Paused {
+		t.Errorf("Resume = %+v, %v", st, err)
+	}
+	if st, err = c.SetRate(ctx, 12.5); err != nil || st.RateRPS != 12.5 || st.RateOverrideRPS != 12.5 {
+		t.Errorf("SetRate = %+v, %v", st, err)
+	}
+	if st, err = c.SetPersona(ctx, "free-lurker", false); err != nil || st.Personas[0].Enabled {
+		t.Errorf("disable = %+v, %v", st, err)
+	}
+	if st, err = c.RaiseBudget(ctx, 25); err != nil || st.Budget.CeilingGBP != 25 {
+		t.Errorf("RaiseBudget = %+v, %v", st, err)
+	}
+	snap, err := c.Snapshot(ctx)
+	if err != nil || snap.Metrics.Requests != 42 || run.snapshots != 1 {
+		t.Errorf("Snapshot = %+v, %v (snapshots taken: %d)", snap, err, run.snapshots)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	_, c := newTestAPI(t)
+	ctx := context.Background()
+
+	if _, err := c.SetPersona(ctx, "nobody", true); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("unknown persona: %v", err)
+	}
+	if _, err := c.RaiseBudget(ctx, 5); err == nil || !strings.Contains(err.Error(), "409") {
+		t.Errorf("lowering budget: %v", err)
+	}
+	if _, err := c.SetRate(ctx, -1); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("negative rate: %v", err)
+	}
+}
+
+func TestHandlerRejectsUnknownPersonaOp(t *testing.T) {
+	run := &fakeRun{disabled: make(map[string]bool)}
+	req := httptest.NewRequest(http.MethodPost, PathPersonas+"free-lurker/toggle", nil)
+	rec := httptest.NewRecorder()
+	Handler(run, nil).ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
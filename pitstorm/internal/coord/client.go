@@ -0,0 +1,191 @@
+package coord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+)
+
+// chargeTimeout bounds a single ledger charge so a slow coordinator
+// cannot stall the heartbeat indefinitely.
+const chargeTimeout = 5 * time.Second
+
+// Client is an instance's connection to a Coordinator. It implements
+// budget.Ledger so a Gate can share the coordinator's global ceiling.
+type Client struct {
+	base string
+	http *http.Client
+	reg  RegisterResponse
+}
+
+var _ budget.Ledger = (*Client)(nil)
+
+// Register joins the run at baseURL and returns a client bound to the
+// assigned instance slot.
+func Register(ctx context.Context, baseURL, host string) (*Client, error) {
+	c := &Client{
+		base: strings.TrimRight(baseURL, "/"),
+		http: &http.Client{Timeout: 30 * time.Second},
+	}
+	if err := c.post(ctx, PathRegister, RegisterRequest{Host: host}, &c.reg); err != nil {
+		return nil, fmt.Errorf("register: %w", err)
+	}
+	return c, nil
+}
+
+// Registration returns the slot assigned by the coordinator.
+func (c *Client) Registration() RegisterResponse { return c.reg }
+
+// WaitStart blocks until the coordinator broadcasts the start and the
+// synchronised start time has arrived, polling every interval.
+func (c *Client) WaitStart(ctx context.Context, interval time.Duration) error {
+	for {
+		st, err := c.State(ctx)
+		if err != nil {
+			return err
+		}
+		switch st.Phase {
+		case PhaseRunning:
+			select {
+			case <-time.After(st.StartIn):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case PhaseStopped:
+			return fmt.Errorf("coordinator stopped the run before start")
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// State fetches the coordinator's current view of the run.
+func (c *Client) State(ctx context.Context) (StateResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+PathState, nil)
+	if err != nil {
+		return StateResponse{}, err
+	}
+	var st StateResponse
+	if err := c.do(req, &st); err != nil {
+		return StateResponse{}, fmt.Errorf("state: %w", err)
+	}
+	return st, nil
+}
+
+// Heartbeat sends the instance's latest snapshot and returns the run state.
+func (c *Client) Heartbeat(ctx context.Context, snap metrics.Snapshot) (StateResponse, error) {
+	var st StateResponse
+	err := c.post(ctx, PathHeartbeat, HeartbeatRequest{InstanceID: c.reg.InstanceID, Snapshot: snap}, &st)
+	if err != nil {
+		return StateResponse{}, fmt.Errorf("heartbeat: %w", err)
+	}
+	return st, nil
+}
+
+// Report sends the instance's final snapshot.
+func (c *Client) Report(ctx context.Context, snap metrics.Snapshot) error {
+	var st StateResponse
+	if err := c.post(ctx, PathReport, ReportRequest{InstanceID: c.reg.InstanceID, Snapshot: snap}, &st); err != nil {
+		return fmt.Errorf("report: %w", err)
+	}
+	return nil
+}
+
+// Charge records a batch of spend against the global budget and
+// returns the new global total. It implements budget.Ledger.
+func (c *Client) Charge(seq int64, spend map[string]float64) (float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), chargeTimeout)
+	defer cancel()
+	var st StateResponse
+	err := c.post(ctx, PathCharge, ChargeRequest{InstanceID: c.reg.InstanceID, Seq: seq, Spend: spend}, &st)
+	if err != nil {
+		return 0, fmt.Errorf("charge: %w", err)
+	}
+	return st.SpentGBP, nil
+}
+
+// Follow sends a heartbeat every interval until ctx is done, keeping
+// gate's global spend current and calling stop when the coordinator
+// broadcasts a stop. Each heartbeat first flushes the gate's spend to
+// the coordinator. Errors are logged and retried.
+func (c *Client) Follow(ctx context.Context, interval time.Duration, snapshot func() metrics.Snapshot, gate *budget.Gate, stop func(), logf func(string, ...any)) {
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if gate != nil {
+			if unsent, err := gate.FlushLedger(); err != nil && ctx.Err() == nil {
+				logf("[coord] £%.4f of charges still unsent: %v", unsent, err)
+			}
+		}
+		st, err := c.Heartbeat(ctx, snapshot())
+		if err != nil {
+			if ctx.Err() == nil {
+				logf("[coord] %v", err)
+			}
+			continue
+		}
+		if gate != nil {
+			gate.SetGlobalSpent(st.SpentGBP)
+		}
+		if st.Phase == PhaseStopped {
+			logf("[coord] stop received from coordinator")
+			stop()
+			return
+		}
+	}
+}
+
+func (c *Client) post(ctx context.Context, path string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.base+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req, out)
+}
+
+func (c *Client) do(req *http.Request, out any) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &e) == nil && e.Error != "" {
+			return fmt.Errorf("coordinator returned %d: %s", resp.StatusCode, e.Error)
+		}
+		return fmt.Errorf("coordinator returned %d", resp.StatusCode)
+	}
+	return json.Unmarshal(body, out)
+}
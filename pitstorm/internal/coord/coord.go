@@ -0,0 +1,335 @@
+// Package coord coordinates a distributed pitstorm run. A Coordinator
+// serves a small JSON-over-HTTP API that `pitstorm run --coordinator`
+// instances register with: it hands out instance IDs, broadcasts a
+// synchronised start and a stop, owns the single global budget ceiling,
+// and merges every instance's metrics into one combined report.
+package coord
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/action"
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+)
+
+// Phase is the lifecycle state of a coordinated run.
+type Phase string
+
+const (
+	PhaseWaiting Phase = "waiting" // accepting registrations
+	PhaseRunning Phase = "running" // all instances registered; start broadcast
+	PhaseStopped Phase = "stopped" // stop broadcast; instances should wind down
+)
+
+// API paths.
+const (
+	PathRegister  = "/v1/register"
+	PathState     = "/v1/state"
+	PathHeartbeat = "/v1/heartbeat"
+	PathCharge    = "/v1/charge"
+	PathReport    = "/v1/report"
+	PathStop      = "/v1/stop"
+)
+
+// RegisterRequest is sent by an instance joining the run.
+type RegisterRequest struct {
+	Host string `json:"host"`
+}
+
+// RegisterResponse assigns the instance its slot.
+type RegisterResponse struct {
+	RunID      string  `json:"runId"`
+	InstanceID int     `json:"instanceId"`
+	InstanceOf int     `json:"instanceOf"`
+	BudgetGBP  float64 `json:"budgetGbp"`
+}
+
+// StateResponse is the coordinator's view of the run, returned by
+// state polls, heartbeats, and charges.
+type StateResponse struct {
+	Phase Phase `json:"phase"`
+	// StartIn is how long until the synchronised start (running phase
+	// only). Relative, so instance clock skew does not matter.
+	StartIn   time.Duration `json:"startInNs,omitempty"`
+	SpentGBP  float64       `json:"spentGbp"`
+	Exhausted bool          `json:"exhausted"`
+}
+
+// HeartbeatRequest carries an instance's latest metrics.
+type HeartbeatRequest struct {
+	InstanceID int              `json:"instanceId"`
+	Snapshot   metrics.Snapshot `json:"snapshot"`
+}
+
+// ChargeRequest forwards a batch of an instance's spend, per model, to
+// the global ledger. Seq increases with every new batch; a resent batch
+// keeps its Seq and is applied only once.
+type ChargeRequest struct {
+	InstanceID int                `json:"instanceId"`
+	Seq        int64              `json:"seq"`
+	Spend      map[string]float64 `json:"spend"`
+}
+
+// ReportRequest is an instance's final metrics snapshot.
+type ReportRequest = HeartbeatRequest
+
+// Config controls the Coordinator.
+type Config struct {
+	Instances  int           // number of instances to wait for before starting
+	BudgetGBP  float64       // global ceiling shared by all instances (0 = unlimited)
+	StartDelay time.Duration // grace between the last registration and start
+}
+
+// InstanceStatus describes one registered instance.
+type InstanceStatus struct {
+	ID       int              `json:"id"`
+	Host     string           `json:"host"`
+	LastSeen time.Time        `json:"lastSeen"`
+	Final    bool             `json:"final"` // final report received
+	SpentGBP float64          `json:"spentGbp"`
+	Snapshot metrics.Snapshot `json:"snapshot"`
+
+	// chargeSeq is the Seq of the last charge batch applied.
+	chargeSeq int64
+}
+
+// Coordinator is the HTTP server side of a distributed run. Safe for
+// concurrent use.
+type Coordinator struct {
+	cfg   Config
+	logf  func(string, ...any)
+	runID string
+	gate  *budget.Gate
+
+	mu        sync.Mutex
+	phase     Phase
+	startAt   time.Time
+	instances []*InstanceStatus // index = ID-1
+	done      chan struct{}
+	doneOnce  sync.Once
+}
+
+// New creates a Coordinator. A nil logf disables logging.
+func New(cfg Config, logf func(string, ...any)) *Coordinator {
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+	return &Coordinator{
+		cfg:   cfg,
+		logf:  logf,
+		runID: action.GenerateID(12),
+		gate:  budget.NewGate(cfg.BudgetGBP),
+		phase: PhaseWaiting,
+		done:  make(chan struct{}),
+	}
+}
+
+// RunID returns the identifier shared by all instances in this run.
+func (c *Coordinator) RunID() string { return c.runID }
+
+// Done is closed once every instance has sent its final report.
+func (c *Coordinator) Done() <-chan struct{} { return c.done }
+
+// Stop broadcasts a stop to all instances via their next heartbeat.
+func (c *Coordinator) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.phase != PhaseStopped {
+		c.phase = PhaseStopped
+		c.logf("[coord] stop broadcast")
+	}
+}
+
+// Instances returns a copy of every registered instance's status,
+// ordered by ID.
+func (c *Coordinator) Instances() []InstanceStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]InstanceStatus, len(c.instances))
+	for i, inst := range c.instances {
+		out[i] = *inst
+	}
+	return out
+}
+
+// Report merges the latest snapshot from every instance (final where
+// available) and returns it with the global budget summary.
+func (c *Coordinator) Report() (metrics.Snapshot, budget.Summary) {
+	insts := c.Instances()
+	snaps := make([]metrics.Snapshot, len(insts))
+	for i, inst := range insts {
+		snaps[i] = inst.Snapshot
+	}
+	return metrics.Merge(snaps...), c.gate.Summary()
+}
+
+// Handler returns the coordinator's HTTP API.
+func (c *Coordinator) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+PathRegister, c.handleRegister)
+	mux.HandleFunc("GET "+PathState, c.handleState)
+	mux.HandleFunc("POST "+PathHeartbeat, c.handleHeartbeat)
+	mux.HandleFunc("POST "+PathCharge, c.handleCharge)
+	mux.HandleFunc("POST "+PathReport, c.handleReport)
+	mux.HandleFunc("POST "+PathStop, func(w http.ResponseWriter, r *http.Request) {
+		c.Stop()
+		writeJSON(w, http.StatusOK, c.state())
+	})
+	return mux
+}
+
+func (c *Coordinator) handleRegister(w http.ResponseWriter, r *http.Request) {
+	var req RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	c.mu.Lock()
+	if c.phase != PhaseWaiting || len(c.instances) >= c.cfg.Instances {
+		c.mu.Unlock()
+		writeError(w, http.StatusConflict, fmt.Sprintf("run already has %d instances", c.cfg.Instances))
+		return
+	}
+	id := len(c.instances) + 1
+	c.instances = append(c.instances, &InstanceStatus{ID: id, Host: req.Host, LastSeen: time.Now()})
+	c.logf("[coord] instance %d/%d registered from %s", id, c.cfg.Instances, req.Host)
+	if len(c.instances) == c.cfg.Instances {
+		c.phase = PhaseRunning
+		c.startAt = time.Now().Add(c.cfg.StartDelay)
+		c.logf("[coord] all instances registered, starting in %s", c.cfg.StartDelay)
+	}
+	c.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, RegisterResponse{
+		RunID:      c.runID,
+		InstanceID: id,
+		InstanceOf: c.cfg.Instances,
+		BudgetGBP:  c.cfg.BudgetGBP,
+	})
+}
+
+func (c *Coordinator) handleState(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, c.state())
+}
+
+func (c *Coordinator) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	var req HeartbeatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	if !c.update(req, false) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown instance %d", req.InstanceID))
+		return
+	}
+	writeJSON(w, http.StatusOK, c.state())
+}
+
+func (c *Coordinator) handleReport(w http.ResponseWriter, r *http.Request) {
+	var req ReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	if !c.update(req, true) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown instance %d", req.InstanceID))
+		return
+	}
+	c.logf("[coord] instance %d final report: %d requests", req.InstanceID, req.Snapshot.Requests)
+	writeJSON(w, http.StatusOK, c.state())
+}
+
+func (c *Coordinator) handleCharge(w http.ResponseWriter, r *http.Request) {
+	var req ChargeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	for _, gbp := range req.Spend {
+		if gbp < 0 {
+			writeError(w, http.StatusBadRequest, "spend must not be negative")
+			return
+		}
+	}
+
+	// A batch whose Seq was already applied is a resend after a lost
+	// response; acknowledge it without charging again.
+	c.mu.Lock()
+	inst := c.instance(req.InstanceID)
+	if inst != nil {
+		inst.LastSeen = time.Now()
+		if req.Seq > inst.chargeSeq {
+			inst.chargeSeq = req.Seq
+			for model, gbp := range req.Spend {
+				inst.SpentGBP += gbp
+				c.gate.Charge(model, gbp)
+			}
+		}
+	}
+	c.mu.Unlock()
+	if inst == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown instance %d", req.InstanceID))
+		return
+	}
+	writeJSON(w, http.StatusOK, c.state())
+}
+
+// update stores an instance's snapshot; final marks its last report.
+func (c *Coordinator) update(req HeartbeatRequest, final bool) bool {
+	c.mu.Lock()
+	inst := c.instance(req.InstanceID)
+	if inst == nil {
+		c.mu.Unlock()
+		return false
+	}
+	inst.Snapshot = req.Snapshot
+	inst.LastSeen = time.Now()
+	inst.Final = inst.Final || final
+
+	allFinal := len(c.instances) == c.cfg.Instances
+	for _, i := range c.instances {
+		allFinal = allFinal && i.Final
+	}
+	c.mu.Unlock()
+
+	if allFinal {
+		c.doneOnce.Do(func() { close(c.done) })
+	}
+	return true
+}
+
+// instance returns the instance with the given ID. Caller holds c.mu.
+func (c *Coordinator) instance(id int) *InstanceStatus {
+	if id < 1 || id > len(c.instances) {
+		return nil
+	}
+	return c.instances[id-1]
+}
+
+func (c *Coordinator) state() StateResponse {
+	c.mu.Lock()
+	st := StateResponse{Phase: c.phase}
+	if c.phase == PhaseRunning {
+		st.StartIn = max(0, time.Until(c.startAt))
+	}
+	c.mu.Unlock()
+	st.SpentGBP = c.gate.Spent()
+	st.Exhausted = c.gate.Exhausted()
+	return st
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
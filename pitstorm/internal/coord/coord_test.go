@@ -0,0 +1,212 @@
+package coord
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+)
+
+func newTestCoordinator(t *testing.T, cfg Config) (*Coordinator, string) {
+	t.Helper()
+	c := New(cfg, nil)
+	srv := httptest.NewServer(c.Handler())
+	t.Cleanup(srv.Close)
+	return c, srv.URL
+}
+
+func TestRegisterAndStart(t *testing.T) {
+	_, url := newTestCoordinator(t, Config{Instances: 2, BudgetGBP: 5, StartDelay: 20 * time.Millisecond})
+	ctx := context.Background()
+
+	a, err := Register(ctx, url, "host-a")
+	if err != nil {
+		t.Fatalf("Register a: %v", err)
+	}
+	st, err := a.State(ctx)
+	if err != nil {
+		t.Fatalf("State: %v", err)
+	}
+	if st.Phase != PhaseWaiting {
+		t.Errorf("phase after 1/2 = %q, want waiting", st.Phase)
+	}
+
+	b, err := Register(ctx, url, "host-b")
+	if err != nil {
+		t.Fatalf("Register b: %v", err)
+	}
+	ra, rb := a.Registration(), b.Registration()
+	if ra.InstanceID != 1 || rb.InstanceID != 2 || rb.InstanceOf != 2 {
+		t.Errorf("slots = %d, %d/%d; want 1, 2/2", ra.InstanceID, rb.InstanceID, rb.InstanceOf)
+	}
+	if ra.RunID != rb.RunID || rb.BudgetGBP != 5 {
+		t.Errorf("runID %q vs %q, budget %v", ra.RunID, rb.RunID, rb.BudgetGBP)
+	}
+
+	if _, err := Register(ctx, url, "host-c"); err == nil {
+		t.Error("third registration should be rejected")
+	}
+
+	start := time.Now()
+	if err := a.WaitStart(ctx, 5*time.Millisecond); err != nil {
+		t.Fatalf("WaitStart: %v", err)
+	}
+	if time.Since(start) < 10*time.Millisecond {
+		t.Error("WaitStart returned before the start delay elapsed")
+	}
+}
+
+func TestGlobalBudget(t *testing.T) {
+	c, url := newTestCoordinator(t, Config{Instances: 2, BudgetGBP: 1})
+	ctx := context.Background()
+
+	var gates []*budget.Gate
+	for _, host := range []string{"a", "b"} {
+		cl, err := Register(ctx, url, host)
+		if err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		g := budget.NewGate(1)
+		g.SetLedger(cl)
+		gates = append(gates, g)
+	}
+
+	gates[0].Charge("claude-haiku-4-5-20251001", 0.6)
+	if got := gates[0].LocalSpent(); got != 0.6 {
+		t.Errorf("LocalSpent = %v, want 0.6", got)
+	}
+	if _, err := gates[0].FlushLedger(); err != nil {
+		t.Fatalf("FlushLedger: %v", err)
+	}
+	if gates[1].Exhausted() {
+		t.Error("gate b exhausted before reaching the global ceiling")
+	}
+
+	gates[1].Charge("claude-haiku-4-5-20251001", 0.5)
+	if _, err := gates[1].FlushLedger(); err != nil {
+		t.Fatalf("FlushLedger: %v", err)
+	}
+	if !gates[1].Exhausted() {
+		t.Error("gate b should see the shared ceiling exhausted")
+	}
+	if _, s := c.Report(); s.SpentGBP < 1.1-1e-9 {
+		t.Errorf("coordinator spent = %v, want 1.1", s.SpentGBP)
+	}
+
+	if st := c.state(); !st.Exhausted {
+		t.Error("coordinator state should report the budget exhausted")
+	}
+}
+
+func TestChargesFlushedOnceAfterOutage(t *testing.T) {
+	c := New(Config{Instances: 1, BudgetGBP: 1}, nil)
+	// down fails requests before they arrive; lossy applies them but
+	// loses the response.
+	var down, lossy atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case down.Load():
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		case lossy.Load():
+			c.Handler().ServeHTTP(httptest.NewRecorder(), r)
+			http.Error(w, "gateway timeout", http.StatusGatewayTimeout)
+		default:
+			c.Handler().ServeHTTP(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cl, err := Register(ctx, srv.URL, "a")
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	g := budget.NewGate(1)
+	g.SetLedger(cl)
+
+	down.Store(true)
+	g.Charge("claude-haiku-4-5-20251001", 0.4)
+	if _, err := g.FlushLedger(); err == nil {
+		t.Fatal("FlushLedger succeeded during the outage")
+	}
+	if _, s := c.Report(); s.SpentGBP != 0 {
+		t.Fatalf("coordinator spent = %v during the outage", s.SpentGBP)
+	}
+	down.Store(false)
+	lossy.Store(true)
+	if _, err := g.FlushLedger(); err == nil {
+		t.Fatal("FlushLedger succeeded with the response lost")
+	}
+	lossy.Store(false)
+
+	go cl.Follow(ctx, 10*time.Millisecond, func() metrics.Snapshot { return metrics.Snapshot{} }, g, cancel, nil)
+	time.Sleep(50 * time.Millisecond) // heartbeats resend the batch
+	if unsent, err := g.FlushLedger(); err != nil || unsent != 0 {
+		t.Errorf("FlushLedger after the outage = %v, %v", unsent, err)
+	}
+	if _, s := c.Report(); s.SpentGBP < 0.4-1e-9 || s.SpentGBP > 0.4+1e-5 {
+		t.Errorf("coordinator spent = %v after the outage, want 0.4 charged once", s.SpentGBP)
+	}
+}
+
+func TestStopAndMergedReport(t *testing.T) {
+	c, url := newTestCoordinator(t, Config{Instances: 2})
+	ctx := context.Background()
+
+	a, _ := Register(ctx, url, "a")
+	b, _ := Register(ctx, url, "b")
+
+	gate := budget.NewGate(10)
+	stopped := make(chan struct{})
+	followCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go a.Follow(followCtx, 5*time.Millisecond, func() metrics.Snapshot {
+		return metrics.Snapshot{Requests: 1}
+	}, gate, func() { close(stopped) }, nil)
+
+	c.Stop()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Follow did not observe stop")
+	}
+
+	if err := a.Report(ctx, metrics.Snapshot{Requests: 10, Successes: 9, Errors: 1}); err != nil {
+		t.Fatalf("Report a: %v", err)
+	}
+	select {
+	case <-c.Done():
+		t.Fatal("Done closed before every instance reported")
+	default:
+	}
+	if err := b.Report(ctx, metrics.Snapshot{Requests: 5, Successes: 5}); err != nil {
+		t.Fatalf("Report b: %v", err)
+	}
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done not closed after all reports")
+	}
+
+	merged, _ := c.Report()
+	if merged.Requests != 15 || merged.Successes != 14 || merged.Errors != 1 {
+		t.Errorf("merged = %d/%d/%d, want 15/14/1", merged.Requests, merged.Successes, merged.Errors)
+	}
+}
+
+func TestUnknownInstance(t *testing.T) {
+	_, url := newTestCoordinator(t, Config{Instances: 1})
+	cl := &Client{base: url, http: &http.Client{}, reg: RegisterResponse{InstanceID: 7}}
+	if _, err := cl.Heartbeat(context.Background(), metrics.Snapshot{}); err == nil {
+		t.Error("heartbeat from unregistered instance should fail")
+	}
+	if _, err := cl.Charge(1, map[string]float64{"m": 0.1}); err == nil {
+		t.Error("charge from unregistered instance should fail")
+	}
+}
@@ -0,0 +1,382 @@
+// Package credits models the credit balance of each simulated account
+// for the credit depletion scenario. Every account starts with the same
+// balance; the dispatcher opens a Hold before each bout (mirroring the
+// server's preauthorization of the estimated bout cost), then settles it
+// with the bout's actual cost, releases it when the bout failed, or marks
+// it refused when the server answered 402.
+//
+// The ledger's expected balance is checked against the server's
+// decisions: a refusal while the balance still covered the bout is
+// premature, and a bout that ran without the balance to cover it is an
+// overdraft — or ran on an empty balance outright. Bouts that overlap
+// on one account may reach the server in either order, so only the
+// empty-balance check applies to them.
+//
+// Units follow lib/credits.ts: 1 credit = CreditValueGBP.
+package credits
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+)
+
+// CreditValueGBP is the GBP value of one credit (lib/credits.ts default).
+const CreditValueGBP = 0.01
+
+// DefaultTolerance is the relative slack allowed between the ledger's
+// expected balance and the server's, which drift apart when token usage
+// is estimated from stream length rather than reported by the server.
+const DefaultTolerance = 0.05
+
+// maxFindings caps the findings kept for the report; later ones are
+// only counted.
+const maxFindings = 50
+
+// ToGBP converts credits to GBP.
+func ToGBP(credits float64) float64 { return credits * CreditValueGBP }
+
+// FromGBP converts GBP to credits.
+func FromGBP(gbp float64) float64 { return gbp / CreditValueGBP }
+
+// Finding kinds.
+const (
+	// KindPremature is a 402 while the expected balance still covered
+	// the bout's preauthorization.
+	KindPremature = "premature-refusal"
+	// KindOverdraft is a bout that ran although the expected balance
+	// did not cover its preauthorization.
+	KindOverdraft = "overdraft"
+	// KindEmpty is a bout that ran on an expected balance of zero or less.
+	KindEmpty = "ran-on-empty"
+)
+
+// Finding is one disagreement between the ledger and the server.
+type Finding struct {
+	Account         string  `json:"account"`
+	Kind            string  `json:"kind"`
+	Model           string  `json:"model"`
+	BalanceCredits  float64 `json:"balanceCredits"`
+	EstimateCredits float64 `json:"estimateCredits"`
+}
+
+// String describes the finding on one line.
+func (f Finding) String() string {
+	switch f.Kind {
+	case KindPremature:
+		return fmt.Sprintf("%s refused a %s bout at %.2f credits (bout needs %.2f)",
+			f.Account, shortModel(f.Model), f.BalanceCredits, f.EstimateCredits)
+	case KindEmpty:
+		return fmt.Sprintf("%s ran a %s bout on an empty balance (%.2f credits)",
+			f.Account, shortModel(f.Model), f.BalanceCredits)
+	default:
+		return fmt.Sprintf("%s ran a %s bout at %.2f credits (bout needs %.2f)",
+			f.Account, shortModel(f.Model), f.BalanceCredits, f.EstimateCredits)
+	}
+}
+
+// Hold is an open bout's preauthorization. A nil Hold (no ledger, or an
+// anonymous session) is ignored by every Ledger method.
+type Hold struct {
+	account   string
+	model     string
+	estimate  float64 // GBP
+	balance   float64 // GBP, at Begin
+	available float64 // GBP, balance less other open holds, at Begin
+	covered   bool
+
+	// contended is set when another bout on the account overlapped this one.
+	contended bool
+}
+
+// accountState is the ledger's view of one account.
+type accountState struct {
+	balance  float64 // GBP
+	held     float64 // GBP
+	spent    float64 // GBP
+	bouts    int
+	refusals int
+	open     map[*Hold]bool
+
+	// refusedAt is the available balance (GBP) at the first refusal.
+	refusedAt *float64
+}
+
+// Ledger tracks the expected balance of every account. It is safe for
+// concurrent use; a nil *Ledger disables tracking.
+type Ledger struct {
+	start     float64 // GBP
+	tolerance float64
+	logf      func(string, ...any)
+
+	mu       sync.Mutex
+	accounts map[string]*accountState
+	findings []Finding
+	counts   map[string]int
+
+	// contended counts bouts closed while overlapping another on the
+	// same account.
+	contended int
+}
+
+// NewLedger creates a ledger in which every account starts with
+// startCredits. A nil logf disables logging.
+func NewLedger(startCredits float64, logf func(string, ...any)) *Ledger {
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+	return &Ledger{
+		start:     ToGBP(startCredits),
+		tolerance: DefaultTolerance,
+		logf:      logf,
+		accounts:  make(map[string]*accountState),
+		counts:    make(map[string]int),
+	}
+}
+
+// SetTolerance sets the relative slack used by the checks.
+func (l *Ledger) SetTolerance(t float64) {
+	l.mu.Lock()
+	l.tolerance = t
+	l.mu.Unlock()
+}
+
+// Begin opens a hold for a bout of the given model and turns on account,
+// preauthorizing its estimated cost as the server does when the balance
+// covers it. Anonymous sessions (empty account) draw on the intro pool,
+// not a balance, and are not tracked.
+func (l *Ledger) Begin(account, model string, turns int) *Hold {
+	if l == nil || account == "" {
+		return nil
+	}
+	est := budget.EstimateBoutCost(model, turns, budget.DefaultOutputPerTurn)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	a := l.account(account)
+	h := &Hold{account: account, model: model, estimate: est, balance: a.balance, available: a.balance - a.held}
+	if h.available >= est {
+		h.covered = true
+		a.held += est
+	}
+	for other := range a.open {
+		other.contended, h.contended = true, true
+	}
+	a.open[h] = true
+	return h
+}
+
+// Refused records that the server refused the bout for lack of credits.
+func (l *Ledger) Refused(h *Hold) {
+	if l == nil || h == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	a := l.release(h)
+	a.refusals++
+	if a.refusedAt == nil {
+		at := h.available
+		a.refusedAt = &at
+		l.logf("[credits] %s refused at %.2f credits (bout needs %.2f)",
+			h.account, FromGBP(h.available), FromGBP(h.estimate))
+	}
+	if !h.contended && h.available >= h.estimate*(1+l.tolerance) {
+		l.find(h, KindPremature)
+	}
+}
+
+// Settle records that the bout ran and cost costGBP.
+func (l *Ledger) Settle(h *Hold, costGBP float64) {
+	if l == nil || h == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	a := l.release(h)
+	switch {
+	case h.balance <= 0:
+		l.find(h, KindEmpty)
+	case !h.contended && h.available < h.estimate*(1-l.tolerance):
+		l.find(h, KindOverdraft)
+	}
+	a.balance -= costGBP
+	a.spent += costGBP
+	a.bouts++
+}
+
+// Release drops the hold of a bout that failed for another reason; the
+// server refunds its preauthorization.
+func (l *Ledger) Release(h *Hold) {
+	if l == nil || h == nil {
+		return
+	}
+	l.mu.Lock()
+	l.release(h)
+	l.mu.Unlock()
+}
+
+// release closes h, dropping its preauthorization. The caller holds l.mu.
+func (l *Ledger) release(h *Hold) *accountState {
+	a := l.account(h.account)
+	if h.covered {
+		a.held -= h.estimate
+	}
+	delete(a.open, h)
+	if h.contended {
+		l.contended++
+	}
+	return a
+}
+
+// account returns the state of id, creating it at the starting balance.
+// The caller holds l.mu.
+func (l *Ledger) account(id string) *accountState {
+	a, ok := l.accounts[id]
+	if !ok {
+		a = &accountState{balance: l.start, open: make(map[*Hold]bool)}
+		l.accounts[id] = a
+	}
+	return a
+}
+
+// find records a finding. The caller holds l.mu.
+func (l *Ledger) find(h *Hold, kind string) {
+	l.counts[kind]++
+	balance := h.available
+	if kind == KindEmpty {
+		balance = h.balance
+	}
+	f := Finding{
+		Account:         h.account,
+		Kind:            kind,
+		Model:           h.model,
+		BalanceCredits:  FromGBP(balance),
+		EstimateCredits: FromGBP(h.estimate),
+	}
+	if len(l.findings) < maxFindings {
+		l.findings = append(l.findings, f)
+	}
+	l.logf("[credits] %s: %s", kind, f)
+}
+
+// AccountReport is one account's line in the report.
+type AccountReport struct {
+	Account        string  `json:"account"`
+	Bouts          int     `json:"bouts"`
+	Refusals       int     `json:"refusals"`
+	SpentCredits   float64 `json:"spentCredits"`
+	BalanceCredits float64 `json:"balanceCredits"`
+
+	// RefusedAtCredits is the expected balance at the first refusal.
+	RefusedAtCredits *float64 `json:"refusedAtCredits,omitempty"`
+}
+
+// Report summarises a credit depletion run.
+type Report struct {
+	StartCredits float64         `json:"startCredits"`
+	Accounts     []AccountReport `json:"accounts"`
+	Counts       map[string]int  `json:"counts,omitempty"`
+	Findings     []Finding       `json:"findings,omitempty"`
+
+	// Contended counts bouts that overlapped another on the same account
+	// and so were only checked for running on an empty balance.
+	Contended int `json:"contended,omitempty"`
+}
+
+// Report returns the ledger's report, accounts sorted by ID.
+func (l *Ledger) Report() Report {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r := Report{StartCredits: FromGBP(l.start), Contended: l.contended}
+	for id, a := range l.accounts {
+		ar := AccountReport{
+			Account:        id,
+			Bouts:          a.bouts,
+			Refusals:       a.refusals,
+			SpentCredits:   FromGBP(a.spent),
+			BalanceCredits: FromGBP(a.balance),
+		}
+		if a.refusedAt != nil {
+			at := FromGBP(*a.refusedAt)
+			ar.RefusedAtCredits = &at
+		}
+		r.Accounts = append(r.Accounts, ar)
+	}
+	sort.Slice(r.Accounts, func(i, j int) bool { return r.Accounts[i].Account < r.Accounts[j].Account })
+	if len(l.counts) > 0 {
+		r.Counts = make(map[string]int, len(l.counts))
+		for k, v := range l.counts {
+			r.Counts[k] = v
+		}
+	}
+	r.Findings = append([]Finding(nil), l.findings...)
+	return r
+}
+
+// Passed reports whether the server's refusals matched the ledger.
+func (r Report) Passed() bool { return len(r.Counts) == 0 }
+
+// Depleted returns the number of accounts the server refused at least once.
+func (r Report) Depleted() int {
+	n := 0
+	for _, a := range r.Accounts {
+		if a.Refusals > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// FormatReport renders the report as a per-account table followed by
+// the checks.
+func FormatReport(r Report) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n  Credits (%.2f per account)\n\n", r.StartCredits)
+	fmt.Fprintf(&b, "  %-28s %6s %8s %10s %10s %11s\n", "Account", "Bouts", "Refused", "Spent", "Balance", "Refused at")
+	fmt.Fprintf(&b, "  %s\n", strings.Repeat("─", 78))
+	for _, a := range r.Accounts {
+		at := "—"
+		if a.RefusedAtCredits != nil {
+			at = fmt.Sprintf("%.2f", *a.RefusedAtCredits)
+		}
+		fmt.Fprintf(&b, "  %-28s %6d %8d %10.2f %10.2f %11s\n",
+			a.Account, a.Bouts, a.Refusals, a.SpentCredits, a.BalanceCredits, at)
+	}
+
+	b.WriteString("\n")
+	if r.Depleted() == 0 {
+		b.WriteString("  WARN: no account ran out of credits (lower --credits or run longer)\n")
+	}
+	if r.Contended > 0 {
+		fmt.Fprintf(&b, "  NOTE: %d bouts overlapped another on the same account and were only checked for empty balances\n", r.Contended)
+	}
+	if r.Passed() {
+		fmt.Fprintf(&b, "  PASS: %d of %d accounts refused at the expected balance, no bout ran without credits\n",
+			r.Depleted(), len(r.Accounts))
+		return b.String()
+	}
+	kinds := make([]string, 0, len(r.Counts))
+	for k := range r.Counts {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	for _, k := range kinds {
+		fmt.Fprintf(&b, "  FAIL: %d %s\n", r.Counts[k], k)
+	}
+	for _, f := range r.Findings {
+		fmt.Fprintf(&b, "    %s\n", f)
+	}
+	return b.String()
+}
+
+// shortModel trims the date suffix from a model ID for display.
+func shortModel(model string) string {
+	if i := strings.LastIndex(model, "-20"); i > 0 {
+		return model[:i]
+	}
+	return model
+}
@@ -0,0 +1,136 @@
+package credits
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+)
+
+const haiku = "claude-haiku-4-5-20251001"
+
+func TestLedgerDepletion(t *testing.T) {
+	est := budget.EstimateBoutCost(haiku, 4, budget.DefaultOutputPerTurn)
+	// Room for exactly two bouts at their estimated cost.
+	l := NewLedger(FromGBP(est*2.5), nil)
+
+	for i := 0; i < 2; i++ {
+		h := l.Begin("account-a", haiku, 4)
+		if !h.covered {
+			t.Fatalf("bout %d not covered", i+1)
+		}
+		l.Settle(h, est)
+	}
+	h := l.Begin("account-a", haiku, 4)
+	if h.covered {
+		t.Fatal("third bout covered by half a bout's balance")
+	}
+	l.Refused(h)
+
+	r := l.Report()
+	if !r.Passed() {
+		t.Errorf("findings = %v", r.Findings)
+	}
+	if len(r.Accounts) != 1 || r.Accounts[0].Bouts != 2 || r.Accounts[0].Refusals != 1 {
+		t.Fatalf("accounts = %+v", r.Accounts)
+	}
+	at := r.Accounts[0].RefusedAtCredits
+	if at == nil || *at < FromGBP(est*0.49) || *at > FromGBP(est*0.51) {
+		t.Errorf("refused at %v, want half a bout", at)
+	}
+	if r.Depleted() != 1 {
+		t.Errorf("Depleted = %d", r.Depleted())
+	}
+}
+
+func TestLedgerFindings(t *testing.T) {
+	est := budget.EstimateBoutCost(haiku, 4, budget.DefaultOutputPerTurn)
+	l := NewLedger(FromGBP(est*1.5), nil)
+
+	// Refused with a bout and a half in the account.
+	l.Refused(l.Begin("account-a", haiku, 4))
+
+	// Ran one bout legitimately, then another with half a bout left,
+	// then a third with nothing left.
+	l.Settle(l.Begin("account-b", haiku, 4), est)
+	l.Settle(l.Begin("account-b", haiku, 4), est)
+	l.Settle(l.Begin("account-b", haiku, 4), est)
+
+	r := l.Report()
+	if r.Passed() {
+		t.Fatal("report passed")
+	}
+	want := map[string]int{KindPremature: 1, KindOverdraft: 1, KindEmpty: 1}
+	for k, n := range want {
+		if r.Counts[k] != n {
+			t.Errorf("%s = %d, want %d", k, r.Counts[k], n)
+		}
+	}
+	out := FormatReport(r)
+	for _, s := range []string{"FAIL: 1 premature-refusal", "account-b ran a claude-haiku-4-5 bout on an empty balance"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("report missing %q:\n%s", s, out)
+		}
+	}
+}
+
+func TestLedgerHoldsAndRelease(t *testing.T) {
+	est := budget.EstimateBoutCost(haiku, 4, budget.DefaultOutputPerTurn)
+	l := NewLedger(FromGBP(est*1.5), nil)
+
+	// A concurrent second bout finds the first one's hold.
+	first := l.Begin("account-a", haiku, 4)
+	second := l.Begin("account-a", haiku, 4)
+	if !first.covered || second.covered {
+		t.Fatalf("covered = %v, %v; want only the first", first.covered, second.covered)
+	}
+	l.Refused(second)
+	l.Release(first)
+
+	// With the failed bout refunded, the balance covers a bout again.
+	if h := l.Begin("account-a", haiku, 4); !h.covered {
+		t.Error("released hold still counted against the balance")
+	}
+	if !l.Report().Passed() {
+		t.Errorf("findings = %v", l.Report().Findings)
+	}
+}
+
+func TestLedgerContendedBouts(t *testing.T) {
+	est := budget.EstimateBoutCost(haiku, 4, budget.DefaultOutputPerTurn)
+	l := NewLedger(FromGBP(est*1.5), nil)
+
+	// Two overlapping bouts reach the server in the opposite order: the
+	// ledger's covered bout is refused and the other one runs.
+	first := l.Begin("account-a", haiku, 4)
+	second := l.Begin("account-a", haiku, 4)
+	l.Settle(second, est)
+	l.Refused(first)
+
+	r := l.Report()
+	if !r.Passed() || r.Contended != 2 {
+		t.Errorf("counts = %v, contended = %d; want no findings and 2 contended", r.Counts, r.Contended)
+	}
+
+	// Running on an empty balance is a finding however bouts overlap.
+	l.Settle(l.Begin("account-a", haiku, 4), est)
+	a := l.Begin("account-a", haiku, 4)
+	b := l.Begin("account-a", haiku, 4)
+	l.Settle(a, est)
+	l.Settle(b, est)
+	if n := l.Report().Counts[KindEmpty]; n != 2 {
+		t.Errorf("ran-on-empty = %d, want 2", n)
+	}
+}
+
+func TestNilLedgerAndAnonymous(t *testing.T) {
+	var l *Ledger
+	h := l.Begin("account-a", haiku, 4)
+	l.Settle(h, 1)
+	l.Refused(h)
+	l.Release(h)
+
+	if h := NewLedger(10, nil).Begin("", haiku, 4); h != nil {
+		t.Error("anonymous session tracked")
+	}
+}
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/action"
+	"github.com/rickhallett/thepit/pitstorm/internal/credits"
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+)
+
+// ABMode selects how a run with a second target divides its traffic.
+type ABMode string
+
+const (
+	// ABMirror sends a copy of every action to target B as well, at the
+	// same moment, so both targets see identical traffic.
+	ABMirror ABMode = "mirror"
+	// ABSplit sends each session to one of the two targets at random.
+	ABSplit ABMode = "split"
+)
+
+// ParseABMode parses an --ab-mode value.
+func ParseABMode(s string) (ABMode, error) {
+	switch m := ABMode(s); m {
+	case ABMirror, ABSplit:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown A/B mode %q (want mirror or split)", s)
+}
+
+// TargetB is the second target of an A/B run. Its traffic is recorded
+// in its own collector and charged to the run's budget gate. In split
+// mode its sessions also go to the run's journal and transcripts,
+// tagged journal.TargetTagB, and are checked against Credits; mirrored
+// requests are copies of journaled ones and are not recorded again.
+type TargetB struct {
+	Mode    ABMode
+	Actor   *action.Actor
+	Metrics *metrics.Collector
+
+	// Credits, if set, checks target B's out-of-credits refusals. Its
+	// accounts have their own balances there, so it is a separate
+	// ledger from Config.Credits.
+	Credits *credits.Ledger
+}
+
+// mirror re-sends the requests of one dispatcher through another.
+type mirror struct {
+	d   *Dispatcher
+	ids boutIDMap
+}
+
+func newMirror(d *Dispatcher) *mirror {
+	return &mirror{d: d, ids: boutIDMap{ids: make(map[string]string)}}
+}
+
+// start returns the call's mirror hook, which sends each recorded
+// payload to the mirror target concurrently, and a wait function that
+// blocks until every mirrored request has completed.
+func (m *mirror) start(ctx context.Context, c call) (hook func(any), wait func()) {
+	var wg sync.WaitGroup
+	hook = func(payload any) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.send(ctx, c, payload)
+		}()
+	}
+	return hook, wg.Wait
+}
+
+// send dispatches a recorded payload through the mirror dispatcher.
+// Bout IDs are mapped to fresh ones, consistently, because the two
+// targets may share a database that rejects duplicate bout IDs.
+func (m *mirror) send(ctx context.Context, c call, payload any) {
+	c.mirror = nil
+	if err := m.d.resend(ctx, c, payload, m.ids.get); err != nil {
+		m.d.logf("[mirror] %v, skipping", err)
+	}
+}
+
+// boutIDMap maps bout IDs sent to one target to the IDs used for the
+// same bouts elsewhere. Unknown IDs get a fresh ID on first sight so
+// later references stay consistent.
+type boutIDMap struct {
+	mu  sync.Mutex
+	ids map[string]string
+}
+
+func (m *boutIDMap) get(id string) string {
+	if id == "" {
+		return id
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mapped, ok := m.ids[id]; ok {
+		return mapped
+	}
+	mapped := action.GenerateID(len(id))
+	m.ids[id] = mapped
+	return mapped
+}
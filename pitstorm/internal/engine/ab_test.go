@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/action"
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+	"github.com/rickhallett/thepit/pitstorm/internal/client"
+	"github.com/rickhallett/thepit/pitstorm/internal/journal"
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+	"github.com/rickhallett/thepit/pitstorm/internal/persona"
+)
+
+// abTarget is a test server that records the bout IDs it is sent.
+type abTarget struct {
+	srv *httptest.Server
+	cl  *client.Client
+
+	mu    sync.Mutex
+	paths []string
+	bouts []string // boutId of each request that carries one
+}
+
+func newABTarget(t *testing.T) *abTarget {
+	t.Helper()
+	tg := &abTarget{}
+	tg.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			BoutID string `json:"boutId"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		tg.mu.Lock()
+		tg.paths = append(tg.paths, r.URL.Path)
+		if body.BoutID != "" {
+			tg.bouts = append(tg.bouts, body.BoutID)
+		}
+		tg.mu.Unlock()
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(tg.srv.Close)
+	clientCfg := client.DefaultConfig(tg.srv.URL)
+	clientCfg.MaxRetries = 0
+	tg.cl = client.New(clientCfg, nil)
+	t.Cleanup(tg.cl.Close)
+	return tg
+}
+
+func TestDispatcherMirrorsToTargetB(t *testing.T) {
+	a, b := newABTarget(t), newABTarget(t)
+	gate := budget.NewGate(10)
+	mA, mB := metrics.NewCollector(), metrics.NewCollector()
+	d := NewDispatcher(action.New(a.cl), mA, gate, nil)
+	d.SetMirror(NewDispatcher(action.New(b.cl), mB, gate, nil))
+
+	spec := persona.LabPowerUser()
+	ctx := context.Background()
+	d.DispatchAs(ctx, 0, spec, "account-lab", persona.ActionAPIBout, nil)
+	d.DispatchAs(ctx, 0, spec, "account-lab", persona.ActionReaction, nil)
+	d.DispatchAs(ctx, 0, spec, "account-lab", persona.ActionBrowse, nil)
+
+	if len(a.paths) != 3 || len(b.paths) != 3 {
+		t.Fatalf("paths = %v / %v, want every request on both targets", a.paths, b.paths)
+	}
+	for i := range a.paths {
+		if a.paths[i] != b.paths[i] {
+			t.Errorf("request %d: A %s, B %s", i, a.paths[i], b.paths[i])
+		}
+	}
+
+	// B gets its own bout ID, and the reaction references it.
+	if len(a.bouts) != 2 || len(b.bouts) != 2 {
+		t.Fatalf("bout IDs = %v / %v", a.bouts, b.bouts)
+	}
+	if a.bouts[0] == b.bouts[0] {
+		t.Error("mirrored bout reused target A's bout ID")
+	}
+	if a.bouts[1] != a.bouts[0] || b.bouts[1] != b.bouts[0] {
+		t.Errorf("reactions reference %s / %s, want each target's own bout", a.bouts[1], b.bouts[1])
+	}
+
+	sA, sB := mA.Snapshot(), mB.Snapshot()
+	if sA.Requests != 3 || sB.Requests != 3 || sB.BoutsDone != 1 {
+		t.Errorf("A requests = %d, B requests = %d, B bouts = %d", sA.Requests, sB.Requests, sB.BoutsDone)
+	}
+	if n := gate.Summary().BoutCount; n != 2 {
+		t.Errorf("budget charged for %d bouts, want both targets' bouts", n)
+	}
+}
+
+func TestEngineSplitsSessions(t *testing.T) {
+	a, b := newABTarget(t), newABTarget(t)
+	mA, mB := metrics.NewCollector(), metrics.NewCollector()
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	jw, err := journal.Create(path, journal.Header{TargetB: b.srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New(Config{
+		Workers:  4,
+		Duration: 300 * time.Millisecond,
+		Seed:     7,
+		Journal:  jw,
+		TargetB:  &TargetB{Mode: ABSplit, Actor: action.New(b.cl), Metrics: mB},
+	}, a.cl, action.New(a.cl), mA, budget.NewGate(10), []*persona.Spec{persona.FreeLurker()}, nil)
+	if err := e.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	jw.Close()
+
+	sA, sB := mA.Snapshot(), mB.Snapshot()
+	if sA.Requests == 0 || sB.Requests == 0 {
+		t.Fatalf("requests = %d / %d, want sessions on both targets", sA.Requests, sB.Requests)
+	}
+	if int(sA.Requests) != len(a.paths) || int(sB.Requests) != len(b.paths) {
+		t.Errorf("collectors counted %d / %d, servers saw %d / %d", sA.Requests, sB.Requests, len(a.paths), len(b.paths))
+	}
+
+	// Both targets' sessions are journaled, B's tagged, so the journal
+	// holds the whole run.
+	_, entries, err := journal.Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nA, nB int
+	for _, en := range entries {
+		if en.Target == journal.TargetTagB {
+			nB++
+		} else {
+			nA++
+		}
+	}
+	if nA != len(a.paths) || nB != len(b.paths) {
+		t.Errorf("journaled %d / %d requests, servers saw %d / %d", nA, nB, len(a.paths), len(b.paths))
+	}
+}
+
+func TestParseABMode(t *testing.T) {
+	if m, err := ParseABMode("split"); err != nil || m != ABSplit {
+		t.Errorf("ParseABMode(split) = %q, %v", m, err)
+	}
+	if _, err := ParseABMode("shadow"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
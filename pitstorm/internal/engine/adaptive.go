@@ -0,0 +1,240 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+)
+
+// AdaptiveConfig controls a closed-loop saturation search.
+type AdaptiveConfig struct {
+	StartRate float64       // first rate probed, req/s
+	MaxRate   float64       // never probe above this rate
+	Step      time.Duration // how long each rate is held
+	// Settle is the start of each step that is excluded from
+	// measurement while in-flight sessions adjust to the new rate.
+	Settle time.Duration
+	// Growth multiplies the rate after each passing step until a
+	// violation is found.
+	Growth float64
+	// Tolerance ends the binary search once the gap between the highest
+	// passing and lowest failing rate is within this fraction.
+	Tolerance float64
+	MaxSteps  int
+	// KneeFactor marks an endpoint as degraded once its p95 exceeds this
+	// multiple of its p95 at the lowest passing rate.
+	KneeFactor float64
+	// MinAchieved is the fraction of the target rate that must actually
+	// be dispatched; below it the step fails as client-limited.
+	MinAchieved float64
+}
+
+// DefaultAdaptiveConfig returns the default search parameters.
+func DefaultAdaptiveConfig() AdaptiveConfig {
+	return AdaptiveConfig{
+		StartRate:   1,
+		MaxRate:     200,
+		Step:        30 * time.Second,
+		Settle:      5 * time.Second,
+		Growth:      1.5,
+		Tolerance:   0.1,
+		MaxSteps:    16,
+		KneeFactor:  2,
+		MinAchieved: 0.8,
+	}
+}
+
+// Target judges a measurement window. It returns a description of each
+// violated objective; none means the rate is sustainable.
+type Target func(window metrics.Snapshot) []string
+
+// AdaptiveStep is one probed rate and what was measured while holding it.
+type AdaptiveStep struct {
+	Rate       float64          `json:"rate"`
+	Achieved   float64          `json:"achievedRps"`
+	Pass       bool             `json:"pass"`
+	Violations []string         `json:"violations,omitempty"`
+	Window     metrics.Snapshot `json:"window"`
+}
+
+// Knee is the highest probed rate at which an endpoint was still healthy
+// (0 if it was already degraded at the lowest rate it saw).
+type Knee struct {
+	Endpoint string  `json:"endpoint"`
+	Rate     float64 `json:"rate"`
+	// Saturated is false when the endpoint never degraded, so Rate is a
+	// lower bound.
+	Saturated bool   `json:"saturated"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// AdaptiveReport is the outcome of a saturation search.
+type AdaptiveReport struct {
+	// MaxSustainable is the highest rate that met the target (0 if none did).
+	MaxSustainable float64 `json:"maxSustainableRps"`
+	// Saturated is false when every probed rate passed (MaxRate reached
+	// or the run ended first), so MaxSustainable is a lower bound.
+	Saturated bool           `json:"saturated"`
+	Steps     []AdaptiveStep `json:"steps"`
+	Knees     []Knee         `json:"knees"`
+}
+
+// Adaptive drives an Engine's rate closed-loop: it grows the rate until
+// the target is violated, then binary-searches between the highest
+// passing and lowest failing rates for the maximum sustainable
+// throughput. Use RateFunc as the engine's Config.RateFunc and call Run
+// alongside Engine.Run.
+type Adaptive struct {
+	cfg     AdaptiveConfig
+	metrics *metrics.Collector
+	target  Target
+	logf    func(string, ...any)
+	rate    atomic.Uint64 // float64 bits
+}
+
+// NewAdaptive creates a saturation search reading from m.
+func NewAdaptive(cfg AdaptiveConfig, m *metrics.Collector, target Target, logf func(string, ...any)) *Adaptive {
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+	a := &Adaptive{cfg: cfg, metrics: m, target: target, logf: logf}
+	a.setRate(cfg.StartRate)
+	return a
+}
+
+// RateFunc returns the engine rate function controlled by the search.
+func (a *Adaptive) RateFunc() RateFunc {
+	return func(elapsed, total time.Duration) float64 {
+		return math.Float64frombits(a.rate.Load())
+	}
+}
+
+func (a *Adaptive) setRate(r float64) {
+	a.rate.Store(math.Float64bits(r))
+}
+
+// Run performs the search and returns the report. It stops early, with
+// a partial report, when ctx is done.
+func (a *Adaptive) Run(ctx context.Context) AdaptiveReport {
+	var rep AdaptiveReport
+	lo, hi := 0.0, math.Inf(1)
+	rate := a.cfg.StartRate
+
+	for len(rep.Steps) < a.cfg.MaxSteps {
+		step, ok := a.probe(ctx, rate)
+		if !ok {
+			break
+		}
+		rep.Steps = append(rep.Steps, step)
+		if step.Pass {
+			lo = rate
+			a.logf("[adaptive] %.2f req/s ok (achieved %.2f)", rate, step.Achieved)
+		} else {
+			hi = rate
+			a.logf("[adaptive] %.2f req/s violated: %v", rate, step.Violations)
+		}
+
+		if math.IsInf(hi, 1) {
+			// Still growing.
+			if lo >= a.cfg.MaxRate {
+				break
+			}
+			rate = math.Min(rate*a.cfg.Growth, a.cfg.MaxRate)
+			continue
+		}
+		if (hi-lo)/hi <= a.cfg.Tolerance {
+			break
+		}
+		rate = (lo + hi) / 2
+	}
+
+	rep.MaxSustainable = lo
+	rep.Saturated = !math.IsInf(hi, 1)
+	rep.Knees = knees(rep.Steps, a.cfg.KneeFactor)
+	return rep
+}
+
+// probe holds rate for one step and measures the window after Settle.
+func (a *Adaptive) probe(ctx context.Context, rate float64) (AdaptiveStep, bool) {
+	a.setRate(rate)
+	if !sleepCtx(ctx, a.cfg.Settle) {
+		return AdaptiveStep{}, false
+	}
+	mark := a.metrics.Mark()
+	if !sleepCtx(ctx, a.cfg.Step-a.cfg.Settle) {
+		return AdaptiveStep{}, false
+	}
+	window := a.metrics.Since(mark)
+
+	step := AdaptiveStep{Rate: rate, Achieved: window.Throughput, Window: window}
+	step.Violations = a.target(window)
+	if window.Throughput < rate*a.cfg.MinAchieved {
+		step.Violations = append(step.Violations,
+			fmt.Sprintf("client-limited: dispatched %.2f of %.2f req/s (add workers)", window.Throughput, rate))
+	}
+	step.Pass = len(step.Violations) == 0
+	return step, true
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// knees finds, per endpoint, the highest probed rate before its p95
+// exceeded kneeFactor times its p95 at the lowest probed rate, or before
+// it returned errors in a step that violated the target. Steps are
+// considered in rate order.
+func knees(steps []AdaptiveStep, kneeFactor float64) []Knee {
+	sorted := make([]AdaptiveStep, len(steps))
+	copy(sorted, steps)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Rate < sorted[j].Rate })
+
+	endpoints := make(map[string]bool)
+	for _, s := range sorted {
+		for ep := range s.Window.Latencies {
+			endpoints[ep] = true
+		}
+	}
+
+	var out []Knee
+	for ep := range endpoints {
+		k := Knee{Endpoint: ep}
+		baseline := 0.0
+		for _, s := range sorted {
+			ls, ok := s.Window.Latencies[ep]
+			if !ok || ls.Count == 0 {
+				continue
+			}
+			if baseline == 0 {
+				baseline = ls.P95
+			}
+			if baseline > 0 && ls.P95 > baseline*kneeFactor {
+				k.Saturated = true
+				k.Reason = fmt.Sprintf("p95 %.0fms vs %.0fms baseline at %.2f req/s", ls.P95, baseline, s.Rate)
+				break
+			}
+			if errs := s.Window.ErrorsByEP[ep]; errs > 0 && !s.Pass {
+				k.Saturated = true
+				k.Reason = fmt.Sprintf("%d error(s) at %.2f req/s", errs, s.Rate)
+				break
+			}
+			k.Rate = s.Rate
+		}
+		out = append(out, k)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Endpoint < out[j].Endpoint })
+	return out
+}
@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+)
+
+func TestAdaptiveFindsSaturation(t *testing.T) {
+	m := metrics.NewCollector()
+	cfg := DefaultAdaptiveConfig()
+	cfg.StartRate = 10
+	cfg.Step = 60 * time.Millisecond
+	cfg.Settle = 15 * time.Millisecond
+	cfg.MinAchieved = 0
+
+	errorRate := func(w metrics.Snapshot) []string {
+		if w.ErrorRate > 0.05 {
+			return []string{"error rate"}
+		}
+		return nil
+	}
+	a := NewAdaptive(cfg, m, errorRate, nil)
+	rate := a.RateFunc()
+
+	// Simulated target: fails every request above 40 req/s.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for ctx.Err() == nil {
+			m.RecordRequest()
+			if rate(0, 0) > 40 {
+				m.RecordError("/api/run-bout")
+			} else {
+				m.RecordSuccess()
+			}
+			m.RecordLatency("/api/run-bout", time.Millisecond)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	rep := a.Run(ctx)
+	if !rep.Saturated {
+		t.Fatalf("expected saturation, steps = %d", len(rep.Steps))
+	}
+	if rep.MaxSustainable < 36 || rep.MaxSustainable > 40 {
+		t.Errorf("MaxSustainable = %.2f, want within 10%% below 40", rep.MaxSustainable)
+	}
+	if len(rep.Knees) != 1 || !rep.Knees[0].Saturated || rep.Knees[0].Rate != rep.MaxSustainable {
+		t.Errorf("knees = %+v", rep.Knees)
+	}
+}
+
+func TestAdaptiveStopsAtMaxRate(t *testing.T) {
+	m := metrics.NewCollector()
+	cfg := DefaultAdaptiveConfig()
+	cfg.StartRate, cfg.MaxRate = 5, 10
+	cfg.Step, cfg.Settle = 10*time.Millisecond, 0
+	cfg.MinAchieved = 0
+
+	a := NewAdaptive(cfg, m, func(metrics.Snapshot) []string { return nil }, nil)
+	rep := a.Run(context.Background())
+	if rep.Saturated || rep.MaxSustainable != 10 {
+		t.Errorf("report = %+v, want unsaturated lower bound 10", rep)
+	}
+	if len(rep.Steps) != 3 { // 5, 7.5, 10
+		t.Errorf("steps = %d, want 3", len(rep.Steps))
+	}
+}
+
+func TestAdaptiveClientLimited(t *testing.T) {
+	m := metrics.NewCollector()
+	cfg := DefaultAdaptiveConfig()
+	cfg.Step, cfg.Settle, cfg.MaxSteps = 10*time.Millisecond, 0, 1
+
+	a := NewAdaptive(cfg, m, func(metrics.Snapshot) []string { return nil }, nil)
+	rep := a.Run(context.Background())
+	if len(rep.Steps) != 1 || rep.Steps[0].Pass {
+		t.Fatalf("steps = %+v, want one failing step", rep.Steps)
+	}
+	if !strings.Contains(rep.Steps[0].Violations[0], "client-limited") {
+		t.Errorf("violations = %v", rep.Steps[0].Violations)
+	}
+}
+
+func TestKneesLatency(t *testing.T) {
+	step := func(rate, fastP95, slowP95 float64) AdaptiveStep {
+		return AdaptiveStep{Rate: rate, Pass: true, Window: metrics.Snapshot{
+			Latencies: map[string]metrics.LatencyStats{
+				"/fast": {Count: 10, P95: fastP95},
+				"/slow": {Count: 10, P95: slowP95},
+			},
+		}}
+	}
+	// Out of rate order, as a binary search produces.
+	steps := []AdaptiveStep{step(10, 50, 100), step(40, 60, 500), step(20, 55, 150)}
+	ks := knees(steps, 2)
+	if len(ks) != 2 {
+		t.Fatalf("knees = %+v", ks)
+	}
+	if k := ks[0]; k.Endpoint != "/fast" || k.Saturated || k.Rate != 40 {
+		t.Errorf("/fast = %+v, want unsaturated at 40", k)
+	}
+	if k := ks[1]; k.Endpoint != "/slow" || !k.Saturated || k.Rate != 20 {
+		t.Errorf("/slow = %+v, want knee at 20", k)
+	}
+}
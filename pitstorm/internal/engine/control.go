@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+	"github.com/rickhallett/thepit/pitstorm/internal/control"
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+)
+
+// Engine implements the runtime control API and the dashboard's keys.
+var _ control.Controller = (*Engine)(nil)
+
+// idlePoll is how often an idle worker, whose persona is disabled or
+// whose budget is exhausted, checks whether it can carry on.
+const idlePoll = 250 * time.Millisecond
+
+// runControl holds the run-time adjustments an operator can make to a
+// running engine. The zero value follows the configured RateFunc with
+// every persona enabled.
+type runControl struct {
+	paused   atomic.Bool
+	override atomic.Uint64 // float64 bits; 0 = follow RateFunc
+	applied  atomic.Uint64 // float64 bits of the rate the feeder last used
+
+	mu       sync.Mutex
+	disabled map[string]bool
+}
+
+// Pause stops the ticket feeder. Workers finish the action in hand and
+// then wait for tickets, discarding any already buffered, so nothing
+// new is dispatched until Resume; the run's clock and the profile keep
+// moving.
+func (e *Engine) Pause() { e.ctl.paused.Store(true) }
+
+// Resume restarts the ticket feeder after Pause.
+func (e *Engine) Resume() { e.ctl.paused.Store(false) }
+
+// Paused reports whether the ticket feeder is paused.
+func (e *Engine) Paused() bool { return e.ctl.paused.Load() }
+
+// SetRate replaces the traffic profile (or adaptive search) with a fixed
+// rate in req/s. A rate of 0 or less hands control back to the profile.
+func (e *Engine) SetRate(rps float64) {
+	e.ctl.override.Store(math.Float64bits(max(rps, 0)))
+}
+
+// RateOverride returns the rate set by SetRate, or 0 when the profile
+// is in control.
+func (e *Engine) RateOverride() float64 {
+	return math.Float64frombits(e.ctl.override.Load())
+}
+
+// Rate returns the target rate the ticket feeder last applied: +Inf
+// when unlimited, 0 before the run starts.
+func (e *Engine) Rate() float64 {
+	return math.Float64frombits(e.ctl.applied.Load())
+}
+
+// targetRate is the rate the feeder should apply at elapsed into the
+// run, +Inf meaning unlimited.
+func (e *Engine) targetRate(elapsed time.Duration) float64 {
+	rps := e.RateOverride()
+	switch {
+	case rps > 0:
+	case e.cfg.RateFunc == nil:
+		rps = math.Inf(1)
+	default:
+		rps = e.cfg.RateFunc(elapsed, e.cfg.Duration)
+	}
+	e.ctl.applied.Store(math.Float64bits(rps))
+	return rps
+}
+
+// Personas reports each persona in the run, its worker count, and
+// whether it is enabled.
+func (e *Engine) Personas() []control.PersonaState {
+	out := make([]control.PersonaState, len(e.personas))
+	for i, spec := range e.personas {
+		workers := e.cfg.Workers / len(e.personas)
+		if i < e.cfg.Workers%len(e.personas) {
+			workers++
+		}
+		out[i] = control.PersonaState{ID: spec.ID, Workers: workers, Enabled: e.personaEnabled(spec.ID)}
+	}
+	return out
+}
+
+// SetPersonaEnabled switches a persona's workers off or back on. A
+// disabled persona's workers finish their current action and idle.
+func (e *Engine) SetPersonaEnabled(id string, enabled bool) error {
+	found := false
+	for _, spec := range e.personas {
+		found = found || spec.ID == id
+	}
+	if !found {
+		return fmt.Errorf("persona %q is not in this run", id)
+	}
+	e.ctl.mu.Lock()
+	defer e.ctl.mu.Unlock()
+	if e.ctl.disabled == nil {
+		e.ctl.disabled = make(map[string]bool)
+	}
+	e.ctl.disabled[id] = !enabled
+	return nil
+}
+
+func (e *Engine) personaEnabled(id string) bool {
+	e.ctl.mu.Lock()
+	defer e.ctl.mu.Unlock()
+	return !e.ctl.disabled[id]
+}
+
+// RaiseBudget lifts the budget ceiling (see budget.Gate.RaiseCeiling).
+// While the control API is served, workers idle rather than stop when
+// the budget is exhausted, so raising it resumes the run.
+func (e *Engine) RaiseBudget(gbp float64) error {
+	return e.budget.RaiseCeiling(gbp)
+}
+
+// Budget returns the budget gate's current summary.
+func (e *Engine) Budget() budget.Summary {
+	return e.budget.Summary()
+}
+
+// Snapshot takes a metrics snapshot now, refreshing the status file if
+// one is configured rather than waiting for the next monitor tick.
+func (e *Engine) Snapshot() metrics.Snapshot {
+	snap := e.metrics.Snapshot()
+	if e.cfg.StatusFile != "" {
+		e.writeStatus(snap, e.budget.Summary())
+	}
+	return snap
+}
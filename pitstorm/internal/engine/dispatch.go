@@ -5,14 +5,19 @@ import (
 	"fmt"
 	"io"
 	"math/rand/v2"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/rickhallett/thepit/pitstorm/internal/action"
 	"github.com/rickhallett/thepit/pitstorm/internal/budget"
 	"github.com/rickhallett/thepit/pitstorm/internal/client"
+	"github.com/rickhallett/thepit/pitstorm/internal/credits"
+	"github.com/rickhallett/thepit/pitstorm/internal/journal"
 	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
 	"github.com/rickhallett/thepit/pitstorm/internal/persona"
+	"github.com/rickhallett/thepit/pitstorm/internal/probe"
+	"github.com/rickhallett/thepit/pitstorm/internal/transcript"
 )
 
 // boutTimeout is the maximum wall-clock time for a single streaming bout
@@ -64,10 +69,35 @@ type Dispatcher struct {
 	budget  *budget.Gate
 	logf    func(string, ...any)
 
+	// journal, if set, receives every request payload just before it is sent.
+	journal *journal.Writer
+
+	// transcripts, if set, receives the full content of every parsed bout stream.
+	transcripts *transcript.Writer
+
+	// mirror, if set, receives a copy of every request sent (A/B mirror).
+	mirror *mirror
+
+	// target tags journal entries and transcripts with the target sent
+	// to (journal.TargetTagB for target B of an A/B split).
+	target string
+
+	// credits, if set, tracks each account's expected credit balance and
+	// checks the server's out-of-credits refusals against it.
+	credits *credits.Ledger
+
 	// boutIDs tracks bout IDs created during this run so that
 	// reactions, votes, and short-links can reference real bouts.
-	boutMu  sync.Mutex
-	boutIDs []string
+	// Seeded runs pick from each worker's own bouts (workerBouts), so
+	// a worker's references don't depend on how workers interleave.
+	boutMu      sync.Mutex
+	boutIDs     []string
+	workerBouts map[int][]string
+
+	// degraded remembers which persona sub-ceilings have already been
+	// reported, so each is logged once.
+	degradeMu sync.Mutex
+	degraded  map[string]bool
 }
 
 // NewDispatcher creates a Dispatcher.
@@ -88,55 +118,237 @@ func NewDispatcher(
 	}
 }
 
+// SetJournal enables recording of every dispatched request to w.
+func (d *Dispatcher) SetJournal(w *journal.Writer) {
+	d.journal = w
+}
+
+// SetTranscripts enables capture of every parsed bout stream to w.
+func (d *Dispatcher) SetTranscripts(w *transcript.Writer) {
+	d.transcripts = w
+}
+
+// SetCredits enables the credit depletion checks against l.
+func (d *Dispatcher) SetCredits(l *credits.Ledger) {
+	d.credits = l
+}
+
+// SetTarget tags the dispatcher's journal entries and transcripts with
+// target.
+func (d *Dispatcher) SetTarget(target string) {
+	d.target = target
+}
+
+// SetMirror sends a copy of every request through m as well, for an
+// A/B run in mirror mode.
+func (d *Dispatcher) SetMirror(m *Dispatcher) {
+	d.mirror = newMirror(m)
+}
+
+// call carries the per-dispatch identity and random source into the
+// action handlers. A nil rng uses the global generator.
+type call struct {
+	worker  int
+	persona string
+	account string
+	act     persona.Action
+	rng     *rand.Rand
+
+	// mirror, if set, is handed every payload as it is recorded.
+	mirror func(payload any)
+}
+
+func (c call) intn(n int) int {
+	if c.rng == nil {
+		return intn(n)
+	}
+	if n <= 0 {
+		return 0
+	}
+	return c.rng.IntN(n)
+}
+
+// newBoutID generates a bout ID from the call's random source.
+func (c call) newBoutID() string {
+	return action.GenerateIDFrom(c.rng, 21)
+}
+
+// pathPayload is the journal payload for GET actions that only vary by path.
+type pathPayload struct {
+	Path string `json:"path"`
+}
+
+// floodPayload is the journal payload for rate-limit floods.
+type floodPayload struct {
+	Count int `json:"count"`
+}
+
 // Dispatch executes a single action for the given persona, recording
 // metrics and budget charges.
 func (d *Dispatcher) Dispatch(ctx context.Context, workerID int, spec *persona.Spec, act persona.Action) {
+	d.DispatchFrom(ctx, workerID, spec, act, nil)
+}
+
+// DispatchFrom is Dispatch with payload choices (preset, topic, page,
+// etc.) drawn from rng, so a seeded worker produces the same requests.
+//
+// Once a persona or its model has reached its budget sub-ceiling, bout
+// actions are replaced by one of the persona's other actions, so the
+// rest of its traffic continues while the run carries on.
+func (d *Dispatcher) DispatchFrom(ctx context.Context, workerID int, spec *persona.Spec, act persona.Action, rng *rand.Rand) {
+	d.DispatchAs(ctx, workerID, spec, accountID(spec), act, rng)
+}
+
+// DispatchAs is DispatchFrom signed in as the given account rather than
+// the persona's own, for sessions drawn from an account pool.
+func (d *Dispatcher) DispatchAs(ctx context.Context, workerID int, spec *persona.Spec, acct string, act persona.Action, rng *rand.Rand) {
+	if act.IsBout() {
+		model := boutModel(spec, act)
+		if _, capped := d.budget.AllowFor(spec.ID, model, spec.MaxTurns); capped == budget.CapPersona || capped == budget.CapModel {
+			d.noteDegraded(spec.ID, model, capped)
+			act = spec.PickNonBoutActionFrom(rng)
+		}
+	}
+
 	d.metrics.RecordRequest()
-	endpoint := actionEndpoint(act)
+	d.metrics.RecordAction(spec.ID, string(act))
+	c := call{
+		worker:  workerID,
+		persona: spec.ID,
+		account: acct,
+		act:     act,
+		rng:     rng,
+	}
+	if d.mirror != nil {
+		hook, wait := d.mirror.start(ctx, c)
+		c.mirror = hook
+		defer wait()
+	}
 
 	switch act {
 	case persona.ActionBrowse:
-		d.doBrowse(ctx, spec)
+		d.doBrowse(ctx, c)
 	case persona.ActionRunBout:
-		d.doRunBout(ctx, workerID, spec)
+		d.doRunBout(ctx, c, spec)
 	case persona.ActionAPIBout:
-		d.doAPIBout(ctx, workerID, spec)
+		d.doAPIBout(ctx, c, spec)
 	case persona.ActionCreateAgent:
-		d.doCreateAgent(ctx, spec)
+		d.doCreateAgent(ctx, c)
 	case persona.ActionReaction:
-		d.doReaction(ctx, spec)
+		d.doReaction(ctx, c)
 	case persona.ActionVote:
-		d.doVote(ctx, spec)
+		d.doVote(ctx, c)
 	case persona.ActionShortLink:
-		d.doShortLink(ctx)
+		d.doShortLink(ctx, c)
 	case persona.ActionListFeatures:
-		d.doListFeatures(ctx)
+		d.execListFeatures(ctx, c)
 	case persona.ActionSubmitFeature:
-		d.doSubmitFeature(ctx, spec)
+		d.doSubmitFeature(ctx, c)
 	case persona.ActionVoteFeature:
-		d.doVoteFeature(ctx, spec)
+		d.doVoteFeature(ctx, c)
 	case persona.ActionSubmitPaper:
-		d.doSubmitPaper(ctx, spec)
+		d.doSubmitPaper(ctx, c)
 	case persona.ActionNewsletter:
-		d.doNewsletter(ctx)
+		d.doNewsletter(ctx, c)
 	case persona.ActionContact:
-		d.doContact(ctx)
+		d.doContact(ctx, c)
 	case persona.ActionBYOK:
-		d.doBYOK(ctx, spec)
+		d.doBYOK(ctx, c)
 	case persona.ActionXSSProbe:
-		d.doXSSProbe(ctx, spec)
+		d.doXSSProbe(ctx, c)
 	case persona.ActionSQLInjection:
-		d.doSQLiProbe(ctx, spec)
+		d.doSQLiProbe(ctx, c)
 	case persona.ActionIDORProbe:
-		d.doIDORProbe(ctx, spec)
+		d.doIDORProbe(ctx, c)
 	case persona.ActionRateLimitFlood:
-		d.doRateLimitFlood(ctx, spec)
+		d.execRateLimitFlood(ctx, c, floodPayload{Count: 10})
 	default:
 		d.logf("[worker-%d] unknown action %q, skipping", workerID, act)
+	}
+}
+
+// resend sends a payload recorded by another call (see record) for
+// c.act, recording the request and action once. Bout IDs in the payload
+// go through boutID first. Replay and the A/B mirror resend this way.
+func (d *Dispatcher) resend(ctx context.Context, c call, payload any, boutID func(string) string) error {
+	d.metrics.RecordRequest()
+	d.metrics.RecordAction(c.persona, string(c.act))
+
+	switch p := payload.(type) {
+	case pathPayload:
+		if c.act == persona.ActionBrowse {
+			d.execBrowse(ctx, c, p)
+		} else {
+			d.execProbe(ctx, c, p)
+		}
+	case floodPayload:
+		d.execRateLimitFlood(ctx, c, p)
+	case action.RunBoutRequest:
+		p.BoutID = boutID(p.BoutID)
+		d.execRunBout(ctx, c, p)
+	case action.APIBoutRequest:
+		p.BoutID = boutID(p.BoutID)
+		d.execAPIBout(ctx, c, p)
+	case action.CreateAgentRequest:
+		d.execCreateAgent(ctx, c, p)
+	case action.ReactionRequest:
+		p.BoutID = boutID(p.BoutID)
+		d.execReaction(ctx, c, p)
+	case action.WinnerVoteRequest:
+		p.BoutID = boutID(p.BoutID)
+		d.execVote(ctx, c, p)
+	case action.ShortLinkRequest:
+		p.BoutID = boutID(p.BoutID)
+		d.execShortLink(ctx, c, p)
+	case action.SubmitFeatureRequest:
+		d.execSubmitFeature(ctx, c, p)
+	case action.FeatureVoteRequest:
+		d.execVoteFeature(ctx, c, p)
+	case action.PaperSubmissionRequest:
+		d.execSubmitPaper(ctx, c, p)
+	case action.NewsletterRequest:
+		d.execNewsletter(ctx, c, p)
+	case action.ContactRequest:
+		d.execContact(ctx, c, p)
+	case action.BYOKStashRequest:
+		d.execBYOK(ctx, c, p)
+	case nil:
+		d.execListFeatures(ctx, c)
+	default:
+		return fmt.Errorf("no handler for %T", payload)
+	}
+	return nil
+}
+
+// noteDegraded logs the first time a sub-ceiling degrades a persona.
+func (d *Dispatcher) noteDegraded(personaID, model string, capped budget.Cap) {
+	key := personaID + "/" + model + "/" + string(capped)
+	d.degradeMu.Lock()
+	defer d.degradeMu.Unlock()
+	if d.degraded[key] {
 		return
 	}
+	if d.degraded == nil {
+		d.degraded = make(map[string]bool)
+	}
+	d.degraded[key] = true
+	if capped == budget.CapModel {
+		d.logf("[budget] %s reached the %s sub-ceiling, degrading to non-bout actions", personaID, model)
+	} else {
+		d.logf("[budget] %s reached its persona sub-ceiling, degrading to non-bout actions", personaID)
+	}
+}
 
-	_ = endpoint // Used for error recording in each handler.
+// boutModel returns the model a persona's bout action runs on.
+func boutModel(spec *persona.Spec, act persona.Action) string {
+	switch {
+	case spec.Model != "":
+		return spec.Model
+	case act == persona.ActionAPIBout:
+		return "claude-opus-4-6"
+	default:
+		return "claude-sonnet-4-5-20250929"
+	}
 }
 
 // accountID returns a stable account identifier for a persona.
@@ -148,31 +360,63 @@ func accountID(spec *persona.Spec) string {
 	return "account-" + spec.ID
 }
 
+// record writes the request about to be sent to the journal, if enabled,
+// and hands it to the call's mirror.
+func (d *Dispatcher) record(c call, payload any) {
+	if c.mirror != nil {
+		c.mirror(payload)
+	}
+	if d.journal == nil {
+		return
+	}
+	if err := d.journal.RecordTarget(d.target, c.worker, c.persona, c.account, c.act, payload); err != nil {
+		d.logf("[journal] %v", err)
+	}
+}
+
 // trackBout records a bout ID so other actions can reference it.
-func (d *Dispatcher) trackBout(id string) {
+func (d *Dispatcher) trackBout(c call, id string) {
 	d.boutMu.Lock()
 	d.boutIDs = append(d.boutIDs, id)
+	if c.rng != nil {
+		if d.workerBouts == nil {
+			d.workerBouts = make(map[int][]string)
+		}
+		d.workerBouts[c.worker] = append(d.workerBouts[c.worker], id)
+	}
 	d.boutMu.Unlock()
 }
 
 // randomBoutID returns a random bout ID from previously created bouts,
-// or empty string if no bouts have been created yet.
-func (d *Dispatcher) randomBoutID() string {
+// or empty string if no bouts have been created yet. Seeded calls only
+// pick from bouts created by the same worker.
+func (d *Dispatcher) randomBoutID(c call) string {
 	d.boutMu.Lock()
 	defer d.boutMu.Unlock()
-	if len(d.boutIDs) == 0 {
+	ids := d.boutIDs
+	if c.rng != nil {
+		ids = d.workerBouts[c.worker]
+	}
+	if len(ids) == 0 {
 		return ""
 	}
-	return d.boutIDs[intn(len(d.boutIDs))]
+	return ids[c.intn(len(ids))]
 }
 
 // ---------- Action handlers ----------
+//
+// Each do* handler picks the request payload; the matching exec* handler
+// records it to the journal, sends it, and records metrics. Replay calls
+// the exec* handlers with journaled payloads through resend.
 
-func (d *Dispatcher) doBrowse(ctx context.Context, spec *persona.Spec) {
+func (d *Dispatcher) doBrowse(ctx context.Context, c call) {
 	pages := []string{"/", "/arena", "/agents", "/leaderboard", "/recent", "/developers"}
-	page := pages[intn(len(pages))]
+	d.execBrowse(ctx, c, pathPayload{Path: pages[c.intn(len(pages))]})
+}
 
-	result, err := d.actor.BrowsePage(ctx, page)
+func (d *Dispatcher) execBrowse(ctx context.Context, c call, p pathPayload) {
+	d.record(c, p)
+	result, err := d.actor.BrowsePage(ctx, p.Path)
 	if err != nil {
 		d.metrics.RecordError("/browse")
 		return
@@ -186,36 +430,34 @@ func (d *Dispatcher) doBrowse(ctx context.Context, spec *persona.Spec) {
 	}
 }
 
-func (d *Dispatcher) doRunBout(ctx context.Context, workerID int, spec *persona.Spec) {
-	model := spec.Model
-	if model == "" {
-		model = "claude-sonnet-4-5-20250929"
-	}
+func (d *Dispatcher) doRunBout(ctx context.Context, c call, spec *persona.Spec) {
+	d.execRunBout(ctx, c, action.RunBoutRequest{
+		BoutID:   c.newBoutID(),
+		PresetID: validPresetIDs[c.intn(len(validPresetIDs))],
+		Topic:    spec.PickTopicFrom(c.rng),
+		Turns:    spec.MaxTurns,
+		Model:    boutModel(spec, c.act),
+	})
+}
 
+func (d *Dispatcher) execRunBout(ctx context.Context, c call, req action.RunBoutRequest) {
 	// Budget pre-flight.
-	_, allowed := d.budget.Allow(model, spec.MaxTurns)
-	if !allowed {
-		d.logf("[worker-%d] budget denied run-bout", workerID)
+	if _, capped := d.budget.AllowFor(c.persona, req.Model, req.Turns); capped != budget.CapNone {
+		d.logf("[worker-%d] budget denied run-bout (%s ceiling)", c.worker, capped)
 		return
 	}
 
-	boutID := action.GenerateID(21)
-	presetID := validPresetIDs[intn(len(validPresetIDs))]
-
 	// Per-bout timeout: if the stream hangs, the worker is freed after boutTimeout
 	// instead of blocking until the engine's overall duration expires.
 	boutCtx, boutCancel := context.WithTimeout(ctx, boutTimeout)
 	defer boutCancel()
 
+	d.record(c, req)
 	d.metrics.RecordBoutStart()
-	handle, err := d.actor.RunBoutStream(boutCtx, accountID(spec), action.RunBoutRequest{
-		BoutID:   boutID,
-		PresetID: presetID,
-		Topic:    spec.PickTopic(),
-		Turns:    spec.MaxTurns,
-		Model:    model,
-	})
+	hold := d.credits.Begin(c.account, req.Model, req.Turns)
+	handle, err := d.actor.RunBoutStream(boutCtx, c.account, req)
 	if err != nil {
+		d.credits.Release(hold)
 		d.metrics.RecordError("/api/run-bout")
 		return
 	}
@@ -224,27 +466,43 @@ func (d *Dispatcher) doRunBout(ctx context.Context, workerID int, spec *persona.
 	d.metrics.RecordStatus(handle.StatusCode)
 
 	if handle.StatusCode >= 400 {
+		d.settleRefused(hold, handle.StatusCode)
 		d.metrics.RecordError("/api/run-bout")
 		d.metrics.RecordLatency("/api/run-bout", handle.Duration)
 		return
 	}
 
 	// Track the bout so reactions/votes/short-links can reference it.
-	d.trackBout(boutID)
+	d.trackBout(c, req.BoutID)
 
 	// Parse the SSE stream, tracking active stream concurrency.
 	// Wrap body with contextReader so reads fail fast on bout timeout.
+	checker := transcript.NewChecker()
+	checker.Order = req.Agents
 	d.metrics.StreamStart()
-	result, err := client.ParseSSEStream(&contextReader{ctx: boutCtx, r: handle.Body}, nil)
+	result, err := client.ParseSSEStream(&contextReader{ctx: boutCtx, r: handle.Body}, checker.Observe)
 	d.metrics.StreamDone()
+	d.recordTurns(c, req.Model, result)
 	if err != nil {
+		handle.Fail(err)
+		d.credits.Release(hold)
 		d.metrics.RecordError("/api/run-bout")
 		return
 	}
 
+	// Streams that report a server-side error are already counted as
+	// stream errors; validating their partial content adds nothing.
+	var violations []string
+	if result.Error == "" {
+		violations = checker.Finish(req.Turns, result)
+	}
+	d.writeTranscript(c, req, result, violations)
+
 	// Check for server-side errors reported inside the SSE stream.
 	// Record latency even for errored streams to avoid biased percentile data.
 	if result.Error != "" {
+		handle.Fail(fmt.Errorf("stream error: %s", result.Error))
+		d.credits.Release(hold)
 		d.metrics.RecordStreamError()
 		d.metrics.RecordLatency("/api/run-bout", handle.Duration)
 		d.metrics.RecordError("/api/run-bout")
@@ -260,36 +518,92 @@ func (d *Dispatcher) doRunBout(ctx context.Context, workerID int, spec *persona.
 	}
 
 	d.metrics.RecordBoutDone()
-	d.metrics.RecordSuccess()
+	if len(violations) > 0 {
+		d.metrics.RecordValidationError()
+		d.metrics.RecordError("/api/run-bout")
+		d.logf("[worker-%d] invalid bout %s: %s", c.worker, req.BoutID, strings.Join(violations, "; "))
+	} else {
+		d.metrics.RecordSuccess()
+	}
 
-	// Charge budget.
-	d.budget.ChargeTokens(model, estimateInputTokens(result.TotalChars), estimateOutputTokens(result.TotalChars))
+	// Charge budget, from the server's reported usage when it sends any.
+	in, out := estimateInputTokens(result.TotalChars), estimateOutputTokens(result.TotalChars)
+	if u := result.Usage; u != nil {
+		d.budget.ChargeUsage(c.persona, req.Model, u.InputTokens, u.OutputTokens, budget.ComputeCost(req.Model, in, out))
+		d.credits.Settle(hold, budget.ComputeCost(req.Model, u.InputTokens, u.OutputTokens))
+	} else {
+		d.budget.ChargeTokensFor(c.persona, req.Model, in, out)
+		d.credits.Settle(hold, budget.ComputeCost(req.Model, in, out))
+	}
 }
 
-func (d *Dispatcher) doAPIBout(ctx context.Context, workerID int, spec *persona.Spec) {
-	model := spec.Model
-	if model == "" {
-		model = "claude-opus-4-6"
+// recordTurns records the stream health of each turn of a parsed bout,
+// including one cut off by an error, whose open pause may be a stall.
+func (d *Dispatcher) recordTurns(c call, model string, res *client.StreamResult) {
+	for _, t := range res.Turns {
+		tokens := estimateOutputTokens(len(t.Text))
+		if u := res.Usage; u != nil && res.TotalChars > 0 {
+			// Usage may be reported per bout; share it out by text length.
+			tokens = u.OutputTokens * len(t.Text) / res.TotalChars
+		}
+		stalls := d.metrics.RecordTurn(model, metrics.Turn{
+			FirstToken: t.FirstDelta,
+			Gaps:       t.Gaps,
+			Tokens:     tokens,
+			Generation: t.LastDelta - t.FirstDelta,
+		})
+		if stalls > 0 {
+			d.logf("[worker-%d] %s stream stalled %d time(s) in turn %d", c.worker, model, stalls, t.Turn)
+		}
 	}
+}
 
-	_, allowed := d.budget.Allow(model, spec.MaxTurns)
-	if !allowed {
-		d.logf("[worker-%d] budget denied api-bout", workerID)
-		return
+// settleRefused closes the credit hold of a bout the server rejected
+// with status: 402 is an out-of-credits refusal, anything else refunds
+// the preauthorization.
+func (d *Dispatcher) settleRefused(hold *credits.Hold, status int) {
+	if status == 402 {
+		d.credits.Refused(hold)
+	} else {
+		d.credits.Release(hold)
 	}
+}
 
-	boutID := action.GenerateID(21)
-	presetID := validPresetIDs[intn(len(validPresetIDs))]
+// writeTranscript persists a parsed bout stream, if capture is enabled.
+func (d *Dispatcher) writeTranscript(c call, req action.RunBoutRequest, res *client.StreamResult, violations []string) {
+	if d.transcripts == nil {
+		return
+	}
+	r := transcript.NewRecord(res, violations)
+	r.Worker, r.Persona, r.Target = c.worker, c.persona, d.target
+	r.BoutID, r.PresetID, r.Topic, r.Model, r.RequestedTurns = req.BoutID, req.PresetID, req.Topic, req.Model, req.Turns
+	if err := d.transcripts.Write(r); err != nil {
+		d.logf("[transcript] %v", err)
+	}
+}
 
-	d.metrics.RecordBoutStart()
-	result, err := d.actor.APIBout(ctx, accountID(spec), action.APIBoutRequest{
-		BoutID:   boutID,
-		PresetID: presetID,
-		Topic:    spec.PickTopic(),
+func (d *Dispatcher) doAPIBout(ctx context.Context, c call, spec *persona.Spec) {
+	d.execAPIBout(ctx, c, action.APIBoutRequest{
+		BoutID:   c.newBoutID(),
+		PresetID: validPresetIDs[c.intn(len(validPresetIDs))],
+		Topic:    spec.PickTopicFrom(c.rng),
 		Turns:    spec.MaxTurns,
-		Model:    model,
+		Model:    boutModel(spec, c.act),
 	})
+}
+
+func (d *Dispatcher) execAPIBout(ctx context.Context, c call, req action.APIBoutRequest) {
+	if _, capped := d.budget.AllowFor(c.persona, req.Model, req.Turns); capped != budget.CapNone {
+		d.logf("[worker-%d] budget denied api-bout (%s ceiling)", c.worker, capped)
+		return
+	}
+
+	d.record(c, req)
+	d.metrics.RecordBoutStart()
+	hold := d.credits.Begin(c.account, req.Model, req.Turns)
+	result, err := d.actor.APIBout(ctx, c.account, req)
 	if err != nil {
+		d.credits.Release(hold)
 		d.metrics.RecordError("/api/v1/bout")
 		return
 	}
@@ -302,150 +616,217 @@ func (d *Dispatcher) doAPIBout(ctx context.Context, workerID int, spec *persona.
 	}
 
 	if result.StatusCode >= 200 && result.StatusCode < 300 {
-		d.trackBout(boutID)
+		d.trackBout(c, req.BoutID)
 		d.metrics.RecordBoutDone()
 		d.metrics.RecordSuccess()
-		d.budget.ChargeTokens(model, spec.MaxTurns*660, spec.MaxTurns*120) // estimated
+		in, out := req.Turns*660, req.Turns*120 // estimated
+		if u := action.ParseUsage(result.Body); u != nil {
+			d.budget.ChargeUsage(c.persona, req.Model, u.InputTokens, u.OutputTokens, budget.ComputeCost(req.Model, in, out))
+			d.credits.Settle(hold, budget.ComputeCost(req.Model, u.InputTokens, u.OutputTokens))
+		} else {
+			d.budget.ChargeTokensFor(c.persona, req.Model, in, out)
+			d.credits.Settle(hold, budget.ComputeCost(req.Model, in, out))
+		}
 	} else {
+		d.settleRefused(hold, result.StatusCode)
 		d.metrics.RecordError("/api/v1/bout")
 	}
 }
 
-func (d *Dispatcher) doCreateAgent(ctx context.Context, spec *persona.Spec) {
-	result, err := d.actor.CreateAgent(ctx, accountID(spec), action.CreateAgentRequest{
-		Name:         fmt.Sprintf("StormAgent-%d", intn(10000)),
+func (d *Dispatcher) doCreateAgent(ctx context.Context, c call) {
+	d.execCreateAgent(ctx, c, action.CreateAgentRequest{
+		Name:         fmt.Sprintf("StormAgent-%d", c.intn(10000)),
 		SystemPrompt: "You are a debater created by pitstorm load testing. Argue your position with conviction and rhetorical flair.",
 	})
+}
+
+func (d *Dispatcher) execCreateAgent(ctx context.Context, c call, req action.CreateAgentRequest) {
+	d.record(c, req)
+	result, err := d.actor.CreateAgent(ctx, c.account, req)
 	d.recordSimpleResult("/api/agents", result, err)
 }
 
-func (d *Dispatcher) doReaction(ctx context.Context, spec *persona.Spec) {
-	boutID := d.randomBoutID()
+func (d *Dispatcher) doReaction(ctx context.Context, c call) {
+	boutID := d.randomBoutID(c)
 	if boutID == "" {
 		// No bouts created yet — generate a format-valid but non-existent ID.
 		// Reactions don't validate bout existence, just format.
-		boutID = action.GenerateID(21)
+		boutID = c.newBoutID()
 	}
 	reactions := []string{"heart", "fire"}
-	result, err := d.actor.ToggleReaction(ctx, accountID(spec), action.ReactionRequest{
+	d.execReaction(ctx, c, action.ReactionRequest{
 		BoutID:       boutID,
-		TurnIndex:    intn(10),
-		ReactionType: reactions[intn(len(reactions))],
+		TurnIndex:    c.intn(10),
+		ReactionType: reactions[c.intn(len(reactions))],
 	})
+}
+
+func (d *Dispatcher) execReaction(ctx context.Context, c call, req action.ReactionRequest) {
+	d.record(c, req)
+	result, err := d.actor.ToggleReaction(ctx, c.account, req)
 	d.recordSimpleResult("/api/reactions", result, err)
 }
 
-func (d *Dispatcher) doVote(ctx context.Context, spec *persona.Spec) {
-	boutID := d.randomBoutID()
+func (d *Dispatcher) doVote(ctx context.Context, c call) {
+	boutID := d.randomBoutID(c)
 	if boutID == "" {
 		// No bouts exist yet — skip rather than send an invalid request.
 		return
 	}
-	result, err := d.actor.CastWinnerVote(ctx, accountID(spec), action.WinnerVoteRequest{
+	d.execVote(ctx, c, action.WinnerVoteRequest{
 		BoutID:  boutID,
 		AgentID: "socrates",
 	})
+}
+
+func (d *Dispatcher) execVote(ctx context.Context, c call, req action.WinnerVoteRequest) {
+	d.record(c, req)
+	result, err := d.actor.CastWinnerVote(ctx, c.account, req)
 	d.recordSimpleResult("/api/winner-vote", result, err)
 }
 
-func (d *Dispatcher) doShortLink(ctx context.Context) {
-	boutID := d.randomBoutID()
+func (d *Dispatcher) doShortLink(ctx context.Context, c call) {
+	boutID := d.randomBoutID(c)
 	if boutID == "" {
 		// No bouts exist yet — skip.
 		return
 	}
-	result, err := d.actor.CreateShortLink(ctx, action.ShortLinkRequest{
+	d.execShortLink(ctx, c, action.ShortLinkRequest{
 		BoutID: boutID,
 	})
+}
+
+func (d *Dispatcher) execShortLink(ctx context.Context, c call, req action.ShortLinkRequest) {
+	d.record(c, req)
+	result, err := d.actor.CreateShortLink(ctx, req)
 	d.recordSimpleResult("/api/short-links", result, err)
 }
 
-func (d *Dispatcher) doListFeatures(ctx context.Context) {
+func (d *Dispatcher) execListFeatures(ctx context.Context, c call) {
+	d.record(c, nil)
 	result, err := d.actor.ListFeatureRequests(ctx)
 	d.recordSimpleResult("/api/feature-requests", result, err)
 }
 
-func (d *Dispatcher) doSubmitFeature(ctx context.Context, spec *persona.Spec) {
-	result, err := d.actor.SubmitFeature(ctx, accountID(spec), action.SubmitFeatureRequest{
-		Title:       fmt.Sprintf("Storm feature request %d", intn(10000)),
+func (d *Dispatcher) doSubmitFeature(ctx context.Context, c call) {
+	d.execSubmitFeature(ctx, c, action.SubmitFeatureRequest{
+		Title:       fmt.Sprintf("Storm feature request %d", c.intn(10000)),
 		Description: "This is an auto-generated feature request from pitstorm load testing. It tests the feature submission pipeline under load.",
-		Category:    featureCategories[intn(len(featureCategories))],
+		Category:    featureCategories[c.intn(len(featureCategories))],
 	})
+}
+
+func (d *Dispatcher) execSubmitFeature(ctx context.Context, c call, req action.SubmitFeatureRequest) {
+	d.record(c, req)
+	result, err := d.actor.SubmitFeature(ctx, c.account, req)
 	d.recordSimpleResult("/api/feature-requests", result, err)
 }
 
-func (d *Dispatcher) doVoteFeature(ctx context.Context, spec *persona.Spec) {
-	result, err := d.actor.VoteFeature(ctx, accountID(spec), action.FeatureVoteRequest{
-		FeatureRequestID: 1 + intn(100),
+func (d *Dispatcher) doVoteFeature(ctx context.Context, c call) {
+	d.execVoteFeature(ctx, c, action.FeatureVoteRequest{
+		FeatureRequestID: 1 + c.intn(100),
 	})
+}
+
+func (d *Dispatcher) execVoteFeature(ctx context.Context, c call, req action.FeatureVoteRequest) {
+	d.record(c, req)
+	result, err := d.actor.VoteFeature(ctx, c.account, req)
 	d.recordSimpleResult("/api/feature-requests/vote", result, err)
 }
 
-func (d *Dispatcher) doSubmitPaper(ctx context.Context, spec *persona.Spec) {
-	result, err := d.actor.SubmitPaper(ctx, accountID(spec), action.PaperSubmissionRequest{
-		ArxivURL:      fmt.Sprintf("https://arxiv.org/abs/2401.%05d", intn(99999)),
+func (d *Dispatcher) doSubmitPaper(ctx context.Context, c call) {
+	d.execSubmitPaper(ctx, c, action.PaperSubmissionRequest{
+		ArxivURL:      fmt.Sprintf("https://arxiv.org/abs/2401.%05d", c.intn(99999)),
 		Justification: "This paper is relevant to AI agent interaction and debate evaluation. Submitted via pitstorm load testing to verify the submission pipeline.",
-		RelevanceArea: relevanceAreas[intn(len(relevanceAreas))],
+		RelevanceArea: relevanceAreas[c.intn(len(relevanceAreas))],
 	})
+}
+
+func (d *Dispatcher) execSubmitPaper(ctx context.Context, c call, req action.PaperSubmissionRequest) {
+	d.record(c, req)
+	result, err := d.actor.SubmitPaper(ctx, c.account, req)
 	d.recordSimpleResult("/api/paper-submissions", result, err)
 }
 
-func (d *Dispatcher) doNewsletter(ctx context.Context) {
-	result, err := d.actor.SubscribeNewsletter(ctx, action.NewsletterRequest{
-		Email: fmt.Sprintf("storm-%d@test.thepit.cloud", intn(100000)),
+func (d *Dispatcher) doNewsletter(ctx context.Context, c call) {
+	d.execNewsletter(ctx, c, action.NewsletterRequest{
+		Email: fmt.Sprintf("storm-%d@test.thepit.cloud", c.intn(100000)),
 	})
+}
+
+func (d *Dispatcher) execNewsletter(ctx context.Context, c call, req action.NewsletterRequest) {
+	d.record(c, req)
+	result, err := d.actor.SubscribeNewsletter(ctx, req)
 	d.recordSimpleResult("/api/newsletter", result, err)
 }
 
-func (d *Dispatcher) doContact(ctx context.Context) {
-	result, err := d.actor.SendContact(ctx, action.ContactRequest{
+func (d *Dispatcher) doContact(ctx context.Context, c call) {
+	d.execContact(ctx, c, action.ContactRequest{
 		Name:    "Storm User",
 		Email:   "storm@test.thepit.cloud",
 		Message: "Automated contact from pitstorm load test.",
 	})
+}
+
+func (d *Dispatcher) execContact(ctx context.Context, c call, req action.ContactRequest) {
+	d.record(c, req)
+	result, err := d.actor.SendContact(ctx, req)
 	d.recordSimpleResult("/api/contact", result, err)
 }
 
-func (d *Dispatcher) doBYOK(ctx context.Context, spec *persona.Spec) {
-	result, err := d.actor.StashBYOK(ctx, accountID(spec), action.BYOKStashRequest{
+func (d *Dispatcher) doBYOK(ctx context.Context, c call) {
+	d.execBYOK(ctx, c, action.BYOKStashRequest{
 		Provider: "anthropic",
 		APIKey:   "sk-ant-REDACTED",
 	})
+}
+
+func (d *Dispatcher) execBYOK(ctx context.Context, c call, req action.BYOKStashRequest) {
+	d.record(c, req)
+	result, err := d.actor.StashBYOK(ctx, c.account, req)
 	d.recordSimpleResult("/api/byok-stash", result, err)
 }
 
 // ---------- Security probe handlers ----------
 
-func (d *Dispatcher) doXSSProbe(ctx context.Context, spec *persona.Spec) {
-	payloads := []string{
-		`<script>alert('xss')</script>`,
-		`<img src=x onerror=alert(1)>`,
-		`"><svg/onload=alert(document.cookie)>`,
-	}
-	// Inject XSS payload as a bout topic.
-	result, err := d.actor.BrowsePage(ctx, "/?q="+payloads[intn(len(payloads))])
-	d.recordSimpleResult("/xss-probe", result, err)
+// probeEndpoints are the metric labels for the GET-based security probes.
+var probeEndpoints = map[persona.Action]string{
+	persona.ActionXSSProbe:     "/xss-probe",
+	persona.ActionSQLInjection: "/sqli-probe",
+	persona.ActionIDORProbe:    "/idor-probe",
 }
 
-func (d *Dispatcher) doSQLiProbe(ctx context.Context, spec *persona.Spec) {
-	payloads := []string{
-		`' OR 1=1 --`,
-		`'; DROP TABLE bouts; --`,
-		`1 UNION SELECT * FROM users --`,
-	}
-	result, err := d.actor.BrowsePage(ctx, "/api/health?id="+payloads[intn(len(payloads))])
-	d.recordSimpleResult("/sqli-probe", result, err)
+// Load runs mix in the GET probes from the probe catalogue; only
+// `pitstorm probe` judges the responses.
+var (
+	xssProbePaths  = probe.GETPaths(probe.KindXSS, "")
+	sqliProbePaths = probe.GETPaths(probe.KindSQLi, "")
+)
+
+func (d *Dispatcher) doXSSProbe(ctx context.Context, c call) {
+	d.execProbe(ctx, c, pathPayload{Path: xssProbePaths[c.intn(len(xssProbePaths))]})
 }
 
-func (d *Dispatcher) doIDORProbe(ctx context.Context, spec *persona.Spec) {
+func (d *Dispatcher) doSQLiProbe(ctx context.Context, c call) {
+	d.execProbe(ctx, c, pathPayload{Path: sqliProbePaths[c.intn(len(sqliProbePaths))]})
+}
+
+func (d *Dispatcher) doIDORProbe(ctx context.Context, c call) {
 	// Try to access another user's resources.
-	result, err := d.actor.BrowsePage(ctx, fmt.Sprintf("/api/agents?userId=user_%d", intn(10000)))
-	d.recordSimpleResult("/idor-probe", result, err)
+	paths := probe.GETPaths(probe.KindIDOR, fmt.Sprintf("user_%d", c.intn(10000)))
+	d.execProbe(ctx, c, pathPayload{Path: paths[c.intn(len(paths))]})
+}
+
+func (d *Dispatcher) execProbe(ctx context.Context, c call, p pathPayload) {
+	d.record(c, p)
+	result, err := d.actor.BrowsePage(ctx, p.Path)
+	d.recordSimpleResult(probeEndpoints[c.act], result, err)
 }
 
-func (d *Dispatcher) doRateLimitFlood(ctx context.Context, spec *persona.Spec) {
-	// Fire 10 rapid requests to trigger rate limiting.
-	for i := 0; i < 10; i++ {
+func (d *Dispatcher) execRateLimitFlood(ctx context.Context, c call, p floodPayload) {
+	d.record(c, p)
+	// Fire rapid requests to trigger rate limiting.
+	for i := 0; i < p.Count; i++ {
 		result, err := d.actor.Health(ctx)
 		if err != nil {
 			d.metrics.RecordError("/api/health")
@@ -8,17 +8,27 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/rickhallett/thepit/pitstorm/internal/account"
 	"github.com/rickhallett/thepit/pitstorm/internal/action"
 	"github.com/rickhallett/thepit/pitstorm/internal/budget"
 	"github.com/rickhallett/thepit/pitstorm/internal/client"
+	"github.com/rickhallett/thepit/pitstorm/internal/control"
+	"github.com/rickhallett/thepit/pitstorm/internal/credits"
+	"github.com/rickhallett/thepit/pitstorm/internal/journal"
 	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
 	"github.com/rickhallett/thepit/pitstorm/internal/persona"
 	"github.com/rickhallett/thepit/pitstorm/internal/profile"
+	"github.com/rickhallett/thepit/pitstorm/internal/transcript"
 )
 
 // Config holds the engine's runtime configuration.
@@ -28,6 +38,36 @@ type Config struct {
 	RateFunc   RateFunc // controls req/s over time; nil = unlimited
 	Verbose    bool
 	StatusFile string // if set, write live JSON status to this path every monitor tick
+
+	// Seed, if non-zero, gives each worker its own deterministic random
+	// source so persona choices, think times, and payloads are reproducible.
+	Seed int64
+
+	// Journal, if set, records every dispatched request for later replay.
+	Journal *journal.Writer
+
+	// Transcripts, if set, captures the content of every bout stream.
+	Transcripts *transcript.Writer
+
+	// MetricsAddr, if set, serves live metrics in OpenMetrics format at
+	// /metrics on this address for the duration of the run.
+	MetricsAddr string
+
+	// ControlAddr, if set, serves the runtime control API (see the
+	// control package) on this address for the duration of the run.
+	ControlAddr string
+
+	// Accounts, if set, spreads each tier's sessions across a pool of
+	// accounts instead of signing every persona in as its own account.
+	Accounts *account.Pool
+
+	// Credits, if set, checks the server's out-of-credits refusals
+	// against each account's expected balance.
+	Credits *credits.Ledger
+
+	// TargetB, if set, sends traffic to a second target too: a copy of
+	// every action (ABMirror) or half of the sessions (ABSplit).
+	TargetB *TargetB
 }
 
 // RateFunc is an alias for profile.RateFunc to avoid type-adapter boilerplate.
@@ -44,6 +84,16 @@ type Engine struct {
 	personas   []*persona.Spec
 	logf       func(string, ...any)
 	dispatcher *Dispatcher
+
+	// dispatcherB sends target B's sessions in an A/B split.
+	dispatcherB *Dispatcher
+
+	// ctl holds pause and rate overrides applied while running.
+	ctl runControl
+
+	// statusMu serialises writeStatus, which both the monitor and the
+	// control API call, as they share the temp file.
+	statusMu sync.Mutex
 }
 
 // New creates an Engine with all dependencies injected.
@@ -59,25 +109,71 @@ func New(
 	if logf == nil {
 		logf = func(string, ...any) {}
 	}
+	d := NewDispatcher(act, m, b, logf)
+	if cfg.Journal != nil {
+		d.SetJournal(cfg.Journal)
+	}
+	if cfg.Transcripts != nil {
+		d.SetTranscripts(cfg.Transcripts)
+	}
+	if cfg.Credits != nil {
+		d.SetCredits(cfg.Credits)
+	}
+	var dB *Dispatcher
+	if cfg.TargetB != nil {
+		dB = NewDispatcher(cfg.TargetB.Actor, cfg.TargetB.Metrics, b, logf)
+		if cfg.TargetB.Mode == ABMirror {
+			d.SetMirror(dB)
+			dB = nil
+		} else {
+			dB.SetTarget(journal.TargetTagB)
+			if cfg.Journal != nil {
+				dB.SetJournal(cfg.Journal)
+			}
+			if cfg.Transcripts != nil {
+				dB.SetTranscripts(cfg.Transcripts)
+			}
+			if cfg.TargetB.Credits != nil {
+				dB.SetCredits(cfg.TargetB.Credits)
+			}
+		}
+	}
 	return &Engine{
-		cfg:        cfg,
-		client:     cl,
-		actor:      act,
-		metrics:    m,
-		budget:     b,
-		personas:   personas,
-		logf:       logf,
-		dispatcher: NewDispatcher(act, m, b, logf),
+		cfg:         cfg,
+		client:      cl,
+		actor:       act,
+		metrics:     m,
+		budget:      b,
+		personas:    personas,
+		logf:        logf,
+		dispatcher:  d,
+		dispatcherB: dB,
 	}
 }
 
 // Run starts the simulation and blocks until completion. It returns when
-// the context is cancelled, the duration expires, or the budget is exhausted.
+// the context is cancelled, the duration expires, or the budget is exhausted
+// (unless the control API is served, when it waits for a raise instead).
 func (e *Engine) Run(ctx context.Context) error {
 	if len(e.personas) == 0 {
 		return fmt.Errorf("no personas configured")
 	}
 
+	if e.cfg.MetricsAddr != "" {
+		stop, err := e.serveMetrics(e.cfg.MetricsAddr)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+	if e.cfg.ControlAddr != "" {
+		stop, err := e.serveControl(e.cfg.ControlAddr)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, e.cfg.Duration)
 	defer cancel()
 
@@ -111,6 +207,8 @@ func (e *Engine) worker(ctx context.Context, id int, spec *persona.Spec, tickets
 	e.metrics.WorkerStart()
 	defer e.metrics.WorkerDone()
 
+	rng := e.workerRand(id)
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -118,21 +216,44 @@ func (e *Engine) worker(ctx context.Context, id int, spec *persona.Spec, tickets
 		default:
 		}
 
-		// Check budget before starting a session.
-		if e.budget.Exhausted() {
+		// Check budget before starting a session. With the control API
+		// up the ceiling can still be raised, so wait for that instead.
+		exhausted := e.budget.Exhausted()
+		if exhausted && e.cfg.ControlAddr == "" {
 			if e.cfg.Verbose {
 				e.logf("[worker-%d] budget exhausted, stopping", id)
 			}
 			return
 		}
 
-		e.runSession(ctx, id, spec, tickets)
+		// Otherwise the worker idles until it can carry on.
+		if exhausted || !e.personaEnabled(spec.ID) {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(idlePoll):
+			}
+			continue
+		}
+
+		e.runSession(ctx, id, spec, tickets, rng)
+	}
+}
+
+// workerRand returns the random source for a worker: nil (the global
+// generator) when unseeded, otherwise a PCG stream keyed by seed and worker.
+func (e *Engine) workerRand(id int) *rand.Rand {
+	if e.cfg.Seed == 0 {
+		return nil
 	}
+	return rand.New(rand.NewPCG(uint64(e.cfg.Seed), uint64(id)))
 }
 
 // runSession executes a single persona session (a series of actions).
-func (e *Engine) runSession(ctx context.Context, workerID int, spec *persona.Spec, tickets <-chan struct{}) {
-	sessionLen := spec.SessionLength()
+func (e *Engine) runSession(ctx context.Context, workerID int, spec *persona.Spec, tickets <-chan struct{}, rng *rand.Rand) {
+	sessionLen := spec.SessionLengthFrom(rng)
+	acct := e.sessionAccount(workerID, spec)
+	d := e.sessionDispatcher(rng)
 
 	for i := 0; i < sessionLen; i++ {
 		select {
@@ -141,24 +262,30 @@ func (e *Engine) runSession(ctx context.Context, workerID int, spec *persona.Spe
 		default:
 		}
 
-		// Budget check before each action.
-		if e.budget.Exhausted() {
+		// Budget and persona checks before each action.
+		if e.budget.Exhausted() || !e.personaEnabled(spec.ID) {
 			return
 		}
 
-		// Wait for a rate-limit ticket.
-		select {
-		case <-ctx.Done():
-			return
-		case <-tickets:
+		// Wait for a rate-limit ticket. Tickets buffered before a
+		// Pause are discarded rather than spent.
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tickets:
+			}
+			if !e.Paused() {
+				break
+			}
 		}
 
 		// Pick and execute an action.
-		act := spec.PickAction()
-		e.dispatcher.Dispatch(ctx, workerID, spec, act)
+		act := spec.PickActionFrom(rng)
+		d.DispatchAs(ctx, workerID, spec, acct, act, rng)
 
 		// Think time (simulated human delay).
-		delay := spec.ThinkDelay()
+		delay := spec.ThinkDelayFrom(rng)
 		select {
 		case <-ctx.Done():
 			return
@@ -167,19 +294,31 @@ func (e *Engine) runSession(ctx context.Context, workerID int, spec *persona.Spe
 	}
 }
 
-// ticketFeeder generates rate-limit tickets at the pace defined by RateFunc.
-func (e *Engine) ticketFeeder(ctx context.Context, start time.Time, tickets chan<- struct{}) {
-	if e.cfg.RateFunc == nil {
-		// Unlimited — feed as fast as possible.
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case tickets <- struct{}{}:
-			}
+// sessionAccount returns the account a new session of spec signs in as:
+// one from the pool when configured, otherwise the persona's own.
+func (e *Engine) sessionAccount(workerID int, spec *persona.Spec) string {
+	if e.cfg.Accounts != nil && spec.RequiresAuth {
+		if id, ok := e.cfg.Accounts.Pick(account.Tier(spec.Tier), workerID); ok {
+			return id
 		}
 	}
+	return accountID(spec)
+}
 
+// sessionDispatcher returns the dispatcher a new session sends through:
+// in an A/B split, target B's for half of the sessions.
+func (e *Engine) sessionDispatcher(rng *rand.Rand) *Dispatcher {
+	if e.dispatcherB != nil && (call{rng: rng}).intn(2) == 1 {
+		return e.dispatcherB
+	}
+	return e.dispatcher
+}
+
+// ticketFeeder generates rate-limit tickets at the pace defined by
+// RateFunc, or by SetRate when overridden. Nothing is issued while the
+// engine is paused, and buffered tickets are drained so that Resume
+// doesn't release a burst.
+func (e *Engine) ticketFeeder(ctx context.Context, start time.Time, tickets chan struct{}) {
 	ticker := time.NewTicker(10 * time.Millisecond) // check rate 100 times/sec
 	defer ticker.Stop()
 
@@ -190,8 +329,17 @@ func (e *Engine) ticketFeeder(ctx context.Context, start time.Time, tickets chan
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			elapsed := time.Since(start)
-			targetRPS := e.cfg.RateFunc(elapsed, e.cfg.Duration)
+			if e.Paused() {
+				tokenBank = 0
+				drainTickets(tickets)
+				continue
+			}
+			targetRPS := e.targetRate(time.Since(start))
+			if math.IsInf(targetRPS, 1) {
+				// Unlimited — keep the channel full.
+				e.fillTickets(ctx, tickets)
+				continue
+			}
 			if targetRPS <= 0 {
 				continue
 			}
@@ -213,6 +361,79 @@ func (e *Engine) ticketFeeder(ctx context.Context, start time.Time, tickets chan
 	}
 }
 
+// drainTickets empties the ticket channel without blocking.
+func drainTickets(tickets chan struct{}) {
+	for {
+		select {
+		case <-tickets:
+		default:
+			return
+		}
+	}
+}
+
+// fillTickets tops the ticket channel up without blocking.
+func (e *Engine) fillTickets(ctx context.Context, tickets chan<- struct{}) {
+	for ctx.Err() == nil {
+		select {
+		case tickets <- struct{}{}:
+		default:
+			return
+		}
+	}
+}
+
+// serveMetrics starts the OpenMetrics endpoint and returns a function
+// that shuts it down.
+func (e *Engine) serveMetrics(addr string) (func(), error) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler(e.metrics, e.budgetFamilies))
+	return e.serve("metrics", addr, mux)
+}
+
+// serveControl starts the runtime control API and returns a function
+// that shuts it down.
+func (e *Engine) serveControl(addr string) (func(), error) {
+	return e.serve("control", addr, control.Handler(e, e.logf))
+}
+
+// serve runs h on addr until the returned function is called.
+func (e *Engine) serve(name, addr string, h http.Handler) (func(), error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("%s listener: %w", name, err)
+	}
+	srv := &http.Server{Handler: h, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			e.logf("[%s] serve error: %v", name, err)
+		}
+	}()
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}, nil
+}
+
+// budgetFamilies exposes budget state alongside the collector's metrics.
+func (e *Engine) budgetFamilies() []metrics.Family {
+	bs := e.budget.Summary()
+	byModel := make([]metrics.Sample, 0, len(bs.ByModel))
+	for model, gbp := range bs.ByModel {
+		byModel = append(byModel, metrics.Sample{Labels: map[string]string{"model": model}, Value: gbp})
+	}
+	sort.Slice(byModel, func(i, j int) bool { return byModel[i].Labels["model"] < byModel[j].Labels["model"] })
+	return []metrics.Family{
+		{Name: "pitstorm_budget_spent_gbp", Help: "Estimated spend so far in GBP.", Type: "gauge",
+			Samples: []metrics.Sample{{Value: bs.SpentGBP}}},
+		{Name: "pitstorm_budget_ceiling_gbp", Help: "Budget ceiling in GBP (0 = unlimited).", Type: "gauge",
+			Samples: []metrics.Sample{{Value: bs.CeilingGBP}}},
+		{Name: "pitstorm_budget_model_spent_gbp", Help: "Estimated spend by model in GBP.", Type: "gauge",
+			Samples: byModel},
+	}
+}
+
 // liveStatus is the JSON structure written to the status file each tick.
 type liveStatus struct {
 	Timestamp string           `json:"timestamp"`
@@ -269,6 +490,9 @@ func (e *Engine) writeStatus(snap metrics.Snapshot, bs budget.Summary) {
 		e.logf("[status] marshal error: %v", err)
 		return
 	}
+	e.statusMu.Lock()
+	defer e.statusMu.Unlock()
+
 	// Atomic write: write to temp file then rename to avoid partial reads.
 	tmp := e.cfg.StatusFile + ".tmp"
 	// Ensure parent directory exists (e.g. results/ is gitignored).
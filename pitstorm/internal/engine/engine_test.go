@@ -2,17 +2,27 @@ package engine
 
 import (
 	"context"
+	"encoding/json"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/rickhallett/thepit/pitstorm/internal/account"
 	"github.com/rickhallett/thepit/pitstorm/internal/action"
 	"github.com/rickhallett/thepit/pitstorm/internal/budget"
 	"github.com/rickhallett/thepit/pitstorm/internal/client"
+	"github.com/rickhallett/thepit/pitstorm/internal/credits"
 	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
 	"github.com/rickhallett/thepit/pitstorm/internal/persona"
+	"github.com/rickhallett/thepit/pitstorm/internal/transcript"
 )
 
 // newTestEngine creates a test engine backed by an httptest server.
@@ -400,6 +410,28 @@ func TestAccountID(t *testing.T) {
 	}
 }
 
+func TestSessionAccountFromPool(t *testing.T) {
+	f := account.PoolAccounts("http://localhost:3000", 2)
+	e := &Engine{cfg: Config{Accounts: account.NewPool(f, account.PoolRotate, nil)}}
+
+	if id := e.sessionAccount(0, persona.FreeLurker()); id != "" {
+		t.Errorf("anon session signed in as %q", id)
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < 5; i++ {
+		seen[e.sessionAccount(0, persona.LabPowerUser())] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("lab sessions used %v, want all 3 lab accounts", seen)
+	}
+
+	// Without a pool every persona keeps its own account.
+	e = &Engine{}
+	if id := e.sessionAccount(0, persona.FreeCasual()); id != "account-free-casual" {
+		t.Errorf("sessionAccount without a pool = %q", id)
+	}
+}
+
 func TestIntn(t *testing.T) {
 	for i := 0; i < 100; i++ {
 		n := intn(10)
@@ -411,3 +443,511 @@ func TestIntn(t *testing.T) {
 		t.Errorf("intn(0) = %d, want 0", n)
 	}
 }
+
+func TestEngineServesMetrics(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	e, cleanup := newTestEngine(t, handler, Config{
+		Workers:     1,
+		Duration:    500 * time.Millisecond,
+		MetricsAddr: addr,
+	}, nil)
+	defer cleanup()
+
+	done := make(chan error, 1)
+	go func() { done <- e.Run(context.Background()) }()
+
+	var body []byte
+	for i := 0; i < 20; i++ {
+		time.Sleep(20 * time.Millisecond)
+		resp, err := http.Get("http://" + addr + "/metrics")
+		if err != nil {
+			continue
+		}
+		body, _ = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		break
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	for _, want := range []string{"pitstorm_requests_total", "pitstorm_budget_spent_gbp", "# EOF"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+
+	// Endpoint is shut down once Run returns.
+	if _, err := http.Get("http://" + addr + "/metrics"); err == nil {
+		t.Error("metrics endpoint still serving after Run returned")
+	}
+}
+
+func TestEngineMetricsAddrInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	e, cleanup := newTestEngine(t, func(w http.ResponseWriter, r *http.Request) {}, Config{
+		Workers:     1,
+		Duration:    time.Second,
+		MetricsAddr: ln.Addr().String(),
+	}, nil)
+	defer cleanup()
+
+	if err := e.Run(context.Background()); err == nil {
+		t.Error("Run should fail when the metrics address is in use")
+	}
+}
+
+func TestDispatcherValidatesBoutStreams(t *testing.T) {
+	// One turn streamed where two were requested.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"type":"data-turn","data":{"turn":0,"agentId":"a","agentName":"A"}}`+"\n\n"+
+			`data: {"type":"text-start","id":"t0"}`+"\n\n"+
+			`data: {"type":"text-delta","id":"t0","delta":"Hello"}`+"\n\n"+
+			`data: {"type":"text-end","id":"t0"}`+"\n\n"+
+			"data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	clientCfg := client.DefaultConfig(srv.URL)
+	clientCfg.MaxRetries = 0
+	cl := client.New(clientCfg, nil)
+	defer cl.Close()
+	m := metrics.NewCollector()
+	d := NewDispatcher(action.New(cl), m, budget.NewGate(10), nil)
+
+	path := filepath.Join(t.TempDir(), "transcripts.jsonl")
+	tw, err := transcript.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.SetTranscripts(tw)
+
+	d.execRunBout(context.Background(), call{worker: 3, persona: "casual"}, action.RunBoutRequest{
+		BoutID: "bout-1", PresetID: "summit", Turns: 2, Model: "claude-haiku-4-5-20251001",
+	})
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	snap := m.Snapshot()
+	if snap.ValidationErrors != 1 || snap.ErrorsByEP["/api/run-bout"] != 1 || snap.Successes != 0 {
+		t.Errorf("validation=%d errors=%v successes=%d", snap.ValidationErrors, snap.ErrorsByEP, snap.Successes)
+	}
+	if snap.BoutsDone != 1 {
+		t.Errorf("BoutsDone = %d, want 1 (the bout still completed)", snap.BoutsDone)
+	}
+
+	recs, err := transcript.Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 {
+		t.Fatalf("records = %d, want 1", len(recs))
+	}
+	r := recs[0]
+	if r.BoutID != "bout-1" || r.Worker != 3 || r.Persona != "casual" || r.Turns[0].Text != "Hello" {
+		t.Errorf("record = %+v", r)
+	}
+	if len(r.Violations) != 1 || r.Violations[0] != "1 turns streamed, 2 requested" {
+		t.Errorf("violations = %v", r.Violations)
+	}
+}
+
+func TestDispatcherReportsStreamErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"type":"error","errorText":"model overloaded"}`+"\n\n"+"data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	clientCfg := client.DefaultConfig(srv.URL)
+	clientCfg.MaxRetries = 0
+	cl := client.New(clientCfg, nil)
+	defer cl.Close()
+	var seen []client.Exchange
+	cl.SetObserver(func(x client.Exchange) { seen = append(seen, x) })
+	d := NewDispatcher(action.New(cl), metrics.NewCollector(), budget.NewGate(10), nil)
+
+	d.execRunBout(context.Background(), call{}, action.RunBoutRequest{BoutID: "b", PresetID: "summit", Turns: 1})
+
+	if len(seen) != 1 {
+		t.Fatalf("observed %d exchanges, want 1", len(seen))
+	}
+	if x := seen[0]; x.StatusCode != 200 || x.Err == nil || !strings.Contains(x.Err.Error(), "model overloaded") {
+		t.Errorf("exchange = %d, %v; want a 200 carrying the stream's error", x.StatusCode, x.Err)
+	}
+}
+
+func TestDispatcherChargesReportedUsage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"type":"data-turn","data":{"turn":0,"agentId":"a","agentName":"A"}}`+"\n\n"+
+			`data: {"type":"text-start","id":"t0"}`+"\n\n"+
+			`data: {"type":"text-delta","id":"t0","delta":"Hello there"}`+"\n\n"+
+			`data: {"type":"text-end","id":"t0"}`+"\n\n"+
+			`data: {"type":"data-usage","data":{"inputTokens":5000,"outputTokens":800}}`+"\n\n"+
+			"data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	clientCfg := client.DefaultConfig(srv.URL)
+	clientCfg.MaxRetries = 0
+	cl := client.New(clientCfg, nil)
+	defer cl.Close()
+	b := budget.NewGate(10)
+	d := NewDispatcher(action.New(cl), metrics.NewCollector(), b, nil)
+
+	model := "claude-haiku-4-5-20251001"
+	d.execRunBout(context.Background(), call{}, action.RunBoutRequest{BoutID: "b", PresetID: "summit", Turns: 1, Model: model})
+
+	s := b.Summary()
+	want := budget.ComputeCost(model, 5000, 800)
+	if s.ActualCount != 1 || s.SpentGBP < want-1e-6 || s.SpentGBP > want+1e-5 {
+		t.Errorf("ActualCount = %d, SpentGBP = %f; want 1, %f", s.ActualCount, s.SpentGBP, want)
+	}
+	if e := s.EstimateErrors[model]; e.Samples != 1 || e.RelError() >= 0 {
+		t.Errorf("EstimateErrors = %+v, want the char-based estimate to undercharge", s.EstimateErrors)
+	}
+}
+
+func TestDispatcherRecordsStreamHealth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		send := func(pause time.Duration, event string) {
+			time.Sleep(pause)
+			io.WriteString(w, "data: "+event+"\n\n")
+			flusher.Flush()
+		}
+		delta := `{"type":"text-delta","id":"t0","delta":"Hello there, "}`
+		send(0, `{"type":"data-turn","data":{"turn":0,"agentId":"a","agentName":"A"}}`)
+		send(20*time.Millisecond, delta)
+		send(5*time.Millisecond, delta)
+		// A pause long enough to count as a stall.
+		send(80*time.Millisecond, delta)
+		send(0, `{"type":"text-end","id":"t0"}`)
+		send(0, "[DONE]")
+	}))
+	defer srv.Close()
+
+	clientCfg := client.DefaultConfig(srv.URL)
+	clientCfg.MaxRetries = 0
+	cl := client.New(clientCfg, nil)
+	defer cl.Close()
+	m := metrics.NewCollector()
+	m.SetStallThreshold(50 * time.Millisecond)
+	d := NewDispatcher(action.New(cl), m, budget.NewGate(10), nil)
+
+	model := "claude-haiku-4-5-20251001"
+	d.execRunBout(context.Background(), call{}, action.RunBoutRequest{BoutID: "b", PresetID: "summit", Turns: 1, Model: model})
+
+	s, ok := m.Snapshot().Streams[model]
+	if !ok {
+		t.Fatal("no stream stats for the bout's model")
+	}
+	if s.Turns != 1 || s.Stalls != 1 || s.InterDelta.Count != 2 {
+		t.Errorf("turns = %d, stalls = %d, gaps = %d; want 1, 1, 2", s.Turns, s.Stalls, s.InterDelta.Count)
+	}
+	if s.FirstToken.Count != 1 || s.FirstToken.P50 < 20 {
+		t.Errorf("time to first token = %+v", s.FirstToken)
+	}
+	if s.TokensPerSec.Count != 1 || s.TokensPerSec.P50 <= 0 {
+		t.Errorf("tokens/sec = %+v", s.TokensPerSec)
+	}
+}
+
+func TestDispatcherChecksCreditRefusals(t *testing.T) {
+	model := "claude-haiku-4-5-20251001"
+	est := budget.EstimateBoutCost(model, 2, budget.DefaultOutputPerTurn)
+
+	// The server serves allowed bouts, reporting usage that costs exactly
+	// the estimate, and refuses the rest with 402.
+	run := func(allowed int) credits.Report {
+		var served atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if served.Add(1) > int32(allowed) {
+				w.WriteHeader(http.StatusPaymentRequired)
+				io.WriteString(w, `{"error":"Insufficient credits."}`)
+				return
+			}
+			io.WriteString(w, `{"status":"completed","usage":{"inputTokens":1320,"outputTokens":240}}`)
+		}))
+		defer srv.Close()
+
+		clientCfg := client.DefaultConfig(srv.URL)
+		clientCfg.MaxRetries = 0
+		cl := client.New(clientCfg, nil)
+		defer cl.Close()
+		ledger := credits.NewLedger(credits.FromGBP(est*2.5), nil)
+		d := NewDispatcher(action.New(cl), metrics.NewCollector(), budget.NewGate(0), nil)
+		d.SetCredits(ledger)
+		for i := 0; i < 4; i++ {
+			d.execAPIBout(context.Background(), call{account: "account-lab"},
+				action.APIBoutRequest{BoutID: "b", PresetID: "summit", Turns: 2, Model: model})
+		}
+		return ledger.Report()
+	}
+
+	if r := run(2); !r.Passed() || r.Accounts[0].Bouts != 2 || r.Accounts[0].Refusals != 2 {
+		t.Errorf("refusing at the right balance: %+v", r)
+	}
+	if r := run(1); r.Counts[credits.KindPremature] != 3 {
+		t.Errorf("refusing early: counts = %v, want 3 premature refusals", r.Counts)
+	}
+	if r := run(4); r.Counts[credits.KindOverdraft] != 1 || r.Counts[credits.KindEmpty] != 1 {
+		t.Errorf("never refusing: counts = %v, want an overdraft and a bout on empty", r.Counts)
+	}
+}
+
+func TestDispatcherDegradesCappedPersona(t *testing.T) {
+	var mu sync.Mutex
+	paths := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Path]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	clientCfg := client.DefaultConfig(srv.URL)
+	clientCfg.MaxRetries = 0
+	cl := client.New(clientCfg, nil)
+	defer cl.Close()
+	b := budget.NewGate(10)
+	if err := b.SetPersonaLimit("capped", budget.Limit{GBP: 0.000001}); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDispatcher(action.New(cl), metrics.NewCollector(), b, nil)
+
+	spec := &persona.Spec{
+		ID:       "capped",
+		Actions:  []persona.WeightedAction{{Action: persona.ActionRunBout, Weight: 1}, {Action: persona.ActionBrowse, Weight: 1}},
+		MaxTurns: 2,
+	}
+	for i := 0; i < 5; i++ {
+		d.Dispatch(context.Background(), 0, spec, persona.ActionRunBout)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if paths["/api/run-bout"] != 0 {
+		t.Errorf("capped persona still ran %d bouts", paths["/api/run-bout"])
+	}
+	if total := len(paths); total == 0 {
+		t.Error("capped persona should fall back to browsing")
+	}
+	if s := b.Summary(); s.Limits[0].Denied != 5 {
+		t.Errorf("Denied = %d, want 5", s.Limits[0].Denied)
+	}
+}
+
+func TestEnginePauseAndSetRate(t *testing.T) {
+	var requestCount atomic.Int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		requestCount.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}
+	cfg := Config{
+		Workers:  2,
+		Duration: 200 * time.Millisecond,
+		RateFunc: func(elapsed, total time.Duration) float64 { return 50 },
+	}
+	e, cleanup := newTestEngine(t, handler, cfg, nil)
+	defer cleanup()
+
+	e.Pause()
+	if err := e.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if n := requestCount.Load(); n != 0 {
+		t.Errorf("paused engine made %d requests", n)
+	}
+	if !e.Paused() {
+		t.Error("Paused() = false after Pause")
+	}
+
+	e.Resume()
+	e.SetRate(200)
+	if err := e.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if n := requestCount.Load(); n == 0 {
+		t.Error("resumed engine made no requests")
+	}
+	if got := e.Rate(); got != 200 {
+		t.Errorf("Rate() = %v, want the 200 req/s override", got)
+	}
+	e.SetRate(0)
+	if got := e.targetRate(0); got != 50 {
+		t.Errorf("targetRate after clearing override = %v, want profile's 50", got)
+	}
+	var actions int64
+	for _, n := range e.metrics.Snapshot().Actions["free-lurker"] {
+		actions += n
+	}
+	if actions == 0 {
+		t.Errorf("Actions = %v, want free-lurker's actions counted", e.metrics.Snapshot().Actions)
+	}
+}
+
+func TestEnginePauseDiscardsBufferedTickets(t *testing.T) {
+	var requestCount atomic.Int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		requestCount.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}
+	// Unlimited rate keeps the ticket buffer full; the think time leaves
+	// workers holding no ticket when the pause lands.
+	spec := fastPersona()
+	spec.ThinkTimeMin, spec.ThinkTimeMax = 20*time.Millisecond, 20*time.Millisecond
+	cfg := Config{Workers: 2, Duration: 2 * time.Second}
+	e, cleanup := newTestEngine(t, handler, cfg, []*persona.Spec{spec})
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- e.Run(ctx) }()
+
+	deadline := time.Now().Add(time.Second)
+	for requestCount.Load() < 10 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	e.Pause()
+	paused := requestCount.Load()
+	time.Sleep(150 * time.Millisecond)
+	after := requestCount.Load()
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// At most the action each worker had in hand completes after Pause.
+	if extra := after - paused; extra > int32(cfg.Workers) {
+		t.Errorf("%d requests after Pause returned, want at most %d in hand", extra, cfg.Workers)
+	}
+}
+
+func TestEngineStatusWritesDontCollide(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	var logged atomic.Int32
+	e := New(Config{StatusFile: path}, nil, nil, metrics.NewCollector(), budget.NewGate(1), nil,
+		func(string, ...any) { logged.Add(1) })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.Snapshot()
+		}()
+	}
+	wg.Wait()
+
+	if n := logged.Load(); n != 0 {
+		t.Errorf("%d status write errors logged", n)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st liveStatus
+	if err := json.Unmarshal(data, &st); err != nil {
+		t.Errorf("status file is not valid JSON: %v", err)
+	}
+}
+
+func TestEngineRaiseBudgetResumesRun(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	var requestCount atomic.Int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		requestCount.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}
+	e, cleanup := newTestEngine(t, handler, Config{
+		Workers:     1,
+		Duration:    2 * time.Second,
+		ControlAddr: addr,
+	}, []*persona.Spec{fastPersona()})
+	defer cleanup()
+	e.budget.RaiseCeiling(10)
+	e.budget.Charge("test", 10) // exhausted before the run starts
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- e.Run(ctx) }()
+
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("Run ended on an exhausted budget while the control API was up")
+	default:
+	}
+	if n := requestCount.Load(); n != 0 {
+		t.Fatalf("%d requests with the budget exhausted", n)
+	}
+
+	if err := e.RaiseBudget(20); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for requestCount.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if requestCount.Load() == 0 {
+		t.Error("raising the budget did not resume the run")
+	}
+}
+
+func TestEngineDisabledPersonaIdles(t *testing.T) {
+	var requestCount atomic.Int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		requestCount.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}
+	cfg := Config{Workers: 2, Duration: 200 * time.Millisecond}
+	e, cleanup := newTestEngine(t, handler, cfg, nil)
+	defer cleanup()
+
+	if err := e.SetPersonaEnabled("nobody", false); err == nil {
+		t.Error("disabling an unknown persona should fail")
+	}
+	if err := e.SetPersonaEnabled("free-lurker", false); err != nil {
+		t.Fatalf("SetPersonaEnabled: %v", err)
+	}
+	if err := e.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if n := requestCount.Load(); n != 0 {
+		t.Errorf("disabled persona made %d requests", n)
+	}
+	ps := e.Personas()
+	if len(ps) != 1 || ps[0].Enabled || ps[0].Workers != 2 {
+		t.Errorf("Personas = %+v", ps)
+	}
+}
@@ -0,0 +1,205 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/action"
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+	"github.com/rickhallett/thepit/pitstorm/internal/journal"
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+	"github.com/rickhallett/thepit/pitstorm/internal/persona"
+)
+
+// ReplayConfig controls how a journal is replayed.
+type ReplayConfig struct {
+	// Speed scales the recorded timing: 1 replays in real time, 10 runs
+	// ten times faster. Zero sends each worker's requests back-to-back.
+	Speed float64
+
+	// KeepIDs sends the recorded bout IDs unchanged. By default each
+	// recorded bout ID is mapped to a fresh one (consistently, so
+	// reactions and votes still reference the replayed bout), because
+	// the target rejects duplicate bout IDs.
+	KeepIDs bool
+}
+
+// Replayer re-issues the requests in a journal against a target,
+// preserving each worker's ordering and, optionally, the recorded timing.
+type Replayer struct {
+	cfg        ReplayConfig
+	metrics    *metrics.Collector
+	budget     *budget.Gate
+	logf       func(string, ...any)
+	dispatcher *Dispatcher
+	ids        boutIDMap // recorded bout ID → replayed bout ID
+}
+
+// NewReplayer creates a Replayer with all dependencies injected.
+func NewReplayer(
+	cfg ReplayConfig,
+	act *action.Actor,
+	m *metrics.Collector,
+	b *budget.Gate,
+	logf func(string, ...any),
+) *Replayer {
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+	return &Replayer{
+		cfg:        cfg,
+		metrics:    m,
+		budget:     b,
+		logf:       logf,
+		dispatcher: NewDispatcher(act, m, b, logf),
+		ids:        boutIDMap{ids: make(map[string]string)},
+	}
+}
+
+// Run replays entries and blocks until all have been sent, the context
+// is cancelled, or the budget is exhausted. Entries are grouped by the
+// worker that recorded them; each group runs sequentially in its own
+// goroutine so per-session ordering is preserved.
+func (r *Replayer) Run(ctx context.Context, entries []journal.Entry) error {
+	if len(entries) == 0 {
+		return fmt.Errorf("journal has no entries")
+	}
+
+	var order []int
+	byWorker := make(map[int][]journal.Entry)
+	for _, e := range entries {
+		if _, ok := byWorker[e.Worker]; !ok {
+			order = append(order, e.Worker)
+		}
+		byWorker[e.Worker] = append(byWorker[e.Worker], e)
+	}
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for _, id := range order {
+		wg.Add(1)
+		go func(workerID int, list []journal.Entry) {
+			defer wg.Done()
+			r.worker(ctx, workerID, start, list)
+		}(id, byWorker[id])
+	}
+	wg.Wait()
+	return nil
+}
+
+// worker sends one recorded worker's entries in order.
+func (r *Replayer) worker(ctx context.Context, id int, start time.Time, entries []journal.Entry) {
+	r.metrics.WorkerStart()
+	defer r.metrics.WorkerDone()
+
+	for _, e := range entries {
+		if r.budget.Exhausted() {
+			r.logf("[replay-%d] budget exhausted, stopping", id)
+			return
+		}
+
+		if r.cfg.Speed > 0 {
+			due := start.Add(time.Duration(float64(e.Offset) / r.cfg.Speed))
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(wait):
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		if err := r.replay(ctx, e); err != nil {
+			r.logf("[replay-%d] seq %d: %v", id, e.Seq, err)
+		}
+	}
+}
+
+// replay decodes a single entry's payload and resends it through the
+// dispatcher.
+func (r *Replayer) replay(ctx context.Context, e journal.Entry) error {
+	payload, err := decodeEntry(e)
+	if err != nil {
+		return err
+	}
+	c := call{
+		worker:  e.Worker,
+		persona: e.Persona,
+		account: e.Account,
+		act:     e.Action,
+	}
+	return r.dispatcher.resend(ctx, c, payload, r.boutID)
+}
+
+// decodeEntry decodes an entry's payload into the type its action's
+// exec handler takes.
+func decodeEntry(e journal.Entry) (any, error) {
+	switch e.Action {
+	case persona.ActionBrowse, persona.ActionXSSProbe, persona.ActionSQLInjection, persona.ActionIDORProbe:
+		return decodeAs[pathPayload](e)
+	case persona.ActionRunBout:
+		return decodeAs[action.RunBoutRequest](e)
+	case persona.ActionAPIBout:
+		return decodeAs[action.APIBoutRequest](e)
+	case persona.ActionCreateAgent:
+		return decodeAs[action.CreateAgentRequest](e)
+	case persona.ActionReaction:
+		return decodeAs[action.ReactionRequest](e)
+	case persona.ActionVote:
+		return decodeAs[action.WinnerVoteRequest](e)
+	case persona.ActionShortLink:
+		return decodeAs[action.ShortLinkRequest](e)
+	case persona.ActionListFeatures:
+		return nil, nil
+	case persona.ActionSubmitFeature:
+		return decodeAs[action.SubmitFeatureRequest](e)
+	case persona.ActionVoteFeature:
+		return decodeAs[action.FeatureVoteRequest](e)
+	case persona.ActionSubmitPaper:
+		return decodeAs[action.PaperSubmissionRequest](e)
+	case persona.ActionNewsletter:
+		return decodeAs[action.NewsletterRequest](e)
+	case persona.ActionContact:
+		return decodeAs[action.ContactRequest](e)
+	case persona.ActionBYOK:
+		return decodeAs[action.BYOKStashRequest](e)
+	case persona.ActionRateLimitFlood:
+		return decodeAs[floodPayload](e)
+	}
+	return nil, fmt.Errorf("unknown action %q", e.Action)
+}
+
+func decodeAs[T any](e journal.Entry) (any, error) {
+	var v T
+	if err := decodePayload(e, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// boutID maps a recorded bout ID to the one used during replay.
+func (r *Replayer) boutID(recorded string) string {
+	if r.cfg.KeepIDs {
+		return recorded
+	}
+	return r.ids.get(recorded)
+}
+
+func decodePayload(e journal.Entry, v any) error {
+	if len(e.Payload) == 0 {
+		return fmt.Errorf("%s: missing payload", e.Action)
+	}
+	if err := json.Unmarshal(e.Payload, v); err != nil {
+		return fmt.Errorf("%s: decode payload: %w", e.Action, err)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/action"
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+	"github.com/rickhallett/thepit/pitstorm/internal/client"
+	"github.com/rickhallett/thepit/pitstorm/internal/journal"
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+	"github.com/rickhallett/thepit/pitstorm/internal/persona"
+)
+
+// fastPersona is a no-think-time persona with deterministic payloads
+// (no bout-ID references), suitable for comparing seeded runs.
+func fastPersona() *persona.Spec {
+	return &persona.Spec{
+		ID:   "fast",
+		Tier: persona.TierAnon,
+		Actions: []persona.WeightedAction{
+			{Action: persona.ActionBrowse, Weight: 3},
+			{Action: persona.ActionNewsletter, Weight: 1},
+			{Action: persona.ActionSubmitPaper, Weight: 1},
+		},
+		SessionActionsMin: 2,
+		SessionActionsMax: 5,
+		MaxTurns:          2,
+	}
+}
+
+// boutPersona is fastPersona with bouts and actions that reference them,
+// for checking that seeded bout IDs are reproducible too.
+func boutPersona() *persona.Spec {
+	p := fastPersona()
+	p.ID = "bouts"
+	p.Actions = []persona.WeightedAction{
+		{Action: persona.ActionAPIBout, Weight: 2},
+		{Action: persona.ActionReaction, Weight: 1},
+		{Action: persona.ActionVote, Weight: 1},
+		{Action: persona.ActionShortLink, Weight: 1},
+	}
+	return p
+}
+
+// recordRun runs a seeded engine against an OK server and returns the journal.
+func recordRun(t *testing.T, seed int64, spec *persona.Spec) []journal.Entry {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	jw, err := journal.Create(path, journal.Header{Seed: seed})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"ok":true}`))
+	}
+	e, cleanup := newTestEngine(t, handler, Config{
+		Workers:  2,
+		Duration: 100 * time.Millisecond,
+		Seed:     seed,
+		Journal:  jw,
+	}, []*persona.Spec{spec})
+	defer cleanup()
+
+	if err := e.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if err := jw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	_, entries, err := journal.Read(path)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	return entries
+}
+
+func byWorker(entries []journal.Entry) map[int][]journal.Entry {
+	out := make(map[int][]journal.Entry)
+	for _, e := range entries {
+		out[e.Worker] = append(out[e.Worker], e)
+	}
+	return out
+}
+
+func TestSeededRunIsReproducible(t *testing.T) {
+	for _, spec := range []*persona.Spec{fastPersona(), boutPersona()} {
+		t.Run(spec.ID, func(t *testing.T) {
+			assertSameJournals(t, recordRun(t, 7, spec), recordRun(t, 7, spec))
+		})
+	}
+}
+
+// assertSameJournals checks that each worker's entries in a and b match
+// as far as the shorter of the two runs went.
+func assertSameJournals(t *testing.T, ja, jb []journal.Entry) {
+	t.Helper()
+	a, b := byWorker(ja), byWorker(jb)
+	for worker, ea := range a {
+		eb := b[worker]
+		n := min(len(ea), len(eb))
+		if n == 0 {
+			t.Fatalf("worker %d recorded no entries", worker)
+		}
+		for i := 0; i < n; i++ {
+			if ea[i].Action != eb[i].Action || string(ea[i].Payload) != string(eb[i].Payload) {
+				t.Fatalf("worker %d entry %d differs: %s %s vs %s %s",
+					worker, i, ea[i].Action, ea[i].Payload, eb[i].Action, eb[i].Payload)
+			}
+		}
+	}
+}
+
+// newTestReplayer creates a Replayer against handler.
+func newTestReplayer(t *testing.T, handler http.HandlerFunc, cfg ReplayConfig) (*Replayer, *metrics.Collector, func()) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	clientCfg := client.DefaultConfig(srv.URL)
+	clientCfg.MaxRetries = 0
+	cl := client.New(clientCfg, nil)
+	m := metrics.NewCollector()
+	r := NewReplayer(cfg, action.New(cl), m, budget.NewGate(10), nil)
+	return r, m, func() {
+		cl.Close()
+		srv.Close()
+	}
+}
+
+func mustPayload(t *testing.T, v any) json.RawMessage {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// boutRecorder captures the boutId of every request body it receives.
+type boutRecorder struct {
+	mu  sync.Mutex
+	ids map[string]string // path → boutId
+}
+
+func (br *boutRecorder) handler(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var v struct {
+		BoutID string `json:"boutId"`
+	}
+	json.Unmarshal(body, &v)
+	br.mu.Lock()
+	br.ids[r.URL.Path] = v.BoutID
+	br.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"ok":true}`))
+}
+
+func boutEntries(t *testing.T) []journal.Entry {
+	return []journal.Entry{
+		{Seq: 1, Worker: 0, Persona: "p", Action: persona.ActionAPIBout, Payload: mustPayload(t, action.APIBoutRequest{
+			BoutID: "recorded-bout-id-0000", PresetID: "roast-battle", Turns: 2, Model: "claude-haiku-4-5-20251001",
+		})},
+		{Seq: 2, Worker: 0, Persona: "p", Action: persona.ActionReaction, Payload: mustPayload(t, action.ReactionRequest{
+			BoutID: "recorded-bout-id-0000", TurnIndex: 1, ReactionType: "fire",
+		})},
+	}
+}
+
+func TestReplayRemapsBoutIDs(t *testing.T) {
+	br := &boutRecorder{ids: make(map[string]string)}
+	r, m, cleanup := newTestReplayer(t, br.handler, ReplayConfig{})
+	defer cleanup()
+
+	if err := r.Run(context.Background(), boutEntries(t)); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	bout := br.ids["/api/v1/bout"]
+	if bout == "" || bout == "recorded-bout-id-0000" {
+		t.Errorf("api-bout boutId = %q, want fresh ID", bout)
+	}
+	if len(bout) != len("recorded-bout-id-0000") {
+		t.Errorf("fresh boutId length = %d, want %d", len(bout), len("recorded-bout-id-0000"))
+	}
+	if got := br.ids["/api/reactions"]; got != bout {
+		t.Errorf("reaction boutId = %q, want remapped %q", got, bout)
+	}
+	snap := m.Snapshot()
+	if snap.Requests != 2 {
+		t.Errorf("Requests = %d, want 2", snap.Requests)
+	}
+	if got := snap.Actions["p"]; got[string(persona.ActionAPIBout)] != 1 || got[string(persona.ActionReaction)] != 1 {
+		t.Errorf("Actions[p] = %v, want one api-bout and one reaction", got)
+	}
+}
+
+func TestReplayKeepIDs(t *testing.T) {
+	br := &boutRecorder{ids: make(map[string]string)}
+	r, _, cleanup := newTestReplayer(t, br.handler, ReplayConfig{KeepIDs: true})
+	defer cleanup()
+
+	if err := r.Run(context.Background(), boutEntries(t)); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := br.ids["/api/v1/bout"]; got != "recorded-bout-id-0000" {
+		t.Errorf("api-bout boutId = %q, want recorded ID", got)
+	}
+}
+
+func TestReplaySpeed(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	entries := []journal.Entry{
+		{Seq: 1, Worker: 0, Action: persona.ActionListFeatures},
+		{Seq: 2, Worker: 0, Offset: 400 * time.Millisecond, Action: persona.ActionListFeatures},
+	}
+
+	r, m, cleanup := newTestReplayer(t, handler, ReplayConfig{Speed: 4})
+	defer cleanup()
+
+	start := time.Now()
+	if err := r.Run(context.Background(), entries); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	elapsed := time.Since(start)
+	if elapsed < 90*time.Millisecond || elapsed > time.Second {
+		t.Errorf("replay at 4x took %v, want ~100ms", elapsed)
+	}
+	if snap := m.Snapshot(); snap.Requests != 2 {
+		t.Errorf("Requests = %d, want 2", snap.Requests)
+	}
+}
+
+func TestReplayBadPayload(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	r, m, cleanup := newTestReplayer(t, handler, ReplayConfig{})
+	defer cleanup()
+
+	entries := []journal.Entry{
+		{Seq: 1, Worker: 0, Action: persona.ActionBrowse},
+		{Seq: 2, Worker: 0, Action: "teleport", Payload: json.RawMessage(`{}`)},
+	}
+	if err := r.Run(context.Background(), entries); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if snap := m.Snapshot(); snap.Requests != 0 {
+		t.Errorf("Requests = %d, want 0 for undecodable entries", snap.Requests)
+	}
+
+	if err := r.Run(context.Background(), nil); err == nil {
+		t.Error("Run(nil) should error")
+	}
+}
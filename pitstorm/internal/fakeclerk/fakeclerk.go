@@ -0,0 +1,457 @@
+// Package fakeclerk is an in-process stand-in for the parts of Clerk that
+// pitstorm's auth package talks to, so `setup`, `login` and the token
+// refresher can be exercised without network access or a Clerk instance.
+// One Server answers both the Frontend API (FAPI) and the Backend API:
+//
+//	POST /v1/users                          create a user (Backend)
+//	GET  /v1/users?email_address=…          look a user up by email (Backend)
+//	POST /v1/sign_in_tokens                 create a one-time sign-in ticket (Backend)
+//	POST /v1/client/sign_ins                password or ticket sign-in (FAPI)
+//	POST /v1/client/sessions/{id}/tokens    mint a session JWT (FAPI)
+//
+// Session tokens are unsigned JWTs whose exp claim is TokenTTL after they
+// were minted. The TTL can be changed while the server runs, and failures
+// can be injected at random or queued for a specific endpoint.
+package fakeclerk
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTokenTTL is the session token lifetime when unset — about what
+// Clerk issues, which is why the refresher runs every 45s.
+const DefaultTokenTTL = 60 * time.Second
+
+// DefaultSecretKey is the secret key pitstorm commands send when given
+// --clerk-url without one configured. The fake accepts any key unless
+// Config.SecretKey is set.
+const DefaultSecretKey = "sk_test_fakeclerk"
+
+// Config controls fake behaviour. The zero value accepts any secret key
+// and issues DefaultTokenTTL tokens without failures.
+type Config struct {
+	// TokenTTL is the lifetime of minted session tokens.
+	TokenTTL time.Duration
+
+	// SecretKey, if set, is the only bearer token the Backend endpoints
+	// accept; anything else gets a 401.
+	SecretKey string
+
+	// ErrorRate is the probability (0–1) that a request gets a 500.
+	ErrorRate float64
+
+	// RateLimitRate is the probability (0–1) that a request gets a 429,
+	// as Clerk's per-IP sign-in limits produce. It is checked before
+	// ErrorRate.
+	RateLimitRate float64
+}
+
+// Endpoint names a Clerk endpoint for failure injection.
+type Endpoint string
+
+// Endpoints the fake serves.
+const (
+	EndpointCreateUser   Endpoint = "create-user"
+	EndpointLookupUser   Endpoint = "lookup-user"
+	EndpointSignInTicket Endpoint = "sign-in-token"
+	EndpointSignIn       Endpoint = "sign-in"
+	EndpointMintToken    Endpoint = "mint-token"
+)
+
+// User is a user known to the fake.
+type User struct {
+	ID       string `json:"id"`
+	Email    string `json:"email"`
+	Password string `json:"-"`
+}
+
+// Stats counts what the fake has served.
+type Stats struct {
+	Requests int64 `json:"requests"`
+	Failures int64 `json:"injectedFailures"`
+	Users    int   `json:"users"`
+	Tickets  int64 `json:"tickets"`
+	SignIns  int64 `json:"signIns"`
+	Tokens   int64 `json:"tokens"`
+}
+
+// failure is a queued injected failure.
+type failure struct {
+	status    int
+	remaining int
+}
+
+// Server is an http.Handler emulating Clerk's FAPI and Backend API.
+type Server struct {
+	cfg  Config
+	logf func(string, ...any)
+	mux  *http.ServeMux
+	ttl  atomic.Int64 // token lifetime in nanoseconds
+
+	rngMu sync.Mutex
+	rng   *rand.Rand
+
+	mu       sync.Mutex
+	users    []*User
+	byEmail  map[string]*User
+	tickets  map[string]string // ticket → user ID, removed when redeemed
+	sessions map[string]string // session ID → user ID
+	failures map[Endpoint]*failure
+
+	nextID   atomic.Int64
+	requests atomic.Int64
+	failed   atomic.Int64
+	issued   atomic.Int64
+	signIns  atomic.Int64
+	minted   atomic.Int64
+}
+
+// New creates a Server. A nil logf disables logging.
+func New(cfg Config, logf func(string, ...any)) *Server {
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+	if cfg.TokenTTL <= 0 {
+		cfg.TokenTTL = DefaultTokenTTL
+	}
+	s := &Server{
+		cfg:      cfg,
+		logf:     logf,
+		mux:      http.NewServeMux(),
+		rng:      rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+		byEmail:  make(map[string]*User),
+		tickets:  make(map[string]string),
+		sessions: make(map[string]string),
+		failures: make(map[Endpoint]*failure),
+	}
+	s.ttl.Store(int64(cfg.TokenTTL))
+
+	s.mux.HandleFunc("POST /v1/users", s.backend(EndpointCreateUser, s.handleCreateUser))
+	s.mux.HandleFunc("GET /v1/users", s.backend(EndpointLookupUser, s.handleLookupUser))
+	s.mux.HandleFunc("POST /v1/sign_in_tokens", s.backend(EndpointSignInTicket, s.handleSignInToken))
+	s.mux.HandleFunc("POST /v1/client/sign_ins", s.inject(EndpointSignIn, s.handleSignIn))
+	s.mux.HandleFunc("POST /v1/client/sessions/{id}/tokens", s.inject(EndpointMintToken, s.handleMintToken))
+	return s
+}
+
+// ServeHTTP routes the request.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests.Add(1)
+	s.logf("[clerk] %s %s", r.Method, r.URL.Path)
+	s.mux.ServeHTTP(w, r)
+}
+
+// SetTokenTTL changes the lifetime of tokens minted from now on.
+func (s *Server) SetTokenTTL(d time.Duration) {
+	s.ttl.Store(int64(d))
+}
+
+// FailNext makes the next n requests to ep fail with status.
+func (s *Server) FailNext(ep Endpoint, status, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[ep] = &failure{status: status, remaining: n}
+}
+
+// AddUser registers a user directly, as if created in the Clerk
+// dashboard, and returns its ID.
+func (s *Server) AddUser(email, password string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addUserLocked(email, password).ID
+}
+
+// Users returns the users the fake knows, in creation order.
+func (s *Server) Users() []User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]User, len(s.users))
+	for i, u := range s.users {
+		out[i] = *u
+	}
+	return out
+}
+
+// Stats returns a snapshot of the counters.
+func (s *Server) Stats() Stats {
+	s.mu.Lock()
+	users := len(s.users)
+	s.mu.Unlock()
+	return Stats{
+		Requests: s.requests.Load(),
+		Failures: s.failed.Load(),
+		Users:    users,
+		Tickets:  s.issued.Load(),
+		SignIns:  s.signIns.Load(),
+		Tokens:   s.minted.Load(),
+	}
+}
+
+// ---------- Handlers ----------
+
+func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		EmailAddress []string `json:"email_address"`
+		Password     string   `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.EmailAddress) == 0 {
+		writeErrors(w, http.StatusUnprocessableEntity, "form_param_missing", "email_address is required")
+		return
+	}
+	email := strings.ToLower(req.EmailAddress[0])
+
+	s.mu.Lock()
+	if _, ok := s.byEmail[email]; ok {
+		s.mu.Unlock()
+		writeErrors(w, http.StatusUnprocessableEntity, "form_identifier_exists", "That email address is taken. Please try another.")
+		return
+	}
+	u := s.addUserLocked(email, req.Password)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"object":          "user",
+		"id":              u.ID,
+		"email_addresses": []map[string]string{{"email_address": u.Email}},
+	})
+}
+
+func (s *Server) handleLookupUser(w http.ResponseWriter, r *http.Request) {
+	email := strings.ToLower(r.URL.Query().Get("email_address"))
+	users := []map[string]string{}
+	s.mu.Lock()
+	if u, ok := s.byEmail[email]; ok {
+		users = append(users, map[string]string{"object": "user", "id": u.ID})
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, users)
+}
+
+func (s *Server) handleSignInToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == "" {
+		writeErrors(w, http.StatusUnprocessableEntity, "form_param_missing", "user_id is required")
+		return
+	}
+	if !s.hasUser(req.UserID) {
+		writeErrors(w, http.StatusNotFound, "resource_not_found", "user not found")
+		return
+	}
+
+	id, ticket := s.newID("sit"), s.newID("tkt")
+	s.mu.Lock()
+	s.tickets[ticket] = req.UserID
+	s.mu.Unlock()
+	s.issued.Add(1)
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"object":  "sign_in_token",
+		"id":      id,
+		"user_id": req.UserID,
+		"token":   ticket,
+		"status":  "pending",
+		"url":     "http://fakeclerk/v1/tickets/accept?ticket=" + ticket,
+	})
+}
+
+func (s *Server) handleSignIn(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeErrors(w, http.StatusBadRequest, "form_param_format_invalid", "invalid form body")
+		return
+	}
+
+	var userID string
+	switch r.PostForm.Get("strategy") {
+	case "password":
+		email := strings.ToLower(r.PostForm.Get("identifier"))
+		s.mu.Lock()
+		u, ok := s.byEmail[email]
+		s.mu.Unlock()
+		if !ok {
+			writeErrors(w, http.StatusUnprocessableEntity, "form_identifier_not_found", "Couldn't find your account.")
+			return
+		}
+		if u.Password != r.PostForm.Get("password") {
+			writeErrors(w, http.StatusUnprocessableEntity, "form_password_incorrect", "Password is incorrect. Try again, or use another method.")
+			return
+		}
+		userID = u.ID
+	case "ticket":
+		ticket := r.PostForm.Get("ticket")
+		s.mu.Lock()
+		id, ok := s.tickets[ticket]
+		delete(s.tickets, ticket)
+		s.mu.Unlock()
+		if !ok {
+			writeErrors(w, http.StatusUnprocessableEntity, "ticket_invalid", "The ticket is invalid or has already been used.")
+			return
+		}
+		userID = id
+	default:
+		writeErrors(w, http.StatusUnprocessableEntity, "strategy_for_user_invalid", "unsupported sign-in strategy")
+		return
+	}
+
+	sessionID := s.newID("sess")
+	s.mu.Lock()
+	s.sessions[sessionID] = userID
+	s.mu.Unlock()
+	s.signIns.Add(1)
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"response": map[string]string{
+			"object":             "sign_in_attempt",
+			"id":                 s.newID("sia"),
+			"status":             "complete",
+			"created_session_id": sessionID,
+		},
+		"client": map[string]any{
+			"object": "client",
+			"sessions": []map[string]any{{
+				"object": "session",
+				"id":     sessionID,
+				"status": "active",
+				"user":   map[string]string{"id": userID},
+				"last_active_token": map[string]string{
+					"object": "token",
+					"jwt":    s.mint(sessionID, userID),
+				},
+			}},
+		},
+	})
+}
+
+func (s *Server) handleMintToken(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	s.mu.Lock()
+	userID, ok := s.sessions[sessionID]
+	s.mu.Unlock()
+	if !ok {
+		writeErrors(w, http.StatusNotFound, "resource_not_found", "session not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"object": "token", "jwt": s.mint(sessionID, userID)})
+}
+
+// ---------- Helpers ----------
+
+// inject wraps a handler with random and queued failure injection.
+func (s *Server) inject(ep Endpoint, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if status := s.failure(ep); status != 0 {
+			s.failed.Add(1)
+			if status == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "1")
+			}
+			writeErrors(w, status, "injected_failure", http.StatusText(status))
+			s.logf("[clerk] %s → %d (injected)", ep, status)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// backend is inject plus the secret-key check of the Backend API.
+func (s *Server) backend(ep Endpoint, next http.HandlerFunc) http.HandlerFunc {
+	return s.inject(ep, func(w http.ResponseWriter, r *http.Request) {
+		key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || key == "" || (s.cfg.SecretKey != "" && key != s.cfg.SecretKey) {
+			writeErrors(w, http.StatusUnauthorized, "authentication_invalid", "invalid secret key")
+			return
+		}
+		next(w, r)
+	})
+}
+
+// failure returns the status a request to ep should fail with, or 0.
+func (s *Server) failure(ep Endpoint) int {
+	s.mu.Lock()
+	if f, ok := s.failures[ep]; ok {
+		f.remaining--
+		if f.remaining <= 0 {
+			delete(s.failures, ep)
+		}
+		s.mu.Unlock()
+		return f.status
+	}
+	s.mu.Unlock()
+
+	switch {
+	case s.roll(s.cfg.RateLimitRate):
+		return http.StatusTooManyRequests
+	case s.roll(s.cfg.ErrorRate):
+		return http.StatusInternalServerError
+	}
+	return 0
+}
+
+func (s *Server) roll(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	s.rngMu.Lock()
+	defer s.rngMu.Unlock()
+	return s.rng.Float64() < p
+}
+
+func (s *Server) addUserLocked(email, password string) *User {
+	u := &User{ID: s.newID("user"), Email: strings.ToLower(email), Password: password}
+	s.users = append(s.users, u)
+	s.byEmail[u.Email] = u
+	return u
+}
+
+func (s *Server) hasUser(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// newID returns a Clerk-style ID such as "user_fake000003".
+func (s *Server) newID(prefix string) string {
+	return fmt.Sprintf("%s_fake%06d", prefix, s.nextID.Add(1))
+}
+
+// mint issues an unsigned session JWT expiring after the current TTL.
+func (s *Server) mint(sessionID, userID string) string {
+	s.minted.Add(1)
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "none", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]any{
+		"iss": "fakeclerk",
+		"sub": userID,
+		"sid": sessionID,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Duration(s.ttl.Load())).Unix(),
+	})
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(header) + "." + enc.EncodeToString(claims) + ".fakeclerk"
+}
+
+// writeErrors writes a Clerk-style error body.
+func writeErrors(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, map[string]any{
+		"errors": []map[string]string{{"code": code, "message": msg, "long_message": msg}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
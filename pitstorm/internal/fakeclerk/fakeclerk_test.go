@@ -0,0 +1,140 @@
+package fakeclerk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/auth"
+)
+
+func newTestClerk(t *testing.T, cfg Config) (*Server, *auth.Client, *auth.BackendClient) {
+	t.Helper()
+	s := New(cfg, nil)
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, auth.NewClientWithURL(srv.URL), auth.NewBackendClientWithURL("sk_test_fake", srv.URL)
+}
+
+func TestProvisionAndSignIn(t *testing.T) {
+	s, fapi, backend := newTestClerk(t, Config{TokenTTL: time.Hour})
+	ctx := context.Background()
+
+	created, err := backend.CreateUser(ctx, auth.CreateUserRequest{Email: "storm@example.com", Password: "pw"})
+	if err != nil || created.AlreadyExisted {
+		t.Fatalf("CreateUser = %+v, %v", created, err)
+	}
+	again, err := backend.CreateUser(ctx, auth.CreateUserRequest{Email: "storm@example.com", Password: "pw"})
+	if err != nil || !again.AlreadyExisted || again.ID != created.ID {
+		t.Fatalf("CreateUser (duplicate) = %+v, %v; want the existing user", again, err)
+	}
+
+	res, err := fapi.SignIn(ctx, "storm@example.com", "pw")
+	if err != nil {
+		t.Fatalf("SignIn: %v", err)
+	}
+	if res.UserID != created.ID || res.SessionID == "" || res.Token == "" {
+		t.Errorf("SignIn = %+v", res)
+	}
+	if left := time.Until(res.ExpiresAt); left < 59*time.Minute || left > time.Hour+time.Second {
+		t.Errorf("token expires in %v, want the 1h TTL", left)
+	}
+	if _, err := fapi.SignIn(ctx, "storm@example.com", "wrong"); err == nil || !strings.Contains(err.Error(), "form_password_incorrect") {
+		t.Errorf("wrong password: %v", err)
+	}
+
+	// Tokens minted from the session follow TTL changes.
+	s.SetTokenTTL(5 * time.Second)
+	_, exp, err := fapi.RefreshToken(ctx, res.SessionID)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if left := time.Until(exp); left > 6*time.Second {
+		t.Errorf("refreshed token expires in %v, want the new 5s TTL", left)
+	}
+}
+
+func TestTicketFlow(t *testing.T) {
+	s, fapi, backend := newTestClerk(t, Config{})
+	ctx := context.Background()
+	userID := s.AddUser("lab@example.com", "pw")
+
+	if got, err := backend.LookupUserByEmail(ctx, "lab@example.com"); err != nil || got != userID {
+		t.Fatalf("LookupUserByEmail = %q, %v; want %q", got, err, userID)
+	}
+	if _, err := backend.LookupUserByEmail(ctx, "nobody@example.com"); err == nil {
+		t.Error("LookupUserByEmail found a missing user")
+	}
+
+	ticket, err := backend.CreateSignInToken(ctx, userID)
+	if err != nil {
+		t.Fatalf("CreateSignInToken: %v", err)
+	}
+	res, err := fapi.SignInWithTicket(ctx, ticket.Token, "")
+	if err != nil {
+		t.Fatalf("SignInWithTicket: %v", err)
+	}
+	if res.UserID != userID || time.Until(res.ExpiresAt) <= 0 {
+		t.Errorf("SignInWithTicket = %+v", res)
+	}
+	if _, err := fapi.SignInWithTicket(ctx, ticket.Token, ""); err == nil || !strings.Contains(err.Error(), "ticket_invalid") {
+		t.Errorf("redeeming a ticket twice: %v", err)
+	}
+	if st := s.Stats(); st.Tickets != 1 || st.SignIns != 1 {
+		t.Errorf("Stats = %+v", st)
+	}
+}
+
+func TestSecretKeyAndInjectedFailures(t *testing.T) {
+	s := New(Config{SecretKey: "sk_test_right"}, nil)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	ctx := context.Background()
+
+	wrong := auth.NewBackendClientWithURL("sk_test_wrong", srv.URL)
+	if _, err := wrong.LookupUserByEmail(ctx, "a@example.com"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("wrong secret key: %v", err)
+	}
+
+	backend := auth.NewBackendClientWithURL("sk_test_right", srv.URL)
+	userID := s.AddUser("a@example.com", "pw")
+	s.FailNext(EndpointSignInTicket, http.StatusTooManyRequests, 2)
+	for i := 0; i < 2; i++ {
+		if _, err := backend.CreateSignInToken(ctx, userID); err == nil || !strings.Contains(err.Error(), "429") {
+			t.Errorf("injected failure %d: %v", i+1, err)
+		}
+	}
+	if _, err := backend.CreateSignInToken(ctx, userID); err != nil {
+		t.Errorf("after the injected failures: %v", err)
+	}
+	if st := s.Stats(); st.Failures != 2 {
+		t.Errorf("Failures = %d, want 2", st.Failures)
+	}
+}
+
+func TestRefresherAgainstFake(t *testing.T) {
+	s, fapi, backend := newTestClerk(t, Config{TokenTTL: 30 * time.Second})
+	userID := s.AddUser("casual@example.com", "pw")
+
+	r := auth.NewRefresher(fapi, time.Minute, nil)
+	r.SetBackend(backend)
+	var token string
+	var expires time.Time
+	r.RefreshNow(context.Background(), []auth.RefreshTarget{{AccountID: "casual", UserID: userID}},
+		func(_, tok string, exp time.Time) { token, expires = tok, exp })
+	if token == "" || time.Until(expires) > 31*time.Second {
+		t.Errorf("refresh gave token %q expiring at %v", token, expires)
+	}
+
+	// A failed ticket leaves the account's old token in place.
+	s.FailNext(EndpointSignIn, http.StatusInternalServerError, 1)
+	token = ""
+	r.RefreshNow(context.Background(), []auth.RefreshTarget{{AccountID: "casual", UserID: userID}},
+		func(_, tok string, _ time.Time) { token = tok })
+	if token != "" {
+		t.Error("callback ran for a failed refresh")
+	}
+}
@@ -0,0 +1,319 @@
+// Package har samples HTTP exchanges during a run — every failure, and
+// a fraction of successes — and exports them as a HAR 1.2 archive that
+// can be opened in browser devtools. Credentials are redacted before an
+// exchange is stored.
+package har
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/client"
+)
+
+// Default recorder limits.
+const (
+	DefaultMaxFailures  = 500
+	DefaultMaxSuccesses = 100
+	DefaultSuccessRate  = 0.01
+	DefaultMaxBodyBytes = 16 * 1024
+)
+
+// Config bounds what a Recorder keeps.
+type Config struct {
+	// MaxFailures and MaxSuccesses cap the stored entries of each kind;
+	// later exchanges are counted as dropped.
+	MaxFailures  int
+	MaxSuccesses int
+
+	// SuccessRate is the fraction of successful exchanges sampled.
+	SuccessRate float64
+
+	// MaxBodyBytes truncates stored request and response bodies.
+	MaxBodyBytes int
+}
+
+// DefaultConfig returns the default limits.
+func DefaultConfig() Config {
+	return Config{
+		MaxFailures:  DefaultMaxFailures,
+		MaxSuccesses: DefaultMaxSuccesses,
+		SuccessRate:  DefaultSuccessRate,
+		MaxBodyBytes: DefaultMaxBodyBytes,
+	}
+}
+
+// Recorder collects sampled exchanges. Its Observe method has the
+// client.Client observer signature. Safe for concurrent use.
+type Recorder struct {
+	cfg Config
+
+	mu        sync.Mutex
+	entries   []Entry
+	failures  int
+	successes int
+	dropped   int
+}
+
+// NewRecorder creates a Recorder.
+func NewRecorder(cfg Config) *Recorder {
+	return &Recorder{cfg: cfg}
+}
+
+// Failed reports whether an exchange counts as a failure: no response,
+// a body that could not be read, a 4xx/5xx status, or a stream whose
+// reader reported an error in it (see client.ReportStreamError).
+func Failed(x client.Exchange) bool {
+	return x.Err != nil || x.StatusCode == 0 || x.StatusCode >= 400
+}
+
+// Observe stores x if it is a failure or is sampled as a success, and
+// there is room for it.
+func (r *Recorder) Observe(x client.Exchange) {
+	failed := Failed(x)
+	if !failed && (r.cfg.SuccessRate <= 0 || rand.Float64() >= r.cfg.SuccessRate) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if failed && r.failures >= r.cfg.MaxFailures || !failed && r.successes >= r.cfg.MaxSuccesses {
+		r.dropped++
+		return
+	}
+	if failed {
+		r.failures++
+	} else {
+		r.successes++
+	}
+	r.entries = append(r.entries, newEntry(x, r.cfg.MaxBodyBytes))
+}
+
+// Counts returns how many failures and sampled successes are stored,
+// and how many more were dropped once the limits were reached.
+func (r *Recorder) Counts() (failures, successes, dropped int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.failures, r.successes, r.dropped
+}
+
+// Log returns the stored entries as a HAR log, in start order.
+func (r *Recorder) Log() Log {
+	r.mu.Lock()
+	entries := make([]Entry, len(r.entries))
+	copy(entries, r.entries)
+	r.mu.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].started.Before(entries[j].started) })
+	return Log{
+		Version: "1.2",
+		Creator: Creator{Name: "pitstorm", Version: "1.0"},
+		Entries: entries,
+	}
+}
+
+// Write exports the stored entries to a HAR file at path.
+func (r *Recorder) Write(path string) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("create har dir: %w", err)
+		}
+	}
+	data, err := json.MarshalIndent(File{Log: r.Log()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal har: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write har: %w", err)
+	}
+	return nil
+}
+
+// ---------- HAR 1.2 ----------
+
+// File is the top-level HAR document.
+type File struct {
+	Log Log `json:"log"`
+}
+
+// Log is the HAR log object.
+type Log struct {
+	Version string  `json:"version"`
+	Creator Creator `json:"creator"`
+	Entries []Entry `json:"entries"`
+}
+
+// Creator identifies the tool that wrote the archive.
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Entry is one request/response pair.
+type Entry struct {
+	StartedDateTime string   `json:"startedDateTime"`
+	Time            float64  `json:"time"` // ms
+	Request         Request  `json:"request"`
+	Response        Response `json:"response"`
+	Cache           struct{} `json:"cache"`
+	Timings         Timings  `json:"timings"`
+	Comment         string   `json:"comment,omitempty"`
+
+	started time.Time
+}
+
+// Request is a HAR request.
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	PostData    *PostData   `json:"postData,omitempty"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+// PostData is a HAR request body.
+type PostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// Response is a HAR response. Status 0 means none was received.
+type Response struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+// Content is a HAR response body.
+type Content struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// Timings splits an entry's time, in ms. pitstorm does not measure
+// connection setup separately, so it is folded into wait.
+type Timings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// NameValue is a HAR header, cookie or query parameter.
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func newEntry(x client.Exchange, maxBody int) Entry {
+	wait, receive := ms(x.Wait), ms(x.Receive)
+	e := Entry{
+		StartedDateTime: x.Started.UTC().Format(time.RFC3339Nano),
+		Time:            wait + receive,
+		Timings:         Timings{Wait: wait, Receive: receive},
+		started:         x.Started,
+		Request: Request{
+			Method:      x.Method,
+			URL:         x.URL,
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []NameValue{},
+			Headers:     headers(x.RequestHeader),
+			QueryString: query(x.URL),
+			HeadersSize: -1,
+			BodySize:    len(x.RequestBody),
+		},
+		Response: Response{
+			Status:      x.StatusCode,
+			StatusText:  http.StatusText(x.StatusCode),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []NameValue{},
+			Headers:     headers(x.ResponseHeader),
+			HeadersSize: -1,
+			BodySize:    len(x.ResponseBody),
+			Content: Content{
+				Size:     len(x.ResponseBody),
+				MimeType: x.ResponseHeader.Get("Content-Type"),
+			},
+		},
+	}
+	if x.Err != nil {
+		e.Comment = fmt.Sprintf("attempt %d: %v", x.Attempt, x.Err)
+	} else if x.Attempt > 1 {
+		e.Comment = fmt.Sprintf("attempt %d", x.Attempt)
+	}
+
+	if len(x.RequestBody) > 0 {
+		text, _ := truncate(RedactBody(x.RequestBody), maxBody)
+		e.Request.PostData = &PostData{MimeType: x.RequestHeader.Get("Content-Type"), Text: text}
+	}
+	switch {
+	case x.Streamed:
+		e.Response.BodySize = -1
+		e.Response.Content.Size = -1
+		e.Response.Content.Comment = "streamed response, body not captured"
+	case len(x.ResponseBody) > 0:
+		text, cut := truncate(RedactBody(x.ResponseBody), maxBody)
+		e.Response.Content.Text = text
+		if cut {
+			e.Response.Content.Comment = fmt.Sprintf("truncated to %d bytes", maxBody)
+		}
+	}
+	return e
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func truncate(b []byte, n int) (string, bool) {
+	if n > 0 && len(b) > n {
+		return string(b[:n]), true
+	}
+	return string(b), false
+}
+
+// headers flattens h in name order, redacting credentials.
+func headers(h http.Header) []NameValue {
+	out := []NameValue{}
+	for name, values := range h {
+		for _, v := range values {
+			out = append(out, NameValue{Name: name, Value: RedactHeader(name, v)})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func query(rawURL string) []NameValue {
+	out := []NameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return out
+	}
+	for name, values := range u.Query() {
+		for _, v := range values {
+			out = append(out, NameValue{Name: name, Value: v})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,128 @@
+package har
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/client"
+)
+
+func exchange(status int, body string) client.Exchange {
+	return client.Exchange{
+		Started:        time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+		Method:         "POST",
+		URL:            "https://example.test/api/byok-stash?x=1",
+		RequestHeader:  http.Header{"Authorization": {"Bearer sk-live"}, "X-Research-Key": {"rk"}, "Content-Type": {"application/json"}},
+		RequestBody:    []byte(`{"provider":"anthropic","apiKey":"sk-ant-secret"}`),
+		StatusCode:     status,
+		ResponseHeader: http.Header{"Content-Type": {"application/json"}},
+		ResponseBody:   []byte(body),
+		Wait:           40 * time.Millisecond,
+		Receive:        2 * time.Millisecond,
+		Attempt:        1,
+	}
+}
+
+func TestRecorderSampling(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxFailures, cfg.SuccessRate = 2, 0
+	r := NewRecorder(cfg)
+
+	r.Observe(exchange(200, "ok"))
+	r.Observe(exchange(500, "boom"))
+	r.Observe(exchange(404, "missing"))
+	r.Observe(exchange(502, "bad gateway"))
+	r.Observe(client.Exchange{Method: "GET", URL: "https://example.test/", Err: errors.New("connection refused")})
+
+	failures, successes, dropped := r.Counts()
+	if failures != 2 || successes != 0 || dropped != 2 {
+		t.Errorf("Counts = %d, %d, %d; want 2, 0, 2", failures, successes, dropped)
+	}
+
+	cfg.SuccessRate = 1
+	r = NewRecorder(cfg)
+	r.Observe(exchange(200, "ok"))
+	if _, successes, _ := r.Counts(); successes != 1 {
+		t.Errorf("successes = %d, want every success sampled at rate 1", successes)
+	}
+}
+
+func TestEntryRedactsAndTruncates(t *testing.T) {
+	e := newEntry(exchange(500, strings.Repeat("x", 100)), 60)
+
+	for _, h := range e.Request.Headers {
+		switch h.Name {
+		case "Authorization":
+			if h.Value != "Bearer "+Redacted {
+				t.Errorf("Authorization = %q", h.Value)
+			}
+		case "X-Research-Key":
+			if h.Value != Redacted {
+				t.Errorf("X-Research-Key = %q", h.Value)
+			}
+		}
+	}
+	if strings.Contains(e.Request.PostData.Text, "sk-ant-secret") || !strings.Contains(e.Request.PostData.Text, "anthropic") {
+		t.Errorf("postData = %q", e.Request.PostData.Text)
+	}
+	if len(e.Response.Content.Text) != 60 || e.Response.Content.Size != 100 || e.Response.Content.Comment == "" {
+		t.Errorf("content = %+v", e.Response.Content)
+	}
+	if e.Time != 42 || e.Response.StatusText != "Internal Server Error" {
+		t.Errorf("time = %v, status = %q", e.Time, e.Response.StatusText)
+	}
+	if len(e.Request.QueryString) != 1 || e.Request.QueryString[0].Name != "x" {
+		t.Errorf("queryString = %+v", e.Request.QueryString)
+	}
+}
+
+func TestRedactBodyLeavesNonJSON(t *testing.T) {
+	for _, body := range []string{"plain text token=abc", `{"name":"agent"}`} {
+		if got := string(RedactBody([]byte(body))); got != body {
+			t.Errorf("RedactBody(%q) = %q", body, got)
+		}
+	}
+	got := string(RedactBody([]byte(`{"items":[{"sessionToken":"t"}]}`)))
+	if strings.Contains(got, `"t"`) {
+		t.Errorf("nested token not redacted: %s", got)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	cfg := DefaultConfig()
+	r := NewRecorder(cfg)
+	r.Observe(exchange(503, "overloaded"))
+	streamed := exchange(200, "")
+	streamed.Streamed, streamed.ResponseBody = true, nil
+	cfg.SuccessRate = 1
+	r.cfg = cfg
+	r.Observe(streamed)
+
+	path := filepath.Join(t.TempDir(), "out", "run.har")
+	if err := r.Write(path); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatalf("invalid HAR JSON: %v", err)
+	}
+	if f.Log.Version != "1.2" || len(f.Log.Entries) != 2 {
+		t.Fatalf("log = %+v", f.Log)
+	}
+	if got := f.Log.Entries[1].Response.Content.Size; got != -1 {
+		t.Errorf("streamed content size = %d, want -1", got)
+	}
+	if strings.Contains(string(data), "sk-live") || strings.Contains(string(data), "sk-ant-secret") {
+		t.Error("HAR file contains a credential")
+	}
+}
@@ -0,0 +1,80 @@
+package har
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Redacted replaces every credential in an exported exchange.
+const Redacted = "[REDACTED]"
+
+// sensitiveHeaders are always redacted; any header whose name mentions
+// a token, secret or key is too (e.g. X-Research-Key).
+var sensitiveHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+}
+
+// RedactHeader returns the value to export for a header. An
+// Authorization scheme is kept so the archive still shows how the
+// request authenticated.
+func RedactHeader(name, value string) string {
+	lower := strings.ToLower(name)
+	if !sensitiveHeaders[lower] && !sensitiveName(lower) {
+		return value
+	}
+	if scheme, _, ok := strings.Cut(value, " "); ok && strings.HasPrefix(lower, "authorization") {
+		return scheme + " " + Redacted
+	}
+	return Redacted
+}
+
+// RedactBody masks credential fields (apiKey, token, password, ...) at
+// any depth of a JSON body. Other bodies are returned unchanged.
+func RedactBody(body []byte) []byte {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	if !redactValue(v) {
+		return body
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+// redactValue masks sensitive fields in place and reports whether it
+// changed anything.
+func redactValue(v any) bool {
+	changed := false
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if _, isString := child.(string); isString && sensitiveName(strings.ToLower(k)) {
+				t[k] = Redacted
+				changed = true
+				continue
+			}
+			changed = redactValue(child) || changed
+		}
+	case []any:
+		for _, child := range t {
+			changed = redactValue(child) || changed
+		}
+	}
+	return changed
+}
+
+func sensitiveName(lower string) bool {
+	for _, s := range []string{"token", "secret", "password", "key", "session"} {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return false
+}
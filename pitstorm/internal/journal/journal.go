@@ -0,0 +1,199 @@
+// Package journal records every action dispatched during a pitstorm run
+// to a JSONL file so the exact sequence can be replayed later. The first
+// line is a Header describing the run; each following line is an Entry
+// carrying the persona, account, action, and the fully-resolved request
+// payload that was sent.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/persona"
+)
+
+// Version is the current journal schema version.
+const Version = 1
+
+// Header is the first line of a journal file.
+type Header struct {
+	Version   int       `json:"journal"`
+	StartedAt time.Time `json:"startedAt"`
+	Target    string    `json:"target"`
+	Seed      int64     `json:"seed,omitempty"`
+	Profile   string    `json:"profile,omitempty"`
+	Rate      float64   `json:"rate,omitempty"`
+	Workers   int       `json:"workers,omitempty"`
+	// TargetB is the second target of an A/B split run, whose sessions
+	// are recorded with Entry.Target TargetTagB.
+	TargetB string `json:"targetB,omitempty"`
+}
+
+// TargetTagB tags the entries of sessions sent to Header.TargetB.
+const TargetTagB = "b"
+
+// Entry is a single dispatched action.
+type Entry struct {
+	// Seq is the 1-indexed order in which the action was dispatched.
+	Seq int64 `json:"seq"`
+
+	// Offset is the time since the run started, in nanoseconds.
+	Offset time.Duration `json:"offsetNs"`
+
+	// Worker is the engine worker that issued the action. Replay keeps
+	// each worker's entries sequential.
+	Worker int `json:"worker"`
+
+	Persona string         `json:"persona"`
+	Account string         `json:"account,omitempty"`
+	Action  persona.Action `json:"action"`
+
+	// Target is TargetTagB for a session sent to the header's TargetB,
+	// empty for the header's Target.
+	Target string `json:"target,omitempty"`
+
+	// Payload is the request body (or path wrapper for GET actions).
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Writer appends entries to a journal file. Safe for concurrent use.
+type Writer struct {
+	mu    sync.Mutex
+	f     *os.File
+	bw    *bufio.Writer
+	enc   *json.Encoder
+	start time.Time
+	seq   int64
+}
+
+// Create opens path for writing (truncating any existing file) and
+// writes the header. The header's StartedAt anchors entry offsets.
+func Create(path string, h Header) (*Writer, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("create journal dir: %w", err)
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create journal: %w", err)
+	}
+
+	h.Version = Version
+	if h.StartedAt.IsZero() {
+		h.StartedAt = time.Now().UTC()
+	}
+
+	bw := bufio.NewWriter(f)
+	w := &Writer{f: f, bw: bw, enc: json.NewEncoder(bw), start: h.StartedAt}
+	if err := w.enc.Encode(h); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("write journal header: %w", err)
+	}
+	return w, nil
+}
+
+// Record appends an entry. Seq and Offset are assigned by the writer;
+// payload is marshalled to JSON (nil for actions without a body).
+func (w *Writer) Record(worker int, personaID, accountID string, act persona.Action, payload any) error {
+	return w.RecordTarget("", worker, personaID, accountID, act, payload)
+}
+
+// RecordTarget is Record for an entry tagged with target (see
+// Entry.Target).
+func (w *Writer) RecordTarget(target string, worker int, personaID, accountID string, act persona.Action, payload any) error {
+	var raw json.RawMessage
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("marshal journal payload: %w", err)
+		}
+		raw = b
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.seq++
+	e := Entry{
+		Seq:     w.seq,
+		Offset:  time.Since(w.start),
+		Worker:  worker,
+		Persona: personaID,
+		Account: accountID,
+		Action:  act,
+		Target:  target,
+		Payload: raw,
+	}
+	if err := w.enc.Encode(e); err != nil {
+		return fmt.Errorf("write journal entry: %w", err)
+	}
+	return nil
+}
+
+// Count returns the number of entries written so far.
+func (w *Writer) Count() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.seq
+}
+
+// Close flushes buffered entries and closes the file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.bw.Flush(); err != nil {
+		w.f.Close()
+		return fmt.Errorf("flush journal: %w", err)
+	}
+	return w.f.Close()
+}
+
+// Read loads a journal file, returning its header and entries in Seq order.
+func Read(path string) (Header, []Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Header{}, nil, fmt.Errorf("open journal: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	// Run-bout payloads are small, but allow generous lines for custom topics.
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return Header{}, nil, fmt.Errorf("read journal header: %w", err)
+		}
+		return Header{}, nil, fmt.Errorf("journal is empty")
+	}
+	var h Header
+	if err := json.Unmarshal(scanner.Bytes(), &h); err != nil {
+		return Header{}, nil, fmt.Errorf("parse journal header: %w", err)
+	}
+	if h.Version != Version {
+		return Header{}, nil, fmt.Errorf("unsupported journal version %d (expected %d)", h.Version, Version)
+	}
+
+	var entries []Entry
+	line := 1
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return Header{}, nil, fmt.Errorf("parse journal line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return Header{}, nil, fmt.Errorf("read journal: %w", err)
+	}
+	return h, entries, nil
+}
@@ -0,0 +1,127 @@
+package journal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/persona"
+)
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results", "journal.jsonl")
+	w, err := Create(path, Header{Target: "http://localhost:3000", Seed: 42, Workers: 4})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	type body struct {
+		Path string `json:"path"`
+	}
+	if err := w.Record(0, "free-lurker", "", persona.ActionBrowse, body{Path: "/arena"}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if err := w.Record(1, "casual", "account-casual", persona.ActionListFeatures, nil); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if w.Count() != 2 {
+		t.Errorf("Count = %d, want 2", w.Count())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	h, entries, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if h.Version != Version || h.Seed != 42 || h.Target != "http://localhost:3000" {
+		t.Errorf("Header = %+v", h)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries = %d, want 2", len(entries))
+	}
+
+	e := entries[0]
+	if e.Seq != 1 || e.Worker != 0 || e.Persona != "free-lurker" || e.Action != persona.ActionBrowse {
+		t.Errorf("entries[0] = %+v", e)
+	}
+	var b body
+	if err := json.Unmarshal(e.Payload, &b); err != nil || b.Path != "/arena" {
+		t.Errorf("entries[0] payload = %s (%v)", e.Payload, err)
+	}
+
+	if entries[1].Account != "account-casual" || len(entries[1].Payload) != 0 {
+		t.Errorf("entries[1] = %+v", entries[1])
+	}
+	if entries[1].Offset < entries[0].Offset {
+		t.Errorf("offsets not monotonic: %v then %v", entries[0].Offset, entries[1].Offset)
+	}
+}
+
+func TestWriterConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	w, err := Create(path, Header{})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				w.Record(worker, "p", "", persona.ActionBrowse, nil)
+			}
+		}(i)
+	}
+	wg.Wait()
+	w.Close()
+
+	_, entries, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(entries) != 200 {
+		t.Fatalf("entries = %d, want 200", len(entries))
+	}
+	for i, e := range entries {
+		if e.Seq != int64(i+1) {
+			t.Fatalf("entries[%d].Seq = %d, want %d", i, e.Seq, i+1)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"empty", "", "empty"},
+		{"bad header", "not json\n", "parse journal header"},
+		{"bad version", `{"journal":99}` + "\n", "unsupported journal version"},
+		{"bad entry", `{"journal":1}` + "\n{oops\n", "line 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".jsonl")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, _, err := Read(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Read error = %v, want substring %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, _, err := Read(filepath.Join(dir, "missing.jsonl")); err == nil {
+		t.Error("Read(missing) should error")
+	}
+}
@@ -0,0 +1,338 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RelativeError bounds the error of Histogram percentiles: a reported
+// percentile is within this fraction of a value that was recorded at
+// that rank. Count, Mean, StdDev and the whole-run Min and Max are exact.
+const RelativeError = 0.005
+
+const (
+	// minTrackableMs is the smallest value given its own bucket, matching
+	// the microsecond resolution of Add. Smaller values count as zero.
+	minTrackableMs = 0.001
+	// maxTrackableMs caps the bucket range at a day; longer samples share
+	// the top bucket but still update Max exactly.
+	maxTrackableMs = 24 * 60 * 60 * 1000
+)
+
+var (
+	gamma    = (1 + RelativeError) / (1 - RelativeError)
+	lnGamma  = math.Log(gamma)
+	minIndex = bucketIndex(minTrackableMs)
+	maxIndex = bucketIndex(maxTrackableMs)
+)
+
+// bucketIndex returns the bucket holding v: bucket i covers
+// (gamma^(i-1), gamma^i].
+func bucketIndex(v float64) int {
+	return int(math.Ceil(math.Log(v) / lnGamma))
+}
+
+// bucketValue is the value reported for samples in bucket i, chosen so
+// that it is within RelativeError of every value the bucket covers.
+func bucketValue(i int) float64 {
+	return 2 * math.Pow(gamma, float64(i)) / (gamma + 1)
+}
+
+// bucketLower is the exclusive lower edge of bucket i.
+func bucketLower(i int) float64 {
+	return math.Pow(gamma, float64(i-1))
+}
+
+// ---------- Histogram ----------
+
+// Histogram collects time.Duration samples into logarithmic buckets and
+// computes percentiles with bounded relative error (see RelativeError).
+// Memory is bounded by the bucket range, not the number of samples.
+// Thread-safe for concurrent Add calls.
+type Histogram struct {
+	mu sync.Mutex
+	d  Distribution
+}
+
+// NewHistogram creates an empty Histogram.
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+// Add records a duration sample.
+func (h *Histogram) Add(d time.Duration) {
+	h.observe(float64(d.Microseconds()) / 1000.0)
+}
+
+// observe records a raw sample, for histograms of something other than
+// milliseconds (e.g. tokens per second).
+func (h *Histogram) observe(v float64) {
+	h.mu.Lock()
+	h.d.add(v)
+	h.mu.Unlock()
+}
+
+// Merge adds every sample recorded in o to h.
+func (h *Histogram) Merge(o *Distribution) {
+	h.mu.Lock()
+	h.d.merge(o)
+	h.mu.Unlock()
+}
+
+// Count returns the number of recorded samples.
+func (h *Histogram) Count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return int(h.d.Count)
+}
+
+// Distribution returns a copy of the histogram's current state.
+func (h *Histogram) Distribution() Distribution {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.d.clone()
+}
+
+// Stats computes percentiles and summary statistics.
+func (h *Histogram) Stats() LatencyStats {
+	d := h.Distribution()
+	return d.Stats()
+}
+
+// ---------- Distribution ----------
+
+// Distribution is the serializable state of a Histogram. It travels in
+// LatencyStats so that snapshots from separate pitstorm instances, or
+// from separate windows of one run, can be merged without losing
+// percentile accuracy. Counts is dense from bucket Offset upwards.
+type Distribution struct {
+	Count  int64   `json:"count"`
+	Zero   int64   `json:"zero,omitempty"` // samples below the smallest bucket
+	Offset int     `json:"offset"`
+	Counts []int64 `json:"counts"`
+	Sum    float64 `json:"sumMs"`
+	// M2 is the sum of squared deviations from the mean (Welford), which
+	// merges exactly where a running sum of squares would lose precision.
+	M2  float64 `json:"m2"`
+	Min float64 `json:"minMs"`
+	Max float64 `json:"maxMs"`
+}
+
+func (d *Distribution) add(v float64) {
+	if d.Count == 0 || v < d.Min {
+		d.Min = v
+	}
+	if d.Count == 0 || v > d.Max {
+		d.Max = v
+	}
+	var mean float64
+	if d.Count > 0 {
+		mean = d.Sum / float64(d.Count)
+	}
+	d.Count++
+	d.Sum += v
+	d.M2 += (v - mean) * (v - d.Sum/float64(d.Count))
+
+	if v < minTrackableMs {
+		d.Zero++
+		return
+	}
+	d.addBucket(min(bucketIndex(v), maxIndex), 1)
+}
+
+// addBucket adds n to bucket i, growing Counts to cover it.
+func (d *Distribution) addBucket(i int, n int64) {
+	switch {
+	case len(d.Counts) == 0:
+		d.Offset = i
+		d.Counts = []int64{0}
+	case i < d.Offset:
+		grown := make([]int64, d.Offset-i+len(d.Counts))
+		copy(grown[d.Offset-i:], d.Counts)
+		d.Counts, d.Offset = grown, i
+	case i >= d.Offset+len(d.Counts):
+		d.Counts = append(d.Counts, make([]int64, i-d.Offset-len(d.Counts)+1)...)
+	}
+	d.Counts[i-d.Offset] += n
+}
+
+// bucket returns the count in bucket i.
+func (d *Distribution) bucket(i int) int64 {
+	if i < d.Offset || i >= d.Offset+len(d.Counts) {
+		return 0
+	}
+	return d.Counts[i-d.Offset]
+}
+
+func (d *Distribution) clone() Distribution {
+	out := *d
+	out.Counts = append([]int64(nil), d.Counts...)
+	return out
+}
+
+// merge adds o's samples to d.
+func (d *Distribution) merge(o *Distribution) {
+	if o == nil || o.Count == 0 {
+		return
+	}
+	if d.Count == 0 {
+		*d = o.clone()
+		return
+	}
+	n := float64(d.Count + o.Count)
+	delta := o.Sum/float64(o.Count) - d.Sum/float64(d.Count)
+	d.M2 += o.M2 + delta*delta*float64(d.Count)*float64(o.Count)/n
+	d.Count += o.Count
+	d.Zero += o.Zero
+	d.Sum += o.Sum
+	d.Min = math.Min(d.Min, o.Min)
+	d.Max = math.Max(d.Max, o.Max)
+	for j, c := range o.Counts {
+		if c > 0 {
+			d.addBucket(o.Offset+j, c)
+		}
+	}
+}
+
+// since returns the samples recorded in d but not in base, an earlier
+// state of the same histogram. Min and Max of the window are taken from
+// its outermost buckets, so they carry the usual bucket error.
+func (d *Distribution) since(base *Distribution) Distribution {
+	if base.Count == 0 {
+		return d.clone()
+	}
+	n := d.Count - base.Count
+	if n <= 0 {
+		return Distribution{}
+	}
+	out := Distribution{
+		Count: n,
+		Zero:  d.Zero - base.Zero,
+		Sum:   d.Sum - base.Sum,
+	}
+	delta := out.Sum/float64(n) - base.Sum/float64(base.Count)
+	out.M2 = max(0, d.M2-base.M2-delta*delta*float64(base.Count)*float64(n)/float64(d.Count))
+
+	lo, hi := 0, -1
+	for j, c := range d.Counts {
+		i := d.Offset + j
+		if w := c - base.bucket(i); w > 0 {
+			out.addBucket(i, w)
+			if hi < 0 {
+				lo = i
+			}
+			hi = i
+		}
+	}
+	out.Min, out.Max = d.clamp(0), d.clamp(0)
+	if hi >= 0 {
+		if out.Zero == 0 {
+			out.Min = d.clamp(bucketValue(lo))
+		}
+		out.Max = d.clamp(bucketValue(hi))
+	}
+	return out
+}
+
+// clamp limits v to the recorded range.
+func (d *Distribution) clamp(v float64) float64 {
+	return math.Max(d.Min, math.Min(d.Max, v))
+}
+
+// valueAt returns the value of the k-th smallest sample (0-based).
+func (d *Distribution) valueAt(k int64) float64 {
+	if k < d.Zero {
+		return d.clamp(0)
+	}
+	seen := d.Zero
+	for j, c := range d.Counts {
+		seen += c
+		if k < seen {
+			return d.clamp(bucketValue(d.Offset + j))
+		}
+	}
+	return d.Max
+}
+
+// Quantile returns the q-th quantile (0.0-1.0), interpolating linearly
+// between the samples either side of the rank.
+func (d *Distribution) Quantile(q float64) float64 {
+	if d.Count == 0 {
+		return 0
+	}
+	rank := q * float64(d.Count-1)
+	lower := int64(math.Floor(rank))
+	if lower+1 >= d.Count {
+		return d.valueAt(d.Count - 1)
+	}
+	lo, hi := d.valueAt(lower), d.valueAt(lower+1)
+	return lo + (rank-float64(lower))*(hi-lo)
+}
+
+// CountAbove returns the number of samples in buckets wholly above v.
+// The bucket holding v is left out, so the count is exact up to the
+// usual bucket error around v.
+func (d *Distribution) CountAbove(v float64) int64 {
+	k := minIndex - 1
+	if v >= minTrackableMs {
+		k = min(bucketIndex(v), maxIndex)
+	}
+	var n int64
+	for j, c := range d.Counts {
+		if d.Offset+j > k {
+			n += c
+		}
+	}
+	return n
+}
+
+// Stats computes percentiles and summary statistics. The returned
+// stats carry a copy of d so they can be merged exactly.
+func (d *Distribution) Stats() LatencyStats {
+	if d.Count == 0 {
+		return LatencyStats{}
+	}
+	var stdDev float64
+	if d.Count > 1 {
+		stdDev = math.Sqrt(d.M2 / float64(d.Count-1))
+	}
+	dist := d.clone()
+	return LatencyStats{
+		Count:        int(d.Count),
+		P50:          d.Quantile(0.50),
+		P95:          d.Quantile(0.95),
+		P99:          d.Quantile(0.99),
+		Min:          d.Min,
+		Max:          d.Max,
+		Mean:         d.Sum / float64(d.Count),
+		StdDev:       stdDev,
+		Distribution: &dist,
+	}
+}
+
+// buckets returns cumulative counts for each upper bound in boundsMs
+// (ascending). A bucket straddling a bound counts towards it, so counts
+// near a bound carry the usual bucket error.
+func (d *Distribution) buckets(boundsMs []float64) []int64 {
+	cumulative := make([]int64, len(boundsMs))
+	if len(cumulative) > 0 {
+		cumulative[0] = d.Zero
+	}
+	for j, c := range d.Counts {
+		if c == 0 {
+			continue
+		}
+		// Tolerate rounding for samples that fall exactly on a bound.
+		lower := bucketLower(d.Offset+j) * (1 - 1e-9)
+		k := sort.Search(len(boundsMs), func(k int) bool { return boundsMs[k] > lower })
+		if k < len(cumulative) {
+			cumulative[k] += c
+		}
+	}
+	for i := 1; i < len(cumulative); i++ {
+		cumulative[i] += cumulative[i-1]
+	}
+	return cumulative
+}
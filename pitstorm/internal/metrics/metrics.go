@@ -44,16 +44,33 @@ type Collector struct {
 	// Active workers gauge.
 	activeWorkers atomic.Int64
 
-	// Active SSE streams gauge + peak watermark.
+	// Active SSE streams gauge + peak watermark, for the whole run and
+	// for the current timeline interval.
 	activeStreams atomic.Int64
 	peakStreams   atomic.Int64
+	intervalPeak  atomic.Int64
 
 	// SSE stream-level errors (stream parsed OK but contained an error event).
 	streamErrors atomic.Int64
 
+	// Streams that completed but failed transcript validation.
+	validationErrors atomic.Int64
+
 	// Per-endpoint first-byte latency histograms.
 	firstByteMu sync.Mutex
 	firstBytes  map[string]*Histogram
+
+	// Dispatched actions per persona.
+	actionMu sync.Mutex
+	actions  map[string]map[string]int64
+
+	// Stream health per model (see RecordTurn).
+	streamMu       sync.Mutex
+	streams        map[string]*modelStreams
+	stallThreshold time.Duration
+
+	// Per-interval history, once WatchTimeline has started.
+	timeline timeline
 }
 
 // NewCollector creates a metrics collector and records the start time.
@@ -64,6 +81,10 @@ func NewCollector() *Collector {
 		statuses:   make(map[int]*atomic.Int64),
 		errCounts:  make(map[string]*atomic.Int64),
 		firstBytes: make(map[string]*Histogram),
+		actions:    make(map[string]map[string]int64),
+
+		streams:        make(map[string]*modelStreams),
+		stallThreshold: DefaultStallThreshold,
 	}
 }
 
@@ -152,13 +173,19 @@ func (c *Collector) WorkerDone() {
 	c.activeWorkers.Add(-1)
 }
 
-// StreamStart increments the active stream gauge and updates the peak watermark.
+// StreamStart increments the active stream gauge and updates the peak watermarks.
 func (c *Collector) StreamStart() {
 	n := c.activeStreams.Add(1)
+	raisePeak(&c.peakStreams, n)
+	raisePeak(&c.intervalPeak, n)
+}
+
+// raisePeak raises the watermark to n if n is higher.
+func raisePeak(peak *atomic.Int64, n int64) {
 	for {
-		peak := c.peakStreams.Load()
-		if n <= peak || c.peakStreams.CompareAndSwap(peak, n) {
-			break
+		p := peak.Load()
+		if n <= p || peak.CompareAndSwap(p, n) {
+			return
 		}
 	}
 }
@@ -173,6 +200,12 @@ func (c *Collector) RecordStreamError() {
 	c.streamErrors.Add(1)
 }
 
+// RecordValidationError increments the counter of streams that failed
+// transcript validation (see the transcript package).
+func (c *Collector) RecordValidationError() {
+	c.validationErrors.Add(1)
+}
+
 // RecordFirstByte records a time-to-first-byte duration for the given endpoint.
 func (c *Collector) RecordFirstByte(endpoint string, d time.Duration) {
 	c.firstByteMu.Lock()
@@ -185,6 +218,18 @@ func (c *Collector) RecordFirstByte(endpoint string, d time.Duration) {
 	h.Add(d)
 }
 
+// RecordAction counts an action dispatched for a persona.
+func (c *Collector) RecordAction(persona, action string) {
+	c.actionMu.Lock()
+	defer c.actionMu.Unlock()
+	m, ok := c.actions[persona]
+	if !ok {
+		m = make(map[string]int64)
+		c.actions[persona] = m
+	}
+	m[action]++
+}
+
 // ---------- Snapshot ----------
 
 // Snapshot is a point-in-time copy of all metrics, safe to serialize.
@@ -203,12 +248,17 @@ type Snapshot struct {
 	ActiveStreams     int64                   `json:"activeStreams"`
 	ActiveStreamsPeak int64                   `json:"activeStreamsPeak"`
 	StreamErrors      int64                   `json:"streamErrors"`
+	ValidationErrors  int64                   `json:"validationErrors"`
 	Throughput        float64                 `json:"throughputRps"`
 	ErrorRate         float64                 `json:"errorRate"`
 	Latencies         map[string]LatencyStats `json:"latencies"`
 	FirstBytes        map[string]LatencyStats `json:"firstBytes,omitempty"`
 	StatusCodes       map[int]int64           `json:"statusCodes"`
 	ErrorsByEP        map[string]int64        `json:"errorsByEndpoint"`
+	// Actions counts dispatched actions by persona, then action.
+	Actions map[string]map[string]int64 `json:"actions,omitempty"`
+	// Streams is the streaming health of bouts, by model.
+	Streams map[string]StreamStats `json:"streams,omitempty"`
 }
 
 // LatencyStats holds computed percentiles for a latency histogram.
@@ -220,6 +270,13 @@ type LatencyStats struct {
 	Min   float64 `json:"minMs"`
 	Max   float64 `json:"maxMs"`
 	Mean  float64 `json:"meanMs"`
+	// StdDev is the sample standard deviation, used by `compare` to
+	// test whether a latency change is statistically meaningful.
+	StdDev float64 `json:"stdDevMs,omitempty"`
+	// Distribution is the histogram the stats were computed from. Merge
+	// uses it to combine snapshots exactly; stats without one (e.g. from
+	// older output files) are merged approximately.
+	Distribution *Distribution `json:"distribution,omitempty"`
 }
 
 // Snapshot takes a consistent point-in-time copy of all metrics.
@@ -268,6 +325,21 @@ func (c *Collector) Snapshot() Snapshot {
 	}
 	c.firstByteMu.Unlock()
 
+	// Copy action counts.
+	c.actionMu.Lock()
+	actions := make(map[string]map[string]int64, len(c.actions))
+	for p, m := range c.actions {
+		actions[p] = make(map[string]int64, len(m))
+		for a, n := range m {
+			actions[p][a] = n
+		}
+	}
+	c.actionMu.Unlock()
+
+	c.streamMu.Lock()
+	streams := c.streamStats()
+	c.streamMu.Unlock()
+
 	return Snapshot{
 		Elapsed:           elapsed,
 		Requests:          reqs,
@@ -283,98 +355,237 @@ func (c *Collector) Snapshot() Snapshot {
 		ActiveStreams:     c.activeStreams.Load(),
 		ActiveStreamsPeak: c.peakStreams.Load(),
 		StreamErrors:      c.streamErrors.Load(),
+		ValidationErrors:  c.validationErrors.Load(),
 		Throughput:        throughput,
 		ErrorRate:         errRate,
 		Latencies:         latencies,
 		FirstBytes:        fb,
 		StatusCodes:       statuses,
 		ErrorsByEP:        errsByEP,
+		Actions:           actions,
+		Streams:           streams,
 	}
 }
 
-// JSON returns the snapshot as indented JSON.
-func (s Snapshot) JSON() ([]byte, error) {
-	return json.MarshalIndent(s, "", "  ")
-}
-
-// ---------- Histogram ----------
+// ---------- Windows ----------
 
-// Histogram collects time.Duration samples and computes percentiles.
-// Thread-safe for concurrent Add calls.
-type Histogram struct {
-	mu      sync.Mutex
-	samples []float64 // milliseconds
+// Mark is a position in a Collector's history. Since reports only what
+// was recorded after it, e.g. for one step of an adaptive search.
+type Mark struct {
+	at         time.Time
+	base       Snapshot
+	latencies  map[string]Distribution
+	firstBytes map[string]Distribution
+	streams    map[string]streamState
 }
 
-// NewHistogram creates an empty Histogram.
-func NewHistogram() *Histogram {
-	return &Histogram{
-		samples: make([]float64, 0, 256),
+// Mark records the collector's current position.
+func (c *Collector) Mark() Mark {
+	m := Mark{
+		at:         time.Now(),
+		base:       c.Snapshot(),
+		latencies:  make(map[string]Distribution),
+		firstBytes: make(map[string]Distribution),
+	}
+	c.latencyMu.Lock()
+	for ep, h := range c.latencies {
+		m.latencies[ep] = h.Distribution()
+	}
+	c.latencyMu.Unlock()
+	c.firstByteMu.Lock()
+	for ep, h := range c.firstBytes {
+		m.firstBytes[ep] = h.Distribution()
 	}
+	c.firstByteMu.Unlock()
+	c.streamMu.Lock()
+	m.streams = c.markStreams()
+	c.streamMu.Unlock()
+	return m
 }
 
-// Add records a duration sample.
-func (h *Histogram) Add(d time.Duration) {
-	ms := float64(d.Microseconds()) / 1000.0
-	h.mu.Lock()
-	h.samples = append(h.samples, ms)
-	h.mu.Unlock()
-}
+// Since returns a snapshot of activity after m: counters are deltas,
+// latency stats cover only samples recorded since, and Throughput and
+// ErrorRate are computed over the window. Gauges are current values.
+func (c *Collector) Since(m Mark) Snapshot {
+	cur := c.Snapshot()
+	b := m.base
+	out := Snapshot{
+		Elapsed:           time.Since(m.at),
+		Requests:          cur.Requests - b.Requests,
+		Successes:         cur.Successes - b.Successes,
+		Errors:            cur.Errors - b.Errors,
+		Retries:           cur.Retries - b.Retries,
+		RateLimits:        cur.RateLimits - b.RateLimits,
+		BoutStarts:        cur.BoutStarts - b.BoutStarts,
+		BoutsDone:         cur.BoutsDone - b.BoutsDone,
+		TotalDeltas:       cur.TotalDeltas - b.TotalDeltas,
+		TotalChars:        cur.TotalChars - b.TotalChars,
+		ActiveWorkers:     cur.ActiveWorkers,
+		ActiveStreams:     cur.ActiveStreams,
+		ActiveStreamsPeak: cur.ActiveStreamsPeak,
+		StreamErrors:      cur.StreamErrors - b.StreamErrors,
+		ValidationErrors:  cur.ValidationErrors - b.ValidationErrors,
+		Latencies:         make(map[string]LatencyStats),
+		FirstBytes:        make(map[string]LatencyStats),
+		StatusCodes:       make(map[int]int64),
+		ErrorsByEP:        make(map[string]int64),
+		Actions:           make(map[string]map[string]int64),
+	}
+	if secs := out.Elapsed.Seconds(); secs > 0 {
+		out.Throughput = float64(out.Requests) / secs
+	}
+	if out.Requests > 0 {
+		out.ErrorRate = float64(out.Errors) / float64(out.Requests)
+	}
+	for code, n := range cur.StatusCodes {
+		if d := n - b.StatusCodes[code]; d > 0 {
+			out.StatusCodes[code] = d
+		}
+	}
+	for ep, n := range cur.ErrorsByEP {
+		if d := n - b.ErrorsByEP[ep]; d > 0 {
+			out.ErrorsByEP[ep] = d
+		}
+	}
+	for p, acts := range cur.Actions {
+		for a, n := range acts {
+			if d := n - b.Actions[p][a]; d > 0 {
+				addAction(out.Actions, p, a, d)
+			}
+		}
+	}
 
-// Count returns the number of recorded samples.
-func (h *Histogram) Count() int {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	return len(h.samples)
+	c.latencyMu.Lock()
+	windowStats(out.Latencies, c.latencies, m.latencies)
+	c.latencyMu.Unlock()
+	c.firstByteMu.Lock()
+	windowStats(out.FirstBytes, c.firstBytes, m.firstBytes)
+	c.firstByteMu.Unlock()
+	c.streamMu.Lock()
+	out.Streams = c.streamsSince(m.streams)
+	c.streamMu.Unlock()
+	return out
 }
 
-// Stats computes percentiles and summary statistics.
-func (h *Histogram) Stats() LatencyStats {
-	h.mu.Lock()
-	if len(h.samples) == 0 {
-		h.mu.Unlock()
-		return LatencyStats{}
+// windowStats fills out with stats for the samples each histogram in hs
+// recorded after its state in base.
+func windowStats(out map[string]LatencyStats, hs map[string]*Histogram, base map[string]Distribution) {
+	for ep, h := range hs {
+		b := base[ep]
+		cur := h.Distribution()
+		w := cur.since(&b)
+		if w.Count > 0 {
+			out[ep] = w.Stats()
+		}
 	}
-	// Copy to avoid holding the lock during sort.
-	sorted := make([]float64, len(h.samples))
-	copy(sorted, h.samples)
-	h.mu.Unlock()
+}
 
-	sort.Float64s(sorted)
-	n := len(sorted)
+// JSON returns the snapshot as indented JSON.
+func (s Snapshot) JSON() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
 
-	var sum float64
-	for _, v := range sorted {
-		sum += v
+// Merge combines snapshots from several pitstorm instances into one.
+// Counters and throughput are summed and Elapsed is the longest run.
+// ActiveStreamsPeak is summed too, which makes it an upper bound: the
+// instances need not have peaked at the same moment.
+// Latency stats are merged through their Distributions, so percentiles
+// keep the histogram's bounded error. Stats without a Distribution fall
+// back to count-weighted average P50/P95/P99 and Mean; Min and Max are
+// exact either way.
+func Merge(snaps ...Snapshot) Snapshot {
+	out := Snapshot{
+		Latencies:   make(map[string]LatencyStats),
+		FirstBytes:  make(map[string]LatencyStats),
+		StatusCodes: make(map[int]int64),
+		ErrorsByEP:  make(map[string]int64),
+		Actions:     make(map[string]map[string]int64),
+		Streams:     make(map[string]StreamStats),
 	}
+	for _, s := range snaps {
+		out.Elapsed = max(out.Elapsed, s.Elapsed)
+		out.Requests += s.Requests
+		out.Successes += s.Successes
+		out.Errors += s.Errors
+		out.Retries += s.Retries
+		out.RateLimits += s.RateLimits
+		out.BoutStarts += s.BoutStarts
+		out.BoutsDone += s.BoutsDone
+		out.TotalDeltas += s.TotalDeltas
+		out.TotalChars += s.TotalChars
+		out.ActiveWorkers += s.ActiveWorkers
+		out.ActiveStreams += s.ActiveStreams
+		out.ActiveStreamsPeak += s.ActiveStreamsPeak
+		out.StreamErrors += s.StreamErrors
+		out.ValidationErrors += s.ValidationErrors
+		out.Throughput += s.Throughput
+		for code, n := range s.StatusCodes {
+			out.StatusCodes[code] += n
+		}
+		for ep, n := range s.ErrorsByEP {
+			out.ErrorsByEP[ep] += n
+		}
+		for p, acts := range s.Actions {
+			for a, n := range acts {
+				addAction(out.Actions, p, a, n)
+			}
+		}
+		for ep, ls := range s.Latencies {
+			out.Latencies[ep] = mergeLatency(out.Latencies[ep], ls)
+		}
+		for ep, ls := range s.FirstBytes {
+			out.FirstBytes[ep] = mergeLatency(out.FirstBytes[ep], ls)
+		}
+		for model, ss := range s.Streams {
+			out.Streams[model] = mergeStreams(out.Streams[model], ss)
+		}
+	}
+	if out.Requests > 0 {
+		out.ErrorRate = float64(out.Errors) / float64(out.Requests)
+	}
+	return out
+}
 
-	return LatencyStats{
-		Count: n,
-		P50:   percentile(sorted, 0.50),
-		P95:   percentile(sorted, 0.95),
-		P99:   percentile(sorted, 0.99),
-		Min:   sorted[0],
-		Max:   sorted[n-1],
-		Mean:  sum / float64(n),
+// addAction adds n to a persona's action count.
+func addAction(m map[string]map[string]int64, persona, action string, n int64) {
+	if m[persona] == nil {
+		m[persona] = make(map[string]int64)
 	}
+	m[persona][action] += n
 }
 
-// percentile computes the p-th percentile (0.0-1.0) using linear interpolation.
-func percentile(sorted []float64, p float64) float64 {
-	if len(sorted) == 0 {
-		return 0
+// mergeLatency combines two latency summaries (see Merge for caveats).
+func mergeLatency(a, b LatencyStats) LatencyStats {
+	if a.Count == 0 {
+		return b
+	}
+	if b.Count == 0 {
+		return a
 	}
-	if len(sorted) == 1 {
-		return sorted[0]
+	if a.Distribution != nil && b.Distribution != nil {
+		d := a.Distribution.clone()
+		d.merge(b.Distribution)
+		return d.Stats()
 	}
-	rank := p * float64(len(sorted)-1)
-	lower := int(math.Floor(rank))
-	upper := lower + 1
-	if upper >= len(sorted) {
-		return sorted[len(sorted)-1]
+	n := float64(a.Count + b.Count)
+	wa, wb := float64(a.Count)/n, float64(b.Count)/n
+	mean := a.Mean*wa + b.Mean*wb
+
+	// Pooled variance: within-group sums of squares plus the spread of
+	// the group means around the combined mean.
+	ss := float64(a.Count-1)*a.StdDev*a.StdDev + float64(a.Count)*(a.Mean-mean)*(a.Mean-mean) +
+		float64(b.Count-1)*b.StdDev*b.StdDev + float64(b.Count)*(b.Mean-mean)*(b.Mean-mean)
+
+	return LatencyStats{
+		Count:  a.Count + b.Count,
+		P50:    a.P50*wa + b.P50*wb,
+		P95:    a.P95*wa + b.P95*wb,
+		P99:    a.P99*wa + b.P99*wb,
+		Min:    math.Min(a.Min, b.Min),
+		Max:    math.Max(a.Max, b.Max),
+		Mean:   mean,
+		StdDev: math.Sqrt(ss / (n - 1)),
 	}
-	frac := rank - float64(lower)
-	return sorted[lower] + frac*(sorted[upper]-sorted[lower])
 }
 
 // ---------- Terminal Reporter ----------
@@ -391,7 +602,7 @@ func FormatSummary(s Snapshot) string {
 	fmt.Fprintf(&b, "  Rate Limits:   %d\n", s.RateLimits)
 	fmt.Fprintf(&b, "  Bouts:         %d started, %d completed\n", s.BoutStarts, s.BoutsDone)
 	fmt.Fprintf(&b, "  Stream Deltas: %d (%d chars)\n", s.TotalDeltas, s.TotalChars)
-	fmt.Fprintf(&b, "  Streams:       %d active, %d peak, %d errors\n", s.ActiveStreams, s.ActiveStreamsPeak, s.StreamErrors)
+	fmt.Fprintf(&b, "  Streams:       %d active, %d peak, %d errors, %d invalid\n", s.ActiveStreams, s.ActiveStreamsPeak, s.StreamErrors, s.ValidationErrors)
 	fmt.Fprintf(&b, "  Workers:       %d active\n", s.ActiveWorkers)
 
 	if len(s.StatusCodes) > 0 {
@@ -434,6 +645,10 @@ func FormatSummary(s Snapshot) string {
 		}
 	}
 
+	if len(s.Streams) > 0 {
+		formatStreams(&b, s.Streams)
+	}
+
 	if len(s.ErrorsByEP) > 0 {
 		fmt.Fprintf(&b, "\n  Errors by Endpoint:\n")
 		eps := make([]string, 0, len(s.ErrorsByEP))
@@ -448,3 +663,110 @@ func FormatSummary(s Snapshot) string {
 
 	return b.String()
 }
+
+// FormatSideBySide renders two runs over the same traffic — an A/B run's
+// targets — as side-by-side request, error, and latency tables, with
+// the relative change of B against A.
+func FormatSideBySide(labelA, labelB string, a, b Snapshot) string {
+	var bld strings.Builder
+	fmt.Fprintf(&bld, "\n  %-24s %14s %14s %9s\n", "", labelA, labelB, "B vs A")
+	row := func(name, va, vb string, fa, fb float64) {
+		fmt.Fprintf(&bld, "  %-24s %14s %14s %9s\n", name, va, vb, relChange(fa, fb))
+	}
+	row("Requests", fmt.Sprint(a.Requests), fmt.Sprint(b.Requests), float64(a.Requests), float64(b.Requests))
+	row("Throughput (req/s)", fmt.Sprintf("%.1f", a.Throughput), fmt.Sprintf("%.1f", b.Throughput), a.Throughput, b.Throughput)
+	row("Errors", fmt.Sprint(a.Errors), fmt.Sprint(b.Errors), float64(a.Errors), float64(b.Errors))
+	row("Error rate", fmt.Sprintf("%.2f%%", a.ErrorRate*100), fmt.Sprintf("%.2f%%", b.ErrorRate*100), a.ErrorRate, b.ErrorRate)
+	row("Rate limits", fmt.Sprint(a.RateLimits), fmt.Sprint(b.RateLimits), float64(a.RateLimits), float64(b.RateLimits))
+	row("Bouts completed", fmt.Sprint(a.BoutsDone), fmt.Sprint(b.BoutsDone), float64(a.BoutsDone), float64(b.BoutsDone))
+	row("Stream errors", fmt.Sprint(a.StreamErrors), fmt.Sprint(b.StreamErrors), float64(a.StreamErrors), float64(b.StreamErrors))
+
+	if eps := endpointUnion(latencyEndpoints(a.Latencies), latencyEndpoints(b.Latencies)); len(eps) > 0 {
+		fmt.Fprintf(&bld, "\n  Latencies (p50 / p95 / p99 ms):\n")
+		for _, ep := range eps {
+			la, lb := a.Latencies[ep], b.Latencies[ep]
+			fmt.Fprintf(&bld, "    %-26s %-20s %-20s p95 %s\n", ep, formatPercentiles(la), formatPercentiles(lb), relChange(la.P95, lb.P95))
+		}
+	}
+
+	if eps := endpointUnion(errorEndpoints(a.ErrorsByEP), errorEndpoints(b.ErrorsByEP)); len(eps) > 0 {
+		fmt.Fprintf(&bld, "\n  Errors by Endpoint:\n")
+		for _, ep := range eps {
+			fmt.Fprintf(&bld, "    %-26s %14d %14d\n", ep, a.ErrorsByEP[ep], b.ErrorsByEP[ep])
+		}
+	}
+
+	codes := make([]int, 0, len(a.StatusCodes)+len(b.StatusCodes))
+	for code := range a.StatusCodes {
+		codes = append(codes, code)
+	}
+	for code := range b.StatusCodes {
+		if _, ok := a.StatusCodes[code]; !ok {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) > 0 {
+		sort.Ints(codes)
+		fmt.Fprintf(&bld, "\n  Status Codes:\n")
+		for _, code := range codes {
+			fmt.Fprintf(&bld, "    %-26d %14d %14d\n", code, a.StatusCodes[code], b.StatusCodes[code])
+		}
+	}
+	return bld.String()
+}
+
+// formatPercentiles renders p50/p95/p99, or a dash without samples.
+func formatPercentiles(ls LatencyStats) string {
+	if ls.Count == 0 {
+		return "—"
+	}
+	return fmt.Sprintf("%.0f / %.0f / %.0f", ls.P50, ls.P95, ls.P99)
+}
+
+// relChange formats b's change relative to a, e.g. "+12.5%", or "="
+// when it rounds to nothing.
+func relChange(a, b float64) string {
+	switch {
+	case a == b:
+		return "="
+	case a == 0:
+		return "new"
+	}
+	pct := (b - a) / a * 100
+	if math.Abs(pct) < 0.05 {
+		return "="
+	}
+	return fmt.Sprintf("%+.1f%%", pct)
+}
+
+// endpointUnion returns the endpoints in either set of names, sorted.
+func endpointUnion(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var eps []string
+	for _, ep := range append(a, b...) {
+		if !seen[ep] {
+			seen[ep] = true
+			eps = append(eps, ep)
+		}
+	}
+	sort.Strings(eps)
+	return eps
+}
+
+// latencyEndpoints returns the endpoints with latency data.
+func latencyEndpoints(m map[string]LatencyStats) []string {
+	eps := make([]string, 0, len(m))
+	for ep := range m {
+		eps = append(eps, ep)
+	}
+	return eps
+}
+
+// errorEndpoints returns the endpoints with errors.
+func errorEndpoints(m map[string]int64) []string {
+	eps := make([]string, 0, len(m))
+	for ep := range m {
+		eps = append(eps, ep)
+	}
+	return eps
+}
@@ -38,6 +38,7 @@ func TestAtomicCounters(t *testing.T) {
 	c.RecordBoutStart()
 	c.RecordBoutDone()
 	c.RecordStreamMetrics(100, 5000)
+	c.RecordValidationError()
 
 	s := c.Snapshot()
 
@@ -68,6 +69,9 @@ func TestAtomicCounters(t *testing.T) {
 	if s.TotalChars != 5000 {
 		t.Errorf("TotalChars = %d, want 5000", s.TotalChars)
 	}
+	if s.ValidationErrors != 1 {
+		t.Errorf("ValidationErrors = %d, want 1", s.ValidationErrors)
+	}
 }
 
 func TestWorkerGauge(t *testing.T) {
@@ -302,6 +306,10 @@ func TestHistogramPercentiles(t *testing.T) {
 	if math.Abs(s.Mean-50.5) > 0.5 {
 		t.Errorf("Mean = %f, want ~50.5", s.Mean)
 	}
+	// Sample standard deviation of 1..100 = 29.01.
+	if math.Abs(s.StdDev-29.01) > 0.01 {
+		t.Errorf("StdDev = %f, want ~29.01", s.StdDev)
+	}
 }
 
 func TestHistogramCount(t *testing.T) {
@@ -313,21 +321,82 @@ func TestHistogramCount(t *testing.T) {
 	}
 }
 
-func TestPercentileEdgeCases(t *testing.T) {
-	// Empty slice.
-	if got := percentile(nil, 0.5); got != 0 {
-		t.Errorf("percentile(nil, 0.5) = %f, want 0", got)
+func TestHistogramQuantileEdgeCases(t *testing.T) {
+	var empty Distribution
+	if got := empty.Quantile(0.5); got != 0 {
+		t.Errorf("empty Quantile(0.5) = %f, want 0", got)
+	}
+
+	h := NewHistogram()
+	h.Add(42 * time.Millisecond)
+	if got := h.Stats().P99; got != 42 {
+		t.Errorf("single-sample P99 = %f, want 42", got)
+	}
+
+	h.Add(0)
+	h.Add(10 * time.Millisecond)
+	h.Add(20 * time.Millisecond)
+	s := h.Stats()
+	if s.Min != 0 || s.Max != 42 {
+		t.Errorf("Min/Max = %f/%f, want 0/42", s.Min, s.Max)
+	}
+	if math.Abs(s.P50-15) > 15*RelativeError {
+		t.Errorf("P50 = %f, want ~15", s.P50)
+	}
+}
+
+func TestDistributionCountAbove(t *testing.T) {
+	h := NewHistogram()
+	h.Add(0)
+	for i := 1; i <= 100; i++ {
+		h.Add(time.Duration(i) * time.Millisecond)
+	}
+	d := h.Distribution()
+	if got := d.CountAbove(0); got != 100 {
+		t.Errorf("CountAbove(0) = %d, want 100", got)
+	}
+	if got := d.CountAbove(90); got != 10 {
+		t.Errorf("CountAbove(90) = %d, want 10", got)
+	}
+	if got := d.CountAbove(d.Quantile(0.95)); got != 5 {
+		t.Errorf("CountAbove(p95) = %d, want 5", got)
 	}
+	if got := d.CountAbove(1000); got != 0 {
+		t.Errorf("CountAbove(1000) = %d, want 0", got)
+	}
+}
 
-	// Single element.
-	if got := percentile([]float64{42}, 0.99); got != 42 {
-		t.Errorf("percentile([42], 0.99) = %f, want 42", got)
+func TestHistogramRelativeError(t *testing.T) {
+	h := NewHistogram()
+	// Spread samples over seven orders of magnitude.
+	var samples []float64
+	for v := 0.01; v < 1e5; v *= 1.37 {
+		h.Add(time.Duration(v * float64(time.Millisecond)))
+		samples = append(samples, float64(time.Duration(v*float64(time.Millisecond)).Microseconds())/1000)
+	}
+	d := h.Distribution()
+	for k, want := range samples {
+		if got := d.valueAt(int64(k)); math.Abs(got-want) > want*RelativeError {
+			t.Errorf("sample %d = %f, want %f within %.1f%%", k, got, want, RelativeError*100)
+		}
+	}
+	if len(d.Counts) > maxIndex-minIndex+1 {
+		t.Errorf("%d buckets, want at most %d", len(d.Counts), maxIndex-minIndex+1)
 	}
+}
 
-	// Two elements.
-	got := percentile([]float64{10, 20}, 0.5)
-	if math.Abs(got-15) > 0.01 {
-		t.Errorf("percentile([10,20], 0.5) = %f, want 15", got)
+func TestHistogramBoundedMemory(t *testing.T) {
+	h := NewHistogram()
+	for i := 0; i < 100000; i++ {
+		h.Add(time.Duration(100+i%50) * time.Millisecond)
+	}
+	d := h.Distribution()
+	if d.Count != 100000 {
+		t.Errorf("Count = %d, want 100000", d.Count)
+	}
+	// 100-149ms spans ~40 buckets at 0.5% error.
+	if len(d.Counts) > 50 {
+		t.Errorf("%d buckets for a 100-149ms range", len(d.Counts))
 	}
 }
 
@@ -419,6 +488,39 @@ func TestFormatSummary(t *testing.T) {
 	}
 }
 
+func TestFormatSideBySide(t *testing.T) {
+	a := Snapshot{
+		Requests: 200, Errors: 2, ErrorRate: 0.01,
+		Latencies:   map[string]LatencyStats{"/browse": {Count: 100, P50: 40, P95: 80, P99: 120}},
+		StatusCodes: map[int]int64{200: 198, 500: 2},
+		ErrorsByEP:  map[string]int64{"/browse": 2},
+	}
+	b := Snapshot{
+		Requests: 200, Errors: 5, ErrorRate: 0.025,
+		Latencies: map[string]LatencyStats{
+			"/browse":       {Count: 100, P50: 44, P95: 100, P99: 150},
+			"/api/run-bout": {Count: 3, P50: 900, P95: 1200, P99: 1300},
+		},
+		StatusCodes: map[int]int64{200: 195, 502: 5},
+		ErrorsByEP:  map[string]int64{"/api/run-bout": 5},
+	}
+	report := FormatSideBySide("prod", "preview", a, b)
+
+	checks := []string{
+		"prod", "preview", "B vs A",
+		"Errors                                2              5   +150.0%",
+		"/browse                    40 / 80 / 120        44 / 100 / 150       p95 +25.0%",
+		"/api/run-bout              —                    900 / 1200 / 1300    p95 new",
+		"500                                     2              0",
+		"502                                     0              5",
+	}
+	for _, check := range checks {
+		if !strings.Contains(report, check) {
+			t.Errorf("report missing %q:\n%s", check, report)
+		}
+	}
+}
+
 func TestFormatSummaryEmpty(t *testing.T) {
 	c := NewCollector()
 	s := c.Snapshot()
@@ -433,3 +535,214 @@ func TestFormatSummaryEmpty(t *testing.T) {
 		t.Error("empty report should not have Status Codes section")
 	}
 }
+
+func TestMerge(t *testing.T) {
+	a := Snapshot{
+		Elapsed: 10 * time.Second, Requests: 100, Errors: 10, Throughput: 10,
+		StatusCodes: map[int]int64{200: 90, 500: 10},
+		ErrorsByEP:  map[string]int64{"/browse": 10},
+		Latencies:   map[string]LatencyStats{"/browse": {Count: 30, P50: 10, P95: 20, P99: 30, Min: 1, Max: 50, Mean: 12}},
+	}
+	b := Snapshot{
+		Elapsed: 12 * time.Second, Requests: 100, Errors: 30, Throughput: 8,
+		StatusCodes: map[int]int64{200: 70, 429: 30},
+		ErrorsByEP:  map[string]int64{"/browse": 5, "/api/run-bout": 25},
+		Latencies:   map[string]LatencyStats{"/browse": {Count: 10, P50: 30, P95: 40, P99: 50, Min: 2, Max: 90, Mean: 32}},
+	}
+
+	m := Merge(a, b)
+	if m.Elapsed != 12*time.Second {
+		t.Errorf("Elapsed = %v, want 12s", m.Elapsed)
+	}
+	if m.Requests != 200 || m.Errors != 40 {
+		t.Errorf("Requests = %d, Errors = %d", m.Requests, m.Errors)
+	}
+	if m.ErrorRate != 0.2 {
+		t.Errorf("ErrorRate = %f, want 0.2", m.ErrorRate)
+	}
+	if m.Throughput != 18 {
+		t.Errorf("Throughput = %f, want 18", m.Throughput)
+	}
+	if m.StatusCodes[200] != 160 || m.StatusCodes[429] != 30 {
+		t.Errorf("StatusCodes = %v", m.StatusCodes)
+	}
+	if m.ErrorsByEP["/browse"] != 15 || m.ErrorsByEP["/api/run-bout"] != 25 {
+		t.Errorf("ErrorsByEP = %v", m.ErrorsByEP)
+	}
+	ls := m.Latencies["/browse"]
+	if ls.Count != 40 || ls.Min != 1 || ls.Max != 90 {
+		t.Errorf("Latencies[/browse] = %+v", ls)
+	}
+	if ls.P50 != 15 { // (30*10 + 10*30) / 40
+		t.Errorf("P50 = %f, want 15", ls.P50)
+	}
+}
+
+func TestMergeLatencyStdDev(t *testing.T) {
+	lo, hi, all := NewHistogram(), NewHistogram(), NewHistogram()
+	for i := 1; i <= 100; i++ {
+		d := time.Duration(i) * time.Millisecond
+		if i <= 30 {
+			lo.Add(d)
+		} else {
+			hi.Add(d)
+		}
+		all.Add(d)
+	}
+	got := mergeLatency(lo.Stats(), hi.Stats())
+	want := all.Stats()
+	if math.Abs(got.Mean-want.Mean) > 1e-9 || math.Abs(got.StdDev-want.StdDev) > 1e-9 {
+		t.Errorf("merged mean/stddev = %f/%f, want %f/%f", got.Mean, got.StdDev, want.Mean, want.StdDev)
+	}
+}
+
+func TestMergeDistributions(t *testing.T) {
+	a, b, all := NewHistogram(), NewHistogram(), NewHistogram()
+	for i := 1; i <= 1000; i++ {
+		// Instance b sees a much slower tail than instance a.
+		d := time.Duration(i) * time.Millisecond
+		if i%4 == 0 {
+			d *= 20
+			b.Add(d)
+		} else {
+			a.Add(d)
+		}
+		all.Add(d)
+	}
+	sa := Snapshot{Latencies: map[string]LatencyStats{"/x": a.Stats()}}
+	sb := Snapshot{Latencies: map[string]LatencyStats{"/x": b.Stats()}}
+	got := Merge(sa, sb).Latencies["/x"]
+	want := all.Stats()
+	if got.Count != want.Count || got.P50 != want.P50 || got.P95 != want.P95 || got.P99 != want.P99 {
+		t.Errorf("merged = %+v, want %+v", got, want)
+	}
+	if math.Abs(got.StdDev-want.StdDev) > 1e-6 || got.Min != want.Min || got.Max != want.Max {
+		t.Errorf("merged stddev/min/max = %f/%f/%f, want %f/%f/%f", got.StdDev, got.Min, got.Max, want.StdDev, want.Min, want.Max)
+	}
+}
+
+func TestSinceStdDev(t *testing.T) {
+	c := NewCollector()
+	for i := 1; i <= 50; i++ {
+		c.RecordLatency("/a", time.Duration(i*7)*time.Millisecond)
+	}
+	mark := c.Mark()
+	win := NewHistogram()
+	for i := 1; i <= 100; i++ {
+		d := time.Duration(i) * time.Millisecond
+		c.RecordLatency("/a", d)
+		win.Add(d)
+	}
+	got, want := c.Since(mark).Latencies["/a"], win.Stats()
+	if got.Count != 100 || math.Abs(got.Mean-want.Mean) > 1e-9 || math.Abs(got.StdDev-want.StdDev) > 1e-6 {
+		t.Errorf("window = %+v, want %+v", got, want)
+	}
+	if got.P50 != want.P50 || got.P99 != want.P99 {
+		t.Errorf("window P50/P99 = %f/%f, want %f/%f", got.P50, got.P99, want.P50, want.P99)
+	}
+}
+
+func TestSince(t *testing.T) {
+	c := NewCollector()
+	c.RecordRequest()
+	c.RecordError("/a")
+	c.RecordStatus(500)
+	c.RecordLatency("/a", 500*time.Millisecond)
+
+	mark := c.Mark()
+	for i := 0; i < 4; i++ {
+		c.RecordRequest()
+		c.RecordStatus(200)
+		c.RecordLatency("/a", 10*time.Millisecond)
+	}
+	c.RecordLatency("/b", 20*time.Millisecond)
+
+	w := c.Since(mark)
+	if w.Requests != 4 || w.Errors != 0 || w.ErrorRate != 0 {
+		t.Errorf("Requests/Errors/ErrorRate = %d/%d/%f, want 4/0/0", w.Requests, w.Errors, w.ErrorRate)
+	}
+	if w.StatusCodes[200] != 4 || w.StatusCodes[500] != 0 {
+		t.Errorf("StatusCodes = %v", w.StatusCodes)
+	}
+	// A window's Max comes from its top bucket, so it is approximate.
+	if ls := w.Latencies["/a"]; ls.Count != 4 || math.Abs(ls.Max-10) > 10*RelativeError {
+		t.Errorf("Latencies[/a] = %+v, want 4 samples of 10ms", ls)
+	}
+	if ls := w.Latencies["/b"]; ls.Count != 1 {
+		t.Errorf("Latencies[/b] = %+v, want 1 sample", ls)
+	}
+	if len(w.ErrorsByEP) != 0 {
+		t.Errorf("ErrorsByEP = %v, want empty", w.ErrorsByEP)
+	}
+}
+
+func TestRecordAction(t *testing.T) {
+	c := NewCollector()
+	c.RecordAction("lurker", "browse")
+	mark := c.Mark()
+	c.RecordAction("lurker", "browse")
+	c.RecordAction("lurker", "vote")
+	c.RecordAction("whale", "run-bout")
+
+	if got := c.Snapshot().Actions["lurker"]["browse"]; got != 2 {
+		t.Errorf("Snapshot browse = %d, want 2", got)
+	}
+	w := c.Since(mark)
+	if w.Actions["lurker"]["browse"] != 1 || w.Actions["lurker"]["vote"] != 1 || w.Actions["whale"]["run-bout"] != 1 {
+		t.Errorf("Since actions = %v", w.Actions)
+	}
+	m := Merge(w, w)
+	if m.Actions["whale"]["run-bout"] != 2 {
+		t.Errorf("Merge actions = %v", m.Actions)
+	}
+}
+
+func TestRecordTurn(t *testing.T) {
+	c := NewCollector()
+	c.SetStallThreshold(time.Second)
+	model := "claude-haiku-4-5-20251001"
+
+	if n := c.RecordTurn(model, Turn{
+		FirstToken: 400 * time.Millisecond,
+		Gaps:       []time.Duration{20 * time.Millisecond, 30 * time.Millisecond},
+		Tokens:     100,
+		Generation: 2 * time.Second,
+	}); n != 0 {
+		t.Errorf("stalls = %d, want 0", n)
+	}
+	mark := c.Mark()
+	if n := c.RecordTurn(model, Turn{
+		FirstToken: 800 * time.Millisecond,
+		Gaps:       []time.Duration{20 * time.Millisecond, 3 * time.Second},
+		Tokens:     100,
+		Generation: 4 * time.Second,
+	}); n != 1 {
+		t.Errorf("stalls = %d, want 1", n)
+	}
+
+	s := c.Snapshot().Streams[model]
+	if s.Turns != 2 || s.Stalls != 1 || s.LongestStallMs != 3000 {
+		t.Errorf("turns/stalls/longest = %d/%d/%f", s.Turns, s.Stalls, s.LongestStallMs)
+	}
+	if s.FirstToken.Count != 2 || s.FirstToken.Max != 800 || s.InterDelta.Count != 4 {
+		t.Errorf("first token = %+v, inter-delta = %+v", s.FirstToken, s.InterDelta)
+	}
+	if s.TokensPerSec.Count != 2 || s.TokensPerSec.Mean != 37.5 {
+		t.Errorf("tokens/sec = %+v, want mean of 50 and 25", s.TokensPerSec)
+	}
+
+	w := c.Since(mark).Streams[model]
+	if w.Turns != 1 || w.Stalls != 1 || w.InterDelta.Count != 2 || math.Abs(w.TokensPerSec.P50-25) > 25*RelativeError {
+		t.Errorf("window = %+v", w)
+	}
+
+	m := Merge(c.Snapshot(), c.Since(mark)).Streams[model]
+	if m.Turns != 3 || m.Stalls != 2 || m.TokensPerSec.Count != 3 || m.InterDelta.Count != 6 {
+		t.Errorf("merged = %+v", m)
+	}
+
+	out := FormatSummary(c.Snapshot())
+	if !strings.Contains(out, "Stream Health") || !strings.Contains(out, "1 (longest 3s)") {
+		t.Errorf("summary missing stream health:\n%s", out)
+	}
+}
@@ -0,0 +1,195 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OpenMetricsContentType is the Content-Type for the OpenMetrics text format.
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// DefaultBucketsMs are the latency histogram bucket upper bounds (in
+// milliseconds) used for OpenMetrics exposition. They span fast page
+// loads through multi-minute streaming bouts.
+var DefaultBucketsMs = []float64{
+	5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000, 120000,
+}
+
+// Family is an extra metric family appended to the OpenMetrics output,
+// used by callers to expose state the Collector does not own (e.g. budget).
+type Family struct {
+	Name    string // without the _total suffix for counters
+	Help    string
+	Type    string // "gauge" or "counter"
+	Samples []Sample
+}
+
+// Sample is a single labelled value within a Family.
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// Buckets returns cumulative sample counts for each upper bound in
+// boundsMs (which must be ascending), plus the sum in milliseconds and
+// the total count. Counts are read from the histogram's own buckets, so
+// samples within RelativeError of a bound may count towards it.
+func (h *Histogram) Buckets(boundsMs []float64) (cumulative []int64, sumMs float64, count int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.d.buckets(boundsMs), h.d.Sum, h.d.Count
+}
+
+// WriteOpenMetrics writes all collector metrics, followed by extra
+// families, in the OpenMetrics text format.
+func (c *Collector) WriteOpenMetrics(w io.Writer, extra []Family) error {
+	bw := bufio.NewWriter(w)
+
+	counter := func(name, help string, v int64) {
+		writeHeader(bw, name, "counter", help)
+		fmt.Fprintf(bw, "%s_total %d\n", name, v)
+	}
+	gauge := func(name, help string, v int64) {
+		writeHeader(bw, name, "gauge", help)
+		fmt.Fprintf(bw, "%s %d\n", name, v)
+	}
+
+	counter("pitstorm_requests", "Actions dispatched.", c.requests.Load())
+	counter("pitstorm_successes", "Actions that completed successfully.", c.successes.Load())
+	counter("pitstorm_errors", "Actions that failed.", c.errors.Load())
+	counter("pitstorm_retries", "Requests retried by the HTTP client.", c.retries.Load())
+	counter("pitstorm_rate_limits", "429 responses received.", c.rateLimits.Load())
+	counter("pitstorm_bouts_started", "Bouts started.", c.boutStarts.Load())
+	counter("pitstorm_bouts_completed", "Bouts completed.", c.boutsDone.Load())
+	counter("pitstorm_stream_deltas", "SSE text-delta events received.", c.totalDeltas.Load())
+	counter("pitstorm_stream_chars", "Characters received in SSE text deltas.", c.totalChars.Load())
+	counter("pitstorm_stream_errors", "SSE streams that reported an error event.", c.streamErrors.Load())
+	counter("pitstorm_stream_validation_errors", "SSE streams that failed transcript validation.", c.validationErrors.Load())
+
+	gauge("pitstorm_active_workers", "Workers currently running.", c.activeWorkers.Load())
+	gauge("pitstorm_active_streams", "SSE streams currently open.", c.activeStreams.Load())
+	gauge("pitstorm_active_streams_peak", "Peak concurrent SSE streams.", c.peakStreams.Load())
+
+	c.statusMu.Lock()
+	codes := make([]int, 0, len(c.statuses))
+	for code := range c.statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	writeHeader(bw, "pitstorm_responses", "counter", "HTTP responses by status code.")
+	for _, code := range codes {
+		fmt.Fprintf(bw, "pitstorm_responses_total{code=\"%d\"} %d\n", code, c.statuses[code].Load())
+	}
+	c.statusMu.Unlock()
+
+	c.errorMu.Lock()
+	errEPs := sortedKeys(c.errCounts)
+	writeHeader(bw, "pitstorm_endpoint_errors", "counter", "Errors by endpoint.")
+	for _, ep := range errEPs {
+		fmt.Fprintf(bw, "pitstorm_endpoint_errors_total{endpoint=\"%s\"} %d\n", escapeLabel(ep), c.errCounts[ep].Load())
+	}
+	c.errorMu.Unlock()
+
+	c.latencyMu.Lock()
+	latencies := copyHistograms(c.latencies)
+	c.latencyMu.Unlock()
+	writeHistograms(bw, "pitstorm_request_duration_seconds", "Request latency by endpoint.", latencies)
+
+	c.firstByteMu.Lock()
+	firstBytes := copyHistograms(c.firstBytes)
+	c.firstByteMu.Unlock()
+	writeHistograms(bw, "pitstorm_first_byte_seconds", "Time to first SSE text delta by endpoint.", firstBytes)
+
+	for _, f := range extra {
+		writeHeader(bw, f.Name, f.Type, f.Help)
+		suffix := ""
+		if f.Type == "counter" {
+			suffix = "_total"
+		}
+		for _, s := range f.Samples {
+			fmt.Fprintf(bw, "%s%s%s %s\n", f.Name, suffix, formatLabels(s.Labels), formatFloat(s.Value))
+		}
+	}
+
+	fmt.Fprintf(bw, "# EOF\n")
+	return bw.Flush()
+}
+
+// Handler serves the collector's metrics in OpenMetrics format. If extra
+// is non-nil it is called on every scrape for additional families.
+func Handler(c *Collector, extra func() []Family) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var fams []Family
+		if extra != nil {
+			fams = extra()
+		}
+		w.Header().Set("Content-Type", OpenMetricsContentType)
+		c.WriteOpenMetrics(w, fams)
+	})
+}
+
+func writeHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+}
+
+func writeHistograms(w io.Writer, name, help string, hs map[string]*Histogram) {
+	writeHeader(w, name, "histogram", help)
+	for _, ep := range sortedKeys(hs) {
+		cumulative, sumMs, count := hs[ep].Buckets(DefaultBucketsMs)
+		label := escapeLabel(ep)
+		for i, le := range DefaultBucketsMs {
+			fmt.Fprintf(w, "%s_bucket{endpoint=\"%s\",le=\"%s\"} %d\n", name, label, formatFloat(le/1000), cumulative[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{endpoint=\"%s\",le=\"+Inf\"} %d\n", name, label, count)
+		fmt.Fprintf(w, "%s_sum{endpoint=\"%s\"} %s\n", name, label, formatFloat(sumMs/1000))
+		fmt.Fprintf(w, "%s_count{endpoint=\"%s\"} %d\n", name, label, count)
+	}
+}
+
+func copyHistograms(m map[string]*Histogram) map[string]*Histogram {
+	out := make(map[string]*Histogram, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(labels))
+	for _, k := range sortedKeys(labels) {
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", k, escapeLabel(labels[k])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
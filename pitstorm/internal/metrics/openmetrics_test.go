@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistogramBuckets(t *testing.T) {
+	h := NewHistogram()
+	for _, ms := range []int{3, 10, 40, 700, 200000} {
+		h.Add(time.Duration(ms) * time.Millisecond)
+	}
+	cumulative, sum, count := h.Buckets([]float64{5, 10, 50, 1000})
+	want := []int64{1, 2, 3, 4}
+	for i := range want {
+		if cumulative[i] != want[i] {
+			t.Errorf("bucket[%d] = %d, want %d", i, cumulative[i], want[i])
+		}
+	}
+	if count != 5 {
+		t.Errorf("count = %d, want 5", count)
+	}
+	if sum != 200753 {
+		t.Errorf("sum = %f, want 200753", sum)
+	}
+}
+
+func TestWriteOpenMetrics(t *testing.T) {
+	c := NewCollector()
+	c.RecordRequest()
+	c.RecordRequest()
+	c.RecordSuccess()
+	c.RecordError(`/api/"quoted"`)
+	c.RecordStatus(200)
+	c.RecordStatus(429)
+	c.RecordLatency("/api/run-bout", 120*time.Millisecond)
+	c.RecordFirstByte("/api/run-bout", 30*time.Millisecond)
+	c.WorkerStart()
+
+	var buf bytes.Buffer
+	err := c.WriteOpenMetrics(&buf, []Family{{
+		Name: "pitstorm_budget_spent_gbp", Help: "Spend.", Type: "gauge",
+		Samples: []Sample{{Labels: map[string]string{"model": "haiku"}, Value: 0.25}},
+	}})
+	if err != nil {
+		t.Fatalf("WriteOpenMetrics: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE pitstorm_requests counter\n",
+		"pitstorm_requests_total 2\n",
+		"pitstorm_active_workers 1\n",
+		`pitstorm_responses_total{code="429"} 1`,
+		`pitstorm_endpoint_errors_total{endpoint="/api/\"quoted\""} 1`,
+		"# TYPE pitstorm_request_duration_seconds histogram\n",
+		`pitstorm_request_duration_seconds_bucket{endpoint="/api/run-bout",le="0.1"} 0`,
+		`pitstorm_request_duration_seconds_bucket{endpoint="/api/run-bout",le="0.25"} 1`,
+		`pitstorm_request_duration_seconds_bucket{endpoint="/api/run-bout",le="+Inf"} 1`,
+		`pitstorm_request_duration_seconds_sum{endpoint="/api/run-bout"} 0.12`,
+		`pitstorm_first_byte_seconds_count{endpoint="/api/run-bout"} 1`,
+		`pitstorm_budget_spent_gbp{model="haiku"} 0.25`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+	if !strings.HasSuffix(out, "# EOF\n") {
+		t.Error("output must end with # EOF")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	c := NewCollector()
+	c.RecordRequest()
+
+	called := false
+	srv := httptest.NewServer(Handler(c, func() []Family {
+		called = true
+		return nil
+	}))
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if ct := resp.Header.Get("Content-Type"); ct != OpenMetricsContentType {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !called {
+		t.Error("extra families func not called")
+	}
+	if !strings.Contains(string(body), "pitstorm_requests_total 1") {
+		t.Errorf("body missing request counter:\n%s", body)
+	}
+}
@@ -0,0 +1,222 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultStallThreshold is the pause between two text-deltas of a turn
+// that counts as a stall.
+const DefaultStallThreshold = 5 * time.Second
+
+// StreamStats summarizes the streaming health of one model's bouts:
+// how long each turn took to start, how smoothly its text arrived, and
+// how often it stalled. Latency fields are in milliseconds.
+type StreamStats struct {
+	Turns int64 `json:"turns"`
+	// Stalls counts pauses of at least the stall threshold between two
+	// deltas of a turn, including streams that died mid-turn.
+	Stalls         int64        `json:"stalls"`
+	LongestStallMs float64      `json:"longestStallMs,omitempty"`
+	FirstToken     LatencyStats `json:"timeToFirstToken"` // per turn
+	InterDelta     LatencyStats `json:"interDelta"`
+	TokensPerSec   RateStats    `json:"tokensPerSec"`
+}
+
+// RateStats summarizes a distribution of rates, such as tokens per
+// second, whose slow tail is at the low end.
+type RateStats struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50"`
+	P5    float64 `json:"p5"` // 5% of samples were at or below this rate
+	P1    float64 `json:"p1"`
+	Mean  float64 `json:"mean"`
+	// Distribution is the histogram the stats were computed from (see
+	// LatencyStats.Distribution).
+	Distribution *Distribution `json:"distribution,omitempty"`
+}
+
+// rateStats computes RateStats from a distribution.
+func rateStats(d *Distribution) RateStats {
+	if d.Count == 0 {
+		return RateStats{}
+	}
+	dist := d.clone()
+	return RateStats{
+		Count:        int(d.Count),
+		P50:          d.Quantile(0.50),
+		P5:           d.Quantile(0.05),
+		P1:           d.Quantile(0.01),
+		Mean:         d.Sum / float64(d.Count),
+		Distribution: &dist,
+	}
+}
+
+// Turn is one streamed turn of a bout, as recorded by RecordTurn.
+type Turn struct {
+	FirstToken time.Duration   // from the turn's start to its first delta
+	Gaps       []time.Duration // pauses between consecutive deltas
+	Tokens     int             // output tokens, reported or estimated
+	Generation time.Duration   // from the first delta to the last
+}
+
+// modelStreams holds one model's stream health histograms.
+type modelStreams struct {
+	turns, stalls int64
+	longest       time.Duration
+	firstToken    *Histogram
+	interDelta    *Histogram
+	tokensPerSec  *Histogram
+}
+
+// streamState is a copy of modelStreams, for Mark.
+type streamState struct {
+	turns, stalls int64
+	firstToken    Distribution
+	interDelta    Distribution
+	tokensPerSec  Distribution
+}
+
+// SetStallThreshold sets the pause between deltas that counts as a
+// stall (default DefaultStallThreshold).
+func (c *Collector) SetStallThreshold(d time.Duration) {
+	c.streamMu.Lock()
+	c.stallThreshold = d
+	c.streamMu.Unlock()
+}
+
+// RecordTurn records the stream health of one turn of a model's bout.
+// It returns the number of stalls found in the turn.
+func (c *Collector) RecordTurn(model string, t Turn) int {
+	c.streamMu.Lock()
+	defer c.streamMu.Unlock()
+	s, ok := c.streams[model]
+	if !ok {
+		s = &modelStreams{firstToken: NewHistogram(), interDelta: NewHistogram(), tokensPerSec: NewHistogram()}
+		c.streams[model] = s
+	}
+	s.turns++
+	if t.FirstToken > 0 {
+		s.firstToken.Add(t.FirstToken)
+	}
+	stalls := 0
+	for _, g := range t.Gaps {
+		s.interDelta.Add(g)
+		if g >= c.stallThreshold {
+			stalls++
+			s.longest = max(s.longest, g)
+		}
+	}
+	s.stalls += int64(stalls)
+	if t.Tokens > 0 && t.Generation > 0 {
+		s.tokensPerSec.observe(float64(t.Tokens) / t.Generation.Seconds())
+	}
+	return stalls
+}
+
+// streamStats summarizes every model's stream health. The caller holds
+// streamMu.
+func (c *Collector) streamStats() map[string]StreamStats {
+	out := make(map[string]StreamStats, len(c.streams))
+	for model, s := range c.streams {
+		tps := s.tokensPerSec.Distribution()
+		out[model] = StreamStats{
+			Turns:          s.turns,
+			Stalls:         s.stalls,
+			LongestStallMs: float64(s.longest.Microseconds()) / 1000,
+			FirstToken:     s.firstToken.Stats(),
+			InterDelta:     s.interDelta.Stats(),
+			TokensPerSec:   rateStats(&tps),
+		}
+	}
+	return out
+}
+
+// markStreams copies every model's stream state. The caller holds
+// streamMu.
+func (c *Collector) markStreams() map[string]streamState {
+	out := make(map[string]streamState, len(c.streams))
+	for model, s := range c.streams {
+		out[model] = streamState{
+			turns:        s.turns,
+			stalls:       s.stalls,
+			firstToken:   s.firstToken.Distribution(),
+			interDelta:   s.interDelta.Distribution(),
+			tokensPerSec: s.tokensPerSec.Distribution(),
+		}
+	}
+	return out
+}
+
+// streamsSince summarizes stream health after the states in base. The
+// window's LongestStallMs is the run's, as a stall's length is only
+// kept as a maximum. The caller holds streamMu.
+func (c *Collector) streamsSince(base map[string]streamState) map[string]StreamStats {
+	out := make(map[string]StreamStats)
+	for model, s := range c.streams {
+		b := base[model]
+		if s.turns == b.turns {
+			continue
+		}
+		ft, id, tps := s.firstToken.Distribution(), s.interDelta.Distribution(), s.tokensPerSec.Distribution()
+		ft, id, tps = ft.since(&b.firstToken), id.since(&b.interDelta), tps.since(&b.tokensPerSec)
+		st := StreamStats{
+			Turns:        s.turns - b.turns,
+			Stalls:       s.stalls - b.stalls,
+			FirstToken:   ft.Stats(),
+			InterDelta:   id.Stats(),
+			TokensPerSec: rateStats(&tps),
+		}
+		if st.Stalls > 0 {
+			st.LongestStallMs = float64(s.longest.Microseconds()) / 1000
+		}
+		out[model] = st
+	}
+	return out
+}
+
+// mergeStreams combines two models' stream stats (see Merge).
+func mergeStreams(a, b StreamStats) StreamStats {
+	out := StreamStats{
+		Turns:          a.Turns + b.Turns,
+		Stalls:         a.Stalls + b.Stalls,
+		LongestStallMs: max(a.LongestStallMs, b.LongestStallMs),
+		FirstToken:     mergeLatency(a.FirstToken, b.FirstToken),
+		InterDelta:     mergeLatency(a.InterDelta, b.InterDelta),
+		TokensPerSec:   a.TokensPerSec,
+	}
+	switch {
+	case a.TokensPerSec.Count == 0:
+		out.TokensPerSec = b.TokensPerSec
+	case b.TokensPerSec.Count == 0:
+	case a.TokensPerSec.Distribution != nil && b.TokensPerSec.Distribution != nil:
+		d := a.TokensPerSec.Distribution.clone()
+		d.merge(b.TokensPerSec.Distribution)
+		out.TokensPerSec = rateStats(&d)
+	default:
+		// Without distributions, keep the larger sample's rates.
+		if b.TokensPerSec.Count > a.TokensPerSec.Count {
+			out.TokensPerSec = b.TokensPerSec
+		}
+	}
+	return out
+}
+
+// formatStreams renders the per-model stream health table of FormatSummary.
+func formatStreams(b *strings.Builder, streams map[string]StreamStats) {
+	fmt.Fprintf(b, "\n  Stream Health (per turn, by model):\n")
+	fmt.Fprintf(b, "    %-28s %6s %16s %22s %14s %7s\n", "model", "turns", "ttft p50/p95", "inter-delta p50/p99", "tok/s p50/p5", "stalls")
+	for _, model := range sortedKeys(streams) {
+		s := streams[model]
+		stalls := fmt.Sprint(s.Stalls)
+		if s.Stalls > 0 {
+			stalls += fmt.Sprintf(" (longest %s)", time.Duration(s.LongestStallMs*float64(time.Millisecond)).Round(time.Millisecond))
+		}
+		fmt.Fprintf(b, "    %-28s %6d %16s %22s %14s %7s\n", model, s.Turns,
+			fmt.Sprintf("%.0f/%.0fms", s.FirstToken.P50, s.FirstToken.P95),
+			fmt.Sprintf("%.0f/%.0fms", s.InterDelta.P50, s.InterDelta.P99),
+			fmt.Sprintf("%.0f/%.0f", s.TokensPerSec.P50, s.TokensPerSec.P5),
+			stalls)
+	}
+}
@@ -0,0 +1,219 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimelineInterval is the width of one timeline interval.
+const DefaultTimelineInterval = 10 * time.Second
+
+// Interval is one window of a run's timeline. Counters cover only the
+// window, so a spike in one minute stands out from the run's totals.
+type Interval struct {
+	Offset      time.Duration `json:"offset"` // from the start of the run
+	Duration    time.Duration `json:"duration"`
+	Requests    int64         `json:"requests"`
+	Errors      int64         `json:"errors"`
+	RateLimits  int64         `json:"rateLimits,omitempty"`
+	BoutsDone   int64         `json:"boutsDone,omitempty"`
+	Throughput  float64       `json:"throughputRps"`
+	ErrorRate   float64       `json:"errorRate"`
+	StatusCodes map[int]int64 `json:"statusCodes,omitempty"`
+	// Latency covers every endpoint; Latencies breaks it down.
+	Latency   LatencyStats            `json:"latency"`
+	Latencies map[string]LatencyStats `json:"latencies,omitempty"`
+	// ActiveStreams is the most SSE streams open at once in the window.
+	ActiveStreams int64 `json:"activeStreams"`
+	// SpentGBP is the budget spent during the window.
+	SpentGBP float64 `json:"spentGbp"`
+}
+
+// timeline is the state behind Collector.WatchTimeline.
+type timeline struct {
+	mu        sync.Mutex
+	spend     func() float64
+	mark      Mark
+	spent     float64 // spend at mark
+	intervals []Interval
+}
+
+// WatchTimeline closes a timeline Interval every interval until ctx is
+// done. spend, if non-nil, returns the run's cumulative budget spend in
+// GBP. Call it once per collector, typically in its own goroutine.
+func (c *Collector) WatchTimeline(ctx context.Context, interval time.Duration, spend func() float64) {
+	t := &c.timeline
+	t.mu.Lock()
+	t.spend = spend
+	t.mark = c.Mark()
+	t.spent = t.spendNow()
+	c.intervalPeak.Store(c.activeStreams.Load())
+	t.mu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		t.mu.Lock()
+		iv, spent := c.currentInterval()
+		t.intervals = append(t.intervals, iv)
+		t.mark, t.spent = c.Mark(), spent
+		c.intervalPeak.Store(c.activeStreams.Load())
+		t.mu.Unlock()
+	}
+}
+
+// Timeline returns the closed intervals followed by the one in
+// progress, or nil if WatchTimeline was never started.
+func (c *Collector) Timeline() []Interval {
+	t := &c.timeline
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.mark.at.IsZero() {
+		return nil
+	}
+	iv, _ := c.currentInterval()
+	out := make([]Interval, len(t.intervals), len(t.intervals)+1)
+	copy(out, t.intervals)
+	if iv.Duration > 0 {
+		out = append(out, iv)
+	}
+	return out
+}
+
+// currentInterval summarizes activity since the timeline's mark, and
+// returns the cumulative spend it was computed from. The caller holds
+// the timeline lock.
+func (c *Collector) currentInterval() (Interval, float64) {
+	t := &c.timeline
+	w := c.Since(t.mark)
+	spent := t.spendNow()
+
+	var all Distribution
+	for ep, ls := range w.Latencies {
+		all.merge(ls.Distribution)
+		// Distributions are for merging runs, not windows; leave them
+		// out to keep the output file small.
+		ls.Distribution = nil
+		w.Latencies[ep] = ls
+	}
+	latency := all.Stats()
+	latency.Distribution = nil
+
+	iv := Interval{
+		Offset:        t.mark.at.Sub(c.start),
+		Duration:      w.Elapsed,
+		Requests:      w.Requests,
+		Errors:        w.Errors,
+		RateLimits:    w.RateLimits,
+		BoutsDone:     w.BoutsDone,
+		Throughput:    w.Throughput,
+		ErrorRate:     w.ErrorRate,
+		Latency:       latency,
+		ActiveStreams: c.intervalPeak.Load(),
+		SpentGBP:      math.Max(0, spent-t.spent),
+	}
+	if len(w.StatusCodes) > 0 {
+		iv.StatusCodes = w.StatusCodes
+	}
+	if len(w.Latencies) > 0 {
+		iv.Latencies = w.Latencies
+	}
+	return iv, spent
+}
+
+func (t *timeline) spendNow() float64 {
+	if t.spend == nil {
+		return 0
+	}
+	return t.spend()
+}
+
+// ---------- Timeline Charts ----------
+
+// chartHeight is the number of rows in a timeline chart.
+const chartHeight = 6
+
+// FormatTimeline renders the timeline as ASCII bar charts of request
+// rate, error rate, p95 latency, concurrent streams and budget spend,
+// one column per interval. Runs with more intervals than width are
+// folded so that each column shows the worst of its intervals.
+func FormatTimeline(intervals []Interval, width int) string {
+	if len(intervals) == 0 {
+		return ""
+	}
+	series := []struct {
+		title  string
+		format string
+		value  func(Interval) float64
+	}{
+		{"Requests/s", "%.1f", func(iv Interval) float64 { return iv.Throughput }},
+		{"Error rate (%)", "%.1f", func(iv Interval) float64 { return iv.ErrorRate * 100 }},
+		{"p95 latency (ms)", "%.0f", func(iv Interval) float64 { return iv.Latency.P95 }},
+		{"Active streams (peak)", "%.0f", func(iv Interval) float64 { return float64(iv.ActiveStreams) }},
+		{"Spend (£ per interval)", "%.4f", func(iv Interval) float64 { return iv.SpentGBP }},
+	}
+
+	per := (len(intervals) + width - 1) / width
+	last := intervals[len(intervals)-1]
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n  Timeline (%d intervals of %s", len(intervals), intervals[0].Duration.Round(time.Second))
+	if per > 1 {
+		fmt.Fprintf(&b, ", %d per column", per)
+	}
+	fmt.Fprintf(&b, "):\n")
+
+	for _, s := range series {
+		cols := make([]float64, 0, width)
+		for i := 0; i < len(intervals); i += per {
+			peak := 0.0
+			for _, iv := range intervals[i:min(i+per, len(intervals))] {
+				peak = math.Max(peak, s.value(iv))
+			}
+			cols = append(cols, peak)
+		}
+		writeChart(&b, s.title, s.format, cols, last.Offset+last.Duration)
+	}
+	return b.String()
+}
+
+// writeChart draws one bar chart with its peak on the top axis label.
+func writeChart(b *strings.Builder, title, format string, cols []float64, span time.Duration) {
+	peak := 0.0
+	for _, v := range cols {
+		peak = math.Max(peak, v)
+	}
+	top, bottom := fmt.Sprintf(format, peak), fmt.Sprintf(format, 0.0)
+	label := max(len(top), len(bottom))
+
+	fmt.Fprintf(b, "\n    %s\n", title)
+	for row := chartHeight; row >= 1; row-- {
+		axis := ""
+		switch row {
+		case chartHeight:
+			axis = top
+		case 1:
+			axis = bottom
+		}
+		line := make([]rune, len(cols))
+		for i, v := range cols {
+			line[i] = ' '
+			if peak > 0 && v > 0 && v/peak*chartHeight > float64(row-1) {
+				line[i] = '█'
+			}
+		}
+		fmt.Fprintf(b, "    %*s ┤%s\n", label, axis, strings.TrimRight(string(line), " "))
+	}
+	fmt.Fprintf(b, "    %*s └%s\n", label, "", strings.Repeat("─", len(cols)))
+	end := span.Round(time.Second).String()
+	fmt.Fprintf(b, "    %*s  0s%*s\n", label, "", max(len(cols)-2, len(end)+1), end)
+}
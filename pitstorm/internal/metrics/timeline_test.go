@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTimeline(t *testing.T) {
+	c := NewCollector()
+	if c.Timeline() != nil {
+		t.Fatal("timeline before WatchTimeline")
+	}
+
+	var spent atomic.Int64 // pence
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		c.WatchTimeline(ctx, 40*time.Millisecond, func() float64 { return float64(spent.Load()) / 100 })
+		close(done)
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	record := func(n int, status int, latency time.Duration) {
+		for i := 0; i < n; i++ {
+			c.RecordRequest()
+			c.RecordStatus(status)
+			c.RecordLatency("/api/run-bout", latency)
+			if status >= 500 {
+				c.RecordError("/api/run-bout")
+			}
+		}
+	}
+	record(10, 200, 20*time.Millisecond)
+	c.StreamStart()
+	c.StreamStart()
+	c.StreamDone()
+	c.StreamDone()
+	spent.Add(5)
+	time.Sleep(60 * time.Millisecond)
+	record(4, 503, 900*time.Millisecond)
+	spent.Add(3)
+
+	cancel()
+	<-done
+	tl := c.Timeline()
+	if len(tl) < 2 {
+		t.Fatalf("%d intervals, want at least 2", len(tl))
+	}
+
+	var reqs, errs int64
+	var gbp float64
+	for i, iv := range tl {
+		reqs += iv.Requests
+		errs += iv.Errors
+		gbp += iv.SpentGBP
+		if i > 0 && iv.Offset < tl[i-1].Offset+tl[i-1].Duration-time.Millisecond {
+			t.Errorf("interval %d starts at %s, inside interval %d", i, iv.Offset, i-1)
+		}
+	}
+	if reqs != 14 || errs != 4 || math.Abs(gbp-0.08) > 1e-9 {
+		t.Errorf("totals = %d requests, %d errors, £%.2f; want 14, 4, £0.08", reqs, errs, gbp)
+	}
+
+	first, final := tl[0], tl[len(tl)-1]
+	if first.ActiveStreams != 2 || first.StatusCodes[200] != 10 {
+		t.Errorf("first interval = %+v", first)
+	}
+	if final.StatusCodes[503] != 4 || final.ErrorRate != 1 || math.Abs(final.Latency.P95-900) > 900*RelativeError {
+		t.Errorf("final interval = %+v", final)
+	}
+	if final.ActiveStreams != 0 {
+		t.Errorf("final interval streams = %d, want 0", final.ActiveStreams)
+	}
+	if final.Latency.Distribution != nil || final.Latencies["/api/run-bout"].Distribution != nil {
+		t.Error("interval latencies carry distributions")
+	}
+}
+
+func TestFormatTimeline(t *testing.T) {
+	var tl []Interval
+	for i := 0; i < 30; i++ {
+		iv := Interval{
+			Offset:     time.Duration(i) * 10 * time.Second,
+			Duration:   10 * time.Second,
+			Throughput: 5,
+			Latency:    LatencyStats{P95: 200},
+		}
+		if i == 7 {
+			// A latency spike in one interval.
+			iv.Latency.P95 = 4000
+			iv.ErrorRate = 0.25
+		}
+		tl = append(tl, iv)
+	}
+
+	out := FormatTimeline(tl, 10)
+	for _, s := range []string{"30 intervals of 10s, 3 per column", "p95 latency (ms)", "4000 ┤  █", "5m0s"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("missing %q in:\n%s", s, out)
+		}
+	}
+	if FormatTimeline(nil, 10) != "" {
+		t.Error("empty timeline rendered")
+	}
+}
@@ -0,0 +1,490 @@
+// Package mockserver implements a local stand-in for The Pit's API so
+// pitstorm can be exercised end to end without network access. It serves
+// every endpoint the action layer calls — including /api/run-bout as a
+// UIMessageStream SSE body — and can inject latency, server errors, and
+// 429 rate limits to exercise the engine, budget gate, and metrics. With
+// a starting credit balance it also preauthorizes and charges bouts per
+// bearer token, refusing them with 402 once the balance runs out.
+package mockserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+	"github.com/rickhallett/thepit/pitstorm/internal/client"
+	"github.com/rickhallett/thepit/pitstorm/internal/credits"
+)
+
+// Config controls mock behaviour. The zero value serves every request
+// immediately and successfully.
+type Config struct {
+	// Latency is added before every response; Jitter adds a further
+	// uniformly random delay in [0, Jitter).
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// ErrorRate is the probability (0–1) that a request gets a 500.
+	ErrorRate float64
+
+	// RateLimitRate is the probability (0–1) that a request gets a 429.
+	// It is checked before ErrorRate.
+	RateLimitRate float64
+
+	// DeltaDelay is the pause between SSE text-delta events on
+	// /api/run-bout, simulating model token generation.
+	DeltaDelay time.Duration
+
+	// DeltasPerTurn is the number of text-delta events per bout turn.
+	// Zero means DefaultDeltasPerTurn.
+	DeltasPerTurn int
+
+	// StreamUsage emits a data-usage event with token counts after each
+	// bout turn. /api/v1/bout always reports usage, as the real API does.
+	StreamUsage bool
+
+	// RequireAuth rejects authenticated endpoints with 401 when the
+	// request has no bearer token.
+	RequireAuth bool
+
+	// Credits, if positive, is every bearer token's starting balance.
+	// Bouts preauthorize their estimated cost and are refused with 402
+	// when the balance does not cover it, then charged their usage.
+	Credits float64
+}
+
+// DefaultDeltasPerTurn is the text-delta count per turn when unset.
+const DefaultDeltasPerTurn = 12
+
+// Stats counts requests served by the mock.
+type Stats struct {
+	Requests   int64            `json:"requests"`
+	Errors     int64            `json:"injectedErrors"`
+	RateLimits int64            `json:"injectedRateLimits"`
+	Bouts      int64            `json:"bouts"`
+	Refusals   int64            `json:"creditRefusals"`
+	ByPath     map[string]int64 `json:"byPath"`
+}
+
+// Server is an http.Handler emulating The Pit's API.
+type Server struct {
+	cfg  Config
+	logf func(string, ...any)
+	mux  *http.ServeMux
+
+	rngMu sync.Mutex
+	rng   *rand.Rand
+
+	requests   atomic.Int64
+	errors     atomic.Int64
+	rateLimits atomic.Int64
+	bouts      atomic.Int64
+	refusals   atomic.Int64
+
+	// balances holds each bearer token's credit balance in GBP.
+	balanceMu sync.Mutex
+	balances  map[string]float64
+
+	pathMu sync.Mutex
+	byPath map[string]int64
+
+	nextID atomic.Int64
+}
+
+// New creates a Server. A nil logf disables logging.
+func New(cfg Config, logf func(string, ...any)) *Server {
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+	if cfg.DeltasPerTurn <= 0 {
+		cfg.DeltasPerTurn = DefaultDeltasPerTurn
+	}
+	s := &Server{
+		cfg:      cfg,
+		logf:     logf,
+		mux:      http.NewServeMux(),
+		rng:      rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+		byPath:   make(map[string]int64),
+		balances: make(map[string]float64),
+	}
+
+	s.mux.HandleFunc("GET /api/health", s.handleHealth)
+	s.mux.HandleFunc("POST /api/run-bout", s.auth(s.handleRunBout))
+	s.mux.HandleFunc("POST /api/v1/bout", s.auth(s.handleAPIBout))
+	s.mux.HandleFunc("POST /api/agents", s.auth(s.handleCreated("agentId")))
+	s.mux.HandleFunc("GET /api/agents", s.handlePage)
+	s.mux.HandleFunc("POST /api/reactions", s.auth(s.handleOK))
+	s.mux.HandleFunc("POST /api/winner-vote", s.auth(s.handleOK))
+	s.mux.HandleFunc("POST /api/short-links", s.handleShortLink)
+	s.mux.HandleFunc("GET /api/feature-requests", s.handleListFeatures)
+	s.mux.HandleFunc("POST /api/feature-requests", s.auth(s.handleCreated("id")))
+	s.mux.HandleFunc("POST /api/feature-requests/vote", s.auth(s.handleOK))
+	s.mux.HandleFunc("POST /api/paper-submissions", s.auth(s.handleCreated("id")))
+	s.mux.HandleFunc("POST /api/newsletter", s.handleOK)
+	s.mux.HandleFunc("POST /api/contact", s.handleOK)
+	s.mux.HandleFunc("POST /api/byok-stash", s.auth(s.handleOK))
+	s.mux.HandleFunc("GET /api/credits/balance", s.auth(s.handleCreditBalance))
+	s.mux.HandleFunc("GET /", s.handlePage)
+	return s
+}
+
+// ServeHTTP applies latency and fault injection, then routes the request.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests.Add(1)
+	s.pathMu.Lock()
+	s.byPath[r.URL.Path]++
+	s.pathMu.Unlock()
+
+	if d := s.delay(); d > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(d):
+		}
+	}
+
+	if s.roll(s.cfg.RateLimitRate) {
+		s.rateLimits.Add(1)
+		w.Header().Set("Retry-After", "1")
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "Rate limit exceeded"})
+		s.logf("[mock] %s %s → 429 (injected)", r.Method, r.URL.Path)
+		return
+	}
+	if s.roll(s.cfg.ErrorRate) {
+		s.errors.Add(1)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		s.logf("[mock] %s %s → 500 (injected)", r.Method, r.URL.Path)
+		return
+	}
+
+	s.logf("[mock] %s %s", r.Method, r.URL.Path)
+	s.mux.ServeHTTP(w, r)
+}
+
+// Stats returns a snapshot of request counters.
+func (s *Server) Stats() Stats {
+	s.pathMu.Lock()
+	byPath := make(map[string]int64, len(s.byPath))
+	for k, v := range s.byPath {
+		byPath[k] = v
+	}
+	s.pathMu.Unlock()
+	return Stats{
+		Requests:   s.requests.Load(),
+		Errors:     s.errors.Load(),
+		RateLimits: s.rateLimits.Load(),
+		Bouts:      s.bouts.Load(),
+		Refusals:   s.refusals.Load(),
+		ByPath:     byPath,
+	}
+}
+
+// ---------- Handlers ----------
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handlePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "<!doctype html><html><head><title>The Pit (mock)</title></head><body>%s</body></html>",
+		htmlEscape(r.URL.Path))
+}
+
+func (s *Server) handleOK(w http.ResponseWriter, r *http.Request) {
+	if !decodeBody(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+// handleCreated returns a handler that responds 201 with a fresh ID
+// under the given JSON key.
+func (s *Server) handleCreated(key string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !decodeBody(w, r) {
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]any{key: s.nextID.Add(1)})
+	}
+}
+
+func (s *Server) handleShortLink(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		BoutID string `json:"boutId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.BoutID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "boutId required"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"slug": fmt.Sprintf("m%d", s.nextID.Add(1))})
+}
+
+func (s *Server) handleListFeatures(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"requests": []map[string]any{
+			{"id": 1, "title": "Mock feature", "category": "other", "votes": 3},
+		},
+	})
+}
+
+// boutRequest mirrors action.RunBoutRequest / action.APIBoutRequest.
+type boutRequest struct {
+	BoutID   string `json:"boutId"`
+	PresetID string `json:"presetId"`
+	Topic    string `json:"topic"`
+	Turns    int    `json:"turns"`
+	Model    string `json:"model"`
+}
+
+func decodeBout(w http.ResponseWriter, r *http.Request) (boutRequest, bool) {
+	var req boutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+		return req, false
+	}
+	if req.BoutID == "" || req.PresetID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "boutId and presetId required"})
+		return req, false
+	}
+	if req.Turns <= 0 {
+		req.Turns = 2
+	}
+	return req, true
+}
+
+func (s *Server) handleRunBout(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeBout(w, r)
+	if !ok {
+		return
+	}
+	settle, ok := s.preauthorize(w, r, req)
+	if !ok {
+		return
+	}
+	var usage client.UsageData
+	defer func() { settle(usage) }()
+	flusher, _ := w.(http.Flusher)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(v any) bool {
+		data, _ := json.Marshal(v)
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return r.Context().Err() == nil
+	}
+
+	if !send(map[string]string{"type": client.EventStart, "messageId": req.BoutID}) {
+		return
+	}
+	for turn := 0; turn < req.Turns; turn++ {
+		agent := mockAgents[turn%len(mockAgents)]
+		textID := fmt.Sprintf("%s-%d", req.BoutID, turn)
+		if !send(map[string]any{"type": client.EventDataTurn, "data": client.TurnData{
+			Turn: turn, AgentID: agent.id, AgentName: agent.name, Color: agent.color,
+		}}) {
+			return
+		}
+		send(map[string]string{"type": client.EventTextStart, "id": textID})
+		for i := 0; i < s.cfg.DeltasPerTurn; i++ {
+			if s.cfg.DeltaDelay > 0 {
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(s.cfg.DeltaDelay):
+				}
+			}
+			if !send(map[string]string{"type": client.EventTextDelta, "id": textID, "delta": mockWords[i%len(mockWords)] + " "}) {
+				return
+			}
+		}
+		send(map[string]string{"type": client.EventTextEnd, "id": textID})
+		in, out := mockTurnUsage(turn, s.cfg.DeltasPerTurn)
+		usage.InputTokens += in
+		usage.OutputTokens += out
+		if s.cfg.StreamUsage {
+			send(map[string]any{"type": client.EventDataUsage, "data": client.UsageData{InputTokens: in, OutputTokens: out}})
+		}
+	}
+	send(map[string]any{"type": client.EventDataShareLine, "data": client.ShareLineData{Text: "A mock bout, fought in the void."}})
+	fmt.Fprintf(w, "data: %s\n\n", client.EventDone)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	s.bouts.Add(1)
+}
+
+func (s *Server) handleAPIBout(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeBout(w, r)
+	if !ok {
+		return
+	}
+	settle, ok := s.preauthorize(w, r, req)
+	if !ok {
+		return
+	}
+	turns := make([]map[string]any, req.Turns)
+	var usage client.UsageData
+	for i := range turns {
+		in, out := mockTurnUsage(i, len(mockWords))
+		usage.InputTokens += in
+		usage.OutputTokens += out
+		agent := mockAgents[i%len(mockAgents)]
+		turns[i] = map[string]any{
+			"turn":    i,
+			"agentId": agent.id,
+			"text":    strings.Join(mockWords, " "),
+		}
+	}
+	settle(usage)
+	s.bouts.Add(1)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"boutId":    req.BoutID,
+		"status":    "completed",
+		"turns":     turns,
+		"shareLine": "A mock bout, fought in the void.",
+		"usage":     usage,
+	})
+}
+
+// mockTurnUsage returns plausible token counts for a turn of the given
+// number of words: the prompt grows with the transcript, and output
+// runs at roughly 1.3 tokens per word.
+func mockTurnUsage(turn, words int) (input, output int) {
+	output = words * 13 / 10
+	return 450 + turn*(output+20), output
+}
+
+// preauthorize holds a bout's estimated cost against the caller's
+// credit balance, as run-bout does when credits are enabled, answering
+// 402 when the balance does not cover it. The returned settle charges
+// the usage the bout produced and refunds the rest of the hold. Without
+// Config.Credits, or for anonymous callers, every bout is authorized.
+func (s *Server) preauthorize(w http.ResponseWriter, r *http.Request, req boutRequest) (settle func(client.UsageData), ok bool) {
+	token := r.Header.Get("Authorization")
+	if s.cfg.Credits <= 0 || token == "" {
+		return func(client.UsageData) {}, true
+	}
+	est := budget.EstimateBoutCost(req.Model, req.Turns, budget.DefaultOutputPerTurn)
+
+	s.balanceMu.Lock()
+	balance, seen := s.balances[token]
+	if !seen {
+		balance = credits.ToGBP(s.cfg.Credits)
+	}
+	if balance < est {
+		s.balances[token] = balance
+		s.balanceMu.Unlock()
+		s.refusals.Add(1)
+		writeJSON(w, http.StatusPaymentRequired, map[string]string{"error": "Insufficient credits."})
+		return nil, false
+	}
+	s.balances[token] = balance - est
+	s.balanceMu.Unlock()
+
+	return func(u client.UsageData) {
+		cost := 0.0
+		if u.InputTokens+u.OutputTokens > 0 {
+			cost = budget.ComputeCost(req.Model, u.InputTokens, u.OutputTokens)
+		}
+		s.balanceMu.Lock()
+		s.balances[token] += est - cost
+		s.balanceMu.Unlock()
+	}, true
+}
+
+// handleCreditBalance reports the caller's credit balance, so pitstorm
+// can check before a --credits run that the accounts start where its
+// ledger expects. The Pit has no such endpoint; without Config.Credits
+// the mock answers 404 as the real target does.
+func (s *Server) handleCreditBalance(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get("Authorization")
+	if s.cfg.Credits <= 0 || token == "" {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Not found"})
+		return
+	}
+	s.balanceMu.Lock()
+	balance, seen := s.balances[token]
+	s.balanceMu.Unlock()
+	if !seen {
+		balance = credits.ToGBP(s.cfg.Credits)
+	}
+	writeJSON(w, http.StatusOK, map[string]float64{"credits": credits.FromGBP(balance)})
+}
+
+// auth wraps a handler with the RequireAuth bearer-token check.
+func (s *Server) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.RequireAuth && !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// ---------- Helpers ----------
+
+var mockAgents = []struct{ id, name, color string }{
+	{"socrates", "Socrates", "#f5c542"},
+	{"nietzsche", "Nietzsche", "#e04848"},
+}
+
+var mockWords = []string{
+	"The", "premise", "collapses", "under", "its", "own", "weight,",
+	"and", "I", "shall", "demonstrate", "why.",
+}
+
+func (s *Server) delay() time.Duration {
+	d := s.cfg.Latency
+	if s.cfg.Jitter > 0 {
+		s.rngMu.Lock()
+		d += time.Duration(s.rng.Int64N(int64(s.cfg.Jitter)))
+		s.rngMu.Unlock()
+	}
+	return d
+}
+
+func (s *Server) roll(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	s.rngMu.Lock()
+	defer s.rngMu.Unlock()
+	return s.rng.Float64() < p
+}
+
+// decodeBody rejects requests whose body is not valid JSON.
+func decodeBody(w http.ResponseWriter, r *http.Request) bool {
+	var v map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func htmlEscape(s string) string {
+	r := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&#39;")
+	return r.Replace(s)
+}
@@ -0,0 +1,313 @@
+package mockserver
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/action"
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+	"github.com/rickhallett/thepit/pitstorm/internal/client"
+	"github.com/rickhallett/thepit/pitstorm/internal/engine"
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+	"github.com/rickhallett/thepit/pitstorm/internal/persona"
+)
+
+func newTestActor(t *testing.T, cfg Config) (*action.Actor, *Server, *client.Client) {
+	t.Helper()
+	s := New(cfg, nil)
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	clientCfg := client.DefaultConfig(srv.URL)
+	clientCfg.MaxRetries = 0
+	cl := client.New(clientCfg, nil)
+	t.Cleanup(func() { cl.Close() })
+	return action.New(cl), s, cl
+}
+
+func TestRunBoutStream(t *testing.T) {
+	act, s, _ := newTestActor(t, Config{DeltasPerTurn: 5})
+
+	h, err := act.RunBoutStream(context.Background(), "", action.RunBoutRequest{
+		BoutID: action.GenerateID(21), PresetID: "roast-battle", Turns: 3,
+	})
+	if err != nil {
+		t.Fatalf("RunBoutStream: %v", err)
+	}
+	defer h.Close()
+	if h.StatusCode != 200 {
+		t.Fatalf("status = %d, want 200", h.StatusCode)
+	}
+
+	result, err := client.ParseSSEStream(h.Body, nil)
+	if err != nil {
+		t.Fatalf("ParseSSEStream: %v", err)
+	}
+	if len(result.Turns) != 3 {
+		t.Errorf("Turns = %d, want 3", len(result.Turns))
+	}
+	if result.DeltaCount != 15 {
+		t.Errorf("DeltaCount = %d, want 15", result.DeltaCount)
+	}
+	if result.ShareLine == "" || result.Error != "" {
+		t.Errorf("ShareLine = %q, Error = %q", result.ShareLine, result.Error)
+	}
+	if s.Stats().Bouts != 1 {
+		t.Errorf("Bouts = %d, want 1", s.Stats().Bouts)
+	}
+	if result.Usage != nil {
+		t.Errorf("Usage = %+v, want none unless StreamUsage", result.Usage)
+	}
+}
+
+func TestStreamUsage(t *testing.T) {
+	act, _, _ := newTestActor(t, Config{DeltasPerTurn: 10, StreamUsage: true})
+
+	h, err := act.RunBoutStream(context.Background(), "", action.RunBoutRequest{
+		BoutID: action.GenerateID(21), PresetID: "roast-battle", Turns: 2,
+	})
+	if err != nil {
+		t.Fatalf("RunBoutStream: %v", err)
+	}
+	defer h.Close()
+	result, err := client.ParseSSEStream(h.Body, nil)
+	if err != nil {
+		t.Fatalf("ParseSSEStream: %v", err)
+	}
+	// Two turns of 10 words: 13 output tokens each, prompts 450 and 483.
+	if u := result.Usage; u == nil || u.OutputTokens != 26 || u.InputTokens != 933 {
+		t.Errorf("Usage = %+v, want 933 in / 26 out", u)
+	}
+
+	res, err := act.APIBout(context.Background(), "", action.APIBoutRequest{
+		BoutID: action.GenerateID(21), PresetID: "roast-battle", Turns: 2,
+	})
+	if err != nil {
+		t.Fatalf("APIBout: %v", err)
+	}
+	if u := action.ParseUsage(res.Body); u == nil || u.InputTokens == 0 || u.OutputTokens == 0 {
+		t.Errorf("api bout usage = %+v", u)
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	act, _, _ := newTestActor(t, Config{})
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func() (*action.Result, error)
+		want int
+	}{
+		{"health", func() (*action.Result, error) { return act.Health(ctx) }, 200},
+		{"api-bout", func() (*action.Result, error) {
+			return act.APIBout(ctx, "", action.APIBoutRequest{BoutID: "b", PresetID: "p"})
+		}, 200},
+		{"api-bout missing preset", func() (*action.Result, error) {
+			return act.APIBout(ctx, "", action.APIBoutRequest{BoutID: "b"})
+		}, 400},
+		{"agent", func() (*action.Result, error) {
+			return act.CreateAgent(ctx, "", action.CreateAgentRequest{Name: "a"})
+		}, 201},
+		{"reaction", func() (*action.Result, error) {
+			return act.ToggleReaction(ctx, "", action.ReactionRequest{BoutID: "b", ReactionType: "fire"})
+		}, 200},
+		{"vote", func() (*action.Result, error) {
+			return act.CastWinnerVote(ctx, "", action.WinnerVoteRequest{BoutID: "b", AgentID: "socrates"})
+		}, 200},
+		{"short-link", func() (*action.Result, error) {
+			return act.CreateShortLink(ctx, action.ShortLinkRequest{BoutID: "b"})
+		}, 200},
+		{"list features", func() (*action.Result, error) { return act.ListFeatureRequests(ctx) }, 200},
+		{"submit feature", func() (*action.Result, error) {
+			return act.SubmitFeature(ctx, "", action.SubmitFeatureRequest{Title: "t"})
+		}, 201},
+		{"vote feature", func() (*action.Result, error) {
+			return act.VoteFeature(ctx, "", action.FeatureVoteRequest{FeatureRequestID: 1})
+		}, 200},
+		{"paper", func() (*action.Result, error) {
+			return act.SubmitPaper(ctx, "", action.PaperSubmissionRequest{ArxivURL: "x"})
+		}, 201},
+		{"newsletter", func() (*action.Result, error) {
+			return act.SubscribeNewsletter(ctx, action.NewsletterRequest{Email: "a@b.c"})
+		}, 200},
+		{"contact", func() (*action.Result, error) {
+			return act.SendContact(ctx, action.ContactRequest{Name: "n"})
+		}, 200},
+		{"byok", func() (*action.Result, error) {
+			return act.StashBYOK(ctx, "", action.BYOKStashRequest{Provider: "anthropic"})
+		}, 200},
+		{"page", func() (*action.Result, error) { return act.BrowsePage(ctx, "/arena") }, 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.call()
+			if err != nil {
+				t.Fatalf("call: %v", err)
+			}
+			if res.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d (body %s)", res.StatusCode, tt.want, res.Body)
+			}
+		})
+	}
+}
+
+func TestFaultInjection(t *testing.T) {
+	ctx := context.Background()
+
+	act, s, _ := newTestActor(t, Config{RateLimitRate: 1})
+	res, err := act.Health(ctx)
+	if err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+	if res.StatusCode != 429 || s.Stats().RateLimits != 1 {
+		t.Errorf("status = %d, rateLimits = %d; want 429, 1", res.StatusCode, s.Stats().RateLimits)
+	}
+
+	act, s, _ = newTestActor(t, Config{ErrorRate: 1})
+	res, err = act.Health(ctx)
+	if err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+	if res.StatusCode != 500 || s.Stats().Errors != 1 {
+		t.Errorf("status = %d, errors = %d; want 500, 1", res.StatusCode, s.Stats().Errors)
+	}
+
+	act, _, _ = newTestActor(t, Config{Latency: 50 * time.Millisecond})
+	res, err = act.Health(ctx)
+	if err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+	if res.Duration < 50*time.Millisecond {
+		t.Errorf("Duration = %v, want >= 50ms", res.Duration)
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	act, _, cl := newTestActor(t, Config{RequireAuth: true})
+	ctx := context.Background()
+	req := action.ReactionRequest{BoutID: "b", ReactionType: "fire"}
+
+	res, err := act.ToggleReaction(ctx, "account-x", req)
+	if err != nil {
+		t.Fatalf("ToggleReaction: %v", err)
+	}
+	if res.StatusCode != 401 {
+		t.Errorf("status without token = %d, want 401", res.StatusCode)
+	}
+
+	cl.SetToken("account-x", "tok")
+	res, err = act.ToggleReaction(ctx, "account-x", req)
+	if err != nil {
+		t.Fatalf("ToggleReaction: %v", err)
+	}
+	if res.StatusCode != 200 {
+		t.Errorf("status with token = %d, want 200", res.StatusCode)
+	}
+}
+
+func TestCreditDepletion(t *testing.T) {
+	act, s, cl := newTestActor(t, Config{Credits: 2})
+	cl.SetToken("account-lab", "tok")
+	ctx := context.Background()
+	req := action.APIBoutRequest{BoutID: "b", PresetID: "summit", Turns: 2, Model: "claude-opus-4-6"}
+
+	// The bout preauthorizes about 1.02 credits and is charged about 0.44
+	// for its usage, so 2 credits run three bouts.
+	statuses := make([]int, 0, 5)
+	for i := 0; i < 5; i++ {
+		res, err := act.APIBout(ctx, "account-lab", req)
+		if err != nil {
+			t.Fatalf("APIBout: %v", err)
+		}
+		statuses = append(statuses, res.StatusCode)
+	}
+	want := []int{200, 200, 200, 402, 402}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Fatalf("statuses = %v, want %v", statuses, want)
+		}
+	}
+	if st := s.Stats(); st.Refusals != 2 || st.Bouts != 3 {
+		t.Errorf("Refusals = %d, Bouts = %d; want 2, 3", st.Refusals, st.Bouts)
+	}
+
+	// Anonymous callers draw on no balance.
+	if res, err := act.APIBout(ctx, "", req); err != nil || res.StatusCode != 200 {
+		t.Errorf("anonymous bout = %v, %v", res, err)
+	}
+}
+
+func TestCreditBalance(t *testing.T) {
+	act, _, cl := newTestActor(t, Config{Credits: 2})
+	cl.SetToken("account-lab", "tok")
+	ctx := context.Background()
+
+	if got, err := act.CreditBalance(ctx, "account-lab"); err != nil || got != 2 {
+		t.Fatalf("starting balance = %v, %v; want 2", got, err)
+	}
+	req := action.APIBoutRequest{BoutID: "b", PresetID: "summit", Turns: 2, Model: "claude-opus-4-6"}
+	if _, err := act.APIBout(ctx, "account-lab", req); err != nil {
+		t.Fatalf("APIBout: %v", err)
+	}
+	if got, err := act.CreditBalance(ctx, "account-lab"); err != nil || got >= 2 {
+		t.Errorf("balance after a bout = %v, %v; want less than 2", got, err)
+	}
+
+	off, _, clOff := newTestActor(t, Config{})
+	clOff.SetToken("account-lab", "tok")
+	if _, err := off.CreditBalance(ctx, "account-lab"); !errors.Is(err, action.ErrNoCreditBalance) {
+		t.Errorf("without credits err = %v, want ErrNoCreditBalance", err)
+	}
+}
+
+// TestEngineEndToEnd drives the real engine, budget gate, and metrics
+// against the mock with no network access.
+func TestEngineEndToEnd(t *testing.T) {
+	s := New(Config{RateLimitRate: 0.05}, nil)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	clientCfg := client.DefaultConfig(srv.URL)
+	clientCfg.MaxRetries = 0
+	cl := client.New(clientCfg, nil)
+	defer cl.Close()
+
+	m := metrics.NewCollector()
+	gate := budget.NewGate(10)
+	spec := &persona.Spec{
+		ID:   "e2e",
+		Tier: persona.TierAnon,
+		Actions: []persona.WeightedAction{
+			{Action: persona.ActionRunBout, Weight: 2},
+			{Action: persona.ActionBrowse, Weight: 1},
+			{Action: persona.ActionReaction, Weight: 1},
+		},
+		SessionActionsMin: 1,
+		SessionActionsMax: 3,
+		MaxTurns:          2,
+		BoutTopics:        []string{"mock"},
+	}
+	e := engine.New(engine.Config{Workers: 4, Duration: 300 * time.Millisecond},
+		cl, action.New(cl), m, gate, []*persona.Spec{spec}, nil)
+	if err := e.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	snap := m.Snapshot()
+	if snap.Requests == 0 || snap.Successes == 0 {
+		t.Fatalf("Requests = %d, Successes = %d; want both > 0", snap.Requests, snap.Successes)
+	}
+	if snap.BoutsDone == 0 {
+		t.Error("expected completed bouts")
+	}
+	if gate.Summary().SpentGBP <= 0 {
+		t.Error("expected budget to be charged for bouts")
+	}
+	if s.Stats().Requests == 0 {
+		t.Error("mock saw no requests")
+	}
+}
@@ -44,6 +44,12 @@ const (
 	ActionRateLimitFlood Action = "rate-limit-flood"
 )
 
+// IsBout reports whether the action starts a model-backed bout, the
+// only actions that spend budget.
+func (a Action) IsBout() bool {
+	return a == ActionRunBout || a == ActionAPIBout
+}
+
 // WeightedAction pairs an action with a relative probability weight.
 type WeightedAction struct {
 	Action Action
@@ -93,6 +99,13 @@ type Spec struct {
 
 // PickAction selects a random action based on the weighted distribution.
 func (s *Spec) PickAction() Action {
+	return s.PickActionFrom(nil)
+}
+
+// PickActionFrom is PickAction drawing from r. A nil r uses the global
+// generator; seeded runs pass a per-worker generator so the decision
+// sequence is reproducible.
+func (s *Spec) PickActionFrom(r *rand.Rand) Action {
 	if len(s.Actions) == 0 {
 		return ActionBrowse
 	}
@@ -102,40 +115,86 @@ func (s *Spec) PickAction() Action {
 		totalWeight += wa.Weight
 	}
 
-	r := rand.Float64() * totalWeight
+	x := float64From(r) * totalWeight
 	var cumulative float64
 	for _, wa := range s.Actions {
 		cumulative += wa.Weight
-		if r <= cumulative {
+		if x <= cumulative {
 			return wa.Action
 		}
 	}
 	return s.Actions[len(s.Actions)-1].Action
 }
 
+// PickNonBoutActionFrom is PickActionFrom restricted to actions that do
+// not start a bout, keeping their relative weights. It is used once a
+// persona's budget is capped. Without any such action it returns
+// ActionBrowse.
+func (s *Spec) PickNonBoutActionFrom(r *rand.Rand) Action {
+	rest := Spec{Actions: make([]WeightedAction, 0, len(s.Actions))}
+	for _, wa := range s.Actions {
+		if !wa.Action.IsBout() {
+			rest.Actions = append(rest.Actions, wa)
+		}
+	}
+	return rest.PickActionFrom(r)
+}
+
 // SessionLength returns a random action count within the session range.
 func (s *Spec) SessionLength() int {
+	return s.SessionLengthFrom(nil)
+}
+
+// SessionLengthFrom is SessionLength drawing from r (nil = global).
+func (s *Spec) SessionLengthFrom(r *rand.Rand) int {
 	if s.SessionActionsMax <= s.SessionActionsMin {
 		return s.SessionActionsMin
 	}
-	return s.SessionActionsMin + rand.IntN(s.SessionActionsMax-s.SessionActionsMin+1)
+	return s.SessionActionsMin + intNFrom(r, s.SessionActionsMax-s.SessionActionsMin+1)
 }
 
 // ThinkDelay returns a random think time within the configured range.
 func (s *Spec) ThinkDelay() time.Duration {
+	return s.ThinkDelayFrom(nil)
+}
+
+// ThinkDelayFrom is ThinkDelay drawing from r (nil = global).
+func (s *Spec) ThinkDelayFrom(r *rand.Rand) time.Duration {
 	if s.ThinkTimeMax <= s.ThinkTimeMin {
 		return s.ThinkTimeMin
 	}
 	delta := s.ThinkTimeMax - s.ThinkTimeMin
-	return s.ThinkTimeMin + time.Duration(rand.Int64N(int64(delta)))
+	if r == nil {
+		return s.ThinkTimeMin + time.Duration(rand.Int64N(int64(delta)))
+	}
+	return s.ThinkTimeMin + time.Duration(r.Int64N(int64(delta)))
 }
 
 // PickTopic returns a random bout topic from the persona's pool.
 func (s *Spec) PickTopic() string {
+	return s.PickTopicFrom(nil)
+}
+
+// PickTopicFrom is PickTopic drawing from r (nil = global).
+func (s *Spec) PickTopicFrom(r *rand.Rand) string {
 	if len(s.BoutTopics) == 0 {
 		return "The meaning of existence"
 	}
-	return s.BoutTopics[rand.IntN(len(s.BoutTopics))]
+	return s.BoutTopics[intNFrom(r, len(s.BoutTopics))]
+}
+
+func float64From(r *rand.Rand) float64 {
+	if r == nil {
+		return rand.Float64()
+	}
+	return r.Float64()
+}
+
+func intNFrom(r *rand.Rand, n int) int {
+	if r == nil {
+		return rand.IntN(n)
+	}
+	return r.IntN(n)
 }
 
 // ---------- Registry ----------
@@ -156,26 +215,12 @@ func All() []*Spec {
 
 // ByID returns the persona spec matching the given ID.
 func ByID(id string) (*Spec, error) {
-	for _, p := range All() {
-		if p.ID == id {
-			return p, nil
-		}
-	}
-	return nil, fmt.Errorf("unknown persona %q", id)
+	return byIDIn(All(), id)
 }
 
 // ByTag returns all personas matching the given tag.
 func ByTag(tag string) []*Spec {
-	var result []*Spec
-	for _, p := range All() {
-		for _, t := range p.Tags {
-			if t == tag {
-				result = append(result, p)
-				break
-			}
-		}
-	}
-	return result
+	return byTagIn(All(), tag)
 }
 
 // IDs returns the IDs of all personas.
@@ -191,8 +236,15 @@ func IDs() []string {
 // Resolve expands persona filter strings into specs.
 // Accepts: "all", specific IDs, or tag names ("free-only", "paid-only", "stress").
 func Resolve(filters []string) ([]*Spec, error) {
+	return ResolveFrom(All(), filters)
+}
+
+// ResolveFrom expands persona filter strings against the given pool of
+// specs instead of the built-in set. Used when a scenario file extends or
+// replaces the compiled-in personas.
+func ResolveFrom(pool []*Spec, filters []string) ([]*Spec, error) {
 	if len(filters) == 0 || (len(filters) == 1 && filters[0] == "all") {
-		return All(), nil
+		return pool, nil
 	}
 
 	seen := make(map[string]bool)
@@ -200,7 +252,7 @@ func Resolve(filters []string) ([]*Spec, error) {
 
 	for _, f := range filters {
 		// Check if it's a tag first.
-		tagged := ByTag(f)
+		tagged := byTagIn(pool, f)
 		if len(tagged) > 0 {
 			for _, p := range tagged {
 				if !seen[p.ID] {
@@ -212,7 +264,7 @@ func Resolve(filters []string) ([]*Spec, error) {
 		}
 
 		// Otherwise treat as a persona ID.
-		p, err := ByID(f)
+		p, err := byIDIn(pool, f)
 		if err != nil {
 			return nil, err
 		}
@@ -225,6 +277,28 @@ func Resolve(filters []string) ([]*Spec, error) {
 	return result, nil
 }
 
+func byIDIn(pool []*Spec, id string) (*Spec, error) {
+	for _, p := range pool {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown persona %q", id)
+}
+
+func byTagIn(pool []*Spec, tag string) []*Spec {
+	var result []*Spec
+	for _, p := range pool {
+		for _, t := range p.Tags {
+			if t == tag {
+				result = append(result, p)
+				break
+			}
+		}
+	}
+	return result
+}
+
 // ---------- Default bout topics ----------
 
 var generalTopics = []string{
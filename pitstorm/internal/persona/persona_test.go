@@ -1,6 +1,7 @@
 package persona
 
 import (
+	"math/rand/v2"
 	"testing"
 	"time"
 )
@@ -382,3 +383,38 @@ func TestAbusiveUserHasSecurityProbes(t *testing.T) {
 		}
 	}
 }
+
+func TestSeededPicksAreReproducible(t *testing.T) {
+	p := LabPowerUser()
+	a := rand.New(rand.NewPCG(42, 1))
+	b := rand.New(rand.NewPCG(42, 1))
+	for i := 0; i < 50; i++ {
+		if x, y := p.PickActionFrom(a), p.PickActionFrom(b); x != y {
+			t.Fatalf("pick %d: %q != %q with identical seeds", i, x, y)
+		}
+		if x, y := p.PickTopicFrom(a), p.PickTopicFrom(b); x != y {
+			t.Fatalf("topic %d: %q != %q with identical seeds", i, x, y)
+		}
+		if x, y := p.SessionLengthFrom(a), p.SessionLengthFrom(b); x != y {
+			t.Fatalf("session %d: %d != %d with identical seeds", i, x, y)
+		}
+		if x, y := p.ThinkDelayFrom(a), p.ThinkDelayFrom(b); x != y {
+			t.Fatalf("think %d: %v != %v with identical seeds", i, x, y)
+		}
+	}
+}
+
+func TestPickNonBoutActionFrom(t *testing.T) {
+	p := LabPowerUser()
+	r := rand.New(rand.NewPCG(7, 1))
+	for i := 0; i < 200; i++ {
+		if a := p.PickNonBoutActionFrom(r); a.IsBout() {
+			t.Fatalf("pick %d: got bout action %q", i, a)
+		}
+	}
+
+	boutsOnly := &Spec{Actions: []WeightedAction{{ActionRunBout, 1}}}
+	if a := boutsOnly.PickNonBoutActionFrom(r); a != ActionBrowse {
+		t.Errorf("bouts-only persona picked %q, want %q", a, ActionBrowse)
+	}
+}
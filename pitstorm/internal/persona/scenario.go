@@ -0,0 +1,286 @@
+package persona
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario modes control how file-defined personas combine with the
+// built-in set returned by All.
+const (
+	ModeMerge   = "merge"   // file personas override built-ins by ID, new IDs are appended
+	ModeReplace = "replace" // only file personas are used
+)
+
+// Scenario is a declarative traffic mix loaded from a YAML file. It lets
+// persona definitions be changed without recompiling pitstorm.
+//
+// Example:
+//
+//	mode: merge
+//	personas:
+//	  - id: weekend-binger
+//	    name: Weekend Binger
+//	    tier: pass
+//	    actions:
+//	      - {action: run-bout, weight: 60}
+//	      - {action: reaction, weight: 40}
+//	    sessionActions: {min: 5, max: 20}
+//	    thinkTime: {min: 1s, max: 4s}
+//	    model: claude-haiku-4-5-20251001
+//	    maxTurns: 6
+//	    topicPools: [general]
+//	    tags: [paid-only]
+type Scenario struct {
+	Mode     string     `yaml:"mode"`
+	Personas []SpecFile `yaml:"personas"`
+}
+
+// SpecFile is the YAML representation of a Spec.
+type SpecFile struct {
+	ID             string            `yaml:"id"`
+	Name           string            `yaml:"name"`
+	Description    string            `yaml:"description"`
+	Tier           Tier              `yaml:"tier"`
+	RequiresAuth   *bool             `yaml:"requiresAuth"` // nil = derived from tier
+	Actions        []WeightedFile    `yaml:"actions"`
+	SessionActions IntRangeFile      `yaml:"sessionActions"`
+	ThinkTime      DurationRangeFile `yaml:"thinkTime"`
+	Model          string            `yaml:"model"`
+	MaxTurns       int               `yaml:"maxTurns"`
+	Topics         []string          `yaml:"topics"`
+	TopicPools     []string          `yaml:"topicPools"`
+	Tags           []string          `yaml:"tags"`
+}
+
+// WeightedFile is the YAML representation of a WeightedAction.
+type WeightedFile struct {
+	Action Action  `yaml:"action"`
+	Weight float64 `yaml:"weight"`
+}
+
+// IntRangeFile is an inclusive integer range.
+type IntRangeFile struct {
+	Min int `yaml:"min"`
+	Max int `yaml:"max"`
+}
+
+// DurationRangeFile is a duration range; values use time.ParseDuration
+// syntax (e.g. "500ms", "2s").
+type DurationRangeFile struct {
+	Min string `yaml:"min"`
+	Max string `yaml:"max"`
+}
+
+// topicPools maps pool names usable in scenario files to the built-in
+// topic lists.
+var topicPools = map[string][]string{
+	"general":     generalTopics,
+	"technical":   technicalTopics,
+	"provocative": provocativeTopics,
+}
+
+// knownActions is the set of actions the engine's dispatcher understands.
+var knownActions = map[Action]bool{
+	ActionBrowse: true, ActionRunBout: true, ActionAPIBout: true,
+	ActionCreateAgent: true, ActionReaction: true, ActionVote: true,
+	ActionShortLink: true, ActionListFeatures: true, ActionSubmitFeature: true,
+	ActionVoteFeature: true, ActionSubmitPaper: true, ActionNewsletter: true,
+	ActionContact: true, ActionBYOK: true, ActionXSSProbe: true,
+	ActionSQLInjection: true, ActionIDORProbe: true, ActionRateLimitFlood: true,
+}
+
+// LoadScenario reads and validates a scenario file from disk.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read scenario file: %w", err)
+	}
+	return ParseScenario(data)
+}
+
+// ParseScenario decodes and validates scenario YAML. Unknown fields are
+// rejected so typos don't silently fall back to zero values.
+func ParseScenario(data []byte) (*Scenario, error) {
+	var sc Scenario
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&sc); err != nil {
+		return nil, fmt.Errorf("parse scenario file: %w", err)
+	}
+	if sc.Mode == "" {
+		sc.Mode = ModeMerge
+	}
+	if err := sc.Validate(); err != nil {
+		return nil, err
+	}
+	return &sc, nil
+}
+
+// Validate checks the scenario for structural issues.
+func (sc *Scenario) Validate() error {
+	switch sc.Mode {
+	case ModeMerge, ModeReplace:
+	default:
+		return fmt.Errorf("invalid scenario mode %q: must be merge|replace", sc.Mode)
+	}
+	if len(sc.Personas) == 0 {
+		return fmt.Errorf("scenario defines no personas")
+	}
+
+	seen := make(map[string]bool)
+	for i := range sc.Personas {
+		sf := &sc.Personas[i]
+		if sf.ID == "" {
+			return fmt.Errorf("persona[%d]: missing id", i)
+		}
+		if seen[sf.ID] {
+			return fmt.Errorf("persona[%d]: duplicate id %q", i, sf.ID)
+		}
+		seen[sf.ID] = true
+
+		if _, err := sf.Spec(); err != nil {
+			return fmt.Errorf("persona[%d] (%s): %w", i, sf.ID, err)
+		}
+	}
+	return nil
+}
+
+// Spec converts the file representation into a validated Spec.
+func (sf *SpecFile) Spec() (*Spec, error) {
+	switch sf.Tier {
+	case TierAnon, TierFree, TierPass, TierLab:
+	default:
+		return nil, fmt.Errorf("invalid tier %q: must be anon|free|pass|lab", sf.Tier)
+	}
+
+	requiresAuth := sf.Tier != TierAnon
+	if sf.RequiresAuth != nil {
+		requiresAuth = *sf.RequiresAuth
+	}
+	if sf.Tier == TierAnon && requiresAuth {
+		return nil, fmt.Errorf("anon tier cannot require auth")
+	}
+
+	if len(sf.Actions) == 0 {
+		return nil, fmt.Errorf("no actions defined")
+	}
+	actions := make([]WeightedAction, len(sf.Actions))
+	for i, wa := range sf.Actions {
+		if !knownActions[wa.Action] {
+			return nil, fmt.Errorf("action[%d]: unknown action %q", i, wa.Action)
+		}
+		if wa.Weight <= 0 {
+			return nil, fmt.Errorf("action[%d] (%s): weight must be positive, got %g", i, wa.Action, wa.Weight)
+		}
+		actions[i] = WeightedAction{Action: wa.Action, Weight: wa.Weight}
+	}
+
+	if sf.SessionActions.Min < 1 {
+		return nil, fmt.Errorf("sessionActions.min must be >= 1, got %d", sf.SessionActions.Min)
+	}
+	if sf.SessionActions.Max < sf.SessionActions.Min {
+		return nil, fmt.Errorf("sessionActions.max (%d) < min (%d)", sf.SessionActions.Max, sf.SessionActions.Min)
+	}
+
+	thinkMin, err := parseDurationField("thinkTime.min", sf.ThinkTime.Min)
+	if err != nil {
+		return nil, err
+	}
+	thinkMax, err := parseDurationField("thinkTime.max", sf.ThinkTime.Max)
+	if err != nil {
+		return nil, err
+	}
+	if thinkMax < thinkMin {
+		return nil, fmt.Errorf("thinkTime.max (%s) < min (%s)", thinkMax, thinkMin)
+	}
+
+	if sf.MaxTurns < 1 {
+		return nil, fmt.Errorf("maxTurns must be >= 1, got %d", sf.MaxTurns)
+	}
+
+	topics := append([]string(nil), sf.Topics...)
+	for _, pool := range sf.TopicPools {
+		t, ok := topicPools[pool]
+		if !ok {
+			return nil, fmt.Errorf("unknown topic pool %q: must be general|technical|provocative", pool)
+		}
+		topics = append(topics, t...)
+	}
+
+	name := sf.Name
+	if name == "" {
+		name = sf.ID
+	}
+
+	return &Spec{
+		ID:                sf.ID,
+		Name:              name,
+		Description:       sf.Description,
+		Tier:              sf.Tier,
+		RequiresAuth:      requiresAuth,
+		Actions:           actions,
+		SessionActionsMin: sf.SessionActions.Min,
+		SessionActionsMax: sf.SessionActions.Max,
+		ThinkTimeMin:      thinkMin,
+		ThinkTimeMax:      thinkMax,
+		Model:             sf.Model,
+		MaxTurns:          sf.MaxTurns,
+		BoutTopics:        topics,
+		Tags:              append([]string(nil), sf.Tags...),
+	}, nil
+}
+
+// Specs returns the persona pool defined by the scenario: either the
+// file personas alone (replace) or the built-ins with file personas
+// overriding by ID and new ones appended (merge).
+func (sc *Scenario) Specs() []*Spec {
+	file := make([]*Spec, 0, len(sc.Personas))
+	for i := range sc.Personas {
+		// Validated on load; conversion cannot fail here.
+		s, _ := sc.Personas[i].Spec()
+		file = append(file, s)
+	}
+	if sc.Mode == ModeReplace {
+		return file
+	}
+
+	byID := make(map[string]*Spec, len(file))
+	for _, s := range file {
+		byID[s.ID] = s
+	}
+
+	var pool []*Spec
+	for _, p := range All() {
+		if override, ok := byID[p.ID]; ok {
+			pool = append(pool, override)
+			delete(byID, p.ID)
+			continue
+		}
+		pool = append(pool, p)
+	}
+	for _, s := range file {
+		if _, ok := byID[s.ID]; ok {
+			pool = append(pool, s)
+		}
+	}
+	return pool
+}
+
+func parseDurationField(name, v string) (time.Duration, error) {
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%s must not be negative", name)
+	}
+	return d, nil
+}
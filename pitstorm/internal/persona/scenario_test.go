@@ -0,0 +1,160 @@
+package persona
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testScenario = `
+mode: merge
+personas:
+  - id: weekend-binger
+    name: Weekend Binger
+    tier: pass
+    actions:
+      - {action: run-bout, weight: 60}
+      - {action: reaction, weight: 40}
+    sessionActions: {min: 5, max: 20}
+    thinkTime: {min: 1s, max: 4s}
+    model: claude-haiku-4-5-20251001
+    maxTurns: 6
+    topics: ["Is cereal soup?"]
+    topicPools: [technical]
+    tags: [paid-only, weekend]
+  - id: free-lurker
+    tier: anon
+    actions:
+      - {action: browse, weight: 1}
+    sessionActions: {min: 1, max: 1}
+    thinkTime: {min: 100ms, max: 100ms}
+    maxTurns: 2
+    tags: [anon]
+`
+
+func TestParseScenario(t *testing.T) {
+	sc, err := ParseScenario([]byte(testScenario))
+	if err != nil {
+		t.Fatalf("ParseScenario: %v", err)
+	}
+	if sc.Mode != ModeMerge {
+		t.Errorf("Mode = %q, want merge", sc.Mode)
+	}
+
+	s, err := sc.Personas[0].Spec()
+	if err != nil {
+		t.Fatalf("Spec: %v", err)
+	}
+	if !s.RequiresAuth {
+		t.Error("pass-tier persona should default to RequiresAuth")
+	}
+	if s.ThinkTimeMin != time.Second || s.ThinkTimeMax != 4*time.Second {
+		t.Errorf("ThinkTime = %v-%v, want 1s-4s", s.ThinkTimeMin, s.ThinkTimeMax)
+	}
+	if len(s.BoutTopics) != 1+len(technicalTopics) {
+		t.Errorf("BoutTopics = %d, want %d", len(s.BoutTopics), 1+len(technicalTopics))
+	}
+	if s.Actions[0].Action != ActionRunBout || s.Actions[0].Weight != 60 {
+		t.Errorf("Actions[0] = %+v", s.Actions[0])
+	}
+}
+
+func TestScenarioSpecsMerge(t *testing.T) {
+	sc, err := ParseScenario([]byte(testScenario))
+	if err != nil {
+		t.Fatalf("ParseScenario: %v", err)
+	}
+	pool := sc.Specs()
+	if len(pool) != 9 {
+		t.Fatalf("merged pool = %d, want 9", len(pool))
+	}
+	// Override keeps the built-in position.
+	if pool[0].ID != "free-lurker" || pool[0].MaxTurns != 2 {
+		t.Errorf("pool[0] = %s maxTurns=%d, want overridden free-lurker", pool[0].ID, pool[0].MaxTurns)
+	}
+	if pool[8].ID != "weekend-binger" {
+		t.Errorf("pool[8] = %s, want weekend-binger appended", pool[8].ID)
+	}
+
+	resolved, err := ResolveFrom(pool, []string{"weekend"})
+	if err != nil {
+		t.Fatalf("ResolveFrom: %v", err)
+	}
+	if len(resolved) != 1 || resolved[0].ID != "weekend-binger" {
+		t.Errorf("ResolveFrom(weekend) = %v", resolved)
+	}
+}
+
+func TestScenarioSpecsReplace(t *testing.T) {
+	sc, err := ParseScenario([]byte(strings.Replace(testScenario, "mode: merge", "mode: replace", 1)))
+	if err != nil {
+		t.Fatalf("ParseScenario: %v", err)
+	}
+	pool := sc.Specs()
+	if len(pool) != 2 {
+		t.Fatalf("replaced pool = %d, want 2", len(pool))
+	}
+	if _, err := ResolveFrom(pool, []string{"churner"}); err == nil {
+		t.Error("built-in persona should not resolve in replace mode")
+	}
+}
+
+func TestParseScenarioInvalid(t *testing.T) {
+	base := `
+personas:
+  - id: p
+    tier: free
+    actions: [{action: browse, weight: 1}]
+    sessionActions: {min: 1, max: 2}
+    maxTurns: 4
+`
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{"bad mode", "mode: sideways" + base, "invalid scenario mode"},
+		{"empty", "mode: merge\n", "no personas"},
+		{"unknown field", strings.Replace(base, "maxTurns", "maxTurnz", 1), "maxTurnz"},
+		{"bad tier", strings.Replace(base, "tier: free", "tier: gold", 1), "invalid tier"},
+		{"unknown action", strings.Replace(base, "action: browse", "action: teleport", 1), "unknown action"},
+		{"zero weight", strings.Replace(base, "weight: 1", "weight: 0", 1), "weight must be positive"},
+		{"session range", strings.Replace(base, "max: 2", "max: 0", 1), "sessionActions.max"},
+		{"turns", strings.Replace(base, "maxTurns: 4", "maxTurns: 0", 1), "maxTurns"},
+		{"bad duration", base + "    thinkTime: {min: soon}\n", "thinkTime.min"},
+		{"bad pool", base + "    topicPools: [cooking]\n", "unknown topic pool"},
+		{"anon auth", strings.Replace(base, "tier: free", "tier: anon\n    requiresAuth: true", 1), "cannot require auth"},
+		{"duplicate", base + strings.TrimPrefix(base, "\npersonas:\n"), "duplicate id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseScenario([]byte(tt.yaml))
+			if err == nil {
+				t.Fatalf("expected error containing %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want substring %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadScenario(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	if err := os.WriteFile(path, []byte(testScenario), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sc, err := LoadScenario(path)
+	if err != nil {
+		t.Fatalf("LoadScenario: %v", err)
+	}
+	if len(sc.Personas) != 2 {
+		t.Errorf("Personas = %d, want 2", len(sc.Personas))
+	}
+
+	if _, err := LoadScenario(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("LoadScenario(missing) should error")
+	}
+}
@@ -0,0 +1,126 @@
+package probe
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/action"
+)
+
+// Marker is embedded in every injected payload so reflections can be
+// told apart from the target's own markup.
+const Marker = "pitstorm-probe"
+
+// XSSPayloads are script injections; a finding is any of them
+// reflected verbatim.
+var XSSPayloads = []string{
+	`<script>alert('` + Marker + `')</script>`,
+	`<img src=x onerror=alert('` + Marker + `')>`,
+	`"><svg/onload=alert('` + Marker + `')>`,
+}
+
+// SQLiPayloads are SQL injections; a finding is a 5xx or a database
+// error leaking into the response.
+var SQLiPayloads = []string{
+	`' OR 1=1 --`,
+	`'; DROP TABLE bouts; --`,
+	`1 UNION SELECT * FROM users --`,
+	`' AND pg_sleep(0) IS NULL --`,
+}
+
+// DefaultBurst is the number of back-to-back requests a rate-limit
+// probe fires.
+const DefaultBurst = 30
+
+// Catalogue returns every probe. victim is the user ID that IDOR probes
+// try to read as another (or no) account.
+func Catalogue(victim string) []Probe {
+	var out []Probe
+	add := func(p Probe) {
+		p.ID = fmt.Sprintf("%s-%02d", p.Kind, countKind(out, p.Kind)+1)
+		out = append(out, p)
+	}
+
+	// XSS: query parameters rendered into pages, and stored fields.
+	for _, payload := range XSSPayloads {
+		q := url.QueryEscape(payload)
+		add(Probe{Kind: KindXSS, Method: "GET", Path: "/?q=" + q, Payload: payload})
+		add(Probe{Kind: KindXSS, Method: "GET", Path: "/arena?topic=" + q, Payload: payload})
+		add(Probe{Kind: KindXSS, Method: "POST", Path: "/api/agents", Auth: true, Payload: payload,
+			Body: action.CreateAgentRequest{Name: payload, SystemPrompt: "Security probe agent " + Marker}})
+		add(Probe{Kind: KindXSS, Method: "POST", Path: "/api/feature-requests", Auth: true, Payload: payload,
+			Body: action.SubmitFeatureRequest{Title: payload, Description: "Security probe " + Marker, Category: "other"}})
+		add(Probe{Kind: KindXSS, Method: "POST", Path: "/api/contact", Payload: payload,
+			Body: action.ContactRequest{Name: payload, Email: "probe@test.thepit.cloud", Message: payload}})
+	}
+
+	// SQL injection: every parameter that reaches a query.
+	for _, payload := range SQLiPayloads {
+		q := url.QueryEscape(payload)
+		add(Probe{Kind: KindSQLi, Method: "GET", Path: "/api/health?id=" + q, Payload: payload})
+		add(Probe{Kind: KindSQLi, Method: "GET", Path: "/api/agents?userId=" + q, Payload: payload})
+		add(Probe{Kind: KindSQLi, Method: "GET", Path: "/api/feature-requests?category=" + q, Payload: payload})
+		add(Probe{Kind: KindSQLi, Method: "POST", Path: "/api/short-links", Payload: payload,
+			Body: action.ShortLinkRequest{BoutID: payload}})
+		add(Probe{Kind: KindSQLi, Method: "POST", Path: "/api/newsletter", Payload: payload,
+			Body: action.NewsletterRequest{Email: payload}})
+	}
+
+	// IDOR: another user's resources, as the probing account.
+	v := url.QueryEscape(victim)
+	add(Probe{Kind: KindIDOR, Method: "GET", Path: "/api/agents?userId=" + v, Auth: true, Payload: victim})
+	add(Probe{Kind: KindIDOR, Method: "GET", Path: "/api/agents?ownerId=" + v, Auth: true, Payload: victim})
+	add(Probe{Kind: KindIDOR, Method: "GET", Path: "/api/feature-requests?userId=" + v, Auth: true, Payload: victim})
+	add(Probe{Kind: KindIDOR, Method: "GET", Path: "/api/user/" + url.PathEscape(victim), Auth: true, Payload: victim})
+
+	// Rate limiting: endpoints that must throttle a single client.
+	add(Probe{Kind: KindRateLimit, Method: "POST", Path: "/api/contact", Burst: DefaultBurst,
+		Body: action.ContactRequest{Name: "Probe", Email: "probe@test.thepit.cloud", Message: "Rate-limit probe " + Marker}})
+	add(Probe{Kind: KindRateLimit, Method: "POST", Path: "/api/newsletter", Burst: DefaultBurst,
+		Body: action.NewsletterRequest{Email: "probe@test.thepit.cloud"}})
+	add(Probe{Kind: KindRateLimit, Method: "POST", Path: "/api/short-links", Burst: DefaultBurst,
+		Body: action.ShortLinkRequest{BoutID: "probe"}})
+	add(Probe{Kind: KindRateLimit, Method: "POST", Path: "/api/agents", Auth: true, Burst: DefaultBurst,
+		Body: action.CreateAgentRequest{Name: "Probe", SystemPrompt: "Rate-limit probe " + Marker}})
+	return out
+}
+
+// GETPaths returns the paths of kind's GET probes, for load runs that
+// mix probes into ordinary traffic.
+func GETPaths(kind Kind, victim string) []string {
+	var out []string
+	for _, p := range Catalogue(victim) {
+		if p.Kind == kind && p.Method == "GET" {
+			out = append(out, p.Path)
+		}
+	}
+	return out
+}
+
+// Filter keeps the probes of the given kinds; no kinds keeps them all.
+func Filter(probes []Probe, kinds []Kind) []Probe {
+	if len(kinds) == 0 {
+		return probes
+	}
+	want := make(map[Kind]bool, len(kinds))
+	for _, k := range kinds {
+		want[k] = true
+	}
+	var out []Probe
+	for _, p := range probes {
+		if want[p.Kind] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func countKind(probes []Probe, k Kind) int {
+	n := 0
+	for _, p := range probes {
+		if p.Kind == k {
+			n++
+		}
+	}
+	return n
+}
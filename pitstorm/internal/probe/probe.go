@@ -0,0 +1,430 @@
+// Package probe is pitstorm's security probe suite: a catalogue of XSS,
+// SQL injection, IDOR and rate-limit probes per endpoint, each judged
+// by an explicit oracle, producing a findings report that is kept
+// separate from the load metrics.
+//
+// The oracles are deliberately simple and conservative:
+//
+//   - xss: the payload is reflected verbatim in the response.
+//   - sqli: an injection yields a 5xx or a database error message.
+//   - idor: a 2xx response contains records owned by the victim account.
+//     Without a probing account or a real victim there is nothing to
+//     read across, so these probes are skipped rather than passed.
+//   - rate-limit: a burst of requests never sees a 429.
+package probe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/client"
+)
+
+// Kind is a class of probe.
+type Kind string
+
+const (
+	KindXSS       Kind = "xss"
+	KindSQLi      Kind = "sqli"
+	KindIDOR      Kind = "idor"
+	KindRateLimit Kind = "rate-limit"
+)
+
+// Kinds lists every probe kind in report order.
+var Kinds = []Kind{KindXSS, KindSQLi, KindIDOR, KindRateLimit}
+
+// ParseKind validates a probe kind name.
+func ParseKind(s string) (Kind, error) {
+	for _, k := range Kinds {
+		if string(k) == s {
+			return k, nil
+		}
+	}
+	return "", fmt.Errorf("unknown probe kind %q (want xss, sqli, idor or rate-limit)", s)
+}
+
+// Probe is one request, or for rate-limit probes a burst of identical
+// requests, and what it injects.
+type Probe struct {
+	ID      string `json:"id"`
+	Kind    Kind   `json:"kind"`
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Body    any    `json:"body,omitempty"`
+	Payload string `json:"payload,omitempty"`
+	// Auth sends the request as the probing account, when there is one.
+	Auth bool `json:"auth,omitempty"`
+	// Burst is the number of requests a rate-limit probe fires.
+	Burst int `json:"burst,omitempty"`
+}
+
+// Outcome is an oracle's verdict.
+type Outcome string
+
+const (
+	OutcomePass    Outcome = "pass"
+	OutcomeFail    Outcome = "fail"    // a finding
+	OutcomeError   Outcome = "error"   // no response to judge
+	OutcomeSkipped Outcome = "skipped" // nothing the oracle could test
+)
+
+// PlaceholderVictim is the user ID IDOR probes target when no real
+// second account is known. It owns no records, so IDOR probes against
+// it are skipped.
+const PlaceholderVictim = "user_pitstorm_probe_victim"
+
+// Severity ranks a finding.
+type Severity string
+
+const (
+	SeverityHigh   Severity = "high"
+	SeverityMedium Severity = "medium"
+)
+
+// Result is one probe's verdict.
+type Result struct {
+	Probe
+	Outcome    Outcome  `json:"outcome"`
+	Severity   Severity `json:"severity,omitempty"`
+	Status     int      `json:"status,omitempty"`
+	Evidence   string   `json:"evidence"`
+	DurationMs float64  `json:"durationMs"`
+}
+
+// KindSummary counts the verdicts for one kind.
+type KindSummary struct {
+	Kind     Kind `json:"kind"`
+	Probes   int  `json:"probes"`
+	Passed   int  `json:"passed"`
+	Findings int  `json:"findings"`
+	Errors   int  `json:"errors"`
+	Skipped  int  `json:"skipped,omitempty"`
+}
+
+// Report is the outcome of a probe run.
+type Report struct {
+	Target     string        `json:"target"`
+	StartedAt  time.Time     `json:"startedAt"`
+	DurationMs float64       `json:"durationMs"`
+	Account    string        `json:"account,omitempty"`
+	Victim     string        `json:"victim"`
+	Summary    []KindSummary `json:"summary"`
+	Results    []Result      `json:"results"`
+}
+
+// Findings returns the failed probes, most severe first.
+func (r Report) Findings() []Result {
+	var out []Result
+	for _, res := range r.Results {
+		if res.Outcome == OutcomeFail {
+			out = append(out, res)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Severity == SeverityHigh && out[j].Severity != SeverityHigh })
+	return out
+}
+
+// Config controls a Runner.
+type Config struct {
+	// Account is the probing account's ID in the client's token store;
+	// empty probes anonymously.
+	Account string
+	// Victim is the user ID that IDOR probes try to read.
+	Victim string
+	// Burst overrides each rate-limit probe's request count when > 0.
+	Burst int
+}
+
+// Runner executes probes sequentially through a client. The client
+// should not retry, so that every response reaches the oracle.
+type Runner struct {
+	c    *client.Client
+	cfg  Config
+	logf func(string, ...any)
+}
+
+// NewRunner creates a Runner.
+func NewRunner(c *client.Client, cfg Config, logf func(string, ...any)) *Runner {
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+	return &Runner{c: c, cfg: cfg, logf: logf}
+}
+
+// Run executes every probe and returns the report. It stops early, with
+// the results so far, when ctx is done.
+func (r *Runner) Run(ctx context.Context, probes []Probe) Report {
+	rep := Report{StartedAt: time.Now().UTC(), Account: r.cfg.Account, Victim: r.cfg.Victim}
+	for _, p := range probes {
+		if ctx.Err() != nil {
+			break
+		}
+		var res Result
+		switch reason := r.untestable(p); {
+		case reason != "":
+			res = Result{Probe: p, Outcome: OutcomeSkipped, Evidence: reason}
+		case p.Kind == KindRateLimit:
+			res = r.runBurst(ctx, p)
+		default:
+			res = r.runOne(ctx, p)
+		}
+		r.logf("[probe] %-14s %-5s %s %s: %s", p.ID, res.Outcome, p.Method, p.Path, res.Evidence)
+		rep.Results = append(rep.Results, res)
+	}
+	rep.DurationMs = float64(time.Since(rep.StartedAt).Microseconds()) / 1000
+	rep.Summary = summarise(rep.Results)
+	return rep
+}
+
+// untestable returns why p can't be judged in this run, or "". An IDOR
+// probe sent anonymously, or for a victim that owns nothing, would pass
+// without testing anything.
+func (r *Runner) untestable(p Probe) string {
+	if p.Kind != KindIDOR {
+		return ""
+	}
+	switch {
+	case r.cfg.Account == "":
+		return "no probing account (anonymous)"
+	case r.cfg.Victim == "" || r.cfg.Victim == PlaceholderVictim:
+		return "no real victim account"
+	}
+	return ""
+}
+
+func (r *Runner) account(p Probe) string {
+	if p.Auth {
+		return r.cfg.Account
+	}
+	return ""
+}
+
+func (r *Runner) runOne(ctx context.Context, p Probe) Result {
+	res := Result{Probe: p}
+	resp, err := r.c.Do(ctx, p.Method, p.Path, r.account(p), p.Body)
+	if err != nil {
+		res.Outcome, res.Evidence = OutcomeError, err.Error()
+		return res
+	}
+	res.Status = resp.StatusCode
+	res.DurationMs = float64(resp.Duration.Microseconds()) / 1000
+	res.Outcome, res.Severity, res.Evidence = Judge(p, resp.StatusCode, resp.Headers, resp.Body, r.cfg.Victim)
+	return res
+}
+
+func (r *Runner) runBurst(ctx context.Context, p Probe) Result {
+	res := Result{Probe: p}
+	n := p.Burst
+	if r.cfg.Burst > 0 {
+		n = r.cfg.Burst
+	}
+	res.Burst = n
+
+	statuses := make(map[int]int)
+	start := time.Now()
+	sent := 0
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		resp, err := r.c.Do(ctx, p.Method, p.Path, r.account(p), p.Body)
+		if err != nil {
+			continue
+		}
+		sent++
+		statuses[resp.StatusCode]++
+		if resp.StatusCode == http.StatusTooManyRequests {
+			res.Status = resp.StatusCode
+			res.Outcome = OutcomePass
+			res.Evidence = fmt.Sprintf("429 after %d requests", sent)
+			break
+		}
+	}
+	res.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+
+	switch {
+	case res.Outcome == OutcomePass:
+	case sent == 0:
+		res.Outcome, res.Evidence = OutcomeError, fmt.Sprintf("all %d requests failed", n)
+	default:
+		res.Outcome, res.Severity = OutcomeFail, SeverityMedium
+		res.Evidence = fmt.Sprintf("no 429 in %d requests (%s)", sent, formatStatuses(statuses))
+	}
+	return res
+}
+
+// dbErrors match database errors leaking into a response.
+var dbErrors = regexp.MustCompile(`(?i)syntax error at or near|unterminated quoted string|SQLSTATE|pg_query|PostgresError|sqlite_error|mysql_fetch|ORA-\d{5}`)
+
+// Judge applies p's oracle to a single response. IDOR probes need the
+// victim's user ID.
+func Judge(p Probe, status int, header http.Header, body []byte, victim string) (Outcome, Severity, string) {
+	switch p.Kind {
+	case KindXSS:
+		if p.Payload != "" && bytes.Contains(body, []byte(p.Payload)) {
+			ct := header.Get("Content-Type")
+			sev := SeverityMedium
+			if strings.Contains(ct, "html") {
+				sev = SeverityHigh
+			}
+			return OutcomeFail, sev, fmt.Sprintf("payload reflected unescaped (HTTP %d, %s)", status, ct)
+		}
+		return OutcomePass, "", fmt.Sprintf("not reflected (HTTP %d)", status)
+
+	case KindSQLi:
+		if status >= 500 {
+			return OutcomeFail, SeverityHigh, fmt.Sprintf("HTTP %d on injected input", status)
+		}
+		if m := dbErrors.Find(body); m != nil {
+			return OutcomeFail, SeverityHigh, fmt.Sprintf("database error in response: %q", m)
+		}
+		return OutcomePass, "", fmt.Sprintf("handled (HTTP %d)", status)
+
+	case KindIDOR:
+		if status >= 200 && status < 300 && ownsRecords(body, victim) {
+			return OutcomeFail, SeverityHigh, fmt.Sprintf("HTTP %d returned records owned by %s", status, victim)
+		}
+		return OutcomePass, "", fmt.Sprintf("no records of %s (HTTP %d)", victim, status)
+	}
+	return OutcomeError, "", fmt.Sprintf("no single-response oracle for %s probes", p.Kind)
+}
+
+// ownsRecords reports whether a JSON body contains an object whose
+// owner field (userId, ownerId, createdBy, ...) is victim. A bare echo
+// of the ID elsewhere in the body does not count.
+func ownsRecords(body []byte, victim string) bool {
+	if victim == "" {
+		return false
+	}
+	var v any
+	if json.Unmarshal(body, &v) != nil {
+		return false
+	}
+	return walkOwners(v, victim)
+}
+
+func walkOwners(v any, victim string) bool {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			switch strings.ToLower(k) {
+			case "userid", "user_id", "ownerid", "owner_id", "createdby", "created_by", "clerkuserid":
+				if s, ok := child.(string); ok && s == victim {
+					return true
+				}
+			}
+			if walkOwners(child, victim) {
+				return true
+			}
+		}
+	case []any:
+		for _, child := range t {
+			if walkOwners(child, victim) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func summarise(results []Result) []KindSummary {
+	byKind := make(map[Kind]*KindSummary)
+	for _, res := range results {
+		s, ok := byKind[res.Kind]
+		if !ok {
+			s = &KindSummary{Kind: res.Kind}
+			byKind[res.Kind] = s
+		}
+		s.Probes++
+		switch res.Outcome {
+		case OutcomePass:
+			s.Passed++
+		case OutcomeFail:
+			s.Findings++
+		case OutcomeSkipped:
+			s.Skipped++
+		default:
+			s.Errors++
+		}
+	}
+	var out []KindSummary
+	for _, k := range Kinds {
+		if s, ok := byKind[k]; ok {
+			out = append(out, *s)
+		}
+	}
+	return out
+}
+
+// skipReasons returns each distinct kind and reason of the skipped
+// probes, in result order.
+func skipReasons(results []Result) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, res := range results {
+		if res.Outcome != OutcomeSkipped {
+			continue
+		}
+		line := fmt.Sprintf("%s: %s", res.Kind, res.Evidence)
+		if !seen[line] {
+			seen[line] = true
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+func formatStatuses(statuses map[int]int) string {
+	codes := make([]int, 0, len(statuses))
+	for c := range statuses {
+		codes = append(codes, c)
+	}
+	sort.Ints(codes)
+	parts := make([]string, len(codes))
+	for i, c := range codes {
+		parts[i] = fmt.Sprintf("%d×%d", c, statuses[c])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// FormatReport returns a terminal-friendly findings report.
+func FormatReport(r Report) string {
+	var b strings.Builder
+	total, findings, errs, skipped := 0, 0, 0, 0
+	for _, s := range r.Summary {
+		total += s.Probes
+		findings += s.Findings
+		errs += s.Errors
+		skipped += s.Skipped
+	}
+	fmt.Fprintf(&b, "  Probes:   %d run, %d findings, %d errors", total-skipped, findings, errs)
+	if skipped > 0 {
+		fmt.Fprintf(&b, ", %d skipped", skipped)
+	}
+	fmt.Fprintf(&b, " (%.1fs)\n", r.DurationMs/1000)
+	for _, s := range r.Summary {
+		fmt.Fprintf(&b, "    %-12s %3d probes  %3d passed  %3d findings  %3d errors",
+			s.Kind, s.Probes, s.Passed, s.Findings, s.Errors)
+		if s.Skipped > 0 {
+			fmt.Fprintf(&b, "  %3d skipped", s.Skipped)
+		}
+		b.WriteString("\n")
+	}
+	if reasons := skipReasons(r.Results); len(reasons) > 0 {
+		b.WriteString("\n  Skipped:\n")
+		for _, reason := range reasons {
+			fmt.Fprintf(&b, "    %s\n", reason)
+		}
+	}
+	if fs := r.Findings(); len(fs) > 0 {
+		b.WriteString("\n  Findings:\n")
+		for _, f := range fs {
+			fmt.Fprintf(&b, "    [%s] %s %s %s\n      %s\n", strings.ToUpper(string(f.Severity)), f.ID, f.Method, f.Path, f.Evidence)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,171 @@
+package probe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/client"
+)
+
+// target serves every probed endpoint, either vulnerably or safely.
+func target(vulnerable bool) http.HandlerFunc {
+	var hits atomic.Int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case r.Method == "POST":
+			if !vulnerable && hits.Add(1) > 5 {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case strings.Contains(q.Get("id")+q.Get("userId")+q.Get("category"), "'"):
+			if vulnerable {
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprint(w, `{"error":"syntax error at or near \"OR\""}`)
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+		case q.Get("userId") != "":
+			w.Header().Set("Content-Type", "application/json")
+			if vulnerable {
+				json.NewEncoder(w).Encode([]map[string]string{{"id": "a1", "userId": q.Get("userId")}})
+				return
+			}
+			fmt.Fprint(w, `[]`)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			topic := q.Get("q") + q.Get("topic")
+			if !vulnerable {
+				topic = html.EscapeString(topic)
+			}
+			fmt.Fprintf(w, "<html><body>%s</body></html>", topic)
+		}
+	}
+}
+
+func runAgainst(t *testing.T, vulnerable bool) Report {
+	t.Helper()
+	return runAs(t, target(vulnerable), Config{Account: "attacker", Victim: "user_victim", Burst: 10})
+}
+
+// runAs runs the catalogue for cfg.Victim against handler, with a token
+// for cfg.Account.
+func runAs(t *testing.T, handler http.HandlerFunc, cfg Config) Report {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	clientCfg := client.DefaultConfig(srv.URL)
+	clientCfg.MaxRetries = 0
+	cl := client.New(clientCfg, nil)
+	t.Cleanup(cl.Close)
+	if cfg.Account != "" {
+		cl.SetToken(cfg.Account, "token")
+	}
+
+	return NewRunner(cl, cfg, nil).Run(context.Background(), Catalogue(cfg.Victim))
+}
+
+func summaryOf(rep Report, k Kind) KindSummary {
+	for _, s := range rep.Summary {
+		if s.Kind == k {
+			return s
+		}
+	}
+	return KindSummary{}
+}
+
+func TestRunnerFindsVulnerabilities(t *testing.T) {
+	rep := runAgainst(t, true)
+
+	// The GET reflections are found; the POST bodies are not echoed.
+	if s := summaryOf(rep, KindXSS); s.Findings != 2*len(XSSPayloads) {
+		t.Errorf("xss = %+v", s)
+	}
+	quoted := 0 // the target only chokes on quotes
+	for _, p := range SQLiPayloads {
+		if strings.Contains(p, "'") {
+			quoted++
+		}
+	}
+	if s := summaryOf(rep, KindSQLi); s.Findings != 3*quoted {
+		t.Errorf("sqli = %+v", s)
+	}
+	if s := summaryOf(rep, KindIDOR); s.Findings != 2 { // userId on both list endpoints
+		t.Errorf("idor = %+v", s)
+	}
+	if s := summaryOf(rep, KindRateLimit); s.Findings != s.Probes || s.Probes == 0 {
+		t.Errorf("rate-limit = %+v", s)
+	}
+	fs := rep.Findings()
+	if len(fs) == 0 || fs[0].Severity != SeverityHigh {
+		t.Errorf("findings not ordered by severity: %+v", fs)
+	}
+	if !strings.Contains(FormatReport(rep), "[HIGH]") {
+		t.Errorf("report missing findings:\n%s", FormatReport(rep))
+	}
+}
+
+func TestRunnerPassesSafeTarget(t *testing.T) {
+	rep := runAgainst(t, false)
+	for _, s := range rep.Summary {
+		if s.Findings != 0 || s.Errors != 0 {
+			t.Errorf("%s = %+v, want all passed", s.Kind, s)
+		}
+	}
+	if len(rep.Findings()) != 0 {
+		t.Errorf("findings = %+v", rep.Findings())
+	}
+}
+
+func TestRunnerSkipsUntestableIDOR(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"anonymous":   {Victim: "user_victim", Burst: 10},
+		"placeholder": {Account: "attacker", Victim: PlaceholderVictim, Burst: 10},
+	} {
+		rep := runAs(t, target(true), cfg)
+		if s := summaryOf(rep, KindIDOR); s.Skipped != s.Probes || s.Probes == 0 || s.Passed != 0 {
+			t.Errorf("%s: idor = %+v, want all skipped", name, s)
+		}
+		if out := FormatReport(rep); !strings.Contains(out, "skipped") {
+			t.Errorf("%s: report doesn't mention skipped probes:\n%s", name, out)
+		}
+	}
+}
+
+func TestJudgeIDORIgnoresEcho(t *testing.T) {
+	p := Probe{Kind: KindIDOR}
+	body := []byte(`{"error":"not allowed","requested":"user_victim"}`)
+	if out, _, _ := Judge(p, 200, http.Header{}, body, "user_victim"); out != OutcomePass {
+		t.Errorf("echoed ID judged %s, want pass", out)
+	}
+	body = []byte(`{"agents":[{"name":"x","ownerId":"user_victim"}]}`)
+	if out, _, _ := Judge(p, 200, http.Header{}, body, "user_victim"); out != OutcomeFail {
+		t.Errorf("owned record judged %s, want fail", out)
+	}
+}
+
+func TestCatalogueIDs(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, p := range Catalogue("v") {
+		if seen[p.ID] {
+			t.Errorf("duplicate probe ID %s", p.ID)
+		}
+		seen[p.ID] = true
+	}
+	if got := Filter(Catalogue("v"), []Kind{KindIDOR}); len(got) != 4 {
+		t.Errorf("Filter(idor) = %d probes, want 4", len(got))
+	}
+	for _, path := range GETPaths(KindXSS, "v") {
+		if strings.ContainsAny(path, " <>") {
+			t.Errorf("unescaped GET path %q", path)
+		}
+	}
+}
@@ -8,6 +8,9 @@
 //   - ramp:    linear ramp up over 60% of duration, then ease off
 //   - spike:   sudden burst at the midpoint
 //   - viral:   exponential ramp up simulating viral growth
+//
+// Custom piecewise schedules (see Schedule) can be given inline in place
+// of a profile name or loaded from a YAML file.
 package profile
 
 import (
@@ -20,7 +23,16 @@ import (
 type RateFunc func(elapsed time.Duration, total time.Duration) float64
 
 // Get returns the RateFunc for the named profile at the given peak rate.
+// An inline schedule spec (see ParseSchedule) is also accepted, in which
+// case the schedule's own rates apply and peakRate is ignored.
 func Get(name string, peakRate float64) (RateFunc, error) {
+	if IsScheduleSpec(name) {
+		s, err := ParseSchedule(name)
+		if err != nil {
+			return nil, err
+		}
+		return s.RateFunc(), nil
+	}
 	switch name {
 	case "trickle":
 		return Trickle(peakRate), nil
@@ -158,6 +170,9 @@ func Describe(name string) string {
 	case "viral":
 		return "Exponential growth from 1% to 100% of peak over duration"
 	default:
+		if s, err := ParseSchedule(name); err == nil {
+			return s.Describe()
+		}
 		return "Unknown profile"
 	}
 }
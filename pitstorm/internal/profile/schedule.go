@@ -0,0 +1,257 @@
+package profile
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Interpolation controls how a Schedule moves between points.
+type Interpolation string
+
+const (
+	Linear Interpolation = "linear" // ramp linearly between points
+	Step   Interpolation = "step"   // hold each point's rate until the next
+)
+
+// Point is a target rate at an offset from the start of the run (or of
+// the cycle, for a repeating schedule).
+type Point struct {
+	At   time.Duration
+	Rate float64
+}
+
+// Schedule is a custom rate profile defined by (offset, req/s) points.
+// Before the first point the first rate applies; after the last point
+// the last rate holds, unless Repeat is set, in which case the schedule
+// wraps every Repeat (e.g. 24h for a diurnal cycle).
+type Schedule struct {
+	Interpolation Interpolation
+	Points        []Point // sorted by At
+	Repeat        time.Duration
+}
+
+// ScheduleFile is the YAML representation of a Schedule. Offsets and
+// the repeat period use time.ParseDuration syntax.
+//
+// Example (a compressed production day, one cycle per hour):
+//
+//	interpolation: linear
+//	repeat: 1h
+//	points:
+//	  - {at: 0s, rate: 2}     # midnight trough
+//	  - {at: 20m, rate: 4}
+//	  - {at: 35m, rate: 20}   # evening peak
+//	  - {at: 50m, rate: 8}
+//	  - {at: 1h, rate: 2}
+type ScheduleFile struct {
+	Interpolation Interpolation `yaml:"interpolation"`
+	Repeat        string        `yaml:"repeat"`
+	Points        []PointFile   `yaml:"points"`
+}
+
+// PointFile is the YAML representation of a Point.
+type PointFile struct {
+	At   string  `yaml:"at"`
+	Rate float64 `yaml:"rate"`
+}
+
+// LoadSchedule reads and validates a schedule file from disk.
+func LoadSchedule(path string) (*Schedule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read schedule file: %w", err)
+	}
+	var f ScheduleFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("parse schedule file: %w", err)
+	}
+
+	s := &Schedule{Interpolation: f.Interpolation}
+	if f.Repeat != "" {
+		if s.Repeat, err = time.ParseDuration(f.Repeat); err != nil {
+			return nil, fmt.Errorf("schedule repeat: %w", err)
+		}
+	}
+	for i, p := range f.Points {
+		at, err := time.ParseDuration(p.At)
+		if err != nil {
+			return nil, fmt.Errorf("schedule point %d: %w", i+1, err)
+		}
+		s.Points = append(s.Points, Point{At: at, Rate: p.Rate})
+	}
+	if err := s.normalize(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// IsScheduleSpec reports whether a --profile value is an inline schedule
+// rather than a built-in profile name.
+func IsScheduleSpec(spec string) bool {
+	return strings.HasPrefix(spec, string(Linear)+":") || strings.HasPrefix(spec, string(Step)+":")
+}
+
+// ParseSchedule parses an inline schedule of the form
+//
+//	<linear|step>:<offset>=<rate>,<offset>=<rate>,...[@<repeat>]
+//
+// e.g. "linear:0s=2,5m=20,10m=5" or "step:0=1,30s=10@1m".
+func ParseSchedule(spec string) (*Schedule, error) {
+	kind, rest, ok := strings.Cut(spec, ":")
+	if !ok {
+		return nil, fmt.Errorf("schedule %q: expected <linear|step>:<offset>=<rate>,...", spec)
+	}
+	s := &Schedule{Interpolation: Interpolation(kind)}
+
+	if body, repeat, ok := strings.Cut(rest, "@"); ok {
+		d, err := time.ParseDuration(repeat)
+		if err != nil {
+			return nil, fmt.Errorf("schedule repeat: %w", err)
+		}
+		s.Repeat, rest = d, body
+	}
+
+	for i, part := range strings.Split(rest, ",") {
+		at, rate, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("schedule point %d %q: expected <offset>=<rate>", i+1, part)
+		}
+		d, err := time.ParseDuration(at)
+		if err != nil {
+			return nil, fmt.Errorf("schedule point %d: %w", i+1, err)
+		}
+		r, err := strconv.ParseFloat(rate, 64)
+		if err != nil {
+			return nil, fmt.Errorf("schedule point %d: invalid rate %q", i+1, rate)
+		}
+		s.Points = append(s.Points, Point{At: d, Rate: r})
+	}
+	if err := s.normalize(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// normalize defaults and validates the schedule and sorts its points.
+func (s *Schedule) normalize() error {
+	if s.Interpolation == "" {
+		s.Interpolation = Linear
+	}
+	if s.Interpolation != Linear && s.Interpolation != Step {
+		return fmt.Errorf("schedule: interpolation must be linear|step, got %q", s.Interpolation)
+	}
+	if len(s.Points) == 0 {
+		return fmt.Errorf("schedule: at least one point is required")
+	}
+	if s.Repeat < 0 {
+		return fmt.Errorf("schedule: repeat must not be negative")
+	}
+	sort.SliceStable(s.Points, func(i, j int) bool { return s.Points[i].At < s.Points[j].At })
+	for i, p := range s.Points {
+		if p.At < 0 {
+			return fmt.Errorf("schedule point %d: offset must not be negative", i+1)
+		}
+		if p.Rate < 0 {
+			return fmt.Errorf("schedule point %d: rate must not be negative", i+1)
+		}
+		if i > 0 && p.At == s.Points[i-1].At {
+			return fmt.Errorf("schedule: duplicate offset %s", p.At)
+		}
+		if s.Repeat > 0 && p.At > s.Repeat {
+			return fmt.Errorf("schedule point %d: offset %s is beyond the repeat period %s", i+1, p.At, s.Repeat)
+		}
+	}
+	if s.Peak() == 0 {
+		return fmt.Errorf("schedule: every rate is zero")
+	}
+	return nil
+}
+
+// Rate returns the scheduled rate at elapsed.
+func (s *Schedule) Rate(elapsed time.Duration) float64 {
+	if s.Repeat > 0 {
+		elapsed %= s.Repeat
+	}
+	pts := s.Points
+	// First point strictly after elapsed.
+	i := sort.Search(len(pts), func(i int) bool { return pts[i].At > elapsed })
+	switch {
+	case i == 0:
+		if s.Repeat == 0 {
+			return pts[0].Rate
+		}
+		// Wrap: continue from the last point of the previous cycle.
+		last := pts[len(pts)-1]
+		if s.Interpolation == Step {
+			return last.Rate
+		}
+		return interpolate(Point{At: last.At - s.Repeat, Rate: last.Rate}, pts[0], elapsed)
+	case i == len(pts):
+		last := pts[len(pts)-1]
+		if s.Repeat > 0 && s.Interpolation == Linear {
+			next := Point{At: pts[0].At + s.Repeat, Rate: pts[0].Rate}
+			return interpolate(last, next, elapsed)
+		}
+		return last.Rate
+	case s.Interpolation == Step:
+		return pts[i-1].Rate
+	default:
+		return interpolate(pts[i-1], pts[i], elapsed)
+	}
+}
+
+func interpolate(a, b Point, at time.Duration) float64 {
+	if b.At == a.At {
+		return b.Rate
+	}
+	frac := float64(at-a.At) / float64(b.At-a.At)
+	return a.Rate + (b.Rate-a.Rate)*frac
+}
+
+// RateFunc adapts the schedule to the engine's RateFunc.
+func (s *Schedule) RateFunc() RateFunc {
+	return func(elapsed, total time.Duration) float64 {
+		return s.Rate(elapsed)
+	}
+}
+
+// Peak returns the highest scheduled rate.
+func (s *Schedule) Peak() float64 {
+	var peak float64
+	for _, p := range s.Points {
+		peak = max(peak, p.Rate)
+	}
+	return peak
+}
+
+// Mean returns the average scheduled rate over a run of length total,
+// sampled once per second of the run (or 1000 samples, if more).
+func (s *Schedule) Mean(total time.Duration) float64 {
+	if total <= 0 {
+		return s.Rate(0)
+	}
+	n := max(int(total/time.Second), 1000)
+	var sum float64
+	for i := range n {
+		sum += s.Rate(total * time.Duration(i) / time.Duration(n))
+	}
+	return sum / float64(n)
+}
+
+// Describe returns a human-readable description of the schedule.
+func (s *Schedule) Describe() string {
+	desc := fmt.Sprintf("Custom %s schedule, %d points, peak %.1f req/s", s.Interpolation, len(s.Points), s.Peak())
+	if s.Repeat > 0 {
+		desc += fmt.Sprintf(", repeating every %s", s.Repeat)
+	}
+	return desc
+}
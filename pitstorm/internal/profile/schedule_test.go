@@ -0,0 +1,145 @@
+package profile
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseScheduleLinear(t *testing.T) {
+	s, err := ParseSchedule("linear:10m=5,0s=2,5m=20")
+	if err != nil {
+		t.Fatalf("ParseSchedule: %v", err)
+	}
+	if s.Points[0].At != 0 || s.Points[2].At != 10*time.Minute {
+		t.Errorf("points not sorted: %+v", s.Points)
+	}
+	tests := []struct {
+		at   time.Duration
+		want float64
+	}{
+		{0, 2},
+		{150 * time.Second, 11}, // halfway 2 → 20
+		{5 * time.Minute, 20},
+		{450 * time.Second, 12.5}, // halfway 20 → 5
+		{10 * time.Minute, 5},
+		{time.Hour, 5}, // last rate holds
+	}
+	for _, tt := range tests {
+		if got := s.Rate(tt.at); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Rate(%v) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+	if s.Peak() != 20 {
+		t.Errorf("Peak = %v, want 20", s.Peak())
+	}
+}
+
+func TestParseScheduleStepRepeat(t *testing.T) {
+	s, err := ParseSchedule("step:10s=1,30s=10@1m")
+	if err != nil {
+		t.Fatalf("ParseSchedule: %v", err)
+	}
+	tests := []struct {
+		at   time.Duration
+		want float64
+	}{
+		{0, 10}, // wraps: holds the previous cycle's last rate
+		{10 * time.Second, 1},
+		{29 * time.Second, 1},
+		{30 * time.Second, 10},
+		{70 * time.Second, 1}, // second cycle
+		{95 * time.Second, 10},
+	}
+	for _, tt := range tests {
+		if got := s.Rate(tt.at); got != tt.want {
+			t.Errorf("Rate(%v) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleDiurnalWrap(t *testing.T) {
+	s, err := ParseSchedule("linear:6h=2,18h=10@24h")
+	if err != nil {
+		t.Fatalf("ParseSchedule: %v", err)
+	}
+	// Midnight is halfway between 18h (10) and 6h next day (2).
+	if got := s.Rate(0); math.Abs(got-6) > 1e-9 {
+		t.Errorf("Rate(0) = %v, want 6", got)
+	}
+	if got := s.Rate(24*time.Hour + 12*time.Hour); math.Abs(got-6) > 1e-9 {
+		t.Errorf("Rate(36h) = %v, want 6", got)
+	}
+	// Symmetric triangle between 2 and 10 averages 6 over a full cycle.
+	if got := s.Mean(24 * time.Hour); math.Abs(got-6) > 0.01 {
+		t.Errorf("Mean = %v, want ~6", got)
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, spec := range []string{
+		"linear",
+		"cubic:0s=1",
+		"linear:0s",
+		"linear:soon=1",
+		"linear:0s=fast",
+		"linear:0s=-1",
+		"linear:0s=1,0s=2",
+		"linear:0s=0,1m=0",
+		"linear:0s=1,2m=3@1m",
+		"step:0s=1@never",
+	} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) should error", spec)
+		}
+	}
+}
+
+func TestLoadSchedule(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "day.yaml")
+	data := `
+interpolation: step
+repeat: 1h
+points:
+  - {at: 0s, rate: 2}
+  - {at: 30m, rate: 8}
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadSchedule(path)
+	if err != nil {
+		t.Fatalf("LoadSchedule: %v", err)
+	}
+	if s.Interpolation != Step || s.Repeat != time.Hour || len(s.Points) != 2 {
+		t.Errorf("schedule = %+v", s)
+	}
+	if got := s.Rate(90 * time.Minute); got != 8 {
+		t.Errorf("Rate(90m) = %v, want 8", got)
+	}
+	if got := s.Mean(time.Hour); math.Abs(got-5) > 0.01 {
+		t.Errorf("Mean = %v, want 5", got)
+	}
+
+	if err := os.WriteFile(path, []byte("points: []\nrepeats: 1h\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSchedule(path); err == nil {
+		t.Error("unknown field should be rejected")
+	}
+}
+
+func TestGetInlineSchedule(t *testing.T) {
+	fn, err := Get("linear:0s=1,1m=3", 100)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got := fn(30*time.Second, time.Minute); got != 2 {
+		t.Errorf("rate = %v, want 2 (peakRate ignored)", got)
+	}
+	if _, err := Get("linear:bogus", 10); err == nil {
+		t.Error("invalid inline schedule should error")
+	}
+}
@@ -0,0 +1,287 @@
+// Package slo evaluates service-level objectives against a metrics
+// snapshot so a run can fail (and optionally abort early) when the target
+// misses them. Objectives are declared in a YAML file.
+package slo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+)
+
+// boutEndpoint is the endpoint whose time-to-first-byte firstByteP95
+// constrains.
+const boutEndpoint = "/api/run-bout"
+
+// File is the YAML representation of a set of objectives. Every
+// threshold is optional; ratios are fractions in [0, 1] and latencies use
+// time.ParseDuration syntax.
+//
+// Example:
+//
+//	minRequests: 100      # live checks wait for this many requests
+//	abortFactor: 2        # abort once a threshold is exceeded 2x
+//	errorRate: 0.02
+//	streamErrorRatio: 0.01
+//	rateLimitRatio: 0.05
+//	firstByteP95: 3s
+//	latency:
+//	  /api/run-bout: {p95: 30s, p99: 60s}
+//	  /api/health: {p95: 250ms}
+type File struct {
+	MinRequests      int64                  `yaml:"minRequests"`
+	AbortFactor      float64                `yaml:"abortFactor"`
+	ErrorRate        *float64               `yaml:"errorRate"`
+	StreamErrorRatio *float64               `yaml:"streamErrorRatio"`
+	RateLimitRatio   *float64               `yaml:"rateLimitRatio"`
+	FirstByteP95     string                 `yaml:"firstByteP95"`
+	Latency          map[string]LatencyFile `yaml:"latency"`
+}
+
+// LatencyFile holds per-endpoint percentile limits.
+type LatencyFile struct {
+	P95 string `yaml:"p95"`
+	P99 string `yaml:"p99"`
+}
+
+// Unit describes how a threshold's values are expressed.
+type Unit string
+
+const (
+	UnitRatio Unit = "ratio"
+	UnitMs    Unit = "ms"
+)
+
+// Threshold is a single objective: the observed value must not exceed Limit.
+type Threshold struct {
+	Name  string
+	Limit float64
+	Unit  Unit
+	// observe extracts the value from a snapshot; ok is false when the
+	// snapshot has no data for it yet.
+	observe func(metrics.Snapshot) (value float64, ok bool)
+}
+
+// Set is a validated collection of thresholds.
+type Set struct {
+	MinRequests int64
+	AbortFactor float64 // 0 = never abort early
+	Thresholds  []Threshold
+}
+
+// Result is the outcome of one threshold against one snapshot.
+type Result struct {
+	Name     string  `json:"name"`
+	Unit     Unit    `json:"unit"`
+	Limit    float64 `json:"limit"`
+	Observed float64 `json:"observed"`
+	NoData   bool    `json:"noData,omitempty"` // no samples; counts as a pass
+	Pass     bool    `json:"pass"`
+	Severe   bool    `json:"severe,omitempty"` // exceeded by at least AbortFactor
+}
+
+// Load reads and validates an SLO file from disk.
+func Load(path string) (*Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read slo file: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes and validates SLO YAML. Unknown fields are rejected so
+// typos don't silently drop an objective.
+func Parse(data []byte) (*Set, error) {
+	var f File
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("parse slo file: %w", err)
+	}
+	return f.Compile()
+}
+
+// Compile validates the file and converts it into a Set.
+func (f File) Compile() (*Set, error) {
+	if f.MinRequests < 0 {
+		return nil, fmt.Errorf("slo: minRequests must not be negative")
+	}
+	if f.AbortFactor != 0 && f.AbortFactor < 1 {
+		return nil, fmt.Errorf("slo: abortFactor must be >= 1, got %v", f.AbortFactor)
+	}
+	s := &Set{MinRequests: f.MinRequests, AbortFactor: f.AbortFactor}
+
+	ratio := func(name string, limit *float64, observe func(metrics.Snapshot) (float64, bool)) error {
+		if limit == nil {
+			return nil
+		}
+		if *limit < 0 || *limit > 1 {
+			return fmt.Errorf("slo: %s must be between 0 and 1, got %v", name, *limit)
+		}
+		s.Thresholds = append(s.Thresholds, Threshold{Name: name, Limit: *limit, Unit: UnitRatio, observe: observe})
+		return nil
+	}
+	if err := ratio("error rate", f.ErrorRate, func(snap metrics.Snapshot) (float64, bool) {
+		return snap.ErrorRate, snap.Requests > 0
+	}); err != nil {
+		return nil, err
+	}
+	if err := ratio("stream error ratio", f.StreamErrorRatio, func(snap metrics.Snapshot) (float64, bool) {
+		if snap.BoutStarts == 0 {
+			return 0, false
+		}
+		return float64(snap.StreamErrors) / float64(snap.BoutStarts), true
+	}); err != nil {
+		return nil, err
+	}
+	if err := ratio("429 ratio", f.RateLimitRatio, func(snap metrics.Snapshot) (float64, bool) {
+		if snap.Requests == 0 {
+			return 0, false
+		}
+		return float64(snap.RateLimits) / float64(snap.Requests), true
+	}); err != nil {
+		return nil, err
+	}
+
+	latency := func(name, value string, observe func(metrics.Snapshot) (float64, bool)) error {
+		if value == "" {
+			return nil
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("slo: %s: %w", name, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("slo: %s must be positive", name)
+		}
+		s.Thresholds = append(s.Thresholds, Threshold{
+			Name: name, Limit: float64(d) / float64(time.Millisecond), Unit: UnitMs, observe: observe,
+		})
+		return nil
+	}
+	if err := latency("first-byte p95 "+boutEndpoint, f.FirstByteP95, percentile(func(snap metrics.Snapshot) map[string]metrics.LatencyStats {
+		return snap.FirstBytes
+	}, boutEndpoint, 95)); err != nil {
+		return nil, err
+	}
+
+	endpoints := make([]string, 0, len(f.Latency))
+	for ep := range f.Latency {
+		endpoints = append(endpoints, ep)
+	}
+	sort.Strings(endpoints)
+	latencies := func(snap metrics.Snapshot) map[string]metrics.LatencyStats { return snap.Latencies }
+	for _, ep := range endpoints {
+		lf := f.Latency[ep]
+		if lf.P95 == "" && lf.P99 == "" {
+			return nil, fmt.Errorf("slo: latency %s: set p95 and/or p99", ep)
+		}
+		if err := latency("p95 "+ep, lf.P95, percentile(latencies, ep, 95)); err != nil {
+			return nil, err
+		}
+		if err := latency("p99 "+ep, lf.P99, percentile(latencies, ep, 99)); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(s.Thresholds) == 0 {
+		return nil, fmt.Errorf("slo: file declares no thresholds")
+	}
+	return s, nil
+}
+
+// percentile returns an observer for the p-th percentile of endpoint ep
+// in the latency map selected by stats.
+func percentile(stats func(metrics.Snapshot) map[string]metrics.LatencyStats, ep string, p int) func(metrics.Snapshot) (float64, bool) {
+	return func(snap metrics.Snapshot) (float64, bool) {
+		ls, ok := stats(snap)[ep]
+		if !ok || ls.Count == 0 {
+			return 0, false
+		}
+		if p == 99 {
+			return ls.P99, true
+		}
+		return ls.P95, true
+	}
+}
+
+// Evaluate checks every threshold against snap.
+func (s *Set) Evaluate(snap metrics.Snapshot) []Result {
+	results := make([]Result, len(s.Thresholds))
+	for i, t := range s.Thresholds {
+		r := Result{Name: t.Name, Unit: t.Unit, Limit: t.Limit, Pass: true}
+		v, ok := t.observe(snap)
+		if !ok {
+			r.NoData = true
+		} else {
+			r.Observed = v
+			r.Pass = v <= t.Limit
+			r.Severe = !r.Pass && s.AbortFactor > 0 && v >= t.Limit*s.AbortFactor
+		}
+		results[i] = r
+	}
+	return results
+}
+
+// Breached reports whether any result failed.
+func Breached(results []Result) bool {
+	for _, r := range results {
+		if !r.Pass {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch evaluates the set against snapshot every interval until ctx is
+// done. Newly breached thresholds are logged; once MinRequests have been
+// made, the first severe breach calls abort (if AbortFactor is set) and
+// Watch returns.
+func (s *Set) Watch(ctx context.Context, interval time.Duration, snapshot func() metrics.Snapshot, abort func(Result), logf func(string, ...any)) {
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	breached := make(map[string]bool)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		snap := snapshot()
+		if snap.Requests < s.MinRequests {
+			continue
+		}
+		for _, r := range s.Evaluate(snap) {
+			if r.Pass {
+				delete(breached, r.Name)
+				continue
+			}
+			if !breached[r.Name] {
+				breached[r.Name] = true
+				logf("[slo] breach: %s = %s (limit %s)", r.Name, r.Format(r.Observed), r.Format(r.Limit))
+			}
+			if r.Severe && abort != nil {
+				abort(r)
+				return
+			}
+		}
+	}
+}
+
+// Format renders v in the result's unit.
+func (r Result) Format(v float64) string {
+	if r.Unit == UnitRatio {
+		return fmt.Sprintf("%.2f%%", v*100)
+	}
+	return fmt.Sprintf("%.0fms", v)
+}
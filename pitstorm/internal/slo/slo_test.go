@@ -0,0 +1,150 @@
+package slo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+)
+
+const testFile = `
+minRequests: 10
+abortFactor: 2
+errorRate: 0.05
+streamErrorRatio: 0.1
+rateLimitRatio: 0.2
+firstByteP95: 2s
+latency:
+  /api/run-bout: {p95: 30s, p99: 60s}
+  /api/health: {p95: 100ms}
+`
+
+func testSnapshot() metrics.Snapshot {
+	return metrics.Snapshot{
+		Requests:     100,
+		ErrorRate:    0.01,
+		RateLimits:   5,
+		BoutStarts:   20,
+		StreamErrors: 1,
+		Latencies: map[string]metrics.LatencyStats{
+			"/api/run-bout": {Count: 20, P95: 12000, P99: 20000},
+			"/api/health":   {Count: 80, P95: 40, P99: 90},
+		},
+		FirstBytes: map[string]metrics.LatencyStats{
+			"/api/run-bout": {Count: 20, P95: 900},
+		},
+	}
+}
+
+func TestParse(t *testing.T) {
+	s, err := Parse([]byte(testFile))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if s.MinRequests != 10 || s.AbortFactor != 2 {
+		t.Errorf("MinRequests/AbortFactor = %d/%v", s.MinRequests, s.AbortFactor)
+	}
+	want := []string{
+		"error rate", "stream error ratio", "429 ratio", "first-byte p95 /api/run-bout",
+		"p95 /api/health", "p95 /api/run-bout", "p99 /api/run-bout",
+	}
+	if len(s.Thresholds) != len(want) {
+		t.Fatalf("got %d thresholds, want %d", len(s.Thresholds), len(want))
+	}
+	for i, name := range want {
+		if s.Thresholds[i].Name != name {
+			t.Errorf("threshold[%d] = %q, want %q", i, s.Thresholds[i].Name, name)
+		}
+	}
+	if s.Thresholds[3].Limit != 2000 || s.Thresholds[3].Unit != UnitMs {
+		t.Errorf("first-byte limit = %v %s, want 2000 ms", s.Thresholds[3].Limit, s.Thresholds[3].Unit)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"empty":          "minRequests: 5\n",
+		"unknown field":  "errorRate: 0.1\nerorRate: 0.2\n",
+		"ratio range":    "errorRate: 1.5\n",
+		"bad duration":   "firstByteP95: soon\n",
+		"no percentiles": "latency:\n  /api/health: {}\n",
+		"abort factor":   "errorRate: 0.1\nabortFactor: 0.5\n",
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse([]byte(data)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	s, err := Parse([]byte(testFile))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	results := s.Evaluate(testSnapshot())
+	if Breached(results) {
+		t.Errorf("healthy snapshot breached: %+v", results)
+	}
+
+	snap := testSnapshot()
+	snap.ErrorRate = 0.07
+	snap.Latencies["/api/health"] = metrics.LatencyStats{Count: 80, P95: 250}
+	delete(snap.FirstBytes, "/api/run-bout")
+	results = s.Evaluate(snap)
+	if !Breached(results) {
+		t.Fatal("expected a breach")
+	}
+	byName := make(map[string]Result)
+	for _, r := range results {
+		byName[r.Name] = r
+	}
+	if r := byName["error rate"]; r.Pass || r.Severe {
+		t.Errorf("error rate = %+v, want fail but not severe", r)
+	}
+	if r := byName["p95 /api/health"]; r.Pass || !r.Severe {
+		t.Errorf("p95 /api/health = %+v, want severe fail", r)
+	}
+	if r := byName["first-byte p95 /api/run-bout"]; !r.NoData || !r.Pass {
+		t.Errorf("first-byte = %+v, want no data", r)
+	}
+}
+
+func TestWatchAborts(t *testing.T) {
+	s, err := Parse([]byte("minRequests: 10\nabortFactor: 2\nerrorRate: 0.1\n"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	snap := metrics.Snapshot{Requests: 5, ErrorRate: 0.5}
+	aborted := make(chan Result, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	done := make(chan struct{})
+	var calls int
+	go func() {
+		s.Watch(ctx, 5*time.Millisecond, func() metrics.Snapshot {
+			calls++
+			if calls > 3 {
+				snap.Requests = 50
+			}
+			return snap
+		}, func(r Result) { aborted <- r }, nil)
+		close(done)
+	}()
+
+	select {
+	case r := <-aborted:
+		if r.Name != "error rate" || calls <= 3 {
+			t.Errorf("aborted on %q after %d calls; want error rate after minRequests", r.Name, calls)
+		}
+	case <-ctx.Done():
+		t.Fatal("Watch did not abort")
+	}
+	<-done
+}
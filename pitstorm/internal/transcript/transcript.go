@@ -0,0 +1,171 @@
+// Package transcript captures the full content of every streamed bout
+// to a JSONL artefact and checks each stream for protocol violations:
+// the wrong number of turns, text that arrives without a data-turn, agents
+// out of their round-robin order, and empty turns.
+package transcript
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/client"
+)
+
+// Record is one line of a transcript file: a single streamed bout.
+type Record struct {
+	Time    time.Time `json:"ts"`
+	Worker  int       `json:"worker"`
+	Persona string    `json:"persona"`
+	// Target is "b" for a bout sent to target B of an A/B split run.
+	Target string `json:"target,omitempty"`
+
+	BoutID         string `json:"boutId"`
+	PresetID       string `json:"presetId"`
+	Topic          string `json:"topic,omitempty"`
+	Model          string `json:"model"`
+	RequestedTurns int    `json:"requestedTurns"`
+
+	Turns      []Turn `json:"turns"`
+	ShareLine  string `json:"shareLine,omitempty"`
+	Error      string `json:"error,omitempty"`
+	EventCount int    `json:"eventCount"`
+	DeltaCount int    `json:"deltaCount"`
+	TotalChars int    `json:"totalChars"`
+
+	DurationMs  float64 `json:"durationMs"`
+	FirstByteMs float64 `json:"firstByteMs"`
+
+	// Token usage, when the server reported it.
+	InputTokens  int `json:"inputTokens,omitempty"`
+	OutputTokens int `json:"outputTokens,omitempty"`
+
+	// Violations lists every failed validation check (see Checker).
+	Violations []string `json:"violations,omitempty"`
+}
+
+// Turn is one agent's contribution to a bout.
+type Turn struct {
+	Turn      int    `json:"turn"`
+	AgentID   string `json:"agentId"`
+	AgentName string `json:"agentName"`
+	Color     string `json:"color,omitempty"`
+	Text      string `json:"text"`
+}
+
+// NewRecord builds a record from a parsed stream. Identity fields
+// (worker, persona, bout request) are left for the caller to fill in.
+func NewRecord(res *client.StreamResult, violations []string) Record {
+	r := Record{
+		Time:        time.Now().UTC(),
+		Turns:       make([]Turn, len(res.Turns)),
+		ShareLine:   res.ShareLine,
+		Error:       res.Error,
+		EventCount:  res.EventCount,
+		DeltaCount:  res.DeltaCount,
+		TotalChars:  res.TotalChars,
+		DurationMs:  float64(res.Duration.Microseconds()) / 1000,
+		FirstByteMs: float64(res.FirstByte.Microseconds()) / 1000,
+		Violations:  violations,
+	}
+	if res.Usage != nil {
+		r.InputTokens, r.OutputTokens = res.Usage.InputTokens, res.Usage.OutputTokens
+	}
+	for i, t := range res.Turns {
+		r.Turns[i] = Turn{Turn: t.Turn, AgentID: t.AgentID, AgentName: t.AgentName, Color: t.Color, Text: t.Text}
+	}
+	return r
+}
+
+// Writer appends records to a transcript file. Safe for concurrent use.
+type Writer struct {
+	mu      sync.Mutex
+	f       *os.File
+	bw      *bufio.Writer
+	enc     *json.Encoder
+	n       int64
+	invalid int64
+}
+
+// Create opens path for writing, truncating any existing file.
+func Create(path string) (*Writer, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("create transcript dir: %w", err)
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create transcript: %w", err)
+	}
+	bw := bufio.NewWriter(f)
+	return &Writer{f: f, bw: bw, enc: json.NewEncoder(bw)}, nil
+}
+
+// Write appends a record.
+func (w *Writer) Write(r Record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.enc.Encode(r); err != nil {
+		return fmt.Errorf("write transcript: %w", err)
+	}
+	w.n++
+	if len(r.Violations) > 0 {
+		w.invalid++
+	}
+	return nil
+}
+
+// Count returns the number of records written and how many of them
+// failed validation.
+func (w *Writer) Count() (total, invalid int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.n, w.invalid
+}
+
+// Close flushes buffered records and closes the file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.bw.Flush(); err != nil {
+		w.f.Close()
+		return fmt.Errorf("flush transcript: %w", err)
+	}
+	return w.f.Close()
+}
+
+// Read loads every record from a transcript file.
+func Read(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open transcript: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	// A full bout transcript easily exceeds the default 64KB line limit.
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var out []Record
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("parse transcript line %d: %w", line, err)
+		}
+		out = append(out, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read transcript: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,148 @@
+package transcript
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/client"
+)
+
+// streamTurn is one turn of a synthetic bout stream.
+type streamTurn struct {
+	agent      string
+	text       string
+	noDataTurn bool // omit the data-turn event
+}
+
+func buildStream(turns []streamTurn) string {
+	var b strings.Builder
+	for i, t := range turns {
+		id := fmt.Sprintf("b-%d-%s", i, t.agent)
+		if !t.noDataTurn {
+			fmt.Fprintf(&b, "data: {\"type\":\"data-turn\",\"data\":{\"turn\":%d,\"agentId\":%q,\"agentName\":%q}}\n\n", i, t.agent, t.agent)
+		}
+		fmt.Fprintf(&b, "data: {\"type\":\"text-start\",\"id\":%q}\n\n", id)
+		if t.text != "" {
+			fmt.Fprintf(&b, "data: {\"type\":\"text-delta\",\"id\":%q,\"delta\":%q}\n\n", id, t.text)
+		}
+		fmt.Fprintf(&b, "data: {\"type\":\"text-end\",\"id\":%q}\n\n", id)
+	}
+	b.WriteString("data: {\"type\":\"data-share-line\",\"data\":{\"text\":\"share\"}}\n\n")
+	b.WriteString("data: [DONE]\n\n")
+	return b.String()
+}
+
+func check(t *testing.T, requested int, turns []streamTurn) []string {
+	t.Helper()
+	c := NewChecker()
+	res, err := client.ParseSSEStream(strings.NewReader(buildStream(turns)), c.Observe)
+	if err != nil {
+		t.Fatalf("ParseSSEStream: %v", err)
+	}
+	return c.Finish(requested, res)
+}
+
+func TestCheckerValid(t *testing.T) {
+	v := check(t, 4, []streamTurn{{"a", "hi", false}, {"b", "yo", false}, {"a", "well", false}, {"b", "no", false}})
+	if len(v) != 0 {
+		t.Errorf("violations = %v, want none", v)
+	}
+}
+
+func TestCheckerViolations(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested int
+		turns     []streamTurn
+		want      string
+	}{
+		{"turn count", 3, []streamTurn{{"a", "x", false}, {"b", "y", false}}, "2 turns streamed, 3 requested"},
+		{"empty turn", 2, []streamTurn{{"a", "x", false}, {"b", "", false}}, "turn 1 (b) is empty"},
+		{"out of order", 3, []streamTurn{{"a", "x", false}, {"b", "y", false}, {"b", "z", false}}, "turn 2 spoken by b, expected a"},
+		{"missing data-turn", 2, []streamTurn{{"a", "x", false}, {"b", "y", true}}, "text-start without a preceding data-turn"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := check(t, tt.requested, tt.turns)
+			found := false
+			for _, msg := range v {
+				found = found || msg == tt.want
+			}
+			if !found {
+				t.Errorf("violations = %v, want %q", v, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckerExplicitOrder(t *testing.T) {
+	c := NewChecker()
+	c.Order = []string{"b", "a"}
+	res, err := client.ParseSSEStream(strings.NewReader(buildStream([]streamTurn{{"a", "x", false}, {"b", "y", false}})), c.Observe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := c.Finish(0, res); len(v) != 2 {
+		t.Errorf("violations = %v, want both turns out of order", v)
+	}
+}
+
+func TestCheckerDeltaOutsideBlock(t *testing.T) {
+	stream := `data: {"type":"text-delta","id":"x","delta":"stray"}` + "\n\n" + buildStream([]streamTurn{{"a", "x", false}})
+	c := NewChecker()
+	res, err := client.ParseSSEStream(strings.NewReader(stream), c.Observe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := c.Finish(1, res)
+	if len(v) != 1 || v[0] != "text-delta outside its turn's text block" {
+		t.Errorf("violations = %v", v)
+	}
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "transcripts.jsonl")
+	w, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	res := &client.StreamResult{
+		Turns:     []client.TurnResult{{Turn: 0, AgentID: "a", AgentName: "A", Text: "hello"}},
+		ShareLine: "share",
+		Duration:  1500 * time.Millisecond,
+		FirstByte: 200 * time.Millisecond,
+	}
+	r := NewRecord(res, nil)
+	r.BoutID, r.RequestedTurns = "bout-1", 1
+	if err := w.Write(r); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(NewRecord(res, []string{"bad"})); err != nil {
+		t.Fatal(err)
+	}
+	if total, invalid := w.Count(); total != 2 || invalid != 1 {
+		t.Errorf("Count = %d, %d; want 2, 1", total, invalid)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	recs, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("records = %d, want 2", len(recs))
+	}
+	got := recs[0]
+	if got.BoutID != "bout-1" || got.Turns[0].Text != "hello" || got.ShareLine != "share" || got.DurationMs != 1500 || got.FirstByteMs != 200 {
+		t.Errorf("record = %+v", got)
+	}
+	if len(recs[1].Violations) != 1 {
+		t.Errorf("violations = %v", recs[1].Violations)
+	}
+}
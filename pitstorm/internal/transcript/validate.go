@@ -0,0 +1,99 @@
+package transcript
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/client"
+)
+
+// Checker validates a single bout stream. Feed every event to Observe
+// (it has the client.SSECallback signature) and call Finish with the
+// parsed result once the stream ends.
+//
+// The server emits, for each turn i of a bout, data-turn{turn: i} then
+// text-start, text-delta..., text-end, cycling through the preset's
+// agents in a fixed order.
+type Checker struct {
+	// Order, if set, is the expected agent rotation (e.g. a request's
+	// explicit lineup). Otherwise the order in which agents first speak
+	// is taken as the preset's.
+	Order []string
+
+	pendingTurn bool   // a data-turn arrived and its text has not started
+	textID      string // the open text block, "" between blocks
+
+	seen       map[string]bool
+	violations []string
+}
+
+// NewChecker creates a Checker for one stream.
+func NewChecker() *Checker {
+	return &Checker{seen: make(map[string]bool)}
+}
+
+// Observe checks event ordering. It never aborts the stream.
+func (c *Checker) Observe(ev client.SSEEvent) error {
+	switch ev.Type {
+	case client.EventDataTurn:
+		c.pendingTurn = true
+	case client.EventTextStart:
+		if !c.pendingTurn {
+			c.fail("text-start without a preceding data-turn")
+		}
+		c.pendingTurn = false
+		c.textID = ev.ID
+	case client.EventTextDelta:
+		if c.textID == "" || (ev.ID != "" && ev.ID != c.textID) {
+			c.fail("text-delta outside its turn's text block")
+		}
+	case client.EventTextEnd:
+		c.textID = ""
+	}
+	return nil
+}
+
+// Finish runs the whole-stream checks and returns every violation found,
+// or nil if the stream is valid. requestedTurns <= 0 skips the turn
+// count check.
+func (c *Checker) Finish(requestedTurns int, res *client.StreamResult) []string {
+	turns := res.Turns
+	if requestedTurns > 0 && len(turns) != requestedTurns {
+		c.fail(fmt.Sprintf("%d turns streamed, %d requested", len(turns), requestedTurns))
+	}
+
+	// Without an explicit lineup, the turn order is the order in which
+	// agents first speak; every later turn must follow the same rotation.
+	order := c.Order
+	if len(order) == 0 {
+		speaking := make(map[string]bool)
+		for _, t := range turns {
+			if !speaking[t.AgentID] {
+				speaking[t.AgentID] = true
+				order = append(order, t.AgentID)
+			}
+		}
+	}
+
+	for i, t := range turns {
+		if t.Turn != i {
+			c.fail(fmt.Sprintf("turn %d is numbered %d", i, t.Turn))
+		}
+		if strings.TrimSpace(t.Text) == "" {
+			c.fail(fmt.Sprintf("turn %d (%s) is empty", i, t.AgentID))
+		}
+		if want := order[i%len(order)]; t.AgentID != want {
+			c.fail(fmt.Sprintf("turn %d spoken by %s, expected %s", i, t.AgentID, want))
+		}
+	}
+	return c.violations
+}
+
+// fail records a violation once, however often it recurs in the stream.
+func (c *Checker) fail(msg string) {
+	if c.seen[msg] {
+		return
+	}
+	c.seen[msg] = true
+	c.violations = append(c.violations, msg)
+}
@@ -0,0 +1,232 @@
+package tui
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+	"github.com/rickhallett/thepit/shared/theme"
+)
+
+// Render draws one frame of the dashboard for a terminal width wide.
+func (d *Dashboard) Render(snap metrics.Snapshot, bs budget.Summary, elapsed time.Duration, width int) string {
+	d.mu.Lock()
+	history := append([]float64(nil), d.throughput...)
+	logs := append([]string(nil), d.logs...)
+	flash := d.flash
+	burn := d.burnRate(bs.SpentGBP, elapsed)
+	d.mu.Unlock()
+
+	c := d.cfg.Controls
+	var b strings.Builder
+	label := func(s string) string { return theme.Muted.Render(fmt.Sprintf("  %-12s", s)) }
+	section := func(s string) { fmt.Fprintf(&b, "\n  %s\n", theme.Bold.Render(s)) }
+
+	// Header.
+	state := theme.Success.Render("● RUNNING")
+	if c.Paused() {
+		state = theme.Warning.Render("❚❚ PAUSED")
+	}
+	fmt.Fprintf(&b, "%s  %s\n\n", theme.Title.Render(d.cfg.Title), state)
+	fmt.Fprintf(&b, "%s%s\n", label("Target"), theme.Value.Render(d.cfg.Target))
+	fmt.Fprintf(&b, "%s%s / %s\n", label("Elapsed"),
+		theme.Value.Render(elapsed.Truncate(time.Second).String()), d.cfg.Duration)
+	fmt.Fprintf(&b, "%s%s\n", label("Rate"), d.formatRate())
+
+	// Throughput.
+	section("Throughput")
+	current := 0.0
+	if len(history) > 0 {
+		current = history[len(history)-1]
+	}
+	fmt.Fprintf(&b, "%s%s now · %.1f avg · %d requests · %s errors\n", label("req/s"),
+		theme.Value.Render(fmt.Sprintf("%.1f", current)), snap.Throughput, snap.Requests, errorRate(snap.ErrorRate))
+	fmt.Fprintf(&b, "%s%s\n", label(""), theme.Accent.Render(Sparkline(history, max(width-16, 10))))
+
+	// Streams and budget.
+	barWidth := min(max(width-48, 10), 40)
+	fmt.Fprintf(&b, "%s%d active / %d peak  %s\n", label("Streams"),
+		snap.ActiveStreams, snap.ActiveStreamsPeak, theme.Accent.Render(Bar(ratio(snap.ActiveStreams, snap.ActiveStreamsPeak), barWidth)))
+	if bs.CeilingGBP > 0 {
+		frac := bs.SpentGBP / bs.CeilingGBP
+		fmt.Fprintf(&b, "%s£%.4f / £%.2f  %s %s\n", label("Budget"),
+			bs.SpentGBP, bs.CeilingGBP, budgetStyle(frac)(Bar(frac, barWidth)), budgetStyle(frac)(fmt.Sprintf("%.1f%%", frac*100)))
+	} else {
+		fmt.Fprintf(&b, "%s£%.4f (no ceiling)\n", label("Budget"), bs.SpentGBP)
+	}
+	fmt.Fprintf(&b, "%sburn £%.4f/min · %s\n", label(""), burn*60,
+		formatProjection(bs, burn, d.cfg.Duration-elapsed))
+
+	// Latency percentiles.
+	if len(snap.Latencies) > 0 {
+		section("Latency")
+		fmt.Fprintf(&b, "%s\n", theme.Muted.Render(fmt.Sprintf("    %-24s %7s %8s %8s %8s", "endpoint", "n", "p50", "p95", "p99")))
+		for _, ep := range sortedKeys(snap.Latencies) {
+			ls := snap.Latencies[ep]
+			fmt.Fprintf(&b, "    %-24s %7d %6.0fms %6.0fms %6.0fms\n", ep, ls.Count, ls.P50, ls.P95, ls.P99)
+		}
+	}
+
+	// Persona action mix.
+	if len(snap.Actions) > 0 {
+		section("Persona mix")
+		for _, p := range sortedKeys(snap.Actions) {
+			total, mix := ActionMix(snap.Actions[p], 4)
+			fmt.Fprintf(&b, "    %-22s %6d  %s\n", p, total, theme.Muted.Render(mix))
+		}
+	}
+
+	if len(logs) > 0 {
+		section("Log")
+		for _, l := range logs {
+			fmt.Fprintf(&b, "    %s\n", truncate(l, width-6))
+		}
+	}
+
+	keys := "p pause · +/- rate · r profile rate · q quit"
+	if flash != "" {
+		keys += "   " + theme.Accent.Render(flash)
+	}
+	fmt.Fprintf(&b, "\n  %s\n", theme.Muted.Render(keys))
+	return b.String()
+}
+
+// formatRate describes the target rate and what sets it.
+func (d *Dashboard) formatRate() string {
+	c := d.cfg.Controls
+	if rps := c.RateOverride(); rps > 0 {
+		return theme.Warning.Render(fmt.Sprintf("%.1f req/s", rps)) + theme.Muted.Render(" (manual — r restores the profile)")
+	}
+	rps := c.Rate()
+	if math.IsInf(rps, 1) {
+		return theme.Value.Render("unlimited") + theme.Muted.Render(" ("+d.cfg.Profile+")")
+	}
+	return theme.Value.Render(fmt.Sprintf("%.1f req/s", rps)) + theme.Muted.Render(" ("+d.cfg.Profile+" profile)")
+}
+
+// sparkBlocks are the eight bar heights of a sparkline.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws the last width values as block characters scaled to
+// their maximum.
+func Sparkline(values []float64, width int) string {
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	peak := 0.0
+	for _, v := range values {
+		peak = max(peak, v)
+	}
+	out := make([]rune, len(values))
+	for i, v := range values {
+		idx := 0
+		if peak > 0 {
+			idx = int(math.Round(v / peak * float64(len(sparkBlocks)-1)))
+		}
+		out[i] = sparkBlocks[min(max(idx, 0), len(sparkBlocks)-1)]
+	}
+	return string(out)
+}
+
+// Bar draws a horizontal gauge filled to frac (clamped to 0–1).
+func Bar(frac float64, width int) string {
+	filled := int(math.Round(min(max(frac, 0), 1) * float64(width)))
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+}
+
+// Projection returns how long until the ceiling is reached at the given
+// burn rate (GBP per second). ok is false when there is no ceiling or no
+// spend to project from.
+func Projection(bs budget.Summary, burnPerSec float64) (time.Duration, bool) {
+	if bs.CeilingGBP <= 0 || burnPerSec <= 0 {
+		return 0, false
+	}
+	remaining := max(bs.CeilingGBP-bs.SpentGBP, 0)
+	return time.Duration(remaining / burnPerSec * float64(time.Second)), true
+}
+
+func formatProjection(bs budget.Summary, burn float64, left time.Duration) string {
+	if bs.CeilingGBP > 0 && bs.SpentGBP >= bs.CeilingGBP {
+		return theme.StatusBad.Render("ceiling reached")
+	}
+	eta, ok := Projection(bs, burn)
+	switch {
+	case !ok:
+		return theme.Muted.Render("no exhaustion projected")
+	case eta > left:
+		return theme.StatusOK.Render(fmt.Sprintf("exhausts in %s (after the run ends)", eta.Truncate(time.Second)))
+	default:
+		return theme.StatusWarn.Render(fmt.Sprintf("exhausts in %s (before the run ends)", eta.Truncate(time.Second)))
+	}
+}
+
+// ActionMix returns a persona's total actions and its top n actions as
+// percentages, most frequent first.
+func ActionMix(actions map[string]int64, n int) (int64, string) {
+	var total int64
+	names := make([]string, 0, len(actions))
+	for a, c := range actions {
+		total += c
+		names = append(names, a)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if actions[names[i]] != actions[names[j]] {
+			return actions[names[i]] > actions[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > n {
+		names = names[:n]
+	}
+	parts := make([]string, len(names))
+	for i, a := range names {
+		parts[i] = fmt.Sprintf("%s %.0f%%", a, float64(actions[a])/float64(total)*100)
+	}
+	return total, strings.Join(parts, " · ")
+}
+
+func budgetStyle(frac float64) func(...string) string {
+	switch {
+	case frac >= 0.9:
+		return theme.StatusBad.Render
+	case frac >= 0.7:
+		return theme.StatusWarn.Render
+	}
+	return theme.StatusOK.Render
+}
+
+func errorRate(r float64) string {
+	s := fmt.Sprintf("%.1f%%", r*100)
+	if r > 0.05 {
+		return theme.StatusBad.Render(s)
+	}
+	return s
+}
+
+func ratio(n, of int64) float64 {
+	if of <= 0 {
+		return 0
+	}
+	return float64(n) / float64(of)
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if n <= 1 || len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
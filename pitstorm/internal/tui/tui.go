@@ -0,0 +1,271 @@
+// Package tui is pitstorm's interactive run dashboard. It takes over the
+// terminal for the duration of a run, redraws live metrics and budget
+// state with the shared lipgloss theme, and maps keys to engine controls
+// so an operator can pause the ticket feeder or change the rate mid-run.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+)
+
+// Controls is the part of the engine the dashboard drives.
+type Controls interface {
+	Pause()
+	Resume()
+	Paused() bool
+	SetRate(rps float64)
+	RateOverride() float64
+	Rate() float64
+}
+
+// Config wires a Dashboard to a run.
+type Config struct {
+	Title    string
+	Target   string
+	Profile  string
+	Duration time.Duration
+	// Refresh is the redraw interval (default 1s).
+	Refresh time.Duration
+
+	Metrics  func() metrics.Snapshot
+	Budget   func() budget.Summary
+	Controls Controls
+	// Quit is called when the operator presses q or Ctrl-C; raw mode
+	// swallows SIGINT, so this is how the run is cancelled.
+	Quit func()
+}
+
+// rateStep is the factor one keypress changes the rate by.
+const rateStep = 1.25
+
+// Number of log lines, throughput samples and seconds of spend history kept.
+const (
+	maxLogLines   = 6
+	maxHistory    = 240
+	burnWindowSec = 60
+)
+
+// Dashboard renders a run and handles its keys.
+type Dashboard struct {
+	cfg   Config
+	start time.Time
+
+	mu         sync.Mutex
+	logs       []string
+	throughput []float64
+	spend      []spendSample
+	lastReqs   int64
+	lastAt     time.Time
+	flash      string
+}
+
+// spendSample is the budget spent at a point in the run.
+type spendSample struct {
+	at  time.Duration
+	gbp float64
+}
+
+// New creates a Dashboard. The run's clock starts now.
+func New(cfg Config) *Dashboard {
+	if cfg.Refresh <= 0 {
+		cfg.Refresh = time.Second
+	}
+	if cfg.Quit == nil {
+		cfg.Quit = func() {}
+	}
+	now := time.Now()
+	return &Dashboard{cfg: cfg, start: now, lastAt: now}
+}
+
+// Available reports whether stdin and stdout are both terminals, which
+// the dashboard needs for raw keys and redraws.
+func Available() bool {
+	return term.IsTerminal(os.Stdin.Fd()) && term.IsTerminal(os.Stdout.Fd())
+}
+
+// Logf appends a line to the dashboard's log pane. It has the signature
+// of the logf functions threaded through pitstorm, so run logs can be
+// routed here instead of scrolling over the display.
+func (d *Dashboard) Logf(format string, a ...any) {
+	line := fmt.Sprintf(format, a...)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.logs = append(d.logs, line)
+	if len(d.logs) > maxLogLines {
+		d.logs = d.logs[len(d.logs)-maxLogLines:]
+	}
+}
+
+// Run takes over the terminal and redraws until ctx is done, then
+// restores it. Keys are read from stdin in raw mode.
+func (d *Dashboard) Run(ctx context.Context) error {
+	in, out := os.Stdin, os.Stdout
+	state, err := term.MakeRaw(in.Fd())
+	if err != nil {
+		return fmt.Errorf("terminal raw mode: %w", err)
+	}
+	defer term.Restore(in.Fd(), state)
+
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l") // alternate screen, hide cursor
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+
+	// The reader outlives Run if no key arrives after ctx ends; it is
+	// abandoned, not leaked across runs, since pitstorm exits after one.
+	keys := make(chan string)
+	go readKeys(in, keys)
+
+	ticker := time.NewTicker(d.cfg.Refresh)
+	defer ticker.Stop()
+
+	d.sample()
+	d.draw(out)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case k := <-keys:
+			d.HandleKey(k)
+			d.draw(out)
+		case <-ticker.C:
+			d.sample()
+			d.draw(out)
+		}
+	}
+}
+
+// readKeys forwards each chunk read from r (one key or escape sequence)
+// until r fails.
+func readKeys(r io.Reader, keys chan<- string) {
+	buf := make([]byte, 16)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		keys <- string(buf[:n])
+	}
+}
+
+// HandleKey applies one keypress:
+//
+//	p, space   pause / resume the ticket feeder
+//	+, ↑, k    raise the rate by 25%
+//	-, ↓, j    lower the rate by 20%
+//	r          hand the rate back to the traffic profile
+//	q, Ctrl-C  stop the run
+func (d *Dashboard) HandleKey(k string) {
+	c := d.cfg.Controls
+	switch k {
+	case "p", "P", " ":
+		if c.Paused() {
+			c.Resume()
+			d.setFlash("resumed")
+		} else {
+			c.Pause()
+			d.setFlash("paused — in-flight actions finish, no new tickets")
+		}
+	case "+", "=", "k", "\x1b[A", "\x1bOA":
+		rps := d.currentRate() * rateStep
+		c.SetRate(rps)
+		d.setFlash(fmt.Sprintf("rate set to %.1f req/s", rps))
+	case "-", "_", "j", "\x1b[B", "\x1bOB":
+		rps := max(d.currentRate()/rateStep, 0.1)
+		c.SetRate(rps)
+		d.setFlash(fmt.Sprintf("rate set to %.1f req/s", rps))
+	case "r", "R":
+		c.SetRate(0)
+		d.setFlash("rate back to the " + d.cfg.Profile + " profile")
+	case "q", "Q", "\x03":
+		d.setFlash("stopping")
+		d.cfg.Quit()
+	}
+}
+
+// currentRate is the base a rate key scales: the override if set, else
+// the profile's current rate, else (unlimited) the observed throughput.
+func (d *Dashboard) currentRate() float64 {
+	c := d.cfg.Controls
+	if rps := c.RateOverride(); rps > 0 {
+		return rps
+	}
+	if rps := c.Rate(); rps > 0 && !math.IsInf(rps, 1) {
+		return rps
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if n := len(d.throughput); n > 0 && d.throughput[n-1] > 0 {
+		return d.throughput[n-1]
+	}
+	return 1
+}
+
+func (d *Dashboard) setFlash(msg string) {
+	d.mu.Lock()
+	d.flash = msg
+	d.mu.Unlock()
+}
+
+// sample records the throughput since the last sample and the spend.
+func (d *Dashboard) sample() {
+	snap := d.cfg.Metrics()
+	spent := d.cfg.Budget().SpentGBP
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if dt := now.Sub(d.lastAt).Seconds(); dt > 0 {
+		d.throughput = appendCapped(d.throughput, float64(snap.Requests-d.lastReqs)/dt, maxHistory)
+	}
+	d.lastReqs, d.lastAt = snap.Requests, now
+	d.spend = append(d.spend, spendSample{at: now.Sub(d.start), gbp: spent})
+	for len(d.spend) > 2 && d.spend[len(d.spend)-1].at-d.spend[0].at > burnWindowSec*time.Second {
+		d.spend = d.spend[1:]
+	}
+}
+
+func appendCapped(s []float64, v float64, n int) []float64 {
+	s = append(s, v)
+	if len(s) > n {
+		s = s[len(s)-n:]
+	}
+	return s
+}
+
+// burnRate is the recent spend in GBP per second, over the spend window
+// or, before two samples exist, the whole run.
+func (d *Dashboard) burnRate(spent float64, elapsed time.Duration) float64 {
+	if n := len(d.spend); n >= 2 {
+		first, last := d.spend[0], d.spend[n-1]
+		if dt := (last.at - first.at).Seconds(); dt > 0 {
+			return (last.gbp - first.gbp) / dt
+		}
+	}
+	if secs := elapsed.Seconds(); secs > 0 {
+		return spent / secs
+	}
+	return 0
+}
+
+// draw renders a frame in place. Raw mode turns off output newline
+// translation, so lines end in an explicit CR LF.
+func (d *Dashboard) draw(w io.Writer) {
+	width, _, err := term.GetSize(os.Stdout.Fd())
+	if err != nil || width <= 0 {
+		width = 80
+	}
+	frame := d.Render(d.cfg.Metrics(), d.cfg.Budget(), time.Since(d.start), width)
+	lines := strings.Split(strings.TrimRight(frame, "\n"), "\n")
+	fmt.Fprint(w, "\x1b[H"+strings.Join(lines, "\x1b[K\r\n")+"\x1b[K\x1b[J")
+}
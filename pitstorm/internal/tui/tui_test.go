@@ -0,0 +1,148 @@
+package tui
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rickhallett/thepit/pitstorm/internal/budget"
+	"github.com/rickhallett/thepit/pitstorm/internal/metrics"
+)
+
+// fakeControls records what the dashboard asks of the engine.
+type fakeControls struct {
+	paused   bool
+	override float64
+	rate     float64
+}
+
+func (f *fakeControls) Pause()                { f.paused = true }
+func (f *fakeControls) Resume()               { f.paused = false }
+func (f *fakeControls) Paused() bool          { return f.paused }
+func (f *fakeControls) SetRate(rps float64)   { f.override = rps }
+func (f *fakeControls) RateOverride() float64 { return f.override }
+func (f *fakeControls) Rate() float64 {
+	if f.override > 0 {
+		return f.override
+	}
+	return f.rate
+}
+
+func newTestDashboard(c *fakeControls, quit func()) *Dashboard {
+	return New(Config{
+		Title:    "pitstorm — run",
+		Target:   "http://localhost:3000",
+		Profile:  "steady",
+		Duration: 10 * time.Minute,
+		Metrics:  func() metrics.Snapshot { return metrics.Snapshot{} },
+		Budget:   func() budget.Summary { return budget.Summary{} },
+		Controls: c,
+		Quit:     quit,
+	})
+}
+
+func TestHandleKey(t *testing.T) {
+	c := &fakeControls{rate: 8}
+	quit := false
+	d := newTestDashboard(c, func() { quit = true })
+
+	d.HandleKey("p")
+	if !c.paused {
+		t.Error("p did not pause")
+	}
+	d.HandleKey(" ")
+	if c.paused {
+		t.Error("space did not resume")
+	}
+	d.HandleKey("+")
+	if c.override != 10 {
+		t.Errorf("+ from 8 req/s = %v, want 10", c.override)
+	}
+	d.HandleKey("\x1b[B")
+	if c.override != 8 {
+		t.Errorf("↓ from 10 req/s = %v, want 8", c.override)
+	}
+	d.HandleKey("r")
+	if c.override != 0 {
+		t.Errorf("r left override %v", c.override)
+	}
+	d.HandleKey("x")
+	d.HandleKey("\x03")
+	if !quit {
+		t.Error("Ctrl-C did not quit")
+	}
+}
+
+func TestRateKeyWhenUnlimited(t *testing.T) {
+	c := &fakeControls{rate: math.Inf(1)}
+	d := newTestDashboard(c, nil)
+	d.throughput = []float64{3, 20}
+	d.HandleKey("-")
+	if c.override != 16 {
+		t.Errorf("- while unlimited = %v, want 80%% of observed 20 req/s", c.override)
+	}
+}
+
+func TestSparklineAndBar(t *testing.T) {
+	if got := Sparkline([]float64{0, 1, 2, 4, 8}, 10); got != "▁▂▃▅█" {
+		t.Errorf("Sparkline = %q", got)
+	}
+	if got := Sparkline([]float64{9, 0, 0}, 2); got != "▁▁" {
+		t.Errorf("Sparkline keeps the last values = %q", got)
+	}
+	if got := Bar(0.5, 4); got != "██░░" {
+		t.Errorf("Bar(0.5) = %q", got)
+	}
+	if got := Bar(3, 4); got != "████" {
+		t.Errorf("Bar clamps = %q", got)
+	}
+}
+
+func TestProjection(t *testing.T) {
+	bs := budget.Summary{CeilingGBP: 10, SpentGBP: 4}
+	if eta, ok := Projection(bs, 0.1); !ok || eta != time.Minute {
+		t.Errorf("Projection = %v, %v; want 1m", eta, ok)
+	}
+	if _, ok := Projection(budget.Summary{SpentGBP: 4}, 0.1); ok {
+		t.Error("projected exhaustion without a ceiling")
+	}
+	if _, ok := Projection(bs, 0); ok {
+		t.Error("projected exhaustion without spend")
+	}
+}
+
+func TestBurnRateUsesWindow(t *testing.T) {
+	d := newTestDashboard(&fakeControls{}, nil)
+	d.spend = []spendSample{{at: 10 * time.Second, gbp: 1}, {at: 20 * time.Second, gbp: 2}}
+	if got := d.burnRate(2, 20*time.Second); got != 0.1 {
+		t.Errorf("burnRate = %v, want 0.1 GBP/s over the window", got)
+	}
+}
+
+func TestRender(t *testing.T) {
+	c := &fakeControls{rate: 5, paused: true}
+	d := newTestDashboard(c, nil)
+	d.throughput = []float64{1, 2, 3}
+	d.Logf("[monitor] %s", "hello")
+	snap := metrics.Snapshot{
+		Requests:          42,
+		ActiveStreams:     2,
+		ActiveStreamsPeak: 4,
+		Latencies:         map[string]metrics.LatencyStats{"/browse": {Count: 40, P50: 12, P95: 30, P99: 55}},
+		Actions:           map[string]map[string]int64{"free-lurker": {"browse": 30, "run-bout": 10}},
+	}
+	out := d.Render(snap, budget.Summary{CeilingGBP: 5, SpentGBP: 1}, time.Minute, 100)
+	for _, want := range []string{"PAUSED", "2 active / 4 peak", "£1.0000 / £5.00", "/browse", "free-lurker", "browse 75%", "[monitor] hello", "steady profile"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("frame missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestActionMix(t *testing.T) {
+	total, mix := ActionMix(map[string]int64{"a": 1, "b": 6, "c": 3}, 2)
+	if total != 10 || mix != "b 60% · c 30%" {
+		t.Errorf("ActionMix = %d, %q", total, mix)
+	}
+}
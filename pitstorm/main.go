@@ -26,6 +26,10 @@ func main() {
 		return
 	case "run":
 		runCmd(args[1:])
+	case "record":
+		recordCmd(args[1:])
+	case "replay":
+		replayCmd(args[1:])
 	case "plan":
 		planCmd(args[1:])
 	case "setup":
@@ -36,6 +40,18 @@ func main() {
 		verifyCmd(args[1:])
 	case "report":
 		reportCmd(args[1:])
+	case "compare":
+		compareCmd(args[1:])
+	case "probe":
+		probeCmd(args[1:])
+	case "mock-server":
+		mockServerCmd(args[1:])
+	case "fake-clerk":
+		fakeClerkCmd(args[1:])
+	case "coordinator":
+		coordinatorCmd(args[1:])
+	case "control":
+		controlCmd(args[1:])
 	default:
 		fmt.Fprintf(os.Stderr, "%s unknown command %q\n", theme.Error.Render("error:"), args[0])
 		usage()
@@ -50,34 +66,142 @@ func usage() {
 	fmt.Fprintf(os.Stderr, "  pitstorm <command> [flags]\n\n")
 	fmt.Fprintf(os.Stderr, "Commands:\n")
 	fmt.Fprintf(os.Stderr, "  run [flags]    Execute traffic simulation\n")
+	fmt.Fprintf(os.Stderr, "  record [flags] Run with a seed and journal every request (default: %s)\n", defaultJournalPath)
+	fmt.Fprintf(os.Stderr, "  replay <file>  Re-issue the requests in a journal against a target\n")
 	fmt.Fprintf(os.Stderr, "  plan [flags]   Dry run — estimate cost and show execution plan\n")
 	fmt.Fprintf(os.Stderr, "  setup [flags]  Provision test accounts in Clerk + DB\n")
 	fmt.Fprintf(os.Stderr, "  login [flags]  Sign in all accounts via Clerk and obtain session tokens\n")
 	fmt.Fprintf(os.Stderr, "  verify         Validate account credentials and API connectivity\n")
 	fmt.Fprintf(os.Stderr, "  report <file>  Parse JSON output into a summary report\n")
+	fmt.Fprintf(os.Stderr, "  compare <a> <b> Diff two JSON outputs and flag significant regressions\n")
+	fmt.Fprintf(os.Stderr, "  probe [flags]  Run the security probe suite and report findings\n")
+	fmt.Fprintf(os.Stderr, "  mock-server    Serve a local mock of The Pit's API for offline runs\n")
+	fmt.Fprintf(os.Stderr, "  fake-clerk     Serve a local fake of Clerk for offline setup, login and token refresh\n")
+	fmt.Fprintf(os.Stderr, "  coordinator    Coordinate a distributed run across several instances\n")
+	fmt.Fprintf(os.Stderr, "  control <action> Steer a run started with --control-addr (see Control Actions)\n")
 	fmt.Fprintf(os.Stderr, "  version        Show version\n\n")
 	fmt.Fprintf(os.Stderr, "Login Flags:\n")
 	fmt.Fprintf(os.Stderr, "  --accounts <path>    Path to accounts.json (default: ./accounts.json)\n")
 	fmt.Fprintf(os.Stderr, "  --key <pk_...>       Clerk publishable key (default: from env/config)\n")
-	fmt.Fprintf(os.Stderr, "  --secret <sk_...>    Clerk secret key for ticket flow (default: from env/config)\n")
-	fmt.Fprintf(os.Stderr, "  --env <path>         Path to .env file (auto-resolved if omitted)\n\n")
+	fmt.Fprintf(os.Stderr, "  --secret <sk_...>    Clerk secret key for ticket flow (default: env/config, or fake-clerk's with --clerk-url)\n")
+	fmt.Fprintf(os.Stderr, "  --env <path>         Path to .env file (auto-resolved if omitted)\n")
+	fmt.Fprintf(os.Stderr, "  --clerk-url <url>    Use this Clerk API instead of the key's, e.g. a fake-clerk server\n\n")
+	fmt.Fprintf(os.Stderr, "Setup Flags:\n")
+	fmt.Fprintf(os.Stderr, "  --target <url>       Target the accounts are for (default: https://www.thepit.cloud)\n")
+	fmt.Fprintf(os.Stderr, "  --output <path>      Accounts file to write (default: ./accounts.json)\n")
+	fmt.Fprintf(os.Stderr, "  --force              Overwrite an existing accounts file\n")
+	fmt.Fprintf(os.Stderr, "  --per-tier <n>       Add a pool of n accounts per signed-in tier (for run --account-pool)\n")
+	fmt.Fprintf(os.Stderr, "  --secret <sk_...>    Clerk secret key to provision users (default: env/config, or fake-clerk's with --clerk-url)\n")
+	fmt.Fprintf(os.Stderr, "  --clerk-url <url>    Provision against this Clerk API, e.g. a fake-clerk server\n")
+	fmt.Fprintf(os.Stderr, "  --env <path>         Path to .env file\n\n")
 	fmt.Fprintf(os.Stderr, "Run Flags:\n")
 	fmt.Fprintf(os.Stderr, "  --target <url>       Target URL (default: https://www.thepit.cloud)\n")
+	fmt.Fprintf(os.Stderr, "  --target-b <url>     A/B run: also send traffic to this target and compare the two side by side\n")
+	fmt.Fprintf(os.Stderr, "  --ab-mode <mode>     mirror (every action to both targets) or split (each session to one) (default: mirror)\n")
 	fmt.Fprintf(os.Stderr, "  --accounts <path>    Path to accounts.json (default: ./accounts.json)\n")
 	fmt.Fprintf(os.Stderr, "  --profile <name>     Traffic profile: trickle|steady|ramp|spike|viral (default: steady)\n")
+	fmt.Fprintf(os.Stderr, "                       or an inline schedule, e.g. linear:0s=2,5m=20,10m=5 or step:0s=1,30s=10@1m\n")
+	fmt.Fprintf(os.Stderr, "  --profile-file <file> YAML rate schedule (linear/step points, optional repeat for diurnal cycles)\n")
 	fmt.Fprintf(os.Stderr, "  --rate <n>           Target peak req/s (default: 5)\n")
 	fmt.Fprintf(os.Stderr, "  --duration <dur>     Simulation duration (default: 10m)\n")
 	fmt.Fprintf(os.Stderr, "  --budget <gbp>       Max spend in GBP (default: 10.0)\n")
+	fmt.Fprintf(os.Stderr, "  --persona-budget <id=gbp,...> Cap a persona's bout spend; once reached it only runs non-bout actions\n")
+	fmt.Fprintf(os.Stderr, "  --model-budget <id=gbp|pct%%,...> Cap spend on a model, in GBP or %% of --budget\n")
 	fmt.Fprintf(os.Stderr, "  --workers <n>        Concurrent worker goroutines (default: 16)\n")
+	fmt.Fprintf(os.Stderr, "  --account-pool <mode> Spread sessions over every account of a tier: rotate (per session) or pin (per worker)\n")
+	fmt.Fprintf(os.Stderr, "  --credits <n>        Credit depletion scenario: accounts start with n credits; checks the server's 402 refusals (needs pitstorm mock --credits)\n")
 	fmt.Fprintf(os.Stderr, "  --personas <list>    Persona mix: all|free-only|paid-only|stress or comma-separated (default: all)\n")
+	fmt.Fprintf(os.Stderr, "  --scenario <file>    YAML persona definitions merged with (or replacing) the built-ins\n")
 	fmt.Fprintf(os.Stderr, "  --instance <n/m>     Instance partitioning, e.g. 1/3 (default: 1/1)\n")
 	fmt.Fprintf(os.Stderr, "  --output <path>      JSON output file (default: stdout)\n")
 	fmt.Fprintf(os.Stderr, "  --status <path>      Live status JSON file (default: results/.live-status.json)\n")
 	fmt.Fprintf(os.Stderr, "  --no-status          Disable live status file\n")
+	fmt.Fprintf(os.Stderr, "  --stall-threshold <dur> Pause between text deltas of a bout turn counted as a stream stall (default: 5s)\n")
+	fmt.Fprintf(os.Stderr, "  --timeline <dur>     Interval of the output's metrics timeline; 0 disables (default: 10s)\n")
+	fmt.Fprintf(os.Stderr, "  --seed <n>           Seed persona choices and payloads for a reproducible run\n")
+	fmt.Fprintf(os.Stderr, "  --journal <path>     Record every request to a JSONL journal for replay\n")
+	fmt.Fprintf(os.Stderr, "  --transcripts <path> Capture every bout stream (turn text, timings, validation) to JSONL\n")
+	fmt.Fprintf(os.Stderr, "  --har <path>         Export failed requests and sampled successes as HAR 1.2 (tokens redacted)\n")
+	fmt.Fprintf(os.Stderr, "  --har-sample <f>     Fraction of successful requests in the HAR export (default: 0.01)\n")
+	fmt.Fprintf(os.Stderr, "  --metrics-addr <addr> Serve live OpenMetrics at /metrics, e.g. :9090\n")
+	fmt.Fprintf(os.Stderr, "  --tui                Live dashboard; keys: p pause, +/- rate, r profile rate, q quit\n")
+	fmt.Fprintf(os.Stderr, "  --control-addr <addr> Serve the runtime control API, e.g. %s\n", DefaultControlAddr)
+	fmt.Fprintf(os.Stderr, "  --coordinator <url>  Join a coordinated run (instance, budget, start/stop come from the coordinator)\n")
+	fmt.Fprintf(os.Stderr, "  --slo <file>         YAML latency/error thresholds; a breach exits with status 2\n")
+	fmt.Fprintf(os.Stderr, "  --adaptive           Search for the max sustainable rate (--slo is the target; default error rate <= 5%%)\n")
+	fmt.Fprintf(os.Stderr, "  --adaptive-start <n> First probed rate in req/s (default: 1)\n")
+	fmt.Fprintf(os.Stderr, "  --adaptive-max <n>   Highest probed rate in req/s (default: 200)\n")
+	fmt.Fprintf(os.Stderr, "  --adaptive-step <dur> How long each rate is held (default: 30s)\n")
+	fmt.Fprintf(os.Stderr, "  --verbose            Log every request\n")
+	fmt.Fprintf(os.Stderr, "  --env <path>         Path to .env file\n")
+	fmt.Fprintf(os.Stderr, "  --clerk-url <url>    Refresh tokens against this Clerk API (also for probe and replay)\n\n")
+	fmt.Fprintf(os.Stderr, "Mock Server Flags:\n")
+	fmt.Fprintf(os.Stderr, "  --addr <host:port>   Listen address (default: 127.0.0.1:8787)\n")
+	fmt.Fprintf(os.Stderr, "  --latency <dur>      Added latency per response (default: 0)\n")
+	fmt.Fprintf(os.Stderr, "  --jitter <dur>       Extra random latency in [0, dur) (default: 0)\n")
+	fmt.Fprintf(os.Stderr, "  --error-rate <p>     Probability of an injected 500, 0–1 (default: 0)\n")
+	fmt.Fprintf(os.Stderr, "  --rate-limit-rate <p> Probability of an injected 429, 0–1 (default: 0)\n")
+	fmt.Fprintf(os.Stderr, "  --delta-delay <dur>  Pause between SSE text deltas (default: 5ms)\n")
+	fmt.Fprintf(os.Stderr, "  --deltas <n>         Text deltas per bout turn (default: 12)\n")
+	fmt.Fprintf(os.Stderr, "  --stream-usage       Report token usage in the bout stream (data-usage events)\n")
+	fmt.Fprintf(os.Stderr, "  --require-auth       Return 401 on authenticated endpoints without a bearer token\n")
+	fmt.Fprintf(os.Stderr, "  --credits <n>        Give each bearer token n credits; refuse bouts with 402 once spent\n")
+	fmt.Fprintf(os.Stderr, "  --verbose            Log every request\n\n")
+	fmt.Fprintf(os.Stderr, "Fake Clerk Flags:\n")
+	fmt.Fprintf(os.Stderr, "  --addr <host:port>   Listen address (default: 127.0.0.1:8788)\n")
+	fmt.Fprintf(os.Stderr, "  --token-ttl <dur>    Session token lifetime (default: 60s)\n")
+	fmt.Fprintf(os.Stderr, "  --secret <sk_...>    Only accept this Backend API secret key (default: any)\n")
+	fmt.Fprintf(os.Stderr, "  --error-rate <p>     Probability of an injected 500, 0–1 (default: 0)\n")
+	fmt.Fprintf(os.Stderr, "  --rate-limit-rate <p> Probability of an injected 429, 0–1 (default: 0)\n")
+	fmt.Fprintf(os.Stderr, "  --verbose            Log every request\n\n")
+	fmt.Fprintf(os.Stderr, "Coordinator Flags:\n")
+	fmt.Fprintf(os.Stderr, "  --listen <host:port> Listen address (default: :7070)\n")
+	fmt.Fprintf(os.Stderr, "  --instances <n>      Instances to wait for before starting (default: 2)\n")
+	fmt.Fprintf(os.Stderr, "  --budget <gbp>       Global max spend in GBP shared by all instances (default: 10.0)\n")
+	fmt.Fprintf(os.Stderr, "  --start-delay <dur>  Delay between the last registration and start (default: 3s)\n")
+	fmt.Fprintf(os.Stderr, "  --report-timeout <dur> Wait for final reports after a stop (default: 30s)\n")
+	fmt.Fprintf(os.Stderr, "  --output <path>      Merged JSON output file\n")
+	fmt.Fprintf(os.Stderr, "  --verbose            Log registrations and reports\n\n")
+	fmt.Fprintf(os.Stderr, "Compare Flags:\n")
+	fmt.Fprintf(os.Stderr, "  --alpha <p>          Significance level for regression tests (default: 0.01)\n")
+	fmt.Fprintf(os.Stderr, "  --min-change <pct>   Ignore changes smaller than this percentage (default: 10)\n")
+	fmt.Fprintf(os.Stderr, "  --min-samples <n>    Minimum latency samples per side to test an endpoint (default: 20)\n")
+	fmt.Fprintf(os.Stderr, "  --fail-on-regression Exit with status 2 when a regression is flagged\n\n")
+	fmt.Fprintf(os.Stderr, "Probe Flags:\n")
+	fmt.Fprintf(os.Stderr, "  --target <url>       Target URL (default: https://www.thepit.cloud)\n")
+	fmt.Fprintf(os.Stderr, "  --accounts <path>    Probe as the first account with a token, against another (default: ./accounts.json)\n")
+	fmt.Fprintf(os.Stderr, "  --kinds <list>       Probe kinds: xss,sqli,idor,rate-limit (default: all)\n")
+	fmt.Fprintf(os.Stderr, "  --burst <n>          Requests per rate-limit probe (default: 30)\n")
+	fmt.Fprintf(os.Stderr, "  --output <path>      JSON findings report\n")
+	fmt.Fprintf(os.Stderr, "  --fail-on-finding    Exit with status 2 when any probe fails\n")
+	fmt.Fprintf(os.Stderr, "  --verbose            Log every probe verdict\n")
+	fmt.Fprintf(os.Stderr, "  --env <path>         Path to .env file\n\n")
+	fmt.Fprintf(os.Stderr, "Control Actions:\n")
+	fmt.Fprintf(os.Stderr, "  status               Show rate, pause state, personas and budget\n")
+	fmt.Fprintf(os.Stderr, "  pause | resume       Stop or restart the ticket feeder\n")
+	fmt.Fprintf(os.Stderr, "  rate <rps>           Fix the target rate; 0 hands it back to the profile\n")
+	fmt.Fprintf(os.Stderr, "  enable | disable <persona> Switch a persona's workers on or off\n")
+	fmt.Fprintf(os.Stderr, "  budget <gbp>         Raise the budget ceiling\n")
+	fmt.Fprintf(os.Stderr, "  snapshot             Take a metrics snapshot now (--output <path> to save it)\n")
+	fmt.Fprintf(os.Stderr, "  --addr <host:port>   Control API address (default: %s)\n\n", DefaultControlAddr)
+	fmt.Fprintf(os.Stderr, "Replay Flags:\n")
+	fmt.Fprintf(os.Stderr, "  --target <url>       Target URL (default: recorded target)\n")
+	fmt.Fprintf(os.Stderr, "  --accounts <path>    Path to accounts.json (default: ./accounts.json)\n")
+	fmt.Fprintf(os.Stderr, "  --speed <x>          Time compression: 1 = recorded pace, 10 = 10x faster, 0 = no delays (default: 1)\n")
+	fmt.Fprintf(os.Stderr, "  --keep-ids           Reuse recorded bout IDs instead of generating fresh ones\n")
+	fmt.Fprintf(os.Stderr, "  --budget <gbp>       Max spend in GBP (default: 10.0)\n")
+	fmt.Fprintf(os.Stderr, "  --output <path>      JSON output file\n")
 	fmt.Fprintf(os.Stderr, "  --verbose            Log every request\n")
 	fmt.Fprintf(os.Stderr, "  --env <path>         Path to .env file\n\n")
 }
 
+// exitCheckFailed is the exit status of a command that ran but failed a
+// check it was asked to make: a run that breached its --slo thresholds
+// or failed its --credits checks, `probe --fail-on-finding` with
+// findings, or `compare --fail-on-regression` with a regression. It is
+// distinct from 1 (configuration or runtime failure).
+const exitCheckFailed = 2
+
 func fatal(ctx string, err error) {
 	fmt.Fprintf(os.Stderr, "\n  %s %v\n\n", theme.Error.Render(ctx+":"), err)
 	os.Exit(1)